@@ -0,0 +1,359 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"mime"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jspdown/traefik-playground/internal/command"
+	"github.com/jspdown/traefik-playground/internal/experiment"
+	"github.com/jspdown/traefik-playground/internal/header"
+	"github.com/rs/zerolog/log"
+)
+
+// apiRunRequest is the JSON payload of the experiments run through the API.
+type apiRunRequest struct {
+	DynamicConfig string         `json:"dynamicConfig"`
+	StaticConfig  string         `json:"staticConfig"`
+	Vars          string         `json:"vars"`
+	Request       apiHTTPRequest `json:"request"`
+}
+
+// apiHTTPRequest is the request sent to Traefik by an experiment run through the API.
+type apiHTTPRequest struct {
+	Method     string              `json:"method"`
+	URL        string              `json:"url"`
+	Proto      string              `json:"proto"`
+	Scheme     string              `json:"scheme"`
+	Host       string              `json:"host"`
+	ClientIP   string              `json:"clientIP"`
+	TrustedIPs []string            `json:"trustedIPs"`
+	Headers    map[string][]string `json:"headers"`
+	Body       string              `json:"body"`
+	Username   string              `json:"username"`
+	Password   string              `json:"password"`
+	Burst      int                 `json:"burst"`
+	DelayMs    int                 `json:"delayMs"`
+
+	KeepCookies            bool `json:"keepCookies"`
+	Concurrent             bool `json:"concurrent"`
+	NoContentTypeDetection bool `json:"noContentTypeDetection"`
+}
+
+// raw converts the request into the raw fields submitted by the experiment form, so that both go through the
+// same validation. The headers are written one per line: names and values which would break out of their line,
+// such as with a CR or LF, are rejected rather than read as other headers.
+func (r apiHTTPRequest) raw() (experiment.RawHTTPRequest, error) {
+	var headers strings.Builder
+	for _, name := range slices.Sorted(maps.Keys(r.Headers)) {
+		if name == "" || !header.ValidHeaderField(name) {
+			return experiment.RawHTTPRequest{}, &experiment.ValidationError{
+				Field:   "headers",
+				Message: fmt.Sprintf("invalid header name %q", name),
+			}
+		}
+
+		for _, value := range r.Headers[name] {
+			if !header.ValidHeaderValue(value) {
+				return experiment.RawHTTPRequest{}, &experiment.ValidationError{
+					Field:   "headers",
+					Message: fmt.Sprintf("invalid header value for %q", name),
+				}
+			}
+
+			_, _ = fmt.Fprintf(&headers, "%s: %s\n", name, value)
+		}
+	}
+
+	return experiment.RawHTTPRequest{
+		Method:                 r.Method,
+		URL:                    r.URL,
+		Proto:                  r.Proto,
+		Scheme:                 r.Scheme,
+		Host:                   r.Host,
+		ClientIP:               r.ClientIP,
+		TrustedIPs:             strings.Join(r.TrustedIPs, ","),
+		Headers:                headers.String(),
+		Body:                   r.Body,
+		Username:               r.Username,
+		Password:               r.Password,
+		Burst:                  formatOptionalInt(r.Burst),
+		DelayMs:                formatOptionalInt(r.DelayMs),
+		KeepCookies:            formatOptionalBool(r.KeepCookies),
+		Concurrent:             formatOptionalBool(r.Concurrent),
+		NoContentTypeDetection: formatOptionalBool(r.NoContentTypeDetection),
+	}, nil
+}
+
+// formatOptionalInt formats the given integer as a form field, left empty when zero.
+func formatOptionalInt(value int) string {
+	if value == 0 {
+		return ""
+	}
+
+	return strconv.Itoa(value)
+}
+
+// formatOptionalBool formats the given boolean as a form field, left empty when false.
+func formatOptionalBool(value bool) string {
+	if !value {
+		return ""
+	}
+
+	return strconv.FormatBool(value)
+}
+
+// RunExperimentAPI runs the experiment described by the JSON body of the request and responds with its result as
+// JSON, for the clients scripting experiments. Errors are always reported as an errorResponse, with the same
+// statuses as RunExperiment. Scripted experiments run with a low priority, after those run from the UI.
+func (a *App) RunExperimentAPI(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var payload apiRunRequest
+	if !decodeJSONBody(rw, req, &payload) {
+		return
+	}
+
+	rawRequest, err := payload.Request.raw()
+	if err != nil {
+		respondJSONError(rw, req, http.StatusBadRequest, fmt.Errorf("request: %w", err))
+
+		return
+	}
+
+	exp, err := experiment.MakeExperiment(payload.DynamicConfig, payload.Vars, rawRequest, a.controller.Limits())
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Invalid experiment")
+		respondJSONError(rw, req, validationErrorStatus(err), err)
+
+		return
+	}
+
+	exp.StaticConfig, err = experiment.MakeStaticConfig(payload.StaticConfig)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Invalid static configuration")
+		respondJSONError(rw, req, validationErrorStatus(err), err)
+
+		return
+	}
+
+	clientIP, _, _ := net.SplitHostPort(req.RemoteAddr)
+
+	res, err := a.controller.Run(command.WithPriority(ctx, command.PriorityLow), exp, clientIP)
+	if err != nil {
+		log.Error().Err(err).Interface("experiment", exp).Msg("Unable to spawn experiment")
+
+		status, err := runErrorStatus(err)
+		respondJSONError(rw, req, status, err)
+
+		return
+	}
+
+	respondJSON(rw, req, http.StatusOK, res)
+}
+
+// apiShareRequest is the JSON payload of the experiments shared through the API. It holds either a signed run
+// bundle, in the format of the files exported by ExportExperimentJSON, or an experiment to run.
+type apiShareRequest struct {
+	runBundleFile
+
+	Experiment *experiment.Experiment `json:"experiment"`
+	Label      string                 `json:"label"`
+}
+
+// apiShareResponse is the response to an experiment shared through the API.
+type apiShareResponse struct {
+	ID string `json:"id"`
+	// URL is the path of the page of the shared experiment, also giving access to it through the API by
+	// prefixing it with "/api".
+	URL string `json:"url"`
+}
+
+// ShareExperimentAPI shares the experiment described by the JSON body of the request and responds with its ID as
+// JSON, for the tools storing and linking experiments. Unlike the result of a signed run bundle, the result of an
+// experiment given on its own can't be trusted: the experiment is run, with a low priority as scripted experiments,
+// and shared with the result of this run.
+func (a *App) ShareExperimentAPI(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var payload apiShareRequest
+	if !decodeJSONBody(rw, req, &payload) {
+		return
+	}
+
+	exp, res, err := a.decodeSharedExperiment(payload)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Invalid shared experiment")
+		respondJSONError(rw, req, validationErrorStatus(err), err)
+
+		return
+	}
+
+	exp.Label, err = experiment.MakeLabel(payload.Label)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Invalid label")
+		respondJSONError(rw, req, http.StatusBadRequest, err)
+
+		return
+	}
+
+	clientIP, _, _ := net.SplitHostPort(req.RemoteAddr)
+
+	if payload.Bundle == nil {
+		res, err = a.controller.Run(command.WithPriority(ctx, command.PriorityLow), exp, clientIP)
+		if err != nil {
+			log.Error().Err(err).Interface("experiment", exp).Msg("Unable to spawn experiment")
+
+			status, err := runErrorStatus(err)
+			respondJSONError(rw, req, status, err)
+
+			return
+		}
+	}
+
+	id, err := a.controller.Share(ctx, exp, res, clientIP, req.UserAgent())
+	if err != nil {
+		log.Error().Err(err).Interface("experiment", exp).Msg("Unable to share experiment")
+		respondJSONError(rw, req, http.StatusInternalServerError, errors.New("unable to share experiment, please retry later"))
+
+		return
+	}
+
+	shareURL, err := a.shareURL(id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Unable to sign share URL")
+		respondJSONError(rw, req, http.StatusInternalServerError, errServiceIssues)
+
+		return
+	}
+
+	respondJSON(rw, req, http.StatusCreated, apiShareResponse{ID: id, URL: shareURL})
+}
+
+// decodeSharedExperiment returns the experiment held by the given payload, along with its result when given a
+// signed run bundle. The result of an experiment given on its own is left empty, the experiment must be run.
+func (a *App) decodeSharedExperiment(payload apiShareRequest) (experiment.Experiment, experiment.Result, error) {
+	switch {
+	case payload.Bundle != nil && payload.Experiment != nil:
+		return experiment.Experiment{}, experiment.Result{}, errors.New("either a run bundle or an experiment can be shared, not both")
+	case payload.Bundle != nil:
+		return a.verifyRunBundleFile(payload.runBundleFile)
+	case payload.Experiment == nil:
+		return experiment.Experiment{}, experiment.Result{}, errors.New("a run bundle or an experiment must be provided")
+	}
+
+	// Validate the experiment the same way as the experiment form, which it's converted back to.
+	rawReq := experiment.RawHTTPRequest(makeRequestForm(payload.Experiment.Request))
+
+	exp, err := experiment.MakeExperiment(payload.Experiment.DynamicConfig, "", rawReq, a.controller.Limits())
+	if err != nil {
+		return experiment.Experiment{}, experiment.Result{}, err
+	}
+
+	exp.StaticConfig, err = experiment.MakeStaticConfig(payload.Experiment.StaticConfig)
+	if err != nil {
+		return experiment.Experiment{}, experiment.Result{}, err
+	}
+
+	return exp, experiment.Result{}, nil
+}
+
+// apiSharedExperiment is the response to a shared experiment retrieved through the API.
+type apiSharedExperiment struct {
+	Experiment experiment.Experiment `json:"experiment"`
+	Result     experiment.Result     `json:"result"`
+	Label      string                `json:"label,omitempty"`
+}
+
+// SharedExperimentAPI responds with the shared experiment identified in the URL and its result as JSON. Like
+// SharedExperiment, it requires the signature of the share URL when share URLs are signed.
+func (a *App) SharedExperimentAPI(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+	id := req.PathValue("id")
+
+	valid, err := a.allowedShare(id, req.PathValue("signature"))
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Unable to verify share URL signature")
+		respondJSONError(rw, req, http.StatusInternalServerError, errServiceIssues)
+
+		return
+	}
+
+	if !valid {
+		log.Ctx(ctx).Debug().Str("id", id).Msg("Invalid share URL signature")
+		respondJSONError(rw, req, http.StatusNotFound, errors.New("unable to find experiment"))
+
+		return
+	}
+
+	exp, res, err := a.controller.Shared(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Unable to retrieve experiment")
+
+		if errors.Is(err, experiment.ErrNotFound) {
+			respondJSONError(rw, req, http.StatusNotFound, errors.New("unable to find experiment"))
+		} else {
+			respondJSONError(rw, req, http.StatusInternalServerError, errors.New("unable to retrieve experiment, please retry later"))
+		}
+
+		return
+	}
+
+	respondJSON(rw, req, http.StatusOK, apiSharedExperiment{
+		Experiment: exp,
+		Result:     res,
+		Label:      exp.Label,
+	})
+}
+
+// decodeJSONBody decodes the JSON body of the given request into v, rejecting unknown fields.
+// It responds with an error and returns false if the body isn't a valid JSON document.
+//
+// The API isn't protected against CSRF with a token. Instead, only the "application/json" content type is accepted,
+// which browsers can't send cross-site without a CORS preflight request.
+func decodeJSONBody(rw http.ResponseWriter, req *http.Request, v any) bool {
+	mediaType, _, err := mime.ParseMediaType(req.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		respondJSONError(rw, req, http.StatusUnsupportedMediaType, errors.New(`the content type must be "application/json"`))
+
+		return false
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	decoder.DisallowUnknownFields()
+
+	if err = decoder.Decode(v); err != nil {
+		log.Ctx(req.Context()).Error().Err(err).Msg("Failed to read request body")
+
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			err = fmt.Errorf("the request body is too large (max: %d bytes)", maxBytesErr.Limit)
+			respondJSONError(rw, req, http.StatusRequestEntityTooLarge, err)
+
+			return false
+		}
+
+		respondJSONError(rw, req, http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+
+		return false
+	}
+
+	return true
+}
+
+// respondJSON responds with the given status and value encoded as JSON.
+func respondJSON(rw http.ResponseWriter, req *http.Request, status int, v any) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+
+	if err := json.NewEncoder(rw).Encode(v); err != nil {
+		log.Ctx(req.Context()).Error().Err(err).Msg("Unable to write response")
+	}
+}
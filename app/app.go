@@ -2,6 +2,7 @@
 package app
 
 import (
+	"bytes"
 	"context"
 	"crypto/hmac"
 	"crypto/sha256"
@@ -13,14 +14,24 @@ import (
 	"html/template"
 	"io"
 	"io/fs"
+	"maps"
+	"mime"
 	"net"
 	"net/http"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/gorilla/schema"
 	"github.com/jspdown/traefik-playground/internal/compose"
+	"github.com/jspdown/traefik-playground/internal/curl"
 	"github.com/jspdown/traefik-playground/internal/experiment"
+	"github.com/jspdown/traefik-playground/internal/kubernetes"
+	"github.com/jspdown/traefik-playground/internal/traefik"
+	"github.com/jspdown/traefik-playground/internal/version"
 	"github.com/rs/zerolog/log"
+	"golang.org/x/sync/singleflight"
+	"golang.org/x/text/language"
 )
 
 //go:embed templates/*
@@ -29,24 +40,92 @@ var templatesFS embed.FS
 //go:embed dist/*
 var assetsFS embed.FS
 
-var schemaDecoder = schema.NewDecoder() //nolint:gochecknoglobals // Needed for caching.
+var (
+	schemaDecoder = schema.NewDecoder() //nolint:gochecknoglobals // Needed for caching.
+	// experimentFormDecoder decodes the experiment form, which holds the inputs of all the actions it's submitted
+	// to, such as the curl command or the search term.
+	experimentFormDecoder = newExperimentFormDecoder() //nolint:gochecknoglobals // Needed for caching.
+)
+
+// errServiceIssues is reported to the user when an unexpected error occurs.
+var errServiceIssues = errors.New("the service is experiencing issues, please retry later")
+
+const (
+	// minSecretKeyLength is the minimum length of the key signing run bundles, in bytes.
+	minSecretKeyLength = 32
+
+	// maxConfigParamLength is the maximum size of the dynamic configuration provided in the "config" query parameter.
+	maxConfigParamLength = 10 * 1024
+
+	// maxFormSize is the maximum size of a submitted form, including imported experiment files.
+	maxFormSize = 10 << 20
+
+	// maxExperimentFormSize is the maximum size of a submitted form describing an experiment. URL encoding can
+	// triple the size of the fields, and a few kilobytes are left for the field names and the short fields.
+	maxExperimentFormSize = 3*(experiment.MaxFieldsLength+curl.MaxCommandLength) + 4096
+)
 
 // App is the web application.
 type App struct {
 	controller *experiment.Controller
 
-	secretKey string
+	// secretKey signs run bundles. Run bundles signed with the secretKey or any of the oldSecretKeys are accepted.
+	secretKey     string
+	oldSecretKeys []string
+
+	// signShareURLs makes share URLs hold a signature of the experiment ID, without which shared experiments
+	// can't be accessed.
+	signShareURLs bool
+
+	// sharedCache caches the shared experiments, nil disables caching.
+	sharedCache *SharedCache
+	// sharedLoads deduplicates the concurrent retrievals of the same shared experiment, by ID.
+	sharedLoads singleflight.Group
 
 	assets fs.FS
 
+	// defaultDynamicConfig prefills the editor when no experiment is loaded.
 	defaultDynamicConfig string
 
+	// middlewares holds the JSON encoded list of supported middlewares.
+	middlewares []byte
+	// headerPresets holds the JSON encoded list of header presets.
+	headerPresets []byte
+	// version holds the JSON encoded versions the playground is built with.
+	version []byte
+
 	experimentTemplate *template.Template
 	infoTemplate       *template.Template
 }
 
-// New creates a new App.
-func New(controller *experiment.Controller, secretKey string) (*App, error) {
+// Options configures an App.
+type Options struct {
+	// SecretKey signs the run bundles.
+	SecretKey string
+	// OldSecretKeys are only used to verify the run bundles issued before a key rotation.
+	OldSecretKeys []string
+	// SignShareURLs makes share URLs hold a signature of the experiment ID, made with the same keys.
+	SignShareURLs bool
+	// SharedCache caches the shared experiments, nil disables caching.
+	SharedCache *SharedCache
+	// DefaultDynamicConfig prefills the editor when no experiment is loaded. It must be valid, the embedded one is
+	// used when empty.
+	DefaultDynamicConfig string
+}
+
+// New creates a new App with the given Options.
+func New(controller *experiment.Controller, options Options) (*App, error) {
+	// A short key would make run bundle signatures easy to forge.
+	if len(options.SecretKey) < minSecretKeyLength {
+		return nil, fmt.Errorf("secret key must be at least %d bytes long", minSecretKeyLength)
+	}
+
+	for i, key := range options.OldSecretKeys {
+		if len(key) < minSecretKeyLength {
+			return nil, fmt.Errorf("old secret key %d must be at least %d bytes long", i, minSecretKeyLength)
+		}
+	}
+
 	assets, err := fs.Sub(assetsFS, "dist")
 	if err != nil {
 		return nil, fmt.Errorf("accessing assets subtree: %w", err)
@@ -57,6 +136,7 @@ func New(controller *experiment.Controller, secretKey string) (*App, error) {
 		Funcs(template.FuncMap{
 			"statusText": http.StatusText,
 			"join":       strings.Join,
+			"indentJSON": indentJSON,
 		})
 
 	experimentTemplate := template.Must(template.Must(baseTemplate.Clone()).
@@ -64,145 +144,370 @@ func New(controller *experiment.Controller, secretKey string) (*App, error) {
 	infoTemplate := template.Must(template.Must(baseTemplate.Clone()).
 		ParseFS(templatesFS, "templates/info.gohtml"))
 
-	defaultDynamicConfigFile, err := assets.Open("default-dynamic-configuration.yaml")
+	defaultDynamicConfig := options.DefaultDynamicConfig
+	if strings.TrimSpace(defaultDynamicConfig) == "" {
+		defaultDynamicConfig, err = readEmbeddedDefaultDynamicConfig(assets)
+		if err != nil {
+			return nil, err
+		}
+	} else if err = experiment.ValidateDynamicConfig(defaultDynamicConfig, controller.Limits()); err != nil {
+		return nil, fmt.Errorf("validating default dynamic configuration: %w", err)
+	}
+
+	middlewares, err := json.Marshal(traefik.Middlewares())
 	if err != nil {
-		return nil, fmt.Errorf("opening default dynamic configuration file: %w", err)
+		return nil, fmt.Errorf("marshaling middlewares: %w", err)
 	}
 
-	defaultDynamicConfig, err := io.ReadAll(defaultDynamicConfigFile)
+	headerPresets, err := json.Marshal(experiment.HeaderPresets())
+	if err != nil {
+		return nil, fmt.Errorf("marshaling header presets: %w", err)
+	}
+
+	versionInfo, err := json.Marshal(version.Get())
 	if err != nil {
-		return nil, fmt.Errorf("reading default dynamic configuration file: %w", err)
+		return nil, fmt.Errorf("marshaling version: %w", err)
 	}
 
 	return &App{
 		controller:           controller,
-		secretKey:            secretKey,
+		secretKey:            options.SecretKey,
+		oldSecretKeys:        options.OldSecretKeys,
+		signShareURLs:        options.SignShareURLs,
+		sharedCache:          options.SharedCache,
 		assets:               assets,
-		defaultDynamicConfig: string(defaultDynamicConfig),
+		defaultDynamicConfig: defaultDynamicConfig,
+		middlewares:          middlewares,
+		headerPresets:        headerPresets,
+		version:              versionInfo,
 		experimentTemplate:   experimentTemplate,
 		infoTemplate:         infoTemplate,
 	}, nil
 }
 
+// readEmbeddedDefaultDynamicConfig reads the default dynamic configuration embedded in the given assets.
+func readEmbeddedDefaultDynamicConfig(assets fs.FS) (string, error) {
+	defaultDynamicConfigFile, err := assets.Open("default-dynamic-configuration.yaml")
+	if err != nil {
+		return "", fmt.Errorf("opening default dynamic configuration file: %w", err)
+	}
+	defer func() { _ = defaultDynamicConfigFile.Close() }()
+
+	defaultDynamicConfig, err := io.ReadAll(defaultDynamicConfigFile)
+	if err != nil {
+		return "", fmt.Errorf("reading default dynamic configuration file: %w", err)
+	}
+
+	return string(defaultDynamicConfig), nil
+}
+
 // MountOn mounts the UI handler on the given muxer.
 func (a *App) MountOn(mux *http.ServeMux) {
-	mux.Handle("GET /", http.HandlerFunc(a.Experiment))
+	mux.Handle("GET /", a.protectCSRF(http.HandlerFunc(a.Experiment)))
 	mux.Handle("GET /info", http.HandlerFunc(a.Info))
-	mux.Handle("POST /run", http.HandlerFunc(a.RunExperiment))
-	mux.Handle("POST /share", http.HandlerFunc(a.ShareExperiment))
-	mux.Handle("POST /export", http.HandlerFunc(a.ExportExperiment))
-	mux.Handle("GET /share/{id}", http.HandlerFunc(a.SharedExperiment))
+	mux.Handle("GET /middlewares", http.HandlerFunc(a.Middlewares))
+	mux.Handle("GET /header-presets", http.HandlerFunc(a.HeaderPresets))
+	mux.Handle("GET /traefik-v3.schema.json", http.HandlerFunc(a.DynamicConfigSchema))
+	mux.Handle("GET /version", http.HandlerFunc(a.Version))
+	mux.Handle("POST /run", limitBody(maxExperimentFormSize, a.protectCSRF(http.HandlerFunc(a.RunExperiment))))
+	mux.Handle("POST /run/stream", limitBody(maxExperimentFormSize, a.protectCSRF(http.HandlerFunc(a.StreamExperiment))))
+	mux.Handle("POST /share", a.protectCSRF(http.HandlerFunc(a.ShareExperiment)))
+	mux.Handle("POST /export", a.protectCSRF(http.HandlerFunc(a.ExportExperiment)))
+	mux.Handle("POST /export/json", a.protectCSRF(http.HandlerFunc(a.ExportExperimentJSON)))
+	mux.Handle("POST /export/kubernetes", a.protectCSRF(http.HandlerFunc(a.ExportExperimentKubernetes)))
+	mux.Handle("POST /import/json", a.protectCSRF(http.HandlerFunc(a.ImportExperiment)))
+	mux.Handle("POST /import/curl", limitBody(maxExperimentFormSize, a.protectCSRF(http.HandlerFunc(a.ImportCurl))))
+	mux.Handle("POST /import/raw", limitBody(maxExperimentFormSize, a.protectCSRF(http.HandlerFunc(a.ImportRawRequest))))
+	mux.Handle("POST /normalize", limitBody(maxExperimentFormSize, a.protectCSRF(http.HandlerFunc(a.NormalizeConfig))))
+	mux.Handle("POST /tokenize", limitBody(maxExperimentFormSize, a.protectCSRF(http.HandlerFunc(a.TokenizeConfig))))
+	mux.Handle("POST /replay", a.protectCSRF(http.HandlerFunc(a.ReplayExperiment)))
+	mux.Handle("POST /search", a.protectCSRF(http.HandlerFunc(a.SearchResponseBody)))
+	mux.Handle("GET /share/{id}", a.protectCSRF(http.HandlerFunc(a.SharedExperiment)))
+	mux.Handle("GET /share/{id}/{signature}", a.protectCSRF(http.HandlerFunc(a.SharedExperiment)))
+	mux.Handle("POST /api/run", limitBody(maxExperimentFormSize, http.HandlerFunc(a.RunExperimentAPI)))
+	mux.Handle("POST /api/share", limitBody(maxFormSize, http.HandlerFunc(a.ShareExperimentAPI)))
+	mux.Handle("GET /api/share/{id}", http.HandlerFunc(a.SharedExperimentAPI))
+	mux.Handle("GET /api/share/{id}/{signature}", http.HandlerFunc(a.SharedExperimentAPI))
 
 	mux.Handle("GET /assets/", http.StripPrefix("/assets/", http.FileServer(http.FS(a.assets))))
 }
 
-// Experiment serves the experiment page.
+// Experiment serves the experiment page. The editor is prefilled with the base64 encoded dynamic configuration
+// of the "config" query parameter if any, or with the default dynamic configuration.
 func (a *App) Experiment(rw http.ResponseWriter, req *http.Request) {
-	a.render(req.Context(), rw, a.experimentTemplate, experimentTemplateData{
-		DynamicConfig: a.defaultDynamicConfig,
+	ctx := req.Context()
+
+	encodedConfig := req.URL.Query().Get("config")
+	if encodedConfig == "" {
+		a.render(rw, req, a.experimentTemplate, experimentTemplateData{
+			DynamicConfig: a.defaultDynamicConfig,
+		})
+
+		return
+	}
+
+	dynamicConfig, err := decodeDynamicConfigParam(encodedConfig, a.controller.Limits())
+	if err != nil {
+		log.Ctx(ctx).Debug().Err(err).Msg("Invalid config parameter")
+		a.respondError(rw, req, http.StatusBadRequest, fmt.Errorf("config parameter: %w", err), experimentTemplateData{
+			DynamicConfig: a.defaultDynamicConfig,
+		})
+
+		return
+	}
+
+	a.render(rw, req, a.experimentTemplate, experimentTemplateData{
+		DynamicConfig: dynamicConfig,
 	})
 }
 
+// decodeDynamicConfigParam decodes and validates a dynamic configuration encoded in standard or URL-safe base64.
+func decodeDynamicConfigParam(encoded string, limits experiment.Limits) (string, error) {
+	// Reject oversized parameters before decoding them.
+	if base64.StdEncoding.DecodedLen(len(encoded)) > maxConfigParamLength {
+		return "", fmt.Errorf("too long (max: %d bytes)", maxConfigParamLength)
+	}
+
+	// "+" is decoded as a space when the parameter isn't URL-encoded.
+	encoded = strings.TrimRight(strings.ReplaceAll(encoded, " ", "+"), "=")
+
+	decoded, err := base64.RawStdEncoding.DecodeString(encoded)
+	if err != nil {
+		if decoded, err = base64.RawURLEncoding.DecodeString(encoded); err != nil {
+			return "", errors.New("invalid base64 encoding")
+		}
+	}
+
+	if err = experiment.ValidateDynamicConfig(string(decoded), limits); err != nil {
+		return "", err
+	}
+
+	return string(decoded), nil
+}
+
+type infoTemplateData struct {
+	// Version holds the versions the playground is built with.
+	Version version.Info
+}
+
 // Info serves the info page.
 func (a *App) Info(rw http.ResponseWriter, req *http.Request) {
-	a.render(req.Context(), rw, a.infoTemplate, nil)
+	a.render(rw, req, a.infoTemplate, infoTemplateData{
+		Version: version.Get(),
+	})
 }
 
 type experimentTemplateData struct {
 	DynamicConfig string
-	Request       experimentTemplateRequestData
+	Request       requestForm
 	Result        *experiment.Result
 
+	// StaticConfig is the static configuration, in YAML, the experiment runs Traefik with on top of the defaults.
+	StaticConfig string
+
+	// Vars are the variables substituted to the placeholders of the dynamic configuration, one "name=value" per line.
+	// Once the experiment runs, the dynamic configuration is shown with its placeholders substituted, and Vars is
+	// empty.
+	Vars string
+
 	RunBundle          string
 	RunBundleSignature string
 
 	ShareURL string
+	// Label is the label of the shared experiment.
+	Label string
+
+	// CSRFToken is the token to submit along with the forms.
+	CSRFToken string
+
+	// Curl is the curl command the request was imported from.
+	Curl string
+	// RawRequest is the raw HTTP request the request was imported from.
+	RawRequest string
+	// CurlCommand is the curl command reproducing the request of the Result.
+	CurlCommand string
 
 	Error error
+	// FieldErrors holds the error of the invalid form fields, keyed by experiment.ValidationError field.
+	FieldErrors map[string]string
+
+	// LogAnnotations holds the lines of the dynamic configuration defining the routers and services named by the
+	// logs of the Result, keyed by log index then log field.
+	LogAnnotations map[int]map[string]traefik.LineRange
 }
 
-type experimentTemplateRequestData struct {
-	Method  string
-	URL     string
-	Headers string
-	Body    string
+// requestForm holds the fields of the request of the experiment form, as submitted and as rendered.
+type requestForm struct {
+	Method     string `schema:"method"`
+	URL        string `schema:"url"`
+	Proto      string `schema:"proto"`
+	Scheme     string `schema:"scheme"`
+	Host       string `schema:"host"`
+	ClientIP   string `schema:"clientIP"`
+	TrustedIPs string `schema:"trustedIPs"`
+	Headers    string `schema:"headers"`
+	Body       string `schema:"body"`
+	Username   string `schema:"username"`
+	Password   string `schema:"password"`
+	Burst      string `schema:"burst"`
+	DelayMs    string `schema:"delayMs"`
+
+	KeepCookies            string `schema:"keepCookies"`
+	Concurrent             string `schema:"concurrent"`
+	NoContentTypeDetection string `schema:"noContentTypeDetection"`
 }
 
-func makeExperimentTemplateRequestData(req experiment.HTTPRequest) experimentTemplateRequestData {
+func makeRequestForm(req experiment.HTTPRequest) requestForm {
 	headers := make([]string, 0, len(req.Headers))
-	for k := range req.Headers {
+	for _, k := range slices.Sorted(maps.Keys(req.Headers)) {
 		headers = append(headers, k+": "+req.Headers.Get(k))
 	}
 
-	return experimentTemplateRequestData{
-		Method:  req.Method,
-		URL:     req.URL,
-		Headers: strings.Join(headers, "\n"),
-		Body:    req.Body,
+	var burst string
+	if req.Burst > 1 {
+		burst = strconv.Itoa(req.Burst)
+	}
+
+	var delayMs string
+	if req.DelayMs > 0 {
+		delayMs = strconv.Itoa(req.DelayMs)
+	}
+
+	var keepCookies string
+	if req.KeepCookies {
+		keepCookies = "true"
+	}
+
+	var concurrent string
+	if req.Concurrent {
+		concurrent = "true"
+	}
+
+	var noContentTypeDetection string
+	if req.NoContentTypeDetection {
+		noContentTypeDetection = "true"
+	}
+
+	return requestForm{
+		Method:     req.Method,
+		URL:        req.URL,
+		Proto:      req.Proto,
+		Scheme:     req.Scheme,
+		Host:       req.Host,
+		ClientIP:   req.ClientIP,
+		TrustedIPs: strings.Join(req.TrustedIPs, ", "),
+		Headers:    strings.Join(headers, "\n"),
+		Body:       req.Body,
+		Username:   req.Username,
+		Password:   req.Password,
+		Burst:      burst,
+		DelayMs:    delayMs,
+
+		KeepCookies:            keepCookies,
+		Concurrent:             concurrent,
+		NoContentTypeDetection: noContentTypeDetection,
 	}
 }
 
-// RunExperiment runs an experiment.
-func (a *App) RunExperiment(rw http.ResponseWriter, req *http.Request) {
+// decodeExperiment decodes the experiment submitted in the form of the given request.
+// It responds with an error and returns false if the experiment is invalid.
+func (a *App) decodeExperiment(rw http.ResponseWriter, req *http.Request) (experiment.Experiment, bool) {
 	ctx := req.Context()
 
 	var payload struct {
-		DynamicConfig string `schema:"dynamicConfig"`
-		Request       struct {
-			Method  string `schema:"method"`
-			URL     string `schema:"url"`
-			Headers string `schema:"headers"`
-			Body    string `schema:"body"`
-		} `schema:"request"`
+		DynamicConfig string      `schema:"dynamicConfig"`
+		StaticConfig  string      `schema:"staticConfig"`
+		Vars          string      `schema:"vars"`
+		Request       requestForm `schema:"request"`
 	}
 
-	if err := decodeForm(req, &payload); err != nil {
+	if err := decodeExperimentForm(req, &payload); err != nil {
 		log.Ctx(ctx).Error().Err(err).Msg("Failed read experiment")
-		rw.WriteHeader(http.StatusBadRequest)
-
-		a.render(req.Context(), rw, a.experimentTemplate, experimentTemplateData{
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
 			DynamicConfig: a.defaultDynamicConfig,
-			Error:         err,
 		})
 
-		return
+		return experiment.Experiment{}, false
 	}
 
-	exp, err := experiment.MakeExperiment(
-		payload.DynamicConfig,
-		payload.Request.Method,
-		payload.Request.URL,
-		payload.Request.Headers,
-		payload.Request.Body)
+	exp, err := experiment.MakeExperiment(payload.DynamicConfig, payload.Vars, experiment.RawHTTPRequest(payload.Request), a.controller.Limits())
 	if err != nil {
 		log.Ctx(ctx).Error().Err(err).Msg("Invalid experiment")
-		rw.WriteHeader(http.StatusBadRequest)
+		a.respondError(rw, req, validationErrorStatus(err), err, experimentTemplateData{
+			DynamicConfig: payload.DynamicConfig,
+			StaticConfig:  payload.StaticConfig,
+			Vars:          payload.Vars,
+			Request:       payload.Request,
+		})
+
+		return experiment.Experiment{}, false
+	}
 
-		a.render(req.Context(), rw, a.experimentTemplate, experimentTemplateData{
+	exp.StaticConfig, err = experiment.MakeStaticConfig(payload.StaticConfig)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Invalid static configuration")
+		a.respondError(rw, req, validationErrorStatus(err), err, experimentTemplateData{
 			DynamicConfig: payload.DynamicConfig,
-			Request:       experimentTemplateRequestData(payload.Request),
-			Error:         err,
+			StaticConfig:  payload.StaticConfig,
+			Vars:          payload.Vars,
+			Request:       payload.Request,
 		})
 
+		return experiment.Experiment{}, false
+	}
+
+	return exp, true
+}
+
+// validationErrorStatus returns the status to respond with when an experiment is invalid. Fields exceeding their
+// maximum size are distinguished from the other validation errors.
+func validationErrorStatus(err error) int {
+	if errors.Is(err, experiment.ErrTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	return http.StatusBadRequest
+}
+
+// runErrorStatus returns the status and the error to respond with when an experiment fails to run.
+func runErrorStatus(err error) (int, error) {
+	var validationErr *experiment.ValidationError
+
+	switch {
+	case errors.As(err, &validationErr):
+		return validationErrorStatus(err), err
+	case errors.Is(err, experiment.ErrTooManyRuns):
+		return http.StatusTooManyRequests, errors.New("too many experiments are running, please wait for them to complete")
+	case errors.Is(err, experiment.ErrBusy):
+		return http.StatusServiceUnavailable, errors.New("the service is currently busy, please retry later")
+	case errors.Is(err, experiment.ErrRunTimeout):
+		return http.StatusGatewayTimeout, errors.New("the experiment took too long to complete, check the dynamic configuration " +
+			"for middlewares or services delaying the response, such as retries or a slow forwardAuth")
+	default:
+		return http.StatusInternalServerError, errServiceIssues
+	}
+}
+
+// RunExperiment runs an experiment.
+func (a *App) RunExperiment(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	exp, ok := a.decodeExperiment(rw, req)
+	if !ok {
 		return
 	}
 
-	res, err := a.controller.Run(ctx, exp)
+	clientIP, _, _ := net.SplitHostPort(req.RemoteAddr)
+
+	res, err := a.controller.Run(ctx, exp, clientIP)
 	if err != nil {
 		log.Error().Err(err).Interface("experiment", exp).Msg("Unable to spawn experiment")
 
-		if errors.Is(err, experiment.ErrRunTimeout) {
-			rw.WriteHeader(http.StatusServiceUnavailable)
-			err = errors.New("the service is currently busy, please retry later")
-		} else {
-			rw.WriteHeader(http.StatusInternalServerError)
-			err = errors.New("the service is experiencing issues, please retry later")
-		}
-
-		a.render(req.Context(), rw, a.experimentTemplate, experimentTemplateData{
+		status, err := runErrorStatus(err)
+		a.respondError(rw, req, status, err, experimentTemplateData{
 			DynamicConfig: exp.DynamicConfig,
-			Request:       makeExperimentTemplateRequestData(exp.Request),
-			Error:         err,
+			StaticConfig:  exp.StaticConfig,
+			Request:       makeRequestForm(exp.Request),
 		})
 
 		return
@@ -211,26 +516,151 @@ func (a *App) RunExperiment(rw http.ResponseWriter, req *http.Request) {
 	bundle, bundleSignature, err := marshalRunBundle(exp, res, a.secretKey)
 	if err != nil {
 		log.Error().Err(err).Interface("experiment", exp).Msg("Unable to marshal run bundle")
-		rw.WriteHeader(http.StatusInternalServerError)
-
-		a.render(req.Context(), rw, a.experimentTemplate, experimentTemplateData{
+		a.respondError(rw, req, http.StatusInternalServerError, errServiceIssues, experimentTemplateData{
 			DynamicConfig: exp.DynamicConfig,
-			Request:       makeExperimentTemplateRequestData(exp.Request),
-			Error:         errors.New("the service is experiencing issues, please retry later"),
+			StaticConfig:  exp.StaticConfig,
+			Request:       makeRequestForm(exp.Request),
 		})
 
 		return
 	}
 
-	a.render(req.Context(), rw, a.experimentTemplate, experimentTemplateData{
+	a.render(rw, req, a.experimentTemplate, experimentTemplateData{
 		DynamicConfig:      exp.DynamicConfig,
-		Request:            makeExperimentTemplateRequestData(exp.Request),
+		StaticConfig:       exp.StaticConfig,
+		Request:            makeRequestForm(exp.Request),
 		Result:             &res,
+		CurlCommand:        curl.Format(exp.Request),
 		RunBundle:          bundle,
 		RunBundleSignature: bundleSignature,
 	})
 }
 
+// streamChunkSize is the maximum number of bytes of the response body sent in a single "chunk" event.
+const streamChunkSize = 32 * 1024
+
+// StreamExperiment runs an experiment and streams its response as Server-Sent Events, so that long responses
+// appear incrementally. A "response" event holds the response headers, followed by a "chunk" event for each
+// part of the body as it's received, and an "end" event once the body is complete. An "error" event is sent
+// instead of the "end" event if the body couldn't be fully streamed.
+func (a *App) StreamExperiment(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	exp, ok := a.decodeExperiment(rw, req)
+	if !ok {
+		return
+	}
+
+	clientIP, _, _ := net.SplitHostPort(req.RemoteAddr)
+
+	res, err := a.controller.Stream(ctx, exp, clientIP)
+	if err != nil {
+		log.Error().Err(err).Interface("experiment", exp).Msg("Unable to stream experiment")
+
+		status, err := runErrorStatus(err)
+		a.respondError(rw, req, status, err, experimentTemplateData{
+			DynamicConfig: exp.DynamicConfig,
+			StaticConfig:  exp.StaticConfig,
+			Request:       makeRequestForm(exp.Request),
+		})
+
+		return
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+
+	events := &eventWriter{rw: rw, rc: http.NewResponseController(rw)}
+
+	err = events.Send("response", struct {
+		Proto           string      `json:"proto"`
+		StatusCode      int         `json:"statusCode"`
+		Headers         http.Header `json:"headers"`
+		Matched         bool        `json:"matched"`
+		MatchedRouter   string      `json:"matchedRouter,omitempty"`
+		MatchedRule     string      `json:"matchedRule,omitempty"`
+		MatchedPriority int         `json:"matchedPriority,omitempty"`
+		MiddlewareChain []string    `json:"middlewareChain,omitempty"`
+		Warnings        []string    `json:"warnings,omitempty"`
+	}{
+		Proto:           res.Response.Proto,
+		StatusCode:      res.Response.StatusCode,
+		Headers:         res.Response.Headers,
+		Matched:         res.Matched,
+		MatchedRouter:   res.MatchedRouter,
+		MatchedRule:     res.MatchedRule,
+		MatchedPriority: res.MatchedPriority,
+		MiddlewareChain: res.MiddlewareChain,
+		Warnings:        res.Warnings,
+	})
+	if err != nil {
+		log.Ctx(ctx).Debug().Err(err).Msg("Unable to send response event")
+
+		return
+	}
+
+	buf := make([]byte, streamChunkSize)
+	for {
+		n, readErr := res.Body.Read(buf)
+		if n > 0 {
+			if err = events.Send("chunk", struct {
+				Data []byte `json:"data"`
+			}{Data: buf[:n]}); err != nil {
+				log.Ctx(ctx).Debug().Err(err).Msg("Unable to send chunk event")
+
+				return
+			}
+		}
+
+		if errors.Is(readErr, io.EOF) {
+			break
+		}
+
+		if readErr != nil {
+			log.Ctx(ctx).Error().Err(readErr).Interface("experiment", exp).Msg("Unable to stream response body")
+
+			message := "the response stream was interrupted, it may have exceeded the time limit"
+			if errors.Is(readErr, experiment.ErrStreamTooLarge) {
+				message = "the response body exceeds the maximum stream size"
+			}
+
+			_ = events.Send("error", struct {
+				Message string `json:"message"`
+			}{Message: message})
+
+			return
+		}
+	}
+
+	_ = events.Send("end", struct{}{})
+}
+
+// eventWriter writes Server-Sent Events with a JSON encoded data.
+type eventWriter struct {
+	rw io.Writer
+	rc *http.ResponseController
+}
+
+// Send sends an event of the given type and flushes it to the client.
+func (w *eventWriter) Send(event string, data any) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("marshaling %q event: %w", event, err)
+	}
+
+	if _, err = fmt.Fprintf(w.rw, "event: %s\ndata: %s\n\n", event, b); err != nil {
+		return fmt.Errorf("writing %q event: %w", event, err)
+	}
+
+	if err = w.rc.Flush(); err != nil {
+		return fmt.Errorf("flushing %q event: %w", event, err)
+	}
+
+	return nil
+}
+
 // ShareExperiment shares an experiment.
 func (a *App) ShareExperiment(rw http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
@@ -238,50 +668,87 @@ func (a *App) ShareExperiment(rw http.ResponseWriter, req *http.Request) {
 	var payload struct {
 		RunBundle          string `schema:"runBundle"`
 		RunBundleSignature string `schema:"runBundleSignature"`
+		Label              string `schema:"label"`
 	}
 
 	if err := decodeForm(req, &payload); err != nil {
 		log.Ctx(ctx).Error().Err(err).Msg("Failed read experiment")
-		rw.WriteHeader(http.StatusBadRequest)
-
-		a.render(req.Context(), rw, a.experimentTemplate, experimentTemplateData{
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
 			DynamicConfig: a.defaultDynamicConfig,
-			Error:         err,
 		})
 
 		return
 	}
 
-	exp, res, err := unmarshalRunBundle(payload.RunBundle, payload.RunBundleSignature, a.secretKey)
+	exp, res, err := unmarshalRunBundle(payload.RunBundle, payload.RunBundleSignature, a.verificationKeys())
 	if err != nil {
 		log.Ctx(ctx).Error().Err(err).Msg("Unable to unmarshal run bundle")
-		rw.WriteHeader(http.StatusBadRequest)
-
-		a.render(req.Context(), rw, a.experimentTemplate, experimentTemplateData{
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
 			DynamicConfig: a.defaultDynamicConfig,
-			Error:         err,
+		})
+
+		return
+	}
+
+	exp.Label, err = experiment.MakeLabel(payload.Label)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Invalid label")
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
+			DynamicConfig:      exp.DynamicConfig,
+			StaticConfig:       exp.StaticConfig,
+			Request:            makeRequestForm(exp.Request),
+			Result:             &res,
+			RunBundle:          payload.RunBundle,
+			RunBundleSignature: payload.RunBundleSignature,
+			Label:              payload.Label,
 		})
 
 		return
 	}
 
 	clientIP, _, _ := net.SplitHostPort(req.RemoteAddr)
-	id, err := a.controller.Share(ctx, exp, res, clientIP)
+	id, err := a.controller.Share(ctx, exp, res, clientIP, req.UserAgent())
 	if err != nil {
 		log.Error().Err(err).Interface("experiment", exp).Msg("Unable to share experiment")
-		rw.WriteHeader(http.StatusInternalServerError)
+		a.respondError(rw, req, http.StatusInternalServerError, errors.New("unable to share experiment, please retry later"), experimentTemplateData{
+			DynamicConfig: exp.DynamicConfig,
+			StaticConfig:  exp.StaticConfig,
+			Request:       makeRequestForm(exp.Request),
+			Result:        &res,
+		})
+
+		return
+	}
 
-		a.render(req.Context(), rw, a.experimentTemplate, experimentTemplateData{
+	shareURL, err := a.shareURL(id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Unable to sign share URL")
+		a.respondError(rw, req, http.StatusInternalServerError, errServiceIssues, experimentTemplateData{
 			DynamicConfig: exp.DynamicConfig,
-			Request:       makeExperimentTemplateRequestData(exp.Request),
+			StaticConfig:  exp.StaticConfig,
+			Request:       makeRequestForm(exp.Request),
 			Result:        &res,
-			Error:         errors.New("unable to share experiment, please retry later"),
 		})
 
 		return
 	}
 
-	http.Redirect(rw, req, "/share/"+id, http.StatusSeeOther)
+	http.Redirect(rw, req, shareURL, http.StatusSeeOther)
+}
+
+// shareURL returns the path of the page of the shared experiment with the given ID, holding its signature when
+// share URLs are signed.
+func (a *App) shareURL(id string) (string, error) {
+	if !a.signShareURLs {
+		return "/share/" + id, nil
+	}
+
+	signature, err := shareSignature(id, a.secretKey)
+	if err != nil {
+		return "", err
+	}
+
+	return "/share/" + id + "/" + signature, nil
 }
 
 // SharedExperiment serves a shared experiment.
@@ -289,75 +756,156 @@ func (a *App) SharedExperiment(rw http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 	id := req.PathValue("id")
 
-	exp, res, err := a.controller.Shared(ctx, id)
+	valid, err := a.allowedShare(id, req.PathValue("signature"))
 	if err != nil {
-		log.Error().Err(err).Str("id", id).Msg("Unable to retrieve experiment")
+		log.Error().Err(err).Str("id", id).Msg("Unable to verify share URL signature")
+		a.respondError(rw, req, http.StatusInternalServerError, errServiceIssues, experimentTemplateData{
+			DynamicConfig: a.defaultDynamicConfig,
+		})
 
-		if errors.Is(err, experiment.ErrNotFound) {
-			rw.WriteHeader(http.StatusNotFound)
-			err = errors.New("unable to find experiment")
-		} else {
-			rw.WriteHeader(http.StatusInternalServerError)
-			err = errors.New("unable to retrieve experiment, please retry later")
-		}
+		return
+	}
 
-		a.render(req.Context(), rw, a.experimentTemplate, experimentTemplateData{
+	if !valid {
+		log.Ctx(ctx).Debug().Str("id", id).Msg("Invalid share URL signature")
+		a.respondError(rw, req, http.StatusNotFound, errors.New("unable to find experiment"), experimentTemplateData{
 			DynamicConfig: a.defaultDynamicConfig,
-			Error:         err,
 		})
 
 		return
 	}
 
+	page, ok := a.sharedPage(rw, req, id)
+	if !ok {
+		return
+	}
+
+	a.render(rw, req, a.experimentTemplate, experimentTemplateData{
+		DynamicConfig:      page.exp.DynamicConfig,
+		StaticConfig:       page.exp.StaticConfig,
+		Request:            makeRequestForm(page.exp.Request),
+		Result:             &page.res,
+		CurlCommand:        curl.Format(page.exp.Request),
+		ShareURL:           req.URL.String(),
+		Label:              page.exp.Label,
+		RunBundle:          page.bundle,
+		RunBundleSignature: page.bundleSignature,
+	})
+}
+
+// errMarshalingRunBundle indicates that the run bundle of a shared experiment couldn't be marshaled.
+var errMarshalingRunBundle = errors.New("marshaling run bundle")
+
+// sharedPage retrieves the shared experiment with the given ID along with its signed run bundle, from the
+// SharedCache when cached. It responds with an error and returns false if it can't be retrieved.
+func (a *App) sharedPage(rw http.ResponseWriter, req *http.Request, id string) (sharedPage, bool) {
+	if a.sharedCache != nil {
+		if page, ok := a.sharedCache.get(id); ok {
+			return page, true
+		}
+	}
+
+	// Concurrent views of an experiment which isn't cached yet share a single retrieval. It's detached from the
+	// request starting it, so that the other views don't fail if this request goes away first.
+	loaded, err, _ := a.sharedLoads.Do(id, func() (any, error) {
+		return a.loadSharedPage(context.WithoutCancel(req.Context()), id)
+	})
+	page, _ := loaded.(sharedPage)
+
+	switch {
+	case errors.Is(err, errMarshalingRunBundle):
+		a.respondError(rw, req, http.StatusInternalServerError, errServiceIssues, experimentTemplateData{
+			DynamicConfig: page.exp.DynamicConfig,
+			StaticConfig:  page.exp.StaticConfig,
+			Request:       makeRequestForm(page.exp.Request),
+		})
+
+		return sharedPage{}, false
+	case errors.Is(err, experiment.ErrNotFound):
+		a.respondError(rw, req, http.StatusNotFound, errors.New("unable to find experiment"), experimentTemplateData{
+			DynamicConfig: a.defaultDynamicConfig,
+		})
+
+		return sharedPage{}, false
+	case err != nil:
+		a.respondError(rw, req, http.StatusInternalServerError, errors.New("unable to retrieve experiment, please retry later"), experimentTemplateData{
+			DynamicConfig: a.defaultDynamicConfig,
+		})
+
+		return sharedPage{}, false
+	}
+
+	return page, true
+}
+
+// loadSharedPage retrieves the shared experiment with the given ID from the store, marshals its signed run bundle
+// and caches them. When only the marshaling fails, the returned sharedPage holds the experiment.
+func (a *App) loadSharedPage(ctx context.Context, id string) (sharedPage, error) {
+	exp, res, err := a.controller.Shared(ctx, id)
+	if err != nil {
+		log.Error().Err(err).Str("id", id).Msg("Unable to retrieve experiment")
+
+		return sharedPage{}, err
+	}
+
 	bundle, bundleSignature, err := marshalRunBundle(exp, res, a.secretKey)
 	if err != nil {
 		log.Error().Err(err).Interface("experiment", exp).Msg("Unable to marshal run bundle")
-		rw.WriteHeader(http.StatusInternalServerError)
 
-		a.render(req.Context(), rw, a.experimentTemplate, experimentTemplateData{
-			DynamicConfig: exp.DynamicConfig,
-			Request:       makeExperimentTemplateRequestData(exp.Request),
-			Error:         errors.New("the service is experiencing issues, please retry later"),
-		})
+		return sharedPage{exp: exp}, fmt.Errorf("%w: %w", errMarshalingRunBundle, err)
+	}
 
-		return
+	page := sharedPage{exp: exp, res: res, bundle: bundle, bundleSignature: bundleSignature}
+	if a.sharedCache != nil {
+		a.sharedCache.add(id, page)
 	}
 
-	a.render(req.Context(), rw, a.experimentTemplate, experimentTemplateData{
-		DynamicConfig:      exp.DynamicConfig,
-		Request:            makeExperimentTemplateRequestData(exp.Request),
-		Result:             &res,
-		ShareURL:           req.URL.JoinPath(id).String(),
-		RunBundle:          bundle,
-		RunBundleSignature: bundleSignature,
-	})
+	return page, nil
+}
+
+// exportForm is the form submitted to export an experiment, whatever the format.
+type exportForm struct {
+	RunBundle          string `schema:"runBundle"`
+	RunBundleSignature string `schema:"runBundleSignature"`
+	// Namespace is the namespace of the Kubernetes manifests.
+	Namespace string `schema:"namespace"`
 }
 
 // ExportExperiment exports an experiment as a docker-compose file.
 func (a *App) ExportExperiment(rw http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 
-	var payload struct {
-		RunBundle          string `schema:"runBundle"`
-		RunBundleSignature string `schema:"runBundleSignature"`
-	}
-
+	var payload exportForm
 	if err := decodeForm(req, &payload); err != nil {
 		log.Ctx(ctx).Error().Err(err).Msg("Failed to read export request")
-		rw.WriteHeader(http.StatusBadRequest)
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
+			DynamicConfig: a.defaultDynamicConfig,
+		})
 
 		return
 	}
 
-	exp, _, err := unmarshalRunBundle(payload.RunBundle, payload.RunBundleSignature, a.secretKey)
+	exp, _, err := unmarshalRunBundle(payload.RunBundle, payload.RunBundleSignature, a.verificationKeys())
 	if err != nil {
 		log.Ctx(ctx).Error().Err(err).Msg("Unable to unmarshal run bundle")
-		rw.WriteHeader(http.StatusBadRequest)
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
+			DynamicConfig: a.defaultDynamicConfig,
+		})
 
 		return
 	}
 
-	dockerCompose := compose.Generate(exp.DynamicConfig)
+	dockerCompose, err := compose.Generate(exp.DynamicConfig)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to generate docker-compose file")
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
+			DynamicConfig: exp.DynamicConfig,
+			StaticConfig:  exp.StaticConfig,
+			Request:       makeRequestForm(exp.Request),
+		})
+
+		return
+	}
 
 	rw.Header().Set("Content-Type", "application/x-yaml")
 	rw.Header().Set("Content-Disposition", `attachment; filename="docker-compose.yaml"`)
@@ -368,19 +916,550 @@ func (a *App) ExportExperiment(rw http.ResponseWriter, req *http.Request) {
 	}
 }
 
-type runBundle struct {
-	Experiment experiment.Experiment `json:"experiment"`
-	Result     experiment.Result     `json:"result"`
-}
-
-func marshalRunBundle(exp experiment.Experiment, res experiment.Result, secretKey string) (string, string, error) {
-	marshaled, err := json.Marshal(runBundle{
-		Experiment: exp,
-		Result:     res,
-	})
-	if err != nil {
-		return "", "", err
-	}
+// ExportExperimentKubernetes exports an experiment as Kubernetes manifests, ready for "kubectl apply -f".
+func (a *App) ExportExperimentKubernetes(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var payload exportForm
+	if err := decodeForm(req, &payload); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to read export request")
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
+			DynamicConfig: a.defaultDynamicConfig,
+		})
+
+		return
+	}
+
+	exp, _, err := unmarshalRunBundle(payload.RunBundle, payload.RunBundleSignature, a.verificationKeys())
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to unmarshal run bundle")
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
+			DynamicConfig: a.defaultDynamicConfig,
+		})
+
+		return
+	}
+
+	namespace := payload.Namespace
+	if namespace == "" {
+		namespace = kubernetes.DefaultNamespace
+	}
+
+	manifests, err := kubernetes.Generate(exp.DynamicConfig, namespace)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to generate Kubernetes manifests")
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
+			DynamicConfig: exp.DynamicConfig,
+			StaticConfig:  exp.StaticConfig,
+			Request:       makeRequestForm(exp.Request),
+		})
+
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/x-yaml")
+	rw.Header().Set("Content-Disposition", `attachment; filename="kubernetes.yaml"`)
+	rw.WriteHeader(http.StatusOK)
+
+	if _, err = rw.Write([]byte(manifests)); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to write export response")
+	}
+}
+
+// ExportExperimentJSON exports an experiment and its result as a signed JSON file, which can be imported back.
+func (a *App) ExportExperimentJSON(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var payload exportForm
+	if err := decodeForm(req, &payload); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to read export request")
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
+			DynamicConfig: a.defaultDynamicConfig,
+		})
+
+		return
+	}
+
+	var exp experiment.Experiment
+
+	bundle, err := base64.StdEncoding.DecodeString(payload.RunBundle)
+	if err == nil {
+		exp, _, err = verifyRunBundle(bundle, payload.RunBundleSignature, a.verificationKeys())
+	}
+
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to unmarshal run bundle")
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
+			DynamicConfig: a.defaultDynamicConfig,
+		})
+
+		return
+	}
+
+	// Sign the bundle with the current key, in case it was issued before a key rotation.
+	signature, err := generateHMAC(bundle, a.secretKey)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to sign run bundle")
+		a.respondError(rw, req, http.StatusInternalServerError, errServiceIssues, experimentTemplateData{
+			DynamicConfig: exp.DynamicConfig,
+			StaticConfig:  exp.StaticConfig,
+			Request:       makeRequestForm(exp.Request),
+		})
+
+		return
+	}
+
+	file, err := json.Marshal(runBundleFile{
+		Bundle:    bundle,
+		Signature: signature,
+	})
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to marshal run bundle file")
+		a.respondError(rw, req, http.StatusInternalServerError, errServiceIssues, experimentTemplateData{
+			DynamicConfig: exp.DynamicConfig,
+			StaticConfig:  exp.StaticConfig,
+			Request:       makeRequestForm(exp.Request),
+		})
+
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.Header().Set("Content-Disposition", `attachment; filename="experiment.json"`)
+	rw.WriteHeader(http.StatusOK)
+
+	if _, err = rw.Write(file); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to write export response")
+	}
+}
+
+// ImportExperiment serves the experiment page populated with the experiment and result of a JSON file
+// produced by ExportExperimentJSON.
+func (a *App) ImportExperiment(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	exp, res, err := a.readRunBundleFile(req)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to import experiment")
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
+			DynamicConfig: a.defaultDynamicConfig,
+		})
+
+		return
+	}
+
+	bundle, bundleSignature, err := marshalRunBundle(exp, res, a.secretKey)
+	if err != nil {
+		log.Error().Err(err).Interface("experiment", exp).Msg("Unable to marshal run bundle")
+		a.respondError(rw, req, http.StatusInternalServerError, errServiceIssues, experimentTemplateData{
+			DynamicConfig: exp.DynamicConfig,
+			StaticConfig:  exp.StaticConfig,
+			Request:       makeRequestForm(exp.Request),
+		})
+
+		return
+	}
+
+	a.render(rw, req, a.experimentTemplate, experimentTemplateData{
+		DynamicConfig:      exp.DynamicConfig,
+		StaticConfig:       exp.StaticConfig,
+		Request:            makeRequestForm(exp.Request),
+		Result:             &res,
+		CurlCommand:        curl.Format(exp.Request),
+		RunBundle:          bundle,
+		RunBundleSignature: bundleSignature,
+	})
+}
+
+// readRunBundleFile reads and verifies the run bundle file uploaded in the "file" field of the given request.
+func (a *App) readRunBundleFile(req *http.Request) (experiment.Experiment, experiment.Result, error) {
+	file, _, err := req.FormFile("file")
+	if err != nil {
+		return experiment.Experiment{}, experiment.Result{}, fmt.Errorf("reading uploaded file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	var f runBundleFile
+	if err = json.NewDecoder(file).Decode(&f); err != nil {
+		return experiment.Experiment{}, experiment.Result{}, fmt.Errorf("decoding uploaded file: %w", err)
+	}
+
+	return a.verifyRunBundleFile(f)
+}
+
+// verifyRunBundleFile verifies the signature of the run bundle held by the given file and returns its experiment
+// and result.
+func (a *App) verifyRunBundleFile(f runBundleFile) (experiment.Experiment, experiment.Result, error) {
+	// The file may have been reformatted since it was exported, while the signature covers the compact bundle.
+	var bundle bytes.Buffer
+	if err := json.Compact(&bundle, f.Bundle); err != nil {
+		return experiment.Experiment{}, experiment.Result{}, fmt.Errorf("compacting bundle: %w", err)
+	}
+
+	return verifyRunBundle(bundle.Bytes(), f.Signature, a.verificationKeys())
+}
+
+// ImportCurl serves the experiment page with the request populated from a curl command.
+func (a *App) ImportCurl(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var payload struct {
+		DynamicConfig string      `schema:"dynamicConfig"`
+		Curl          string      `schema:"curl"`
+		Request       requestForm `schema:"request"`
+	}
+
+	if err := decodeExperimentForm(req, &payload); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to read curl import request")
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
+			DynamicConfig: a.defaultDynamicConfig,
+		})
+
+		return
+	}
+
+	httpReq, err := curl.Parse(payload.Curl)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Invalid curl command")
+		// The request fields still hold their previous values, report the error on the curl command instead.
+		err = &experiment.ValidationError{Field: "curl", Message: "curl: " + err.Error()}
+
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
+			DynamicConfig: payload.DynamicConfig,
+			Request:       payload.Request,
+			Curl:          payload.Curl,
+		})
+
+		return
+	}
+
+	a.render(rw, req, a.experimentTemplate, experimentTemplateData{
+		DynamicConfig: payload.DynamicConfig,
+		Request:       makeRequestForm(httpReq),
+	})
+}
+
+// ImportRawRequest serves the experiment page with the request populated from a raw HTTP/1.x request.
+func (a *App) ImportRawRequest(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var payload struct {
+		DynamicConfig string      `schema:"dynamicConfig"`
+		RawRequest    string      `schema:"rawRequest"`
+		Request       requestForm `schema:"request"`
+	}
+
+	if err := decodeExperimentForm(req, &payload); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to read raw request import request")
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
+			DynamicConfig: a.defaultDynamicConfig,
+		})
+
+		return
+	}
+
+	httpReq, err := experiment.ParseRawHTTPRequest(payload.RawRequest)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Invalid raw request")
+		// The request fields still hold their previous values, report the error on the raw request instead.
+		err = &experiment.ValidationError{Field: "rawRequest", Message: err.Error(), Err: err}
+
+		a.respondError(rw, req, validationErrorStatus(err), err, experimentTemplateData{
+			DynamicConfig: payload.DynamicConfig,
+			Request:       payload.Request,
+			RawRequest:    payload.RawRequest,
+		})
+
+		return
+	}
+
+	a.render(rw, req, a.experimentTemplate, experimentTemplateData{
+		DynamicConfig: payload.DynamicConfig,
+		Request:       makeRequestForm(httpReq),
+	})
+}
+
+// NormalizeConfig rewrites the submitted dynamic configuration in its canonical and minimal form. Clients accepting
+// JSON receive the normalized configuration, others receive the experiment page populated with it.
+func (a *App) NormalizeConfig(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var payload struct {
+		DynamicConfig string      `schema:"dynamicConfig"`
+		Request       requestForm `schema:"request"`
+	}
+
+	if err := decodeExperimentForm(req, &payload); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to read normalize request")
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
+			DynamicConfig: a.defaultDynamicConfig,
+		})
+
+		return
+	}
+
+	dynamicConfig, err := experiment.NormalizeDynamicConfig(payload.DynamicConfig)
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to normalize dynamic configuration")
+
+		status := validationErrorStatus(err)
+
+		var validationErr *experiment.ValidationError
+		if !errors.As(err, &validationErr) {
+			status = http.StatusInternalServerError
+			err = errServiceIssues
+		}
+
+		a.respondError(rw, req, status, err, experimentTemplateData{
+			DynamicConfig: payload.DynamicConfig,
+			Request:       payload.Request,
+		})
+
+		return
+	}
+
+	if acceptsJSON(req) {
+		rw.Header().Set("Content-Type", "application/json")
+
+		if err = json.NewEncoder(rw).Encode(struct {
+			DynamicConfig string `json:"dynamicConfig"`
+		}{DynamicConfig: dynamicConfig}); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Unable to write normalized dynamic configuration")
+		}
+
+		return
+	}
+
+	a.render(rw, req, a.experimentTemplate, experimentTemplateData{
+		DynamicConfig: dynamicConfig,
+		Request:       payload.Request,
+	})
+}
+
+// TokenizeConfig responds with the submitted dynamic configuration parsed as a JSON encoded tree of
+// traefik.ConfigNode, for editors to highlight it and hint at its fields.
+func (a *App) TokenizeConfig(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var payload struct {
+		DynamicConfig string `schema:"dynamicConfig"`
+	}
+
+	if err := decodeExperimentForm(req, &payload); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to read tokenize request")
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
+			DynamicConfig: a.defaultDynamicConfig,
+		})
+
+		return
+	}
+
+	tree, err := experiment.TokenizeDynamicConfig(payload.DynamicConfig)
+	if err != nil {
+		log.Ctx(ctx).Debug().Err(err).Msg("Unable to tokenize dynamic configuration")
+		a.respondError(rw, req, validationErrorStatus(err), err, experimentTemplateData{
+			DynamicConfig: payload.DynamicConfig,
+		})
+
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	if err = json.NewEncoder(rw).Encode(tree); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to write tokenized dynamic configuration")
+	}
+}
+
+// Middlewares lists the supported Traefik middlewares along with their options.
+func (a *App) Middlewares(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+
+	if _, err := rw.Write(a.middlewares); err != nil {
+		log.Ctx(req.Context()).Error().Err(err).Msg("Unable to write middlewares response")
+	}
+}
+
+// HeaderPresets lists the sets of request headers commonly sent together.
+func (a *App) HeaderPresets(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+
+	if _, err := rw.Write(a.headerPresets); err != nil {
+		log.Ctx(req.Context()).Error().Err(err).Msg("Unable to write header presets response")
+	}
+}
+
+// DynamicConfigSchema serves the JSON schema the dynamic configurations are validated against, for the editor to
+// report the same violations.
+func (a *App) DynamicConfigSchema(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "application/schema+json")
+	rw.WriteHeader(http.StatusOK)
+
+	if _, err := rw.Write(experiment.DynamicConfigSchema()); err != nil {
+		log.Ctx(req.Context()).Error().Err(err).Msg("Unable to write dynamic configuration schema response")
+	}
+}
+
+// Version reports the versions of the playground, Traefik and Go the playground is built with.
+func (a *App) Version(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+
+	if _, err := rw.Write(a.version); err != nil {
+		log.Ctx(req.Context()).Error().Err(err).Msg("Unable to write version response")
+	}
+}
+
+// searchResponse holds the positions of a term in the response body of a run bundle.
+type searchResponse struct {
+	Matches []experiment.TextMatch `json:"matches"`
+}
+
+// SearchResponseBody finds the given term in the response body of the given run bundle, and responds with the
+// byte offsets of its occurrences for the page to highlight them. The experiment isn't run again. Bodies which
+// aren't text never match.
+func (a *App) SearchResponseBody(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var payload struct {
+		RunBundle          string `schema:"runBundle"`
+		RunBundleSignature string `schema:"runBundleSignature"`
+		Search             string `schema:"search"`
+	}
+	if err := decodeForm(req, &payload); err != nil {
+		log.Ctx(ctx).Debug().Err(err).Msg("Failed to read search request")
+		respondJSONError(rw, req, http.StatusBadRequest, err)
+
+		return
+	}
+
+	_, res, err := unmarshalRunBundle(payload.RunBundle, payload.RunBundleSignature, a.verificationKeys())
+	if err != nil {
+		log.Ctx(ctx).Debug().Err(err).Msg("Unable to unmarshal run bundle")
+		respondJSONError(rw, req, http.StatusBadRequest, err)
+
+		return
+	}
+
+	matches := []experiment.TextMatch{}
+	if res.Response.IsText {
+		matches = append(matches, experiment.FindMatches(res.Response.Body, payload.Search)...)
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+
+	if err = json.NewEncoder(rw).Encode(searchResponse{Matches: matches}); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to write search response")
+	}
+}
+
+// ReplayExperiment serves the experiment page pre-populated with the experiment of a run bundle,
+// allowing it to be modified and ran again.
+func (a *App) ReplayExperiment(rw http.ResponseWriter, req *http.Request) {
+	ctx := req.Context()
+
+	var payload struct {
+		RunBundle          string `schema:"runBundle"`
+		RunBundleSignature string `schema:"runBundleSignature"`
+	}
+
+	if err := decodeForm(req, &payload); err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Failed to read replay request")
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
+			DynamicConfig: a.defaultDynamicConfig,
+		})
+
+		return
+	}
+
+	exp, _, err := unmarshalRunBundle(payload.RunBundle, payload.RunBundleSignature, a.verificationKeys())
+	if err != nil {
+		log.Ctx(ctx).Error().Err(err).Msg("Unable to unmarshal run bundle")
+		a.respondError(rw, req, http.StatusBadRequest, err, experimentTemplateData{
+			DynamicConfig: a.defaultDynamicConfig,
+		})
+
+		return
+	}
+
+	a.render(rw, req, a.experimentTemplate, experimentTemplateData{
+		DynamicConfig: exp.DynamicConfig,
+		StaticConfig:  exp.StaticConfig,
+		Request:       makeRequestForm(exp.Request),
+	})
+}
+
+// verificationKeys returns the keys run bundles can be signed with, starting with the current one.
+func (a *App) verificationKeys() []string {
+	return append([]string{a.secretKey}, a.oldSecretKeys...)
+}
+
+// allowedShare reports whether the shared experiment with the given ID can be served with the given share URL
+// signature. A signature is required when share URLs are signed, but is checked whenever provided. An invalid
+// signature is meant to be reported the same way as an unknown experiment, so IDs can't be told apart.
+func (a *App) allowedShare(id, signature string) (bool, error) {
+	if signature == "" && !a.signShareURLs {
+		return true, nil
+	}
+
+	return a.verifyShareSignature(id, signature)
+}
+
+// verifyShareSignature reports whether the given share URL signature has been issued for the given experiment ID
+// with one of the verification keys.
+func (a *App) verifyShareSignature(id, signature string) (bool, error) {
+	for _, secretKey := range a.verificationKeys() {
+		gotSignature, err := shareSignature(id, secretKey)
+		if err != nil {
+			return false, err
+		}
+
+		if hmac.Equal([]byte(gotSignature), []byte(signature)) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// shareSignature returns the signature of the share URL of the given experiment ID. The data is prefixed so the
+// signature can't be mistaken for the one of a run bundle, and re-encoded to be safely used as a path segment.
+func shareSignature(id, secretKey string) (string, error) {
+	signature, err := generateHMAC([]byte("share:"+id), secretKey)
+	if err != nil {
+		return "", fmt.Errorf("generating HMAC signature for share URL: %w", err)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return "", fmt.Errorf("decoding HMAC signature: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(decoded), nil
+}
+
+type runBundle struct {
+	Experiment experiment.Experiment `json:"experiment"`
+	Result     experiment.Result     `json:"result"`
+}
+
+// runBundleFile is the JSON file an experiment is exported as.
+type runBundleFile struct {
+	Bundle    json.RawMessage `json:"bundle"`
+	Signature string          `json:"signature"`
+}
+
+func marshalRunBundle(exp experiment.Experiment, res experiment.Result, secretKey string) (string, string, error) {
+	marshaled, err := json.Marshal(runBundle{
+		Experiment: exp,
+		Result:     res,
+	})
+	if err != nil {
+		return "", "", err
+	}
 
 	signature, err := generateHMAC(marshaled, secretKey)
 	if err != nil {
@@ -390,28 +1469,40 @@ func marshalRunBundle(exp experiment.Experiment, res experiment.Result, secretKe
 	return base64.StdEncoding.EncodeToString(marshaled), signature, nil
 }
 
-func unmarshalRunBundle(bundle, signature, secretKey string) (exp experiment.Experiment, res experiment.Result, err error) {
+func unmarshalRunBundle(bundle, signature string, secretKeys []string) (exp experiment.Experiment, res experiment.Result, err error) {
 	decoded, err := base64.StdEncoding.DecodeString(bundle)
 	if err != nil {
 		return
 	}
 
-	gotSignature, err := generateHMAC(decoded, secretKey)
-	if err != nil {
-		err = fmt.Errorf("generating HMAC signature from run bundle: %w", err)
+	return verifyRunBundle(decoded, signature, secretKeys)
+}
 
-		return
+// verifyRunBundle checks the given JSON run bundle has been signed with one of the given keys and decodes it.
+func verifyRunBundle(bundle []byte, signature string, secretKeys []string) (exp experiment.Experiment, res experiment.Result, err error) {
+	var valid bool
+	for _, secretKey := range secretKeys {
+		gotSignature, err := generateHMAC(bundle, secretKey)
+		if err != nil {
+			return experiment.Experiment{}, experiment.Result{}, fmt.Errorf("generating HMAC signature from run bundle: %w", err)
+		}
+
+		// Compare safely the received and compute signatures.
+		if hmac.Equal([]byte(gotSignature), []byte(signature)) {
+			valid = true
+
+			break
+		}
 	}
 
-	// Compare safely the received and compute signatures.
-	if !hmac.Equal([]byte(gotSignature), []byte(signature)) {
+	if !valid {
 		err = errors.New("invalid response signature")
 
 		return
 	}
 
 	var b runBundle
-	if err = json.Unmarshal(decoded, &b); err != nil {
+	if err = json.Unmarshal(bundle, &b); err != nil {
 		return
 	}
 
@@ -430,19 +1521,171 @@ func generateHMAC(data []byte, secretKey string) (string, error) {
 	return base64.StdEncoding.EncodeToString(h.Sum(nil)), nil
 }
 
+func newExperimentFormDecoder() *schema.Decoder {
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(true)
+
+	return decoder
+}
+
+// limitBody limits the size of the body of the requests served by the given handler to the given number of bytes.
+// Reading past the limit fails with an *http.MaxBytesError instead of buffering the rest of the body.
+func limitBody(size int64, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		req.Body = http.MaxBytesReader(rw, req.Body, size)
+
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// decodeForm decodes the form of the given request into v, failing on unknown fields.
 func decodeForm(r *http.Request, v interface{}) error {
+	return decodeFormWith(schemaDecoder, r, v)
+}
+
+// decodeExperimentForm decodes the experiment form of the given request into v, ignoring the fields of the other
+// actions of the form.
+func decodeExperimentForm(r *http.Request, v interface{}) error {
+	return decodeFormWith(experimentFormDecoder, r, v)
+}
+
+func decodeFormWith(decoder *schema.Decoder, r *http.Request, v interface{}) error {
 	if err := r.ParseForm(); err != nil {
 		return err
 	}
 
-	return schemaDecoder.Decode(v, r.PostForm)
+	// The CSRF token has already been checked by protectCSRF.
+	form := maps.Clone(r.PostForm)
+	delete(form, csrfFieldName)
+
+	return decoder.Decode(v, form)
+}
+
+// errorResponse is the error returned to the clients accepting JSON.
+type errorResponse struct {
+	Error   string            `json:"error"`
+	Details string            `json:"details,omitempty"`
+	Fields  map[string]string `json:"fields,omitempty"`
+}
+
+// respondError responds with the given status and error. Clients accepting JSON receive an errorResponse,
+// others receive the experiment page populated with the given data. Validation errors are reported
+// along with the invalid field.
+func (a *App) respondError(rw http.ResponseWriter, req *http.Request, status int, err error, page experimentTemplateData) {
+	var fieldErrors map[string]string
+
+	var validationErr *experiment.ValidationError
+	if errors.As(err, &validationErr) {
+		fieldErrors = map[string]string{validationErr.Field: validationErr.Message}
+	}
+
+	if acceptsJSON(req) {
+		respondJSONError(rw, req, status, err)
+
+		return
+	}
+
+	rw.WriteHeader(status)
+
+	page.Error = err
+	page.FieldErrors = fieldErrors
+	a.render(rw, req, a.experimentTemplate, page)
+}
+
+// respondJSONError responds with the given status and error as an errorResponse. Validation errors are reported
+// along with the invalid field.
+func respondJSONError(rw http.ResponseWriter, req *http.Request, status int, err error) {
+	var fieldErrors map[string]string
+
+	var validationErr *experiment.ValidationError
+	if errors.As(err, &validationErr) {
+		fieldErrors = map[string]string{validationErr.Field: validationErr.Message}
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(status)
+
+	if err = json.NewEncoder(rw).Encode(errorResponse{
+		Error:   http.StatusText(status),
+		Details: err.Error(),
+		Fields:  fieldErrors,
+	}); err != nil {
+		log.Ctx(req.Context()).Error().Err(err).Msg("Unable to write error response")
+	}
 }
 
-func (a *App) render(ctx context.Context, rw http.ResponseWriter, tmpl *template.Template, templateData any) {
+// indentJSON indents the given JSON document for display.
+func indentJSON(raw json.RawMessage) (string, error) {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return "", fmt.Errorf("indenting JSON: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// acceptsJSON reports whether the client prefers JSON over HTML, that is, whether the Accept header of the given
+// request lists "application/json" before "text/html".
+func acceptsJSON(req *http.Request) bool {
+	for _, accept := range req.Header.Values("Accept") {
+		for mediaRange := range strings.SplitSeq(accept, ",") {
+			mediaType, _, err := mime.ParseMediaType(mediaRange)
+			if err != nil {
+				continue
+			}
+
+			switch mediaType {
+			case "application/json":
+				return true
+			case "text/html":
+				return false
+			}
+		}
+	}
+
+	return false
+}
+
+// defaultLang is the language of the pages rendered for clients without language preferences.
+const defaultLang = "en"
+
+// preferredLang returns the language the client of the given request prefers the most according to its
+// Accept-Language header, as a BCP 47 tag. It falls back to defaultLang when the header is missing or invalid,
+// or when the client accepts any language.
+func preferredLang(req *http.Request) string {
+	tags, _, err := language.ParseAcceptLanguage(strings.Join(req.Header.Values("Accept-Language"), ","))
+	if err != nil {
+		return defaultLang
+	}
+
+	// Tags are sorted by decreasing quality. The "*" wildcard is parsed as "mul", for multiple languages.
+	for _, tag := range tags {
+		if lang := tag.String(); tag != language.Und && lang != "mul" {
+			return lang
+		}
+	}
+
+	return defaultLang
+}
+
+func (a *App) render(rw http.ResponseWriter, req *http.Request, tmpl *template.Template, templateData any) {
+	ctx := req.Context()
+
+	if experimentData, ok := templateData.(experimentTemplateData); ok {
+		experimentData.CSRFToken = csrfToken(ctx)
+		if experimentData.Result != nil {
+			experimentData.LogAnnotations = traefik.AnnotateLogs(experimentData.DynamicConfig, experimentData.Result.Logs)
+		}
+		templateData = experimentData
+	}
+
 	data := struct {
 		Main any
+		// Lang is the language the client prefers, for the templates to select the language of the page.
+		Lang string
 	}{
 		Main: templateData,
+		Lang: preferredLang(req),
 	}
 
 	if err := tmpl.ExecuteTemplate(rw, "base", data); err != nil {
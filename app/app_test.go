@@ -0,0 +1,2248 @@
+package app_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jspdown/traefik-playground/app"
+	"github.com/jspdown/traefik-playground/internal/command"
+	"github.com/jspdown/traefik-playground/internal/experiment"
+	"github.com/jspdown/traefik-playground/internal/traefik"
+	"github.com/jspdown/traefik-playground/internal/version"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSecretKey = "0123456789abcdef0123456789abcdef"
+	// testCSRFToken is a well-formed CSRF token, sent by the requests built by newFormRequest and newFileRequest.
+	testCSRFToken = "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+)
+
+// fakeStore implements a simple in-memory store for testing.
+type fakeStore struct {
+	experiments map[string]storedExperiment
+	// gets counts the calls to Get.
+	gets int
+}
+
+type storedExperiment struct {
+	exp experiment.Experiment
+	res experiment.Result
+}
+
+func newFakeStore() *fakeStore {
+	return &fakeStore{
+		experiments: make(map[string]storedExperiment),
+	}
+}
+
+func (s *fakeStore) Save(_ context.Context, exp experiment.Experiment, res experiment.Result, _, _ string) (string, error) {
+	s.experiments["test-id"] = storedExperiment{exp, res}
+
+	return "test-id", nil
+}
+
+func (s *fakeStore) Get(_ context.Context, id string) (experiment.Experiment, experiment.Result, error) {
+	s.gets++
+
+	if stored, ok := s.experiments[id]; ok {
+		return stored.exp, stored.res, nil
+	}
+
+	return experiment.Experiment{}, experiment.Result{}, experiment.ErrNotFound
+}
+
+// fakeTraefik implements a test double for the traefikRunner interface.
+type fakeTraefik func(ctx context.Context, dynamicConfig string, req *http.Request, opts traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error)
+
+func (f fakeTraefik) Run(ctx context.Context, dynamicConfig string, req *http.Request, opts traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+	return f(ctx, dynamicConfig, req, opts)
+}
+
+// newTestHandler creates the handler of an App running the experiments with the given runner, nil for a runner
+// failing the test if it gets run.
+func newTestHandler(t *testing.T, store experiment.Storer, runner experiment.TraefikRunner, options app.Options) http.Handler {
+	t.Helper()
+
+	if runner == nil {
+		runner = fakeTraefik(func(_ context.Context, _ string, _ *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+			t.Error("unexpected run")
+
+			return nil, traefik.Report{}, nil, errors.New("unexpected run")
+		})
+	}
+
+	a, err := app.New(experiment.NewController(store, runner, experiment.ControllerConfig{}), options)
+	require.NoError(t, err)
+
+	mux := http.NewServeMux()
+	a.MountOn(mux)
+
+	return mux
+}
+
+func serve(handler http.Handler, req *http.Request) (*http.Response, string) {
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+
+	return rw.Result(), rw.Body.String()
+}
+
+func newFormRequest(target string, form url.Values) *http.Request {
+	form = maps.Clone(form)
+	form.Set("csrfToken", testCSRFToken)
+
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.AddCookie(&http.Cookie{Name: "csrf_token", Value: testCSRFToken})
+
+	return req
+}
+
+func newFileRequest(t *testing.T, target, content string) *http.Request {
+	t.Helper()
+
+	var body bytes.Buffer
+
+	w := multipart.NewWriter(&body)
+
+	require.NoError(t, w.WriteField("csrfToken", testCSRFToken))
+
+	part, err := w.CreateFormFile("file", "experiment.json")
+	require.NoError(t, err)
+
+	_, err = part.Write([]byte(content))
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	req := httptest.NewRequest(http.MethodPost, target, &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.AddCookie(&http.Cookie{Name: "csrf_token", Value: testCSRFToken})
+
+	return req
+}
+
+func TestApp_csrf(t *testing.T) {
+	t.Parallel()
+
+	handler := newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey})
+
+	// The token is issued along with the experiment page.
+	res, page := serve(handler, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	cookies := res.Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "csrf_token", cookies[0].Name)
+	assert.True(t, cookies[0].HttpOnly)
+	assert.Contains(t, page, `name="csrfToken" value="`+cookies[0].Value+`"`)
+
+	form := url.Values{
+		"dynamicConfig": {"http: {}"},
+		"curl":          {"curl https://example.com"},
+	}
+
+	tests := []struct {
+		name       string
+		cookie     string
+		token      string
+		wantStatus int
+	}{
+		{
+			name:       "valid token",
+			cookie:     cookies[0].Value,
+			token:      cookies[0].Value,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "missing token",
+			cookie:     cookies[0].Value,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "mismatching token",
+			cookie:     cookies[0].Value,
+			token:      testCSRFToken,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "missing cookie",
+			token:      cookies[0].Value,
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			name:       "malformed cookie",
+			cookie:     "token",
+			token:      "token",
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			values := maps.Clone(form)
+			if test.token != "" {
+				values.Set("csrfToken", test.token)
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/import/curl", strings.NewReader(values.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+			if test.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "csrf_token", Value: test.cookie})
+			}
+
+			res, _ := serve(handler, req)
+			assert.Equal(t, test.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestApp_Experiment_configParam(t *testing.T) {
+	t.Parallel()
+
+	dynamicConfig := "http:\n  routers:\n    foo:\n      rule: Host(`foo.localhost`)\n      service: whoami@playground"
+
+	tests := []struct {
+		name       string
+		config     string
+		wantStatus int
+		wantConfig string
+		wantError  string
+	}{
+		{
+			name:       "standard base64",
+			config:     base64.StdEncoding.EncodeToString([]byte(dynamicConfig)),
+			wantStatus: http.StatusOK,
+			wantConfig: dynamicConfig,
+		},
+		{
+			name:       "URL-safe base64",
+			config:     base64.RawURLEncoding.EncodeToString([]byte(dynamicConfig)),
+			wantStatus: http.StatusOK,
+			wantConfig: dynamicConfig,
+		},
+		{
+			name:       "invalid base64",
+			config:     "not base64!",
+			wantStatus: http.StatusBadRequest,
+			wantError:  "config parameter: invalid base64 encoding",
+		},
+		{
+			name:       "invalid dynamic configuration",
+			config:     base64.StdEncoding.EncodeToString([]byte("invalid yaml")),
+			wantStatus: http.StatusBadRequest,
+			wantError:  "config parameter: invalid dynamic configuration",
+		},
+		{
+			name:       "oversized",
+			config:     base64.StdEncoding.EncodeToString([]byte(strings.Repeat("#", 10*1024+1))),
+			wantStatus: http.StatusBadRequest,
+			wantError:  "config parameter: too long (max: 10240 bytes)",
+		},
+	}
+
+	handler := newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			target := "/?" + url.Values{"config": {test.config}}.Encode()
+
+			res, page := serve(handler, httptest.NewRequest(http.MethodGet, target, nil))
+			require.Equal(t, test.wantStatus, res.StatusCode)
+
+			if test.wantError != "" {
+				assert.Contains(t, page, html.EscapeString(test.wantError))
+				assert.Contains(t, page, "whoami@playground")
+
+				return
+			}
+
+			assert.Contains(t, page, "required>"+html.EscapeString(test.wantConfig)+"</textarea>")
+		})
+	}
+}
+
+func TestNew_secretKey(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		secretKey     string
+		oldSecretKeys []string
+		wantErr       error
+	}{
+		{
+			name:      "empty key",
+			secretKey: "",
+			wantErr:   errors.New("secret key must be at least 32 bytes long"),
+		},
+		{
+			name:      "short key",
+			secretKey: "secret",
+			wantErr:   errors.New("secret key must be at least 32 bytes long"),
+		},
+		{
+			name:      "long enough key",
+			secretKey: strings.Repeat("k", 32),
+		},
+		{
+			name:          "short old key",
+			secretKey:     strings.Repeat("k", 32),
+			oldSecretKeys: []string{strings.Repeat("o", 32), "secret"},
+			wantErr:       errors.New("old secret key 1 must be at least 32 bytes long"),
+		},
+		{
+			name:          "long enough old keys",
+			secretKey:     strings.Repeat("k", 32),
+			oldSecretKeys: []string{strings.Repeat("o", 32), strings.Repeat("p", 40)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			controller := experiment.NewController(newFakeStore(), nil, experiment.ControllerConfig{})
+
+			a, err := app.New(controller, app.Options{
+				SecretKey:     test.secretKey,
+				OldSecretKeys: test.oldSecretKeys,
+			})
+			if test.wantErr != nil {
+				require.EqualError(t, err, test.wantErr.Error())
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.NotNil(t, a)
+		})
+	}
+}
+
+func TestNew_defaultDynamicConfig(t *testing.T) {
+	t.Parallel()
+
+	customConfig := "http:\n  routers:\n    org:\n      rule: Host(`org.localhost`)\n      service: whoami@playground\n"
+
+	tests := []struct {
+		name                 string
+		defaultDynamicConfig string
+		wantConfig           string
+		wantErr              string
+	}{
+		{
+			name:                 "embedded",
+			defaultDynamicConfig: " \n",
+			wantConfig:           "whoami@playground",
+		},
+		{
+			name:                 "custom",
+			defaultDynamicConfig: customConfig,
+			wantConfig:           "required>" + html.EscapeString(customConfig) + "</textarea>",
+		},
+		{
+			name:                 "invalid",
+			defaultDynamicConfig: "invalid yaml",
+			wantErr:              "validating default dynamic configuration: invalid dynamic configuration",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			controller := experiment.NewController(newFakeStore(), nil, experiment.ControllerConfig{})
+
+			a, err := app.New(controller, app.Options{
+				SecretKey:            testSecretKey,
+				DefaultDynamicConfig: test.defaultDynamicConfig,
+			})
+			if test.wantErr != "" {
+				require.ErrorContains(t, err, test.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			mux := http.NewServeMux()
+			a.MountOn(mux)
+
+			res, page := serve(mux, httptest.NewRequest(http.MethodGet, "/", nil))
+			require.Equal(t, http.StatusOK, res.StatusCode)
+
+			assert.Contains(t, page, test.wantConfig)
+		})
+	}
+}
+
+func TestApp_secretKeyRotation(t *testing.T) {
+	t.Parallel()
+
+	oldKey := strings.Repeat("o", 32)
+	newKey := strings.Repeat("n", 32)
+
+	store := newFakeStore()
+	store.experiments["shared-id"] = storedExperiment{
+		exp: experiment.Experiment{
+			DynamicConfig: "http: {}",
+			Request: experiment.HTTPRequest{
+				Method: http.MethodGet,
+				URL:    "https://example.com/foo",
+			},
+		},
+	}
+
+	oldHandler := newTestHandler(t, store, nil, app.Options{SecretKey: oldKey})
+	rotatedHandler := newTestHandler(t, store, nil, app.Options{SecretKey: newKey, OldSecretKeys: []string{oldKey}})
+	newHandler := newTestHandler(t, store, nil, app.Options{SecretKey: newKey})
+
+	replayForm := func(page string) url.Values {
+		return url.Values{
+			"runBundle":          {extractReplayInput(t, page, "runBundle")},
+			"runBundleSignature": {extractReplayInput(t, page, "runBundleSignature")},
+		}
+	}
+
+	// Bundles issued before the rotation are still accepted.
+	_, oldPage := serve(oldHandler, httptest.NewRequest(http.MethodGet, "/share/shared-id", nil))
+
+	res, _ := serve(rotatedHandler, newFormRequest("/replay", replayForm(oldPage)))
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, _ = serve(newHandler, newFormRequest("/replay", replayForm(oldPage)))
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	// New bundles are signed with the current key.
+	_, rotatedPage := serve(rotatedHandler, httptest.NewRequest(http.MethodGet, "/share/shared-id", nil))
+
+	res, _ = serve(newHandler, newFormRequest("/replay", replayForm(rotatedPage)))
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, _ = serve(oldHandler, newFormRequest("/replay", replayForm(rotatedPage)))
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestApp_SharedExperiment(t *testing.T) {
+	t.Parallel()
+
+	store := newFakeStore()
+	store.experiments["shared-id"] = storedExperiment{
+		exp: experiment.Experiment{
+			DynamicConfig: "http: {}",
+			Request: experiment.HTTPRequest{
+				Method: http.MethodPatch,
+				URL:    "https://example.com/foo",
+				Headers: http.Header{
+					"X-Foo": {"foo"},
+					"X-Bar": {"bar"},
+				},
+				Body: "body",
+			},
+		},
+		res: experiment.Result{
+			Response: experiment.HTTPResponse{StatusCode: http.StatusTeapot},
+		},
+	}
+
+	handler := newTestHandler(t, store, nil, app.Options{SecretKey: testSecretKey})
+
+	res, page := serve(handler, httptest.NewRequest(http.MethodGet, "/share/shared-id", nil))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	assert.Regexp(t, `<option value="PATCH"\s+selected>`, page)
+	assert.Regexp(t, `<input name="request.url"[^>]*value="https://example.com/foo"`, page)
+	assert.Contains(t, page, `<textarea id="headers" name="request.headers" aria-label="headers" rows=4>X-Bar: bar
+X-Foo: foo</textarea>`)
+	assert.Contains(t, page, `<textarea name="request.body" aria-label="body" rows=10>body</textarea>`)
+	assert.Contains(t, page, `form="replay"`)
+	assert.Contains(t, page, html.EscapeString(`curl -X PATCH 'https://example.com/foo' -H 'X-Bar: bar' -H 'X-Foo: foo' --data 'body'`))
+}
+
+func TestApp_SharedExperiment_cached(t *testing.T) {
+	t.Parallel()
+
+	store := newFakeStore()
+	store.experiments["shared-id"] = storedExperiment{
+		exp: experiment.Experiment{
+			DynamicConfig: "http: {}",
+			Request: experiment.HTTPRequest{
+				Method: http.MethodGet,
+				URL:    "https://example.com/foo",
+			},
+		},
+	}
+
+	mux := newTestHandler(t, store, nil, app.Options{
+		SecretKey:   testSecretKey,
+		SharedCache: app.NewSharedCache(10, time.Minute),
+	})
+
+	_, firstPage := serve(mux, httptest.NewRequest(http.MethodGet, "/share/shared-id", nil))
+	res, secondPage := serve(mux, httptest.NewRequest(http.MethodGet, "/share/shared-id", nil))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	// The experiment is only retrieved, and its run bundle marshaled, for the first request.
+	assert.Equal(t, 1, store.gets)
+	assert.Regexp(t, `<input name="request.url"[^>]*value="https://example.com/foo"`, secondPage)
+	assert.Equal(t, extractReplayInput(t, firstPage, "runBundle"), extractReplayInput(t, secondPage, "runBundle"))
+
+	res, _ = serve(mux, httptest.NewRequest(http.MethodGet, "/share/unknown-id", nil))
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	assert.Equal(t, 2, store.gets)
+}
+
+func TestApp_SharedExperiment_cachedByPublicID(t *testing.T) {
+	t.Parallel()
+
+	// The experiment can be retrieved with its short code or with the public ID it's stored under.
+	exp := experiment.Experiment{
+		DynamicConfig: "http: {}",
+		Request: experiment.HTTPRequest{
+			Method: http.MethodGet,
+			URL:    "https://example.com/foo",
+		},
+		ID: "public-id",
+	}
+
+	store := newFakeStore()
+	store.experiments["short-code"] = storedExperiment{exp: exp}
+	store.experiments["public-id"] = storedExperiment{exp: exp}
+
+	mux := newTestHandler(t, store, nil, app.Options{
+		SecretKey:   testSecretKey,
+		SharedCache: app.NewSharedCache(10, time.Minute),
+	})
+
+	res, _ := serve(mux, httptest.NewRequest(http.MethodGet, "/share/short-code", nil))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, _ = serve(mux, httptest.NewRequest(http.MethodGet, "/share/public-id", nil))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, _ = serve(mux, httptest.NewRequest(http.MethodGet, "/share/short-code", nil))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	// The experiment is cached once, under its public ID.
+	assert.Equal(t, 1, store.gets)
+}
+
+// blockingStore is a fakeStore whose Get blocks until release is closed.
+type blockingStore struct {
+	*fakeStore
+
+	gets    atomic.Int64
+	release chan struct{}
+}
+
+func (s *blockingStore) Get(ctx context.Context, id string) (experiment.Experiment, experiment.Result, error) {
+	s.gets.Add(1)
+	<-s.release
+
+	return s.fakeStore.Get(ctx, id)
+}
+
+func TestApp_SharedExperiment_concurrentViews(t *testing.T) {
+	t.Parallel()
+
+	store := &blockingStore{fakeStore: newFakeStore(), release: make(chan struct{})}
+	store.experiments["shared-id"] = storedExperiment{
+		exp: experiment.Experiment{
+			DynamicConfig: "http: {}",
+			Request: experiment.HTTPRequest{
+				Method: http.MethodGet,
+				URL:    "https://example.com/foo",
+			},
+		},
+	}
+
+	// Without a cache, only the concurrent views share the retrieval of the experiment.
+	mux := newTestHandler(t, store, nil, app.Options{SecretKey: testSecretKey})
+
+	statuses := make([]int, 10)
+
+	var wg sync.WaitGroup
+	for i := range statuses {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			res, _ := serve(mux, httptest.NewRequest(http.MethodGet, "/share/shared-id", nil))
+			statuses[i] = res.StatusCode
+		}()
+	}
+
+	require.Eventually(t, func() bool { return store.gets.Load() == 1 }, time.Second, time.Millisecond)
+
+	// Give the other views the time to wait for the ongoing retrieval.
+	time.Sleep(50 * time.Millisecond)
+	close(store.release)
+	wg.Wait()
+
+	assert.Equal(t, int64(1), store.gets.Load())
+	for _, status := range statuses {
+		assert.Equal(t, http.StatusOK, status)
+	}
+}
+
+func TestApp_SharedExperiment_cacheExpired(t *testing.T) {
+	t.Parallel()
+
+	store := newFakeStore()
+	store.experiments["shared-id"] = storedExperiment{
+		exp: experiment.Experiment{
+			DynamicConfig: "http: {}",
+			Request: experiment.HTTPRequest{
+				Method: http.MethodGet,
+				URL:    "https://example.com/foo",
+			},
+		},
+	}
+
+	mux := newTestHandler(t, store, nil, app.Options{
+		SecretKey:   testSecretKey,
+		SharedCache: app.NewSharedCache(10, 10*time.Millisecond),
+	})
+
+	res, _ := serve(mux, httptest.NewRequest(http.MethodGet, "/share/shared-id", nil))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	// Once expired, the experiment is retrieved again, and no longer served when deleted in the meantime.
+	time.Sleep(20 * time.Millisecond)
+	delete(store.experiments, "shared-id")
+
+	res, _ = serve(mux, httptest.NewRequest(http.MethodGet, "/share/shared-id", nil))
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	assert.Equal(t, 2, store.gets)
+}
+
+func TestApp_SharedExperiment_signed(t *testing.T) {
+	t.Parallel()
+
+	store := newFakeStore()
+	store.experiments["shared-id"] = storedExperiment{
+		exp: experiment.Experiment{
+			DynamicConfig: "http: {}",
+			Request: experiment.HTTPRequest{
+				Method: http.MethodGet,
+				URL:    "https://example.com/foo",
+			},
+		},
+	}
+
+	handler := newTestHandler(t, store, nil, app.Options{SecretKey: testSecretKey})
+	signingHandler := newTestHandler(t, store, nil, app.Options{SecretKey: testSecretKey, SignShareURLs: true})
+
+	_, sharedPage := serve(handler, httptest.NewRequest(http.MethodGet, "/share/shared-id", nil))
+
+	form := url.Values{
+		"runBundle":          {extractReplayInput(t, sharedPage, "runBundle")},
+		"runBundleSignature": {extractReplayInput(t, sharedPage, "runBundleSignature")},
+	}
+
+	res, _ := serve(signingHandler, newFormRequest("/share", form))
+	require.Equal(t, http.StatusSeeOther, res.StatusCode)
+
+	shareURL := res.Header.Get("Location")
+	require.Regexp(t, `^/share/test-id/[\w-]+$`, shareURL)
+
+	res, page := serve(signingHandler, httptest.NewRequest(http.MethodGet, shareURL, nil))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Regexp(t, `<input name="request.url"[^>]*value="https://example.com/foo"`, page)
+
+	// The signature is checked even when share URLs aren't signed.
+	res, _ = serve(handler, httptest.NewRequest(http.MethodGet, shareURL, nil))
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	tests := []struct {
+		desc    string
+		handler http.Handler
+		url     string
+	}{
+		{
+			desc:    "unsigned",
+			handler: signingHandler,
+			url:     "/share/test-id",
+		},
+		{
+			desc:    "invalid signature",
+			handler: signingHandler,
+			url:     "/share/test-id/invalid",
+		},
+		{
+			desc:    "signature of another experiment",
+			handler: signingHandler,
+			url:     strings.Replace(shareURL, "test-id", "shared-id", 1),
+		},
+		{
+			desc:    "invalid signature without signing",
+			handler: handler,
+			url:     "/share/test-id/invalid",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			res, page := serve(test.handler, httptest.NewRequest(http.MethodGet, test.url, nil))
+			assert.Equal(t, http.StatusNotFound, res.StatusCode)
+			assert.Contains(t, page, "unable to find experiment")
+		})
+	}
+}
+
+func TestApp_ShareExperiment_label(t *testing.T) {
+	t.Parallel()
+
+	store := newFakeStore()
+	store.experiments["shared-id"] = storedExperiment{
+		exp: experiment.Experiment{
+			DynamicConfig: "http: {}",
+			Request: experiment.HTTPRequest{
+				Method: http.MethodGet,
+				URL:    "https://example.com/foo",
+			},
+		},
+	}
+
+	handler := newTestHandler(t, store, nil, app.Options{SecretKey: testSecretKey})
+
+	_, sharedPage := serve(handler, httptest.NewRequest(http.MethodGet, "/share/shared-id", nil))
+
+	form := url.Values{
+		"runBundle":          {extractReplayInput(t, sharedPage, "runBundle")},
+		"runBundleSignature": {extractReplayInput(t, sharedPage, "runBundleSignature")},
+	}
+
+	form.Set("label", strings.Repeat("a", 51))
+	res, page := serve(handler, newFormRequest("/share", form))
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	assert.Contains(t, page, "label is too long (max: 50)")
+
+	form.Set("label", " retry-test ")
+	res, _ = serve(handler, newFormRequest("/share", form))
+	require.Equal(t, http.StatusSeeOther, res.StatusCode)
+	assert.Equal(t, "retry-test", store.experiments["test-id"].exp.Label)
+
+	_, page = serve(handler, httptest.NewRequest(http.MethodGet, "/share/test-id", nil))
+	assert.Contains(t, page, `<span class="experiment-label" title="Label">retry-test</span>`)
+}
+
+func TestApp_ReplayExperiment(t *testing.T) {
+	t.Parallel()
+
+	store := newFakeStore()
+	store.experiments["shared-id"] = storedExperiment{
+		exp: experiment.Experiment{
+			DynamicConfig: "http: {}",
+			Request: experiment.HTTPRequest{
+				Method:  http.MethodPost,
+				URL:     "https://example.com/foo",
+				Headers: http.Header{"X-Foo": {"foo"}},
+			},
+		},
+	}
+
+	handler := newTestHandler(t, store, nil, app.Options{SecretKey: testSecretKey})
+
+	_, sharedPage := serve(handler, httptest.NewRequest(http.MethodGet, "/share/shared-id", nil))
+
+	res, page := serve(handler, newFormRequest("/replay", url.Values{
+		"runBundle":          {extractReplayInput(t, sharedPage, "runBundle")},
+		"runBundleSignature": {extractReplayInput(t, sharedPage, "runBundleSignature")},
+	}))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	assert.Regexp(t, `<option value="POST"\s+selected>`, page)
+	assert.Regexp(t, `<input name="request.url"[^>]*value="https://example.com/foo"`, page)
+	assert.Contains(t, page, `rows=4>X-Foo: foo</textarea>`)
+	assert.NotContains(t, page, `form="replay"`)
+	assert.NotContains(t, page, `status-line`)
+}
+
+func TestApp_ReplayExperiment_basicAuth(t *testing.T) {
+	t.Parallel()
+
+	var authorizations []string
+	runner := fakeTraefik(func(_ context.Context, _ string, req *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		authorizations = append(authorizations, req.Header.Get("Authorization"))
+
+		return &http.Response{Proto: "HTTP/1.1", StatusCode: http.StatusOK, Body: http.NoBody}, traefik.Report{}, nil, nil
+	})
+
+	store := newFakeStore()
+	handler := newTestHandler(t, store, runner, app.Options{SecretKey: testSecretKey})
+
+	res, page := serve(handler, newFormRequest("/run", url.Values{
+		"dynamicConfig":    {"http: {}"},
+		"request.method":   {http.MethodGet},
+		"request.url":      {"http://example.com"},
+		"request.username": {"user"},
+		"request.password": {"pass"},
+	}))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	res, _ = serve(handler, newFormRequest("/share", url.Values{
+		"runBundle":          {extractReplayInput(t, page, "runBundle")},
+		"runBundleSignature": {extractReplayInput(t, page, "runBundleSignature")},
+	}))
+	require.Equal(t, http.StatusSeeOther, res.StatusCode)
+	assert.Equal(t, "pass", store.experiments["test-id"].exp.Request.Password)
+
+	_, sharedPage := serve(handler, httptest.NewRequest(http.MethodGet, "/share/test-id", nil))
+
+	res, page = serve(handler, newFormRequest("/replay", url.Values{
+		"runBundle":          {extractReplayInput(t, sharedPage, "runBundle")},
+		"runBundleSignature": {extractReplayInput(t, sharedPage, "runBundleSignature")},
+	}))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Regexp(t, `<input name="request.username"[^>]*value="user"`, page)
+	assert.Regexp(t, `<input name="request.password"[^>]*value="pass"`, page)
+
+	assert.Equal(t, []string{"Basic dXNlcjpwYXNz"}, authorizations)
+}
+
+func TestApp_ReplayExperiment_invalidSignature(t *testing.T) {
+	t.Parallel()
+
+	res, _ := serve(newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey}), newFormRequest("/replay", url.Values{
+		"runBundle":          {"e30="},
+		"runBundleSignature": {"invalid"},
+	}))
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestApp_ExportImportExperimentJSON(t *testing.T) {
+	t.Parallel()
+
+	store := newFakeStore()
+	store.experiments["shared-id"] = storedExperiment{
+		exp: experiment.Experiment{
+			DynamicConfig: "http:\n  routers: {}",
+			Request: experiment.HTTPRequest{
+				Method:  http.MethodPut,
+				URL:     "https://example.com/foo",
+				Headers: http.Header{"X-Foo": {"foo"}},
+				Body:    "body",
+			},
+		},
+		res: experiment.Result{
+			Response: experiment.HTTPResponse{
+				Proto:      "HTTP/1.1",
+				StatusCode: http.StatusTeapot,
+				Headers:    http.Header{"X-Bar": {"bar"}},
+				Body:       []byte("response body"),
+				IsText:     true,
+			},
+		},
+	}
+
+	handler := newTestHandler(t, store, nil, app.Options{SecretKey: testSecretKey})
+
+	_, sharedPage := serve(handler, httptest.NewRequest(http.MethodGet, "/share/shared-id", nil))
+
+	res, file := serve(handler, newFormRequest("/export/json", url.Values{
+		"runBundle":          {extractReplayInput(t, sharedPage, "runBundle")},
+		"runBundleSignature": {extractReplayInput(t, sharedPage, "runBundleSignature")},
+	}))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="experiment.json"`, res.Header.Get("Content-Disposition"))
+
+	// The file can be reformatted without invalidating its signature.
+	var indented bytes.Buffer
+	require.NoError(t, json.Indent(&indented, []byte(file), "", "  "))
+
+	res, page := serve(handler, newFileRequest(t, "/import/json", indented.String()))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	assert.Contains(t, page, "required>http:\n  routers: {}</textarea>")
+	assert.Regexp(t, `<option value="PUT"\s+selected>`, page)
+	assert.Regexp(t, `<input name="request.url"[^>]*value="https://example.com/foo"`, page)
+	assert.Contains(t, page, `rows=4>X-Foo: foo</textarea>`)
+	assert.Contains(t, page, `<textarea name="request.body" aria-label="body" rows=10>body</textarea>`)
+	assert.Contains(t, page, `<span class="status-code">418</span>`)
+	assert.Contains(t, page, "response body")
+	assert.NotEmpty(t, extractReplayInput(t, page, "runBundle"))
+}
+
+func TestApp_ExportExperimentKubernetes(t *testing.T) {
+	t.Parallel()
+
+	store := newFakeStore()
+	store.experiments["shared-id"] = storedExperiment{
+		exp: experiment.Experiment{
+			DynamicConfig: "http:\n  routers:\n    api:\n      rule: PathPrefix(`/foo`)\n      service: whoami@playground",
+			Request: experiment.HTTPRequest{
+				Method: http.MethodGet,
+				URL:    "https://example.com/foo",
+			},
+		},
+	}
+
+	handler := newTestHandler(t, store, nil, app.Options{SecretKey: testSecretKey})
+
+	_, sharedPage := serve(handler, httptest.NewRequest(http.MethodGet, "/share/shared-id", nil))
+
+	tests := []struct {
+		name          string
+		namespace     string
+		wantStatus    int
+		wantNamespace string
+	}{
+		{
+			name:          "default namespace",
+			wantStatus:    http.StatusOK,
+			wantNamespace: "default",
+		},
+		{
+			name:          "custom namespace",
+			namespace:     "playground",
+			wantStatus:    http.StatusOK,
+			wantNamespace: "playground",
+		},
+		{
+			name:       "invalid namespace",
+			namespace:  "../etc",
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			res, body := serve(handler, newFormRequest("/export/kubernetes", url.Values{
+				"runBundle":          {extractReplayInput(t, sharedPage, "runBundle")},
+				"runBundleSignature": {extractReplayInput(t, sharedPage, "runBundleSignature")},
+				"namespace":          {test.namespace},
+			}))
+			require.Equal(t, test.wantStatus, res.StatusCode)
+
+			if test.wantStatus != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, `attachment; filename="kubernetes.yaml"`, res.Header.Get("Content-Disposition"))
+			assert.Contains(t, body, "kind: IngressRoute")
+			assert.Contains(t, body, "namespace: "+test.wantNamespace+"\n")
+		})
+	}
+}
+
+func TestApp_ImportExperiment_invalidSignature(t *testing.T) {
+	t.Parallel()
+
+	res, _ := serve(newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey}), newFileRequest(t, "/import/json", `{"bundle":{},"signature":"invalid"}`))
+
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
+
+func TestApp_ImportCurl(t *testing.T) {
+	t.Parallel()
+
+	res, page := serve(newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey}), newFormRequest("/import/curl", url.Values{
+		"dynamicConfig":  {"http: {}"},
+		"curl":           {`curl -X PATCH https://example.com/foo -H 'X-Foo: foo' -d '{"a": 1}'`},
+		"request.method": {http.MethodGet},
+		"request.url":    {"https://example.org"},
+	}))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	assert.Contains(t, page, "required>http: {}</textarea>")
+	assert.Regexp(t, `<option value="PATCH"\s+selected>`, page)
+	assert.Regexp(t, `<input name="request.url"[^>]*value="https://example.com/foo"`, page)
+	assert.Contains(t, page, `rows=4>Content-Type: application/x-www-form-urlencoded
+X-Foo: foo</textarea>`)
+	assert.Contains(t, page, `rows=10>{&#34;a&#34;: 1}</textarea>`)
+}
+
+func TestApp_NormalizeConfig(t *testing.T) {
+	t.Parallel()
+
+	handler := newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey})
+	form := url.Values{
+		"dynamicConfig":  {"http:\n  services:\n    api: {loadBalancer: {passHostHeader: true, servers: [{url: 'http://10.10.10.10'}]}}\n"},
+		"request.method": {http.MethodPatch},
+		"request.url":    {"https://example.com/foo"},
+	}
+
+	res, page := serve(handler, newFormRequest("/normalize", form))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	wantConfig := "http:\n  services:\n    api:\n      loadBalancer:\n        servers:\n          - url: http://10.10.10.10\n"
+
+	assert.Contains(t, page, "required>"+wantConfig+"</textarea>")
+	assert.Regexp(t, `<option value="PATCH"\s+selected>`, page)
+	assert.Regexp(t, `<input name="request.url"[^>]*value="https://example.com/foo"`, page)
+
+	req := newFormRequest("/normalize", form)
+	req.Header.Set("Accept", "application/json")
+
+	res, body := serve(handler, req)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+	var got struct {
+		DynamicConfig string `json:"dynamicConfig"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &got))
+	assert.Equal(t, wantConfig, got.DynamicConfig)
+}
+
+func TestApp_NormalizeConfig_unknownField(t *testing.T) {
+	t.Parallel()
+
+	res, page := serve(newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey}), newFormRequest("/normalize", url.Values{
+		"dynamicConfig":  {"http:\n  routers:\n    api: {rul: foo}\n"},
+		"request.method": {http.MethodGet},
+		"request.url":    {"https://example.com"},
+	}))
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	// The configuration is left untouched for the user to fix it.
+	assert.Contains(t, page, "required aria-invalid=\"true\">http:\n  routers:\n    api: {rul: foo}\n</textarea>")
+	assert.Contains(t, page, "normalizing would drop unknown fields: line 3: field rul not found in type dynamic.Router")
+}
+
+func TestApp_ImportCurl_invalidCommand(t *testing.T) {
+	t.Parallel()
+
+	res, page := serve(newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey}), newFormRequest("/import/curl", url.Values{
+		"dynamicConfig": {"http: {}"},
+		"curl":          {"curl -o out.html https://example.com"},
+		"request.url":   {"https://example.org"},
+	}))
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	assert.Contains(t, page, html.EscapeString(`curl: unsupported option "-o"`))
+	assert.Contains(t, page, `value="curl -o out.html https://example.com"`)
+	assert.Regexp(t, `<input name="request.url"[^>]*value="https://example.org"`, page)
+}
+
+func TestApp_ImportRawRequest(t *testing.T) {
+	t.Parallel()
+
+	res, page := serve(newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey}), newFormRequest("/import/raw", url.Values{
+		"dynamicConfig":  {"http: {}"},
+		"rawRequest":     {"PATCH /foo HTTP/1.0\r\nHost: example.com\r\nX-Foo: foo\r\nContent-Length: 8\r\n\r\n{\"a\": 1}"},
+		"request.method": {http.MethodGet},
+		"request.url":    {"https://example.org"},
+	}))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	assert.Contains(t, page, "required>http: {}</textarea>")
+	assert.Regexp(t, `<option value="PATCH"\s+selected>`, page)
+	assert.Regexp(t, `<input name="request.url"[^>]*value="http://example.com/foo"`, page)
+	assert.Regexp(t, `<option value="HTTP/1.0"\s+selected>`, page)
+	assert.Contains(t, page, `rows=4>X-Foo: foo</textarea>`)
+	assert.Contains(t, page, `rows=10>{&#34;a&#34;: 1}</textarea>`)
+	// The raw request is sent as written, without detecting its Content-Type.
+	assert.Regexp(t, `name="request.noContentTypeDetection"\s+value="true" checked>`, page)
+}
+
+func TestApp_ImportRawRequest_malformed(t *testing.T) {
+	t.Parallel()
+
+	res, page := serve(newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey}), newFormRequest("/import/raw", url.Values{
+		"dynamicConfig": {"http: {}"},
+		"rawRequest":    {"GET /foo\r\nHost: example.com"},
+		"request.url":   {"https://example.org"},
+	}))
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	assert.Contains(t, page, html.EscapeString(`raw request is invalid: malformed HTTP request "GET /foo"`))
+	assert.Regexp(t, `aria-invalid="true"\s+rows=6>GET /foo\r\nHost: example.com</textarea>`, page)
+	assert.Regexp(t, `<input name="request.url"[^>]*value="https://example.org"`, page)
+}
+
+// unsupportedStaticOptionErr is the error reported for the "api" option set by a static configuration.
+const unsupportedStaticOptionErr = `static configuration option "api" isn't supported, only ` +
+	`entryPoints.<name>.forwardedHeaders.connection, entryPoints.<name>.forwardedHeaders.insecure, ` +
+	`entryPoints.<name>.forwardedHeaders.trustedIPs, entryPoints.<name>.transport.respondingTimeouts.readTimeout, ` +
+	`serversTransport.forwardingTimeouts.dialTimeout, serversTransport.forwardingTimeouts.idleConnTimeout, ` +
+	`serversTransport.forwardingTimeouts.responseHeaderTimeout can be set`
+
+func TestApp_jsonErrors(t *testing.T) {
+	t.Parallel()
+
+	handler := newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey})
+
+	forbiddenReq := newFormRequest("/replay", url.Values{})
+	forbiddenReq.Header.Del("Cookie")
+
+	tests := []struct {
+		name        string
+		req         *http.Request
+		wantStatus  int
+		wantDetails string
+		wantFields  map[string]string
+	}{
+		{
+			name:        "invalid config parameter",
+			req:         httptest.NewRequest(http.MethodGet, "/?config=invalid!", nil),
+			wantStatus:  http.StatusBadRequest,
+			wantDetails: "config parameter: invalid base64 encoding",
+		},
+		{
+			name: "invalid experiment",
+			req: newFormRequest("/run", url.Values{
+				"dynamicConfig": {"http: {}"},
+				"request.url":   {"http://example.com"},
+			}),
+			wantStatus:  http.StatusBadRequest,
+			wantDetails: "request: method is required",
+			wantFields:  map[string]string{"method": "method is required"},
+		},
+		{
+			name: "dynamic config too large",
+			req: newFormRequest("/run", url.Values{
+				"dynamicConfig":  {"# " + strings.Repeat("a", 10*1024)},
+				"request.method": {http.MethodGet},
+				"request.url":    {"http://example.com"},
+			}),
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantDetails: "dynamic config is too large (max: 10240 bytes)",
+			wantFields:  map[string]string{"dynamicConfig": "dynamic config is too large (max: 10240 bytes)"},
+		},
+		{
+			name: "header value too large",
+			req: newFormRequest("/run", url.Values{
+				"dynamicConfig":   {"http: {}"},
+				"request.method":  {http.MethodGet},
+				"request.url":     {"http://example.com"},
+				"request.headers": {"X-Foo: " + strings.Repeat("a", 201)},
+			}),
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantDetails: `request: header value of "X-Foo" is too large (max: 200 bytes)`,
+			wantFields:  map[string]string{"headers": `header value of "X-Foo" is too large (max: 200 bytes)`},
+		},
+		{
+			name: "unresolved placeholders",
+			req: newFormRequest("/run", url.Values{
+				"dynamicConfig":  {"http:\n  routers:\n    api:\n      rule: Host(`${host}`)\n"},
+				"vars":           {"path=/"},
+				"request.method": {http.MethodGet},
+				"request.url":    {"http://example.com"},
+			}),
+			wantStatus:  http.StatusBadRequest,
+			wantDetails: "unresolved placeholders: ${host}",
+			wantFields:  map[string]string{"dynamicConfig": "unresolved placeholders: ${host}"},
+		},
+		{
+			name: "invalid variables",
+			req: newFormRequest("/run", url.Values{
+				"dynamicConfig":  {"http: {}"},
+				"vars":           {"host"},
+				"request.method": {http.MethodGet},
+				"request.url":    {"http://example.com"},
+			}),
+			wantStatus:  http.StatusBadRequest,
+			wantDetails: `invalid variable format, want "name=value", got: "host"`,
+			wantFields:  map[string]string{"vars": `invalid variable format, want "name=value", got: "host"`},
+		},
+		{
+			name:        "unknown share field",
+			req:         newFormRequest("/share", url.Values{"runBundle": {"bundle"}, "foo": {"bar"}}),
+			wantStatus:  http.StatusBadRequest,
+			wantDetails: `schema: invalid path "foo"`,
+		},
+		{
+			name: "unsupported static configuration option",
+			req: newFormRequest("/run", url.Values{
+				"dynamicConfig":  {"http: {}"},
+				"staticConfig":   {"api:\n  insecure: true\n"},
+				"request.method": {http.MethodGet},
+				"request.url":    {"http://example.com"},
+			}),
+			wantStatus:  http.StatusBadRequest,
+			wantDetails: unsupportedStaticOptionErr,
+			wantFields:  map[string]string{"staticConfig": unsupportedStaticOptionErr},
+		},
+		{
+			name:        "unknown shared experiment",
+			req:         httptest.NewRequest(http.MethodGet, "/share/unknown", nil),
+			wantStatus:  http.StatusNotFound,
+			wantDetails: "unable to find experiment",
+		},
+		{
+			name: "invalid run bundle",
+			req: newFormRequest("/export", url.Values{
+				"runBundle":          {"e30="},
+				"runBundleSignature": {"invalid"},
+			}),
+			wantStatus:  http.StatusBadRequest,
+			wantDetails: "invalid response signature",
+		},
+		{
+			name:        "missing CSRF token",
+			req:         forbiddenReq,
+			wantStatus:  http.StatusForbidden,
+			wantDetails: "the form has expired, please reload the page and retry",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			test.req.Header.Set("Accept", "application/json")
+
+			res, body := serve(handler, test.req)
+			require.Equal(t, test.wantStatus, res.StatusCode)
+			assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+			var got struct {
+				Error   string            `json:"error"`
+				Details string            `json:"details"`
+				Fields  map[string]string `json:"fields"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(body), &got))
+
+			assert.Equal(t, http.StatusText(test.wantStatus), got.Error)
+			assert.Equal(t, test.wantDetails, got.Details)
+			assert.Equal(t, test.wantFields, got.Fields)
+		})
+	}
+}
+
+func TestApp_RunExperiment_runErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		runErr      error
+		wantStatus  int
+		wantDetails string
+	}{
+		{
+			name:        "queue full",
+			runErr:      fmt.Errorf("%w: too many commands in the queue: %w", command.ErrNoWorkerAvailable, context.DeadlineExceeded),
+			wantStatus:  http.StatusServiceUnavailable,
+			wantDetails: "the service is currently busy, please retry later",
+		},
+		{
+			name:       "slow configuration",
+			runErr:     fmt.Errorf("getting Traefik result: %w", context.DeadlineExceeded),
+			wantStatus: http.StatusGatewayTimeout,
+			wantDetails: "the experiment took too long to complete, check the dynamic configuration " +
+				"for middlewares or services delaying the response, such as retries or a slow forwardAuth",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			runner := fakeTraefik(func(_ context.Context, _ string, _ *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+				return nil, traefik.Report{}, nil, test.runErr
+			})
+			handler := newTestHandler(t, newFakeStore(), runner, app.Options{SecretKey: testSecretKey})
+
+			req := newFormRequest("/run", url.Values{
+				"dynamicConfig":  {"http: {}"},
+				"request.method": {http.MethodGet},
+				"request.url":    {"http://example.com"},
+			})
+			req.Header.Set("Accept", "application/json")
+
+			res, body := serve(handler, req)
+			require.Equal(t, test.wantStatus, res.StatusCode)
+
+			var got struct {
+				Details string `json:"details"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(body), &got))
+
+			assert.Equal(t, test.wantDetails, got.Details)
+		})
+	}
+}
+
+func TestApp_StreamExperiment(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		closeErr error
+		wantLast string
+	}{
+		{
+			name:     "complete",
+			wantLast: "event: end\ndata: {}\n",
+		},
+		{
+			name:     "interrupted",
+			closeErr: context.DeadlineExceeded,
+			wantLast: "event: error\n" +
+				`data: {"message":"the response stream was interrupted, it may have exceeded the time limit"}` + "\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			bodyReader, bodyWriter := io.Pipe()
+
+			runner := fakeTraefik(func(_ context.Context, _ string, _ *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+				return &http.Response{
+					Proto:      "HTTP/1.1",
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": {"text/event-stream"}},
+					Body:       bodyReader,
+				}, traefik.Report{Router: "api@file", Rule: "PathPrefix(`/`)", Priority: 15}, nil, nil
+			})
+
+			server := httptest.NewServer(newTestHandler(t, newFakeStore(), runner, app.Options{SecretKey: testSecretKey}))
+			t.Cleanup(server.Close)
+
+			req := newFormRequest("/run/stream", url.Values{
+				"dynamicConfig":  {"http: {}"},
+				"request.method": {http.MethodGet},
+				"request.url":    {"http://example.com"},
+			})
+			req.URL, _ = url.Parse(server.URL + "/run/stream")
+			req.RequestURI = ""
+
+			res, err := server.Client().Do(req)
+			require.NoError(t, err)
+
+			defer func() { _ = res.Body.Close() }()
+
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+			events := bufio.NewReader(res.Body)
+			readEvent := func() string {
+				t.Helper()
+
+				var event strings.Builder
+				for {
+					line, err := events.ReadString('\n')
+					require.NoError(t, err)
+
+					if line == "\n" {
+						return event.String()
+					}
+
+					event.WriteString(line)
+				}
+			}
+
+			assert.Equal(t, "event: response\n"+
+				`data: {"proto":"HTTP/1.1","statusCode":200,"headers":{"Content-Type":["text/event-stream"]},"matched":true,"matchedRouter":"api@file","matchedRule":"PathPrefix(`+"`/`"+`)","matchedPriority":15}`+"\n",
+				readEvent())
+
+			// The chunk must reach the client while the backend is still writing.
+			go func() { _, _ = bodyWriter.Write([]byte("data: 1\n\n")) }()
+
+			assert.Equal(t, "event: chunk\n"+
+				`data: {"data":"`+base64.StdEncoding.EncodeToString([]byte("data: 1\n\n"))+`"}`+"\n",
+				readEvent())
+
+			require.NoError(t, bodyWriter.CloseWithError(test.closeErr))
+
+			assert.Equal(t, test.wantLast, readEvent())
+		})
+	}
+}
+
+func TestApp_RunExperiment_burst(t *testing.T) {
+	t.Parallel()
+
+	runner := fakeTraefik(func(_ context.Context, _ string, _ *http.Request, opts traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		return &http.Response{
+			Proto:      "HTTP/1.1",
+			StatusCode: http.StatusTooManyRequests,
+			Body:       http.NoBody,
+		}, traefik.Report{
+			Router: "api@file",
+			Burst: []traefik.BurstResponse{
+				{StatusCode: http.StatusTeapot},
+				{StatusCode: http.StatusTooManyRequests, Limited: true},
+			}[:opts.Burst],
+		}, nil, nil
+	})
+	handler := newTestHandler(t, newFakeStore(), runner, app.Options{SecretKey: testSecretKey})
+
+	res, page := serve(handler, newFormRequest("/run", url.Values{
+		"dynamicConfig":  {"http: {}"},
+		"request.method": {http.MethodGet},
+		"request.url":    {"http://example.com"},
+		"request.burst":  {"2"},
+	}))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	assert.Regexp(t, `<input name="request.burst"[^>]*value="2"`, page)
+	assert.Contains(t, page, "Sent 2 requests back to back, 1 rejected by Traefik, showing the last response:")
+	assert.Contains(t, page, `<span class="burst-response">418</span>`)
+	assert.Contains(t, page, `<span class="burst-response limited" title="Rejected by Traefik before reaching a backend">429 (limited)</span>`)
+
+	res, page = serve(handler, newFormRequest("/run", url.Values{
+		"dynamicConfig":      {"http: {}"},
+		"request.method":     {http.MethodGet},
+		"request.url":        {"http://example.com"},
+		"request.burst":      {"2"},
+		"request.concurrent": {"true"},
+	}))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	assert.Regexp(t, `<input type="checkbox"\s+name="request.concurrent"\s+value="true" checked>`, page)
+	assert.Contains(t, page, "Sent 2 requests at once, 1 rejected by Traefik, showing the last response:")
+
+	res, body := serve(handler, newFormRequest("/run/stream", url.Values{
+		"dynamicConfig":  {"http: {}"},
+		"request.method": {http.MethodGet},
+		"request.url":    {"http://example.com"},
+		"request.burst":  {"2"},
+	}))
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	assert.Contains(t, body, "requests sent in burst can&#39;t be streamed")
+}
+
+func TestApp_RunExperiment_headerChanges(t *testing.T) {
+	t.Parallel()
+
+	runner := fakeTraefik(func(_ context.Context, _ string, _ *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		return &http.Response{
+			Proto:      "HTTP/1.1",
+			StatusCode: http.StatusTeapot,
+			Body:       http.NoBody,
+		}, traefik.Report{
+			Router: "api@file",
+			HeaderChanges: []traefik.HeaderChange{
+				{Name: "X-Forwarded-For", Values: []string{"192.0.2.1"}},
+				{Name: "X-Removed", Original: []string{"original"}},
+			},
+		}, nil, nil
+	})
+	handler := newTestHandler(t, newFakeStore(), runner, app.Options{SecretKey: testSecretKey})
+
+	res, page := serve(handler, newFormRequest("/run", url.Values{
+		"dynamicConfig":  {"http: {}"},
+		"request.method": {http.MethodGet},
+		"request.url":    {"http://example.com"},
+	}))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	assert.Contains(t, page, "<summary>Request headers changed by Traefik</summary>")
+	assert.Regexp(t, `<span class="header-key">X-Forwarded-For</span>:\s+<span class="header-change-added">added</span> 192.0.2.1`, page)
+	assert.Regexp(t, `<span class="header-key">X-Removed</span>:\s+<span class="header-change-removed">removed</span> original`, page)
+}
+
+func TestApp_RunExperiment_logAnnotations(t *testing.T) {
+	t.Parallel()
+
+	runner := fakeTraefik(func(_ context.Context, _ string, _ *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		return &http.Response{
+			Proto:      "HTTP/1.1",
+			StatusCode: http.StatusBadGateway,
+			Body:       http.NoBody,
+		}, traefik.Report{Router: "api@file"}, []traefik.Log{
+			{
+				Level:   traefik.LogLevelDebug,
+				Message: "502 Bad Gateway",
+				Fields:  map[string]interface{}{"routerName": "api@file", "serviceName": "api@file"},
+			},
+		}, nil
+	})
+	handler := newTestHandler(t, newFakeStore(), runner, app.Options{SecretKey: testSecretKey})
+
+	dynamicConfig := `http:
+  routers:
+    api:
+      rule: PathPrefix(` + "`/`" + `)
+      service: api
+  services:
+    api:
+      loadBalancer:
+        servers:
+          - url: http://127.0.0.1:1
+`
+
+	res, page := serve(handler, newFormRequest("/run", url.Values{
+		"dynamicConfig":  {dynamicConfig},
+		"request.method": {http.MethodGet},
+		"request.url":    {"http://example.com"},
+	}))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	assert.Regexp(t, `<span class="field-key">routerName</span>=<a class="field-value config-link" href="#" data-start-line="3" data-end-line="5"[^>]*>api@file</a>`, page)
+	assert.Regexp(t, `<span class="field-key">serviceName</span>=<a class="field-value config-link" href="#" data-start-line="7" data-end-line="10"[^>]*>api@file</a>`, page)
+}
+
+func TestApp_SearchResponseBody(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc        string
+		body        []byte
+		search      string
+		wantMatches []experiment.TextMatch
+	}{
+		{
+			desc:        "matches",
+			body:        []byte("X-Real-Ip: 10.0.0.1\r\nX-Forwarded-For: <10.0.0.1>\r\n"),
+			search:      "10.0.0.1",
+			wantMatches: []experiment.TextMatch{{Start: 11, End: 19}, {Start: 39, End: 47}},
+		},
+		{
+			desc:        "overlapping matches",
+			body:        []byte("baaaab"),
+			search:      "aa",
+			wantMatches: []experiment.TextMatch{{Start: 1, End: 3}, {Start: 2, End: 4}, {Start: 3, End: 5}},
+		},
+		{
+			desc:        "no match",
+			body:        []byte("X-Real-Ip: 10.0.0.1"),
+			search:      "Authorization",
+			wantMatches: []experiment.TextMatch{},
+		},
+		{
+			desc:        "binary body without Content-Type",
+			body:        []byte{'a', 0x00, 0xff, 'a'},
+			search:      "a",
+			wantMatches: []experiment.TextMatch{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			var runs int
+			runner := fakeTraefik(func(_ context.Context, _ string, _ *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+				runs++
+
+				return &http.Response{
+					Proto:      "HTTP/1.1",
+					StatusCode: http.StatusTeapot,
+					Body:       io.NopCloser(bytes.NewReader(test.body)),
+				}, traefik.Report{}, nil, nil
+			})
+			handler := newTestHandler(t, newFakeStore(), runner, app.Options{SecretKey: testSecretKey})
+
+			res, page := serve(handler, newFormRequest("/run", url.Values{
+				"dynamicConfig":  {"http: {}"},
+				"request.method": {http.MethodGet},
+				"request.url":    {"http://example.com"},
+			}))
+			require.Equal(t, http.StatusOK, res.StatusCode)
+
+			res, body := serve(handler, newFormRequest("/search", url.Values{
+				"runBundle":          {extractReplayInput(t, page, "runBundle")},
+				"runBundleSignature": {extractReplayInput(t, page, "runBundleSignature")},
+				"search":             {test.search},
+			}))
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+			var got struct {
+				Matches []experiment.TextMatch `json:"matches"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(body), &got))
+			assert.Equal(t, test.wantMatches, got.Matches)
+
+			// The result of the page is searched, the experiment isn't run again.
+			assert.Equal(t, 1, runs)
+		})
+	}
+}
+
+func TestApp_SearchResponseBody_invalidBundle(t *testing.T) {
+	t.Parallel()
+
+	res, body := serve(newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey}), newFormRequest("/search", url.Values{
+		"runBundle":          {base64.StdEncoding.EncodeToString([]byte(`{}`))},
+		"runBundleSignature": {"invalid"},
+		"search":             {"foo"},
+	}))
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	assert.Contains(t, body, `"error":"Bad Request"`)
+}
+
+func TestApp_RunExperiment_binaryBody(t *testing.T) {
+	t.Parallel()
+
+	runner := fakeTraefik(func(_ context.Context, _ string, _ *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		return &http.Response{
+			Proto:      "HTTP/1.1",
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(bytes.NewReader([]byte{0x00, 0x01, 0xff})),
+		}, traefik.Report{}, nil, nil
+	})
+	handler := newTestHandler(t, newFakeStore(), runner, app.Options{SecretKey: testSecretKey})
+
+	res, page := serve(handler, newFormRequest("/run", url.Values{
+		"dynamicConfig":  {"http: {}"},
+		"request.method": {http.MethodGet},
+		"request.url":    {"http://example.com"},
+	}))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	// The body has no Content-Type, but isn't text.
+	assert.Contains(t, page, `<pre class="response-body binary" data-content-type="">00 01 ff</pre>`)
+	assert.NotContains(t, page, `<input name="search"`)
+}
+
+func TestApp_RunExperiment_fieldError(t *testing.T) {
+	t.Parallel()
+
+	res, page := serve(newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey}), newFormRequest("/run", url.Values{
+		"dynamicConfig":  {"http: {}"},
+		"request.method": {http.MethodGet},
+		"request.url":    {"not-a-url"},
+	}))
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	assert.Regexp(t, `<input name="request.url"[^>]*value="not-a-url"\s+required aria-invalid="true" />`, page)
+	assert.Contains(t, page, `<small class="field-error">url is invalid</small>`)
+	assert.NotContains(t, page, `<select name="request.method" aria-label="method" required aria-invalid="true">`)
+}
+
+func TestApp_RunExperiment_tooLarge(t *testing.T) {
+	t.Parallel()
+
+	var dynamicConfig endlessReader
+
+	body := io.MultiReader(strings.NewReader("csrfToken="+testCSRFToken+"&dynamicConfig="), &dynamicConfig)
+
+	req := httptest.NewRequest(http.MethodPost, "/run", body)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.AddCookie(&http.Cookie{Name: "csrf_token", Value: testCSRFToken})
+
+	res, content := serve(newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey}), req)
+	require.Equal(t, http.StatusRequestEntityTooLarge, res.StatusCode)
+
+	assert.Contains(t, content, "the form is too large")
+	// The body is rejected once the limit is reached, way before reading megabytes.
+	assert.Less(t, dynamicConfig.read, 1<<20)
+}
+
+// endlessReader is an io.Reader never running out of bytes, counting the number of bytes read.
+type endlessReader struct {
+	read int
+}
+
+func (r *endlessReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 'a'
+	}
+
+	r.read += len(p)
+
+	return len(p), nil
+}
+
+// newAPIRequest builds a request posting the given payload as JSON to the given API endpoint.
+func newAPIRequest(t *testing.T, target string, payload any) *http.Request {
+	t.Helper()
+
+	body, err := json.Marshal(payload)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	return req
+}
+
+func TestApp_RunExperimentAPI(t *testing.T) {
+	t.Parallel()
+
+	var (
+		gotReq      *http.Request
+		gotPriority command.Priority
+	)
+
+	runner := fakeTraefik(func(ctx context.Context, _ string, req *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		gotReq = req
+		gotPriority = command.PriorityFromContext(ctx)
+
+		return &http.Response{
+			Proto:      "HTTP/1.1",
+			StatusCode: http.StatusTeapot,
+			Header:     http.Header{"Content-Type": {"text/plain"}},
+			Body:       io.NopCloser(strings.NewReader("I'm a teapot")),
+		}, traefik.Report{Router: "api@file"}, nil, nil
+	})
+	handler := newTestHandler(t, newFakeStore(), runner, app.Options{SecretKey: testSecretKey})
+
+	res, body := serve(handler, newAPIRequest(t, "/api/run", map[string]any{
+		"dynamicConfig": "http: {}",
+		"request": map[string]any{
+			"method":  http.MethodPost,
+			"url":     "http://example.com/api",
+			"headers": map[string][]string{"X-Foo": {"bar", "baz"}},
+			"body":    "hello",
+		},
+	}))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+	require.NotNil(t, gotReq)
+	assert.Equal(t, http.MethodPost, gotReq.Method)
+	assert.Equal(t, "/api", gotReq.URL.Path)
+	assert.Equal(t, []string{"bar", "baz"}, gotReq.Header.Values("X-Foo"))
+
+	// Scripted experiments wait for those run from the UI.
+	assert.Equal(t, command.PriorityLow, gotPriority)
+
+	var got experiment.Result
+	require.NoError(t, json.Unmarshal([]byte(body), &got))
+
+	assert.True(t, got.Matched)
+	assert.Equal(t, "api@file", got.MatchedRouter)
+	assert.Equal(t, http.StatusTeapot, got.Response.StatusCode)
+	assert.Equal(t, "I'm a teapot", string(got.Response.Body))
+}
+
+func TestApp_RunExperimentAPI_errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		req         func(t *testing.T) *http.Request
+		wantStatus  int
+		wantDetails string
+		wantFields  map[string]string
+	}{
+		{
+			name: "invalid field",
+			req: func(t *testing.T) *http.Request {
+				t.Helper()
+
+				return newAPIRequest(t, "/api/run", map[string]any{
+					"dynamicConfig": "http: {}",
+					"request":       map[string]any{"method": http.MethodGet, "url": "http://example.com", "burst": -1},
+				})
+			},
+			wantStatus:  http.StatusBadRequest,
+			wantDetails: "request: burst must be between 1 and 20",
+			wantFields:  map[string]string{"burst": "burst must be between 1 and 20"},
+		},
+		{
+			name: "header value with a line break",
+			req: func(t *testing.T) *http.Request {
+				t.Helper()
+
+				return newAPIRequest(t, "/api/run", map[string]any{
+					"dynamicConfig": "http: {}",
+					"request": map[string]any{
+						"method":  http.MethodGet,
+						"url":     "http://example.com",
+						"headers": map[string][]string{"X-Foo": {"bar\r\nX-Injected: 1"}},
+					},
+				})
+			},
+			wantStatus:  http.StatusBadRequest,
+			wantDetails: `request: invalid header value for "X-Foo"`,
+			wantFields:  map[string]string{"headers": `invalid header value for "X-Foo"`},
+		},
+		{
+			name: "invalid header name",
+			req: func(t *testing.T) *http.Request {
+				t.Helper()
+
+				return newAPIRequest(t, "/api/run", map[string]any{
+					"dynamicConfig": "http: {}",
+					"request": map[string]any{
+						"method":  http.MethodGet,
+						"url":     "http://example.com",
+						"headers": map[string][]string{"X-Injected: 1\nX-Foo": {"bar"}},
+					},
+				})
+			},
+			wantStatus:  http.StatusBadRequest,
+			wantDetails: `request: invalid header name "X-Injected: 1\nX-Foo"`,
+			wantFields:  map[string]string{"headers": `invalid header name "X-Injected: 1\nX-Foo"`},
+		},
+		{
+			name: "unsupported static configuration option",
+			req: func(t *testing.T) *http.Request {
+				t.Helper()
+
+				return newAPIRequest(t, "/api/run", map[string]any{
+					"dynamicConfig": "http: {}",
+					"staticConfig":  "api:\n  insecure: true\n",
+					"request":       map[string]any{"method": http.MethodGet, "url": "http://example.com"},
+				})
+			},
+			wantStatus:  http.StatusBadRequest,
+			wantDetails: unsupportedStaticOptionErr,
+			wantFields:  map[string]string{"staticConfig": unsupportedStaticOptionErr},
+		},
+		{
+			name: "unknown field",
+			req: func(t *testing.T) *http.Request {
+				t.Helper()
+
+				return newAPIRequest(t, "/api/run", map[string]any{"dynamicConfig": "http: {}", "config": "http: {}"})
+			},
+			wantStatus:  http.StatusBadRequest,
+			wantDetails: `invalid request body: json: unknown field "config"`,
+		},
+		{
+			name: "too large",
+			req: func(t *testing.T) *http.Request {
+				t.Helper()
+
+				body := io.MultiReader(strings.NewReader(`{"dynamicConfig":"`), &endlessReader{})
+
+				req := httptest.NewRequest(http.MethodPost, "/api/run", body)
+				req.Header.Set("Content-Type", "application/json")
+
+				return req
+			},
+			wantStatus:  http.StatusRequestEntityTooLarge,
+			wantDetails: "the request body is too large (max: 125203 bytes)",
+		},
+		{
+			name: "form content type",
+			req: func(t *testing.T) *http.Request {
+				t.Helper()
+
+				return newFormRequest("/api/run", url.Values{
+					"dynamicConfig":  {"http: {}"},
+					"request.method": {http.MethodGet},
+					"request.url":    {"http://example.com"},
+				})
+			},
+			wantStatus:  http.StatusUnsupportedMediaType,
+			wantDetails: `the content type must be "application/json"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			res, body := serve(newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey}), test.req(t))
+			require.Equal(t, test.wantStatus, res.StatusCode)
+			assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+			var got struct {
+				Error   string            `json:"error"`
+				Details string            `json:"details"`
+				Fields  map[string]string `json:"fields"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(body), &got))
+
+			assert.Equal(t, http.StatusText(test.wantStatus), got.Error)
+			assert.Equal(t, test.wantDetails, got.Details)
+			assert.Equal(t, test.wantFields, got.Fields)
+		})
+	}
+}
+
+func TestApp_ShareExperimentAPI(t *testing.T) {
+	t.Parallel()
+
+	var (
+		gotReq      *http.Request
+		gotPriority command.Priority
+	)
+
+	runner := fakeTraefik(func(ctx context.Context, _ string, req *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		gotReq = req
+		gotPriority = command.PriorityFromContext(ctx)
+
+		return &http.Response{
+			Proto:      "HTTP/1.1",
+			StatusCode: http.StatusTeapot,
+			Header:     http.Header{"Content-Type": {"text/plain"}},
+			Body:       io.NopCloser(strings.NewReader("response body")),
+		}, traefik.Report{}, nil, nil
+	})
+	handler := newTestHandler(t, newFakeStore(), runner, app.Options{SecretKey: testSecretKey})
+
+	res, body := serve(handler, newAPIRequest(t, "/api/share", map[string]any{
+		"experiment": map[string]any{
+			"dynamicConfig": "http:\n  routers: {}",
+			"request": map[string]any{
+				"method":  http.MethodPut,
+				"url":     "https://example.com/foo",
+				"headers": map[string][]string{"X-Foo": {"foo"}},
+				"body":    "body",
+			},
+		},
+		"label": "teapot",
+	}))
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"id":"test-id","url":"/share/test-id"}`, body)
+
+	// The experiment is run to get a result which can be trusted, with the priority of scripted experiments.
+	require.NotNil(t, gotReq)
+	assert.Equal(t, http.MethodPut, gotReq.Method)
+	assert.Equal(t, "foo", gotReq.Header.Get("X-Foo"))
+	assert.Equal(t, command.PriorityLow, gotPriority)
+
+	res, body = serve(handler, httptest.NewRequest(http.MethodGet, "/api/share/test-id", nil))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+	var got struct {
+		Experiment experiment.Experiment `json:"experiment"`
+		Result     experiment.Result     `json:"result"`
+		Label      string                `json:"label"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &got))
+
+	assert.Equal(t, "http:\n  routers: {}\n", got.Experiment.DynamicConfig)
+	assert.Equal(t, http.MethodPut, got.Experiment.Request.Method)
+	assert.Equal(t, "https://example.com/foo", got.Experiment.Request.URL)
+	assert.Equal(t, "foo", got.Experiment.Request.Headers.Get("X-Foo"))
+	assert.Equal(t, "body", got.Experiment.Request.Body)
+	assert.Equal(t, http.StatusTeapot, got.Result.Response.StatusCode)
+	assert.Equal(t, "response body", string(got.Result.Response.Body))
+	assert.Equal(t, "teapot", got.Label)
+}
+
+func TestApp_ShareExperimentAPI_runBundle(t *testing.T) {
+	t.Parallel()
+
+	store := newFakeStore()
+	store.experiments["shared-id"] = storedExperiment{
+		exp: experiment.Experiment{
+			DynamicConfig: "http:\n  routers: {}",
+			Request:       experiment.HTTPRequest{Method: http.MethodGet, URL: "https://example.com/foo"},
+		},
+		res: experiment.Result{
+			Response: experiment.HTTPResponse{Proto: "HTTP/1.1", StatusCode: http.StatusTeapot},
+		},
+	}
+
+	handler := newTestHandler(t, store, nil, app.Options{SecretKey: testSecretKey})
+	signingHandler := newTestHandler(t, store, nil, app.Options{SecretKey: testSecretKey, SignShareURLs: true})
+
+	_, sharedPage := serve(handler, httptest.NewRequest(http.MethodGet, "/share/shared-id", nil))
+
+	res, file := serve(handler, newFormRequest("/export/json", url.Values{
+		"runBundle":          {extractReplayInput(t, sharedPage, "runBundle")},
+		"runBundleSignature": {extractReplayInput(t, sharedPage, "runBundleSignature")},
+	}))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/share", strings.NewReader(file))
+	req.Header.Set("Content-Type", "application/json")
+
+	res, body := serve(signingHandler, req)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+
+	var created struct {
+		ID  string `json:"id"`
+		URL string `json:"url"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &created))
+
+	assert.Equal(t, "test-id", created.ID)
+	assert.Regexp(t, `^/share/test-id/[\w-]+$`, created.URL)
+
+	// Share URLs are signed, the signature is required to retrieve the experiment.
+	res, _ = serve(signingHandler, httptest.NewRequest(http.MethodGet, "/api/share/test-id", nil))
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	res, body = serve(signingHandler, httptest.NewRequest(http.MethodGet, "/api"+created.URL, nil))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	var got struct {
+		Experiment experiment.Experiment `json:"experiment"`
+		Result     experiment.Result     `json:"result"`
+	}
+	require.NoError(t, json.Unmarshal([]byte(body), &got))
+
+	assert.Equal(t, store.experiments["shared-id"].exp, got.Experiment)
+	assert.Equal(t, http.StatusTeapot, got.Result.Response.StatusCode)
+}
+
+func TestApp_ShareExperimentAPI_errors(t *testing.T) {
+	t.Parallel()
+
+	validExperiment := map[string]any{
+		"dynamicConfig": "http: {}",
+		"request":       map[string]any{"method": http.MethodGet, "url": "http://example.com"},
+	}
+
+	tests := []struct {
+		name        string
+		payload     map[string]any
+		wantStatus  int
+		wantDetails string
+		wantFields  map[string]string
+	}{
+		{
+			name:        "missing experiment",
+			payload:     map[string]any{"label": "label"},
+			wantStatus:  http.StatusBadRequest,
+			wantDetails: "a run bundle or an experiment must be provided",
+		},
+		{
+			name: "result given",
+			payload: map[string]any{
+				"experiment": validExperiment,
+				"result":     map[string]any{},
+			},
+			wantStatus:  http.StatusBadRequest,
+			wantDetails: `invalid request body: json: unknown field "result"`,
+		},
+		{
+			name: "run bundle and experiment",
+			payload: map[string]any{
+				"bundle":     map[string]any{},
+				"signature":  "signature",
+				"experiment": validExperiment,
+			},
+			wantStatus:  http.StatusBadRequest,
+			wantDetails: "either a run bundle or an experiment can be shared, not both",
+		},
+		{
+			name:        "invalid signature",
+			payload:     map[string]any{"bundle": map[string]any{}, "signature": "signature"},
+			wantStatus:  http.StatusBadRequest,
+			wantDetails: "invalid response signature",
+		},
+		{
+			name: "invalid experiment",
+			payload: map[string]any{
+				"experiment": map[string]any{
+					"dynamicConfig": "http: {}",
+					"request":       map[string]any{"method": http.MethodGet, "url": "ftp://example.com"},
+				},
+			},
+			wantStatus:  http.StatusBadRequest,
+			wantDetails: "request: url scheme must be one of: http, https",
+			wantFields:  map[string]string{"url": "url scheme must be one of: http, https"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			store := newFakeStore()
+
+			res, body := serve(newTestHandler(t, store, nil, app.Options{SecretKey: testSecretKey}), newAPIRequest(t, "/api/share", test.payload))
+			require.Equal(t, test.wantStatus, res.StatusCode)
+
+			var got struct {
+				Details string            `json:"details"`
+				Fields  map[string]string `json:"fields"`
+			}
+			require.NoError(t, json.Unmarshal([]byte(body), &got))
+
+			assert.Equal(t, test.wantDetails, got.Details)
+			assert.Equal(t, test.wantFields, got.Fields)
+			assert.Empty(t, store.experiments)
+		})
+	}
+}
+
+func TestApp_SharedExperimentAPI_notFound(t *testing.T) {
+	t.Parallel()
+
+	res, body := serve(newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey}), httptest.NewRequest(http.MethodGet, "/api/share/unknown-id", nil))
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"error":"Not Found","details":"unable to find experiment"}`, body)
+}
+
+func TestApp_jsonErrors_prefersHTML(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "/share/unknown", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,application/json;q=0.9")
+
+	res, page := serve(newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey}), req)
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	assert.NotEqual(t, "application/json", res.Header.Get("Content-Type"))
+	assert.Contains(t, page, "unable to find experiment")
+}
+
+func TestApp_Middlewares(t *testing.T) {
+	t.Parallel()
+
+	res, body := serve(newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey}), httptest.NewRequest(http.MethodGet, "/middlewares", nil))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+	var middlewares []traefik.MiddlewareSchema
+	require.NoError(t, json.Unmarshal([]byte(body), &middlewares))
+
+	byName := make(map[string]traefik.MiddlewareSchema)
+	for _, middleware := range middlewares {
+		byName[middleware.Name] = middleware
+	}
+
+	require.Contains(t, byName, "stripPrefix")
+	assert.Contains(t, byName["stripPrefix"].Fields, traefik.FieldSchema{Name: "prefixes", Type: "array<string>"})
+
+	require.Contains(t, byName, "rateLimit")
+	assert.Contains(t, byName["rateLimit"].Fields, traefik.FieldSchema{Name: "average", Type: "integer"})
+	assert.Contains(t, byName["rateLimit"].Fields, traefik.FieldSchema{Name: "period", Type: "duration"})
+	assert.Contains(t, byName["rateLimit"].Fields, traefik.FieldSchema{Name: "burst", Type: "integer"})
+
+	// Gateway API filters can't be declared in the YAML dynamic configuration.
+	assert.NotContains(t, byName, "requestHeaderModifier")
+}
+
+func TestApp_HeaderPresets(t *testing.T) {
+	t.Parallel()
+
+	res, body := serve(newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey}), httptest.NewRequest(http.MethodGet, "/header-presets", nil))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+	var presets []experiment.HeaderPreset
+	require.NoError(t, json.Unmarshal([]byte(body), &presets))
+	assert.Equal(t, experiment.HeaderPresets(), presets)
+}
+
+func TestApp_DynamicConfigSchema(t *testing.T) {
+	t.Parallel()
+
+	res, body := serve(newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey}), httptest.NewRequest(http.MethodGet, "/traefik-v3.schema.json", nil))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/schema+json", res.Header.Get("Content-Type"))
+
+	assert.JSONEq(t, string(experiment.DynamicConfigSchema()), body)
+}
+
+func TestApp_Version(t *testing.T) {
+	t.Parallel()
+
+	res, body := serve(newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey}), httptest.NewRequest(http.MethodGet, "/version", nil))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+	var got map[string]string
+	require.NoError(t, json.Unmarshal([]byte(body), &got))
+	assert.Equal(t, map[string]string{
+		"version":        "dev",
+		"traefikVersion": version.TraefikVersion,
+		"goVersion":      runtime.Version(),
+	}, got)
+
+	res, page := serve(newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey}), httptest.NewRequest(http.MethodGet, "/info", nil))
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Contains(t, page, "<strong>Traefik:</strong> <code>"+version.TraefikVersion+"</code>")
+}
+
+func TestApp_preferredLang(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc           string
+		acceptLanguage []string
+		want           string
+	}{
+		{desc: "no header", want: "en"},
+		{desc: "single language", acceptLanguage: []string{"fr"}, want: "fr"},
+		{desc: "regional variant", acceptLanguage: []string{"fr-CH, fr;q=0.9, en;q=0.8"}, want: "fr-CH"},
+		{desc: "sorted by quality", acceptLanguage: []string{"en;q=0.5, de"}, want: "de"},
+		{desc: "refused language", acceptLanguage: []string{"fr;q=0, de;q=0.5"}, want: "de"},
+		{desc: "several headers", acceptLanguage: []string{"en;q=0.5", "ja"}, want: "ja"},
+		{desc: "any language", acceptLanguage: []string{"*"}, want: "en"},
+		{desc: "any language before a specific one", acceptLanguage: []string{"*, es;q=0.5"}, want: "es"},
+		{desc: "invalid header", acceptLanguage: []string{"not a language!"}, want: "en"},
+	}
+
+	handler := newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey})
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/info", nil)
+			for _, value := range test.acceptLanguage {
+				req.Header.Add("Accept-Language", value)
+			}
+
+			res, page := serve(handler, req)
+			require.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Contains(t, page, `<html lang="en" data-preferred-lang="`+test.want+`">`)
+		})
+	}
+}
+
+func TestApp_TokenizeConfig(t *testing.T) {
+	t.Parallel()
+
+	handler := newTestHandler(t, newFakeStore(), nil, app.Options{SecretKey: testSecretKey})
+
+	req := newFormRequest("/tokenize", url.Values{
+		"dynamicConfig": {"http:\n  routers:\n    api:\n      service: whoami # The service\n"},
+	})
+	req.Header.Set("Accept", "application/json")
+
+	res, body := serve(handler, req)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "application/json", res.Header.Get("Content-Type"))
+
+	var tree traefik.ConfigNode
+	require.NoError(t, json.Unmarshal([]byte(body), &tree))
+
+	service := tree.Children[0].Children[0].Children[0].Children[0]
+	assert.Equal(t, traefik.ConfigNode{
+		Key:         "service",
+		KeyPosition: &traefik.Position{Line: 4, Column: 7},
+		Kind:        "scalar",
+		Tag:         "str",
+		Type:        "string",
+		Value:       "whoami",
+		Position:    traefik.Position{Line: 4, Column: 16},
+		LineComment: "# The service",
+	}, service)
+
+	req = newFormRequest("/tokenize", url.Values{"dynamicConfig": {"http: ["}})
+	req.Header.Set("Accept", "application/json")
+
+	res, body = serve(handler, req)
+	require.Equal(t, http.StatusBadRequest, res.StatusCode)
+	assert.Contains(t, body, `"fields":{"dynamicConfig":"invalid dynamic configuration: parsing YAML: yaml: line 1: did not find expected node content"}`)
+}
+
+// extractReplayInput extracts the value of the given input of the replay form.
+func extractReplayInput(t *testing.T, page, name string) string {
+	t.Helper()
+
+	_, replayForm, ok := strings.Cut(page, `<form id="replay"`)
+	require.True(t, ok)
+
+	matches := regexp.MustCompile(`name="` + name + `" value="([^"]*)"`).FindStringSubmatch(replayForm)
+	require.Len(t, matches, 2)
+
+	return html.UnescapeString(matches[1])
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+const (
+	csrfCookieName = "csrf_token"
+	csrfFieldName  = "csrfToken"
+	csrfTokenSize  = 32
+
+	// maxMultipartMemory is the maximum size of a multipart form kept in memory, the rest is stored on disk.
+	maxMultipartMemory = 32 << 20
+)
+
+type csrfTokenKey struct{}
+
+// protectCSRF protects the given handler against cross-site request forgery using the double-submit cookie
+// pattern. A random token is issued in a cookie and must be sent back in the csrfToken field of the forms.
+// Requests with a safe method are never rejected.
+func (a *App) protectCSRF(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		token, ok := csrfTokenFromCookie(req)
+		if !ok {
+			var err error
+			if token, err = newCSRFToken(); err != nil {
+				http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+				return
+			}
+
+			http.SetCookie(rw, &http.Cookie{
+				Name:     csrfCookieName,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   req.TLS != nil,
+				SameSite: http.SameSiteLaxMode,
+			})
+		}
+
+		req = req.WithContext(context.WithValue(req.Context(), csrfTokenKey{}, token))
+
+		if req.Method == http.MethodPost {
+			// Forms are parsed before reaching the handler, make sure the body size is limited. Routes can set a
+			// lower limit with limitBody.
+			req.Body = http.MaxBytesReader(rw, req.Body, maxFormSize)
+
+			var maxBytesErr *http.MaxBytesError
+			if err := parseForm(req); errors.As(err, &maxBytesErr) {
+				err = fmt.Errorf("the form is too large (max: %d bytes)", maxBytesErr.Limit)
+
+				a.respondError(rw, req, http.StatusRequestEntityTooLarge, err, experimentTemplateData{
+					DynamicConfig: a.defaultDynamicConfig,
+				})
+
+				return
+			}
+
+			if !ok || !validCSRFToken(token, req.PostFormValue(csrfFieldName)) {
+				err := errors.New("the form has expired, please reload the page and retry")
+
+				a.respondError(rw, req, http.StatusForbidden, err, experimentTemplateData{
+					DynamicConfig: a.defaultDynamicConfig,
+				})
+
+				return
+			}
+		}
+
+		next.ServeHTTP(rw, req)
+	})
+}
+
+// parseForm parses the form of the given request, whether it's URL-encoded or multipart.
+func parseForm(req *http.Request) error {
+	if err := req.ParseForm(); err != nil {
+		return err
+	}
+
+	err := req.ParseMultipartForm(maxMultipartMemory)
+	if errors.Is(err, http.ErrNotMultipart) {
+		return nil
+	}
+
+	return err
+}
+
+func csrfTokenFromCookie(req *http.Request) (string, bool) {
+	cookie, err := req.Cookie(csrfCookieName)
+	if err != nil {
+		return "", false
+	}
+
+	decoded, err := base64.RawURLEncoding.DecodeString(cookie.Value)
+	if err != nil || len(decoded) != csrfTokenSize {
+		return "", false
+	}
+
+	return cookie.Value, true
+}
+
+func newCSRFToken() (string, error) {
+	token := make([]byte, csrfTokenSize)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+func validCSRFToken(want, got string) bool {
+	return got != "" && subtle.ConstantTimeCompare([]byte(want), []byte(got)) == 1
+}
+
+// csrfToken returns the CSRF token issued for the request the given context belongs to.
+func csrfToken(ctx context.Context) string {
+	token, _ := ctx.Value(csrfTokenKey{}).(string)
+
+	return token
+}
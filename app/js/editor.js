@@ -8,8 +8,7 @@ import { tags as t } from "@lezer/highlight";
 import {Kind, parseWithPointers} from "@stoplight/yaml";
 import AJV from "ajv"
 import betterAjvErrors from "better-ajv-errors";
-
-import traefikSchema from "./traefik-v3.schema.json"
+import {enhanceMiddlewarePicker} from "./middlewares.js";
 
 export function enhanceEditor(originalEditor) {
     const theme = EditorView.theme({
@@ -94,7 +93,7 @@ export function enhanceEditor(originalEditor) {
             theme,
             style,
             yaml(),
-            linter(yamlSchemaLinter(traefikSchema), {}),
+            linter(yamlSchemaLinter(fetchSchema()), {}),
             lintGutter({}),
             EditorView.lineWrapping,
             keymap.of(defaultKeymap),
@@ -116,13 +115,49 @@ export function enhanceEditor(originalEditor) {
             originalEditor.value = editorView.state.doc.toString();
         }
     })(editorView.dispatch);
+
+    enhanceConfigLinks(editorView);
+    enhanceMiddlewarePicker(editorView, editorContainer);
 }
 
-function yamlSchemaLinter(schema) {
-    const ajv = new AJV()
-    const validate = ajv.compile(schema)
+// enhanceConfigLinks makes the log fields naming a router or a service select their definition in the editor.
+function enhanceConfigLinks(editorView) {
+    for (let link of document.querySelectorAll(".config-link")) {
+        link.addEventListener("click", (e) => {
+            e.preventDefault();
+
+            const doc = editorView.state.doc;
+
+            // The configuration may have been edited since it ran.
+            const startLine = Number(link.dataset.startLine);
+            const endLine = Math.min(Number(link.dataset.endLine), doc.lines);
+            if (!startLine || startLine > doc.lines) {
+                return;
+            }
 
-    return (view) => {
+            editorView.dispatch({
+                selection: {anchor: doc.line(startLine).from, head: doc.line(endLine).to},
+                scrollIntoView: true,
+            });
+            editorView.focus();
+        });
+    }
+}
+
+// fetchSchema loads the JSON schema of the dynamic configuration the server validates experiments against.
+async function fetchSchema() {
+    const res = await fetch("/traefik-v3.schema.json", {headers: {"Accept": "application/schema+json"}});
+    if (!res.ok) {
+        throw new Error(`Unable to load the dynamic configuration schema: ${res.status}`);
+    }
+
+    return res.json();
+}
+
+function yamlSchemaLinter(schemaPromise) {
+    const compiled = schemaPromise.then(schema => ({schema, validate: new AJV().compile(schema)}));
+
+    return async (view) => {
         const text = view.state.doc.toString();
         if (!text) {
             return [];
@@ -146,6 +181,14 @@ function yamlSchemaLinter(schema) {
             return diagnostics
         }
 
+        let schema, validate;
+        try {
+            ({schema, validate} = await compiled);
+        } catch (err) {
+            console.error(err);
+            return [];
+        }
+
         if (!validate(parsed.data) && validate.errors) {
             const output = betterAjvErrors(schema, parsed.data, validate.errors, {format: "js"});
 
@@ -1,24 +1,90 @@
 export function enhanceHeaderInput(originalInput) {
     const container = document.createElement("div");
 
-    const value = originalInput.value || "";
-    const lines = value.split("\n");
+    renderHeaders(originalInput, container);
 
-    for (let line of lines) {
-        const parts = line.split(":");
+    originalInput.parentNode.appendChild(container);
+
+    enhanceHeaderPresets(originalInput, container);
+}
+
+function renderHeaders(originalInput, container) {
+    container.replaceChildren();
 
-        if (parts.length !== 2) {
+    for (let [name, value] of parseHeaders(originalInput.value || "")) {
+        addHeader(originalInput, container, name, value);
+    }
+
+    addHeader(originalInput, container);
+}
+
+function parseHeaders(value) {
+    const headers = [];
+
+    for (let line of value.split("\n")) {
+        // Split on the first colon only, values may hold some.
+        const index = line.indexOf(":");
+        if (index === -1) {
             continue
         }
 
-        addHeader(originalInput, container,
-            parts[0].trim(),
-            parts[1].trim())
+        headers.push([line.slice(0, index).trim(), line.slice(index + 1).trim()]);
     }
 
-    addHeader(originalInput, container);
+    return headers;
+}
 
-    originalInput.parentNode.appendChild(container);
+async function enhanceHeaderPresets(originalInput, container) {
+    let presets;
+    try {
+        const res = await fetch("/header-presets", {headers: {"Accept": "application/json"}});
+        if (!res.ok) {
+            return;
+        }
+
+        presets = await res.json();
+    } catch {
+        return;
+    }
+
+    const select = document.createElement("select");
+    select.setAttribute("aria-label", "header presets");
+
+    const placeholder = document.createElement("option");
+    placeholder.value = "";
+    placeholder.textContent = "Add headers from a preset...";
+    select.appendChild(placeholder);
+
+    presets.forEach((preset, i) => {
+        const option = document.createElement("option");
+        option.value = `${i}`;
+        option.textContent = preset.name;
+        option.title = preset.description;
+        select.appendChild(option);
+    });
+
+    select.addEventListener("change", () => {
+        const preset = presets[select.value];
+        select.value = "";
+
+        if (!preset) {
+            return;
+        }
+
+        // Headers of the preset replace the existing ones with the same name.
+        const presetHeaders = parseHeaders(preset.headers);
+        const presetNames = new Set(presetHeaders.map(([name]) => name.toLowerCase()));
+
+        const headers = parseHeaders(originalInput.value || "")
+            .filter(([name, value]) => (name !== "" || value !== "") && !presetNames.has(name.toLowerCase()))
+            .concat(presetHeaders);
+
+        originalInput.value = headers.map(([name, value]) => `${name}: ${value}`).join("\n");
+
+        renderHeaders(originalInput, container);
+    });
+
+    container.before(select);
 }
 
 function removeHeader(originalInput, header) {
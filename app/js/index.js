@@ -1,6 +1,7 @@
 import {enhanceEditor} from "./editor.js";
 import {enhanceHeaderInput} from "./header.js";
 import {enhanceResizablePanels} from "./resize.js";
+import {enhanceBodySearch} from "./search.js";
 
 const dynamicConfigTextarea = document.getElementById("dynamic-config-editor")
 if (dynamicConfigTextarea) {
@@ -12,4 +13,9 @@ if (headerTextarea) {
     enhanceHeaderInput(headerTextarea);
 }
 
+const searchForm = document.getElementById("search")
+if (searchForm) {
+    enhanceBodySearch(searchForm);
+}
+
 enhanceResizablePanels();
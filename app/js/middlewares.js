@@ -0,0 +1,99 @@
+// enhanceMiddlewarePicker adds a picker inserting the options of a middleware at the cursor of the editor.
+export async function enhanceMiddlewarePicker(editorView, container) {
+    let middlewares;
+    try {
+        const res = await fetch("/middlewares", {headers: {"Accept": "application/json"}});
+        if (!res.ok) {
+            return;
+        }
+
+        middlewares = await res.json();
+    } catch {
+        return;
+    }
+
+    const select = document.createElement("select");
+    select.className = "middleware-picker";
+    select.setAttribute("aria-label", "middlewares");
+
+    const placeholder = document.createElement("option");
+    placeholder.value = "";
+    placeholder.textContent = "Insert a middleware...";
+    select.appendChild(placeholder);
+
+    middlewares.forEach((middleware, i) => {
+        const option = document.createElement("option");
+        option.value = `${i}`;
+        option.textContent = middleware.name;
+        option.title = middleware.fields.map(field => `${field.name}: ${field.type}`).join("\n");
+        select.appendChild(option);
+    });
+
+    select.addEventListener("change", () => {
+        const middleware = middlewares[select.value];
+        select.value = "";
+
+        if (!middleware) {
+            return;
+        }
+
+        // The snippet is indented like the line holding the cursor.
+        const cursor = editorView.state.selection.main;
+        const line = editorView.state.doc.lineAt(cursor.from);
+        const indent = line.text.match(/^\s*/)[0];
+
+        const snippet = renderMiddleware(middleware, indent);
+        const insert = line.text.trim() === "" ? snippet : `\n${indent}${snippet}`;
+        const from = line.text.trim() === "" ? line.from : line.to;
+
+        editorView.dispatch({
+            changes: {from, to: line.to, insert},
+            selection: {anchor: from + insert.length},
+            scrollIntoView: true,
+        });
+        editorView.focus();
+    });
+
+    container.before(select);
+}
+
+// renderMiddleware renders the YAML declaration of a middleware with its options left empty.
+function renderMiddleware(middleware, indent) {
+    const lines = [`${indent}${middleware.name}:`];
+    renderFields(middleware.fields, `${indent}  `, lines);
+
+    return lines.join("\n");
+}
+
+function renderFields(fields, indent, lines) {
+    for (let field of fields || []) {
+        if (field.type === "object" && field.fields?.length) {
+            lines.push(`${indent}${field.name}:`);
+            renderFields(field.fields, `${indent}  `, lines);
+
+            continue;
+        }
+
+        lines.push(`${indent}${field.name}: ${placeholderValue(field.type)}`);
+    }
+}
+
+// placeholderValue returns the empty YAML value of the given field type.
+function placeholderValue(type) {
+    if (type.startsWith("array<")) {
+        return "[]";
+    }
+    if (type.startsWith("map<") || type === "object") {
+        return "{}";
+    }
+
+    switch (type) {
+        case "boolean":
+            return "false";
+        case "integer":
+        case "number":
+            return "0";
+        default:
+            return '""';
+    }
+}
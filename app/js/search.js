@@ -0,0 +1,65 @@
+export function enhanceBodySearch(form) {
+    const body = document.querySelector("pre.response-body:not(.binary)");
+    const count = document.querySelector(".body-search-count");
+    if (!body || !count) {
+        return;
+    }
+
+    // Matches are byte offsets in the body, as sent by the server.
+    const bytes = new TextEncoder().encode(body.textContent);
+
+    form.addEventListener("submit", async (event) => {
+        event.preventDefault();
+
+        let matches;
+        try {
+            const res = await fetch(form.action, {
+                method: "POST",
+                headers: {"Accept": "application/json"},
+                body: new URLSearchParams(new FormData(form)),
+            });
+            if (!res.ok) {
+                return;
+            }
+
+            ({matches} = await res.json());
+        } catch {
+            return;
+        }
+
+        highlightMatches(body, bytes, matches);
+
+        count.textContent = `${matches.length} match(es)`;
+        count.hidden = false;
+    });
+}
+
+function highlightMatches(body, bytes, matches) {
+    const decoder = new TextDecoder();
+    const nodes = [];
+
+    let offset = 0;
+    for (let i = 0; i < matches.length;) {
+        // Overlapping and adjacent matches are highlighted as one.
+        let {start, end} = matches[i];
+        for (i++; i < matches.length && matches[i].start <= end; i++) {
+            end = Math.max(end, matches[i].end);
+        }
+
+        if (start > offset) {
+            nodes.push(document.createTextNode(decoder.decode(bytes.subarray(offset, start))));
+        }
+
+        const mark = document.createElement("mark");
+        mark.textContent = decoder.decode(bytes.subarray(start, end));
+        nodes.push(mark);
+
+        offset = end;
+    }
+
+    if (offset < bytes.length) {
+        nodes.push(document.createTextNode(decoder.decode(bytes.subarray(offset))));
+    }
+
+    body.replaceChildren(...nodes);
+}
@@ -0,0 +1,143 @@
+package app
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/jspdown/traefik-playground/internal/experiment"
+)
+
+// SharedCache caches the shared experiments along with their signed run bundle, so that a popular share URL
+// neither hits the store nor marshals the run bundle on every view. It holds a bounded number of shared
+// experiments for a limited time, when full, the least recently viewed one is evicted.
+//
+// A shared experiment is cached once, under the public ID it's stored under. The other IDs it's retrieved with, such
+// as its short code, are aliases of this public ID.
+//
+// Experiments are deleted from the store by the cleanup command, which runs in another process and can't
+// invalidate the cache: the TTL bounds how long a deleted experiment is still served.
+type SharedCache struct {
+	size int
+	ttl  time.Duration
+
+	mu sync.Mutex
+	// entries indexes the shared experiments by public ID.
+	entries map[string]*list.Element
+	// aliases maps the other IDs of the cached shared experiments to their public ID.
+	aliases map[string]string
+	order   *list.List
+}
+
+// sharedPage is a shared experiment, ready to be rendered.
+type sharedPage struct {
+	exp             experiment.Experiment
+	res             experiment.Result
+	bundle          string
+	bundleSignature string
+}
+
+type sharedCacheEntry struct {
+	publicID  string
+	aliases   []string
+	page      sharedPage
+	expiresAt time.Time
+}
+
+// NewSharedCache creates a new SharedCache holding at most size shared experiments, each for the given TTL.
+// A size or a TTL of zero disables caching.
+func NewSharedCache(size int, ttl time.Duration) *SharedCache {
+	return &SharedCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		aliases: make(map[string]string),
+		order:   list.New(),
+	}
+}
+
+// get returns the shared experiment cached under the given ID, its public ID or one of its aliases, if any and not
+// expired.
+func (c *SharedCache) get(id string) (sharedPage, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if publicID, ok := c.aliases[id]; ok {
+		id = publicID
+	}
+
+	elem, ok := c.entries[id]
+	if !ok {
+		return sharedPage{}, false
+	}
+
+	entry := sharedEntryOf(elem)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(elem)
+
+		return sharedPage{}, false
+	}
+
+	c.order.MoveToFront(elem)
+
+	return entry.page, true
+}
+
+// add caches the given shared experiment, retrieved with the given ID, under its public ID. The given ID becomes an
+// alias of the public ID when they differ.
+func (c *SharedCache) add(id string, page sharedPage) {
+	if c.size <= 0 || c.ttl <= 0 {
+		return
+	}
+
+	publicID := page.exp.ID
+	if publicID == "" {
+		publicID = id
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+
+	elem, ok := c.entries[publicID]
+	if ok {
+		entry := sharedEntryOf(elem)
+		entry.page = page
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+	} else {
+		elem = c.order.PushFront(&sharedCacheEntry{
+			publicID:  publicID,
+			page:      page,
+			expiresAt: expiresAt,
+		})
+		c.entries[publicID] = elem
+	}
+
+	if _, ok = c.aliases[id]; id != publicID && !ok {
+		c.aliases[id] = publicID
+
+		entry := sharedEntryOf(elem)
+		entry.aliases = append(entry.aliases, id)
+	}
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *SharedCache) remove(elem *list.Element) {
+	entry := sharedEntryOf(elem)
+
+	c.order.Remove(elem)
+	delete(c.entries, entry.publicID)
+
+	for _, alias := range entry.aliases {
+		delete(c.aliases, alias)
+	}
+}
+
+func sharedEntryOf(elem *list.Element) *sharedCacheEntry {
+	return elem.Value.(*sharedCacheEntry) //nolint:forcetypeassert // Only entries are stored in the list.
+}
@@ -0,0 +1,114 @@
+package cleanup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/ettle/strcase"
+	"github.com/jspdown/traefik-playground/internal/database"
+	"github.com/jspdown/traefik-playground/internal/experiment"
+	"github.com/jspdown/traefik-playground/internal/logger"
+	"github.com/rs/zerolog/log"
+	"github.com/urfave/cli/v3"
+)
+
+const (
+	flagLogLevel           = "log-level"
+	flagLogFormat          = "log-format"
+	flagDatabaseConnString = "db"
+	flagOlderThan          = "older-than"
+	flagClientIP           = "client-ip"
+	flagClientIPSalt       = "client-ip-salt"
+)
+
+// NewCommand creates the cleanup CLI command.
+func NewCommand() *cli.Command {
+	return &cli.Command{
+		Name:  "cleanup",
+		Usage: "Deletes shared experiments older than a given age or shared from a given client IP",
+		Flags: []cli.Flag{
+			&cli.StringFlag{
+				Name:  flagLogLevel,
+				Usage: "Log level (debug, info, error)",
+				Value: "info",
+			},
+			&cli.StringFlag{
+				Name:  flagLogFormat,
+				Usage: "Log format (console, json)",
+				Value: "json",
+			},
+			&cli.StringFlag{
+				Name:     flagDatabaseConnString,
+				Usage:    "Database connection string to a PostgreSQL database, or sqlite://<path> to a SQLite database",
+				Sources:  cli.EnvVars(strcase.ToSNAKE(flagDatabaseConnString)),
+				Required: true,
+			},
+			&cli.DurationFlag{
+				Name:  flagOlderThan,
+				Usage: "Delete the experiments shared for longer than this duration",
+			},
+			&cli.StringFlag{
+				Name:  flagClientIP,
+				Usage: "Delete the experiments shared from this client IP",
+			},
+			&cli.StringFlag{
+				Name:    flagClientIPSalt,
+				Usage:   "Salt of the hash stored instead of the client IPs, as configured on the server",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagClientIPSalt)),
+			},
+		},
+		Action: func(ctx context.Context, cmd *cli.Command) error {
+			if err := logger.Configure(cmd.String(flagLogLevel), cmd.String(flagLogFormat)); err != nil {
+				return err
+			}
+
+			olderThan := cmd.Duration(flagOlderThan)
+			clientIP := cmd.String(flagClientIP)
+
+			if olderThan == 0 && clientIP == "" {
+				return errors.New("at least one of older-than or client-ip must be provided")
+			}
+			if olderThan < 0 {
+				return errors.New("older-than must not be negative")
+			}
+			if clientIP != "" && net.ParseIP(clientIP) == nil {
+				return fmt.Errorf("invalid client-ip %q", clientIP)
+			}
+
+			db, dialect, err := database.Open(cmd.String(flagDatabaseConnString))
+			if err != nil {
+				return fmt.Errorf("opening database connection: %w", err)
+			}
+
+			defer func() { _ = db.Close() }()
+
+			store := experiment.NewStore(db, experiment.StoreConfig{
+				Dialect:      dialect,
+				ClientIPSalt: cmd.String(flagClientIPSalt),
+			})
+
+			if olderThan > 0 {
+				deleted, err := store.DeleteExpired(ctx, time.Now().Add(-olderThan))
+				if err != nil {
+					return err
+				}
+
+				log.Info().Int64("deleted", deleted).Stringer("olderThan", olderThan).Msg("Deleted expired experiments")
+			}
+
+			if clientIP != "" {
+				deleted, err := store.Delete(ctx, clientIP)
+				if err != nil {
+					return err
+				}
+
+				log.Info().Int64("deleted", deleted).Str("clientIP", clientIP).Msg("Deleted experiments shared from client IP")
+			}
+
+			return nil
+		},
+	}
+}
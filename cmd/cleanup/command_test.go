@@ -0,0 +1,164 @@
+package cleanup
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/jspdown/traefik-playground/db/migrations"
+	"github.com/jspdown/traefik-playground/internal/database"
+	"github.com/jspdown/traefik-playground/internal/experiment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+//nolint:paralleltest // The command configures the global logger.
+func TestCleanup(t *testing.T) {
+	backends := []struct {
+		name  string
+		setup func(t *testing.T) string
+	}{
+		{name: "postgres", setup: setupTestDB},
+		{name: "sqlite", setup: setupSQLiteTestDB},
+	}
+
+	for _, backend := range backends {
+		t.Run(backend.name, func(t *testing.T) {
+			connString := backend.setup(t)
+
+			db, dialect, err := database.Open(connString)
+			require.NoError(t, err)
+
+			t.Cleanup(func() { _ = db.Close() })
+
+			store := experiment.NewStore(db, experiment.StoreConfig{Dialect: dialect})
+			ctx := context.Background()
+
+			save := func(url, clientIP string) string {
+				t.Helper()
+
+				publicID, err := store.Save(ctx, experiment.Experiment{
+					DynamicConfig: "dynamicConfig",
+					Request: experiment.HTTPRequest{
+						Method: http.MethodGet,
+						URL:    url,
+					},
+				}, experiment.Result{}, clientIP, "")
+				require.NoError(t, err)
+
+				return publicID
+			}
+
+			oldID := save("https://example.com/old", "127.0.0.1")
+			abusiveID := save("https://example.com/abusive", "127.0.0.2")
+			recentID := save("https://example.com/recent", "127.0.0.1")
+
+			_, err = db.ExecContext(ctx, `UPDATE shared_experiments SET created_at = '2000-01-01 00:00:00' WHERE public_id = $1`, oldID)
+			require.NoError(t, err)
+
+			err = NewCommand().Run(ctx, []string{"cleanup", "--db", connString, "--older-than", "24h", "--client-ip", "127.0.0.2"})
+			require.NoError(t, err)
+
+			for _, id := range []string{oldID, abusiveID} {
+				_, _, err = store.Get(ctx, id)
+				require.ErrorIs(t, err, experiment.ErrNotFound)
+			}
+
+			_, _, err = store.Get(ctx, recentID)
+			require.NoError(t, err)
+		})
+	}
+}
+
+//nolint:paralleltest // The command configures the global logger.
+func TestCleanup_invalidFlags(t *testing.T) {
+	tests := []struct {
+		desc    string
+		args    []string
+		wantErr string
+	}{
+		{
+			desc:    "no filter",
+			args:    []string{},
+			wantErr: "at least one of older-than or client-ip must be provided",
+		},
+		{
+			desc:    "negative age",
+			args:    []string{"--older-than", "-1h"},
+			wantErr: "older-than must not be negative",
+		},
+		{
+			desc:    "invalid client IP",
+			args:    []string{"--client-ip", "localhost"},
+			wantErr: `invalid client-ip "localhost"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			args := append([]string{"cleanup", "--db", "postgres://localhost/unused"}, test.args...)
+
+			err := NewCommand().Run(context.Background(), args)
+			assert.EqualError(t, err, test.wantErr)
+		})
+	}
+}
+
+// setupSQLiteTestDB initializes a SQLite test database in a temporary directory, and returns its connection string.
+func setupSQLiteTestDB(t *testing.T) string {
+	t.Helper()
+
+	connString := "sqlite://" + filepath.Join(t.TempDir(), "test.db")
+
+	db, dialect, err := database.Open(connString)
+	require.NoError(t, err)
+
+	defer func() { _ = db.Close() }()
+
+	require.NoError(t, database.Migrate(db, dialect))
+
+	return connString
+}
+
+// setupTestDB initializes a PostgreSQL test database inside a container, and returns its connection string.
+func setupTestDB(t *testing.T) string {
+	t.Helper()
+
+	pgContainer, err := postgres.Run(context.Background(), "postgres:16",
+		postgres.WithDatabase("testdb"),
+		postgres.WithUsername("testuser"),
+		postgres.WithPassword("testpass"),
+
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").WithOccurrence(2),
+			wait.ForListeningPort("5432/tcp")),
+	)
+	if err != nil {
+		t.Fatalf("failed to start PostgreSQL container: %v", err)
+	}
+
+	t.Cleanup(func() {
+		require.NoError(t, pgContainer.Terminate(context.Background()))
+	})
+
+	dsn, err := pgContainer.ConnectionString(context.Background(), "sslmode=disable")
+	if err != nil {
+		t.Fatalf("failed to get database connection string: %v", err)
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatalf("failed to connect to test database: %v", err)
+	}
+
+	defer func() { _ = db.Close() }()
+
+	require.NoError(t, migrations.Migrate(db))
+
+	return dsn
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/signal"
 
+	"github.com/jspdown/traefik-playground/cmd/cleanup"
 	"github.com/jspdown/traefik-playground/cmd/server"
 	"github.com/jspdown/traefik-playground/cmd/tester"
 	"github.com/rs/zerolog/log"
@@ -18,6 +19,7 @@ func main() {
 		Commands: []*cli.Command{
 			server.NewCommand(),
 			tester.NewCommand(),
+			cleanup.NewCommand(),
 		},
 	}
 
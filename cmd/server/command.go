@@ -6,18 +6,54 @@ import (
 
 	"github.com/ettle/strcase"
 	"github.com/jspdown/traefik-playground/internal/logger"
+	"github.com/jspdown/traefik-playground/internal/traefik"
 	"github.com/urfave/cli/v3"
 )
 
 const (
 	flagAddr               = "addr"
+	flagReadTimeout        = "read-timeout"
+	flagIdleTimeout        = "idle-timeout"
 	flagLogLevel           = "log-level"
 	flagLogFormat          = "log-format"
 	flagDatabaseConnString = "db"
+	flagDBMaxOpenConns     = "db-max-open-conns"
+	flagMemoryStoreSize    = "memory-store-size"
+	flagDBMaxIdleConns     = "db-max-idle-conns"
+	flagDBConnMaxLifetime  = "db-conn-max-lifetime"
+	flagDBMaxRetries       = "db-max-retries"
+	flagClientIPSalt       = "client-ip-salt"
 	flagSecretKey          = "secret-key"
+	flagOldSecretKey       = "old-secret-key"
+	flagSignShareURLs      = "sign-share-urls"
+	flagShortCodes         = "short-codes"
+	flagDebugToken         = "debug-token"
+	flagDefaultConfig      = "default-config"
 	flagTesterTimeout      = "tester-timeout"
+	flagMaxRunDuration     = "max-run-duration"
 	flagMaxProcesses       = "max-processes"
 	flagMaxPendingCommands = "max-pending-commands"
+	flagNoiseLogPrefixes   = "noise-log-prefixes"
+	flagMaxLogSize         = "max-log-size"
+	flagResultCacheSize    = "result-cache-size"
+	flagResultCacheTTL     = "result-cache-ttl"
+	flagSharedCacheSize    = "shared-cache-size"
+	flagSharedCacheTTL     = "shared-cache-ttl"
+	flagMaxRunsPerClient   = "max-runs-per-client"
+	flagMaxStreamSize      = "max-stream-size"
+	flagMaxRouters         = "max-routers"
+	flagMaxServices        = "max-services"
+	flagMaxMiddlewares     = "max-middlewares"
+	flagRestrictBackends   = "restrict-backend-hosts"
+	flagAllowedBackends    = "allowed-backend-hosts"
+	flagRestrictRequests   = "restrict-request-hosts"
+	flagAllowedRequests    = "allowed-request-hosts"
+	flagAllowedSchemes     = "allowed-request-schemes"
+	flagMaxCommandMemory   = "max-command-memory"
+	flagMaxCommandCPUTime  = "max-command-cpu-time"
+	flagCommandPassEnv     = "command-pass-env"
+	flagPrewarmedTesters   = "prewarmed-testers"
+	flagMaxTesterRuns      = "max-tester-runs"
 )
 
 // NewCommand creates the server CLI command.
@@ -32,6 +68,18 @@ func NewCommand() *cli.Command {
 				Sources:  cli.EnvVars(strcase.ToSNAKE(flagAddr)),
 				Required: true,
 			},
+			&cli.DurationFlag{
+				Name:    flagReadTimeout,
+				Usage:   "Duration the server waits for a request, body included",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagReadTimeout)),
+				Value:   10 * time.Second,
+			},
+			&cli.DurationFlag{
+				Name:    flagIdleTimeout,
+				Usage:   "Duration an idle connection is kept open, waiting for the next request",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagIdleTimeout)),
+				Value:   60 * time.Second,
+			},
 			&cli.StringFlag{
 				Name:  flagLogLevel,
 				Usage: "Log level (debug, info, error)",
@@ -43,29 +91,215 @@ func NewCommand() *cli.Command {
 				Value: "json",
 			},
 			&cli.StringFlag{
-				Name:     flagDatabaseConnString,
-				Usage:    "Database connection string to a PostgreSQL database",
-				Sources:  cli.EnvVars(strcase.ToSNAKE(flagDatabaseConnString)),
-				Required: true,
+				Name:    flagDatabaseConnString,
+				Usage:   "Database connection string to a PostgreSQL database, or sqlite://<path> to a SQLite database. Shared experiments are kept in memory when empty",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagDatabaseConnString)),
+			},
+			&cli.IntFlag{
+				Name:    flagMemoryStoreSize,
+				Usage:   "Number of shared experiments kept in memory when no database is configured",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagMemoryStoreSize)),
+				Value:   1000,
+			},
+			&cli.IntFlag{
+				Name:    flagDBMaxOpenConns,
+				Usage:   "Maximum number of open database connections, 0 means unlimited",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagDBMaxOpenConns)),
+			},
+			&cli.IntFlag{
+				Name:    flagDBMaxIdleConns,
+				Usage:   "Maximum number of idle database connections kept open, 0 means none",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagDBMaxIdleConns)),
+				Value:   2,
+			},
+			&cli.DurationFlag{
+				Name:    flagDBConnMaxLifetime,
+				Usage:   "Maximum duration a database connection can be reused, 0 means forever",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagDBConnMaxLifetime)),
+			},
+			&cli.IntFlag{
+				Name:    flagDBMaxRetries,
+				Usage:   "Number of times a query failing with a transient database error is retried",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagDBMaxRetries)),
+				Value:   2,
+			},
+			&cli.StringFlag{
+				Name:    flagClientIPSalt,
+				Usage:   "Salt of the hash stored instead of the client IPs of the shared experiments, raw client IPs are stored when empty",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagClientIPSalt)),
 			},
 			&cli.StringFlag{
 				Name:     flagSecretKey,
-				Usage:    "Secret key to use for experiment response signing",
+				Usage:    "Secret key to use for experiment response signing (at least 32 bytes)",
 				Sources:  cli.EnvVars(strcase.ToSNAKE(flagSecretKey)),
 				Required: true,
 			},
+			&cli.StringSliceFlag{
+				Name:    flagOldSecretKey,
+				Usage:   "Previous secret key still accepted when verifying experiment responses, can be repeated",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagOldSecretKey)),
+			},
+			&cli.BoolFlag{
+				Name:    flagSignShareURLs,
+				Usage:   "Sign share URLs with the secret key, shared experiments can't be accessed from their ID alone",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagSignShareURLs)),
+			},
+			&cli.BoolFlag{
+				Name:    flagShortCodes,
+				Usage:   "Identify shared experiments with short codes, easier to type but to guess as well, always on with --" + flagSignShareURLs,
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagShortCodes)),
+			},
+			&cli.StringFlag{
+				Name:    flagDebugToken,
+				Usage:   "Bearer token granting access to the debug endpoints, which are disabled when empty",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagDebugToken)),
+			},
+			&cli.StringFlag{
+				Name:    flagDefaultConfig,
+				Usage:   "Path to a file holding the dynamic configuration prefilling the editor, the embedded one is used when empty",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagDefaultConfig)),
+			},
 			&cli.DurationFlag{
 				Name:    flagTesterTimeout,
 				Usage:   "Duration before the experiment is canceled",
 				Sources: cli.EnvVars(strcase.ToSNAKE(flagTesterTimeout)),
 				Value:   2 * time.Second,
 			},
+			&cli.DurationFlag{
+				Name: flagMaxRunDuration,
+				Usage: "Maximum duration of an experiment as a whole, waiting for a worker and all its requests included, " +
+					"at least tester-timeout (0 for unlimited)",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagMaxRunDuration)),
+			},
+			&cli.IntFlag{
+				Name:    flagMaxLogSize,
+				Usage:   "Maximum number of bytes of Traefik logs kept per experiment (0 for unlimited)",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagMaxLogSize)),
+				Value:   64 * 1024,
+			},
+			&cli.StringSliceFlag{
+				Name:    flagNoiseLogPrefixes,
+				Usage:   "Prefixes of Traefik log messages hidden by default in the experiment results",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagNoiseLogPrefixes)),
+				Value:   traefik.DefaultNoiseLogPrefixes(),
+			},
+			&cli.IntFlag{
+				Name:    flagResultCacheSize,
+				Usage:   "Maximum number of experiment results kept in cache (0 to disable caching)",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagResultCacheSize)),
+				Value:   1000,
+			},
+			&cli.DurationFlag{
+				Name:    flagResultCacheTTL,
+				Usage:   "Duration an experiment result is kept in cache",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagResultCacheTTL)),
+				Value:   time.Minute,
+			},
+			&cli.IntFlag{
+				Name:    flagSharedCacheSize,
+				Usage:   "Maximum number of shared experiments kept in cache (0 to disable caching)",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagSharedCacheSize)),
+				Value:   100,
+			},
+			&cli.DurationFlag{
+				Name:    flagSharedCacheTTL,
+				Usage:   "Duration a shared experiment is kept in cache, and still served once deleted (0 to disable caching)",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagSharedCacheTTL)),
+				Value:   30 * time.Second,
+			},
 			&cli.IntFlag{
 				Name:    flagMaxProcesses,
 				Usage:   "Maximum number of concurrent test processes",
 				Sources: cli.EnvVars(strcase.ToSNAKE(flagMaxProcesses)),
 				Value:   100,
 			},
+			&cli.IntFlag{
+				Name:    flagMaxRunsPerClient,
+				Usage:   "Maximum number of experiments a client IP can run simultaneously (0 for unlimited)",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagMaxRunsPerClient)),
+				Value:   5,
+			},
+			&cli.IntFlag{
+				Name:    flagMaxStreamSize,
+				Usage:   "Maximum number of bytes of a streamed experiment response body (0 for unlimited)",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagMaxStreamSize)),
+				Value:   1 << 20,
+			},
+			&cli.IntFlag{
+				Name:    flagMaxRouters,
+				Usage:   "Maximum number of routers an experiment configuration can declare (0 for unlimited)",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagMaxRouters)),
+				Value:   100,
+			},
+			&cli.IntFlag{
+				Name:    flagMaxServices,
+				Usage:   "Maximum number of services an experiment configuration can declare (0 for unlimited)",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagMaxServices)),
+				Value:   100,
+			},
+			&cli.IntFlag{
+				Name:    flagMaxMiddlewares,
+				Usage:   "Maximum number of middlewares an experiment configuration can declare (0 for unlimited)",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagMaxMiddlewares)),
+				Value:   100,
+			},
+			&cli.BoolFlag{
+				Name:    flagRestrictBackends,
+				Usage:   "Reject experiment configurations whose servers point at hosts other than the playground backends",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagRestrictBackends)),
+				Value:   true,
+			},
+			&cli.StringSliceFlag{
+				Name:    flagAllowedBackends,
+				Usage:   "Hosts experiment configurations can point at despite restrict-backend-hosts",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagAllowedBackends)),
+			},
+			&cli.BoolFlag{
+				Name:    flagRestrictRequests,
+				Usage:   "Reject experiment requests whose URL points at localhost or at a loopback, private or link-local IP, once resolved",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagRestrictRequests)),
+				Value:   true,
+			},
+			&cli.StringSliceFlag{
+				Name:    flagAllowedRequests,
+				Usage:   "Hosts experiment requests can target despite restrict-request-hosts",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagAllowedRequests)),
+			},
+			&cli.StringSliceFlag{
+				Name:    flagAllowedSchemes,
+				Usage:   "Schemes the URL of experiment requests can use",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagAllowedSchemes)),
+				Value:   []string{"http", "https"},
+			},
+			&cli.IntFlag{
+				Name:    flagMaxCommandMemory,
+				Usage:   "Maximum virtual memory of a test process, in bytes, enforced with prlimit (0 for unlimited)",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagMaxCommandMemory)),
+			},
+			&cli.DurationFlag{
+				Name:    flagMaxCommandCPUTime,
+				Usage:   "Maximum CPU time of a test process, rounded up to the second, enforced with prlimit (0 for unlimited)",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagMaxCommandCPUTime)),
+			},
+			&cli.StringSliceFlag{
+				Name:    flagCommandPassEnv,
+				Usage:   "Environment variables forwarded to the test processes, such as TZ",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagCommandPassEnv)),
+				Value:   []string{"TZ"},
+			},
+			&cli.IntFlag{
+				Name: flagPrewarmedTesters,
+				Usage: "Number of test processes started ahead of time and reused across experiments, " +
+					"except the streamed ones (0 to start a process per experiment)",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagPrewarmedTesters)),
+			},
+			&cli.IntFlag{
+				Name: flagMaxTesterRuns,
+				Usage: "Number of experiments a prewarmed test process runs before being replaced, " +
+					"they share its maximum CPU time",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagMaxTesterRuns)),
+				Value:   50,
+			},
 			&cli.IntFlag{
 				Name:    flagMaxPendingCommands,
 				Usage:   "Maximum number commands that can be waiting to be executed",
@@ -79,12 +313,47 @@ func NewCommand() *cli.Command {
 			}
 
 			s, err := New(Config{
-				Addr:               cmd.String(flagAddr),
-				DatabaseConnString: cmd.String(flagDatabaseConnString),
-				SecretKey:          cmd.String(flagSecretKey),
-				TesterTimeout:      cmd.Duration(flagTesterTimeout),
-				MaxPendingCommands: cmd.Int(flagMaxPendingCommands),
-				MaxProcesses:       cmd.Int(flagMaxProcesses),
+				Addr:                     cmd.String(flagAddr),
+				ReadTimeout:              cmd.Duration(flagReadTimeout),
+				IdleTimeout:              cmd.Duration(flagIdleTimeout),
+				DatabaseConnString:       cmd.String(flagDatabaseConnString),
+				MemoryStoreSize:          cmd.Int(flagMemoryStoreSize),
+				DBMaxOpenConns:           cmd.Int(flagDBMaxOpenConns),
+				DBMaxIdleConns:           cmd.Int(flagDBMaxIdleConns),
+				DBConnMaxLifetime:        cmd.Duration(flagDBConnMaxLifetime),
+				DBMaxRetries:             cmd.Int(flagDBMaxRetries),
+				ClientIPSalt:             cmd.String(flagClientIPSalt),
+				SecretKey:                cmd.String(flagSecretKey),
+				OldSecretKeys:            cmd.StringSlice(flagOldSecretKey),
+				SignShareURLs:            cmd.Bool(flagSignShareURLs),
+				ShortCodes:               cmd.Bool(flagShortCodes),
+				DebugToken:               cmd.String(flagDebugToken),
+				DefaultDynamicConfigFile: cmd.String(flagDefaultConfig),
+				TesterTimeout:            cmd.Duration(flagTesterTimeout),
+				MaxRunDuration:           cmd.Duration(flagMaxRunDuration),
+				MaxLogSize:               cmd.Int(flagMaxLogSize),
+				NoiseLogPrefixes:         cmd.StringSlice(flagNoiseLogPrefixes),
+				ResultCacheSize:          cmd.Int(flagResultCacheSize),
+				ResultCacheTTL:           cmd.Duration(flagResultCacheTTL),
+				SharedCacheSize:          cmd.Int(flagSharedCacheSize),
+				SharedCacheTTL:           cmd.Duration(flagSharedCacheTTL),
+				MaxPendingCommands:       cmd.Int(flagMaxPendingCommands),
+				MaxProcesses:             cmd.Int(flagMaxProcesses),
+				MaxCommandMemory:         cmd.Int(flagMaxCommandMemory),
+				MaxCommandCPUTime:        cmd.Duration(flagMaxCommandCPUTime),
+				CommandPassEnv:           cmd.StringSlice(flagCommandPassEnv),
+				PrewarmedTesters:         cmd.Int(flagPrewarmedTesters),
+				MaxTesterRuns:            cmd.Int(flagMaxTesterRuns),
+				MaxRunsPerClient:         cmd.Int(flagMaxRunsPerClient),
+				MaxStreamSize:            cmd.Int(flagMaxStreamSize),
+				MaxRouters:               cmd.Int(flagMaxRouters),
+				MaxServices:              cmd.Int(flagMaxServices),
+				MaxMiddlewares:           cmd.Int(flagMaxMiddlewares),
+				RestrictBackendHosts:     cmd.Bool(flagRestrictBackends),
+				AllowedBackendHosts:      cmd.StringSlice(flagAllowedBackends),
+				RestrictRequestHosts:     cmd.Bool(flagRestrictRequests),
+				AllowedRequestHosts:      cmd.StringSlice(flagAllowedRequests),
+				AllowedRequestSchemes:    cmd.StringSlice(flagAllowedSchemes),
 			})
 			if err != nil {
 				return err
@@ -2,39 +2,135 @@ package server
 
 import (
 	"context"
+	"crypto/subtle"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/jspdown/traefik-playground/app"
-	"github.com/jspdown/traefik-playground/db/migrations"
 	"github.com/jspdown/traefik-playground/internal/command"
+	"github.com/jspdown/traefik-playground/internal/database"
 	"github.com/jspdown/traefik-playground/internal/experiment"
+	"github.com/jspdown/traefik-playground/internal/traefik"
 	"github.com/rs/zerolog/log"
 )
 
 // Config holds the Server configuration.
 type Config struct {
-	Addr               string
+	Addr string
+	// ReadTimeout defines how long the server waits for a request, body included.
+	ReadTimeout time.Duration
+	// IdleTimeout defines how long the server keeps an idle connection open, waiting for the next request.
+	IdleTimeout time.Duration
+	// DatabaseConnString is the connection string of the database shared experiments are stored in. They are kept
+	// in memory when empty.
 	DatabaseConnString string
+	// MemoryStoreSize defines the number of shared experiments kept in memory when no database is configured.
+	MemoryStoreSize int
+
+	// DBMaxOpenConns defines the number of open database connections, 0 means unlimited.
+	DBMaxOpenConns int
+	// DBMaxIdleConns defines the number of idle database connections kept open, 0 means none.
+	DBMaxIdleConns int
+	// DBConnMaxLifetime defines how long a database connection can be reused, 0 means forever.
+	DBConnMaxLifetime time.Duration
+	// DBMaxRetries defines how many times a query failing with a transient database error is retried.
+	DBMaxRetries int
+	// ClientIPSalt is the salt of the hash stored instead of the client IPs of the shared experiments. Raw client IPs
+	// are stored when empty.
+	ClientIPSalt string
 
 	// SecretKey is the key used to sign experiment responses.
 	SecretKey string
+	// OldSecretKeys are previous secret keys, still accepted when verifying experiment responses.
+	OldSecretKeys []string
+	// DebugToken is the bearer token granting access to the debug endpoints, which are disabled when empty.
+	DebugToken string
+	// SignShareURLs makes share URLs hold a signature, without which shared experiments can't be accessed.
+	SignShareURLs bool
+	// ShortCodes makes shared experiments be handed out with their short code rather than their public ID. Short
+	// codes are always handed out along with signed share URLs.
+	ShortCodes bool
+	// DefaultDynamicConfigFile is the path of the file holding the dynamic configuration prefilling the editor.
+	// The embedded one is used when empty.
+	DefaultDynamicConfigFile string
 
 	// TesterTimeout defines how long an experiment is allowed to run.
 	TesterTimeout time.Duration
+	// MaxRunDuration defines how long an experiment is allowed to take as a whole, waiting for a worker and all its
+	// requests included, 0 means unlimited. TesterTimeout still bounds each run of the tester.
+	MaxRunDuration time.Duration
+	// MaxLogSize defines the maximum number of bytes of logs kept per experiment.
+	MaxLogSize int
+	// NoiseLogPrefixes defines the prefixes of Traefik log messages hidden by default.
+	NoiseLogPrefixes []string
+
+	// ResultCacheSize defines the number of experiment results kept in cache, 0 disables caching.
+	ResultCacheSize int
+	// ResultCacheTTL defines how long an experiment result is kept in cache.
+	ResultCacheTTL time.Duration
+	// SharedCacheSize defines the number of shared experiments kept in cache, 0 disables caching.
+	SharedCacheSize int
+	// SharedCacheTTL defines how long a shared experiment is kept in cache, and therefore still served once deleted.
+	SharedCacheTTL time.Duration
 
 	// MaxPendingCommands defines the size of the spawner command queue.
 	MaxPendingCommands int
 	// MaxProcesses defines the number of simultaneous processes executing spawner commands.
 	MaxProcesses int
+	// MaxCommandMemory defines the virtual memory, in bytes, a spawner command can use, 0 means unlimited.
+	MaxCommandMemory int
+	// MaxCommandCPUTime defines the CPU time a spawner command can consume, 0 means unlimited.
+	MaxCommandCPUTime time.Duration
+	// CommandPassEnv lists the environment variables forwarded to the spawner commands.
+	CommandPassEnv []string
+	// PrewarmedTesters defines the number of tester processes started ahead of time and reused across experiments,
+	// 0 starts a process per experiment.
+	PrewarmedTesters int
+	// MaxTesterRuns defines the number of experiments a prewarmed tester process runs before being replaced.
+	MaxTesterRuns int
+	// MaxRunsPerClient defines the number of experiments a client IP can run simultaneously, 0 means unlimited.
+	MaxRunsPerClient int
+	// MaxStreamSize defines the maximum number of bytes of a streamed response body, 0 means unlimited.
+	MaxStreamSize int
+
+	// MaxRouters, MaxServices and MaxMiddlewares define the number of objects an experiment dynamic configuration
+	// can declare, 0 means unlimited.
+	MaxRouters     int
+	MaxServices    int
+	MaxMiddlewares int
+	// RestrictBackendHosts rejects the experiment configurations whose servers point at hosts other than the
+	// playground backends and the AllowedBackendHosts.
+	RestrictBackendHosts bool
+	// AllowedBackendHosts defines the hosts experiment configurations can point at despite RestrictBackendHosts.
+	AllowedBackendHosts []string
+	// RestrictRequestHosts rejects the experiment requests whose URL points at localhost or at an internal IP.
+	RestrictRequestHosts bool
+	// AllowedRequestHosts defines the hosts experiment requests can target despite RestrictRequestHosts.
+	AllowedRequestHosts []string
+	// AllowedRequestSchemes defines the schemes the URL of experiment requests can use, http and https when empty.
+	AllowedRequestSchemes []string
 }
 
 // Server serves the traefik-playground service.
 type Server struct {
 	config Config
+
+	// defaultDynamicConfig is the content of the DefaultDynamicConfigFile, empty when not set.
+	defaultDynamicConfig string
+
+	// startedAt, db, store and pool are set when the server starts, for the debug endpoints. The db and store are
+	// only set when shared experiments are kept in a database.
+	startedAt time.Time
+	db        *sql.DB
+	store     *experiment.Store
+	pool      *command.WorkerPool
 }
 
 // New creates a new Server.
@@ -42,36 +138,149 @@ func New(config Config) (*Server, error) {
 	if config.MaxPendingCommands < config.MaxProcesses {
 		return nil, errors.New("max-pending-commands must be greater or equal to max-processes")
 	}
+	if config.MaxLogSize < 0 {
+		return nil, errors.New("max-log-size must not be negative")
+	}
+	if config.MaxCommandMemory < 0 {
+		return nil, errors.New("max-command-memory must not be negative")
+	}
+	if config.MaxCommandCPUTime < 0 {
+		return nil, errors.New("max-command-cpu-time must not be negative")
+	}
+	if config.PrewarmedTesters < 0 {
+		return nil, errors.New("prewarmed-testers must not be negative")
+	}
+	if config.PrewarmedTesters > 0 && config.MaxTesterRuns < 1 {
+		return nil, errors.New("max-tester-runs must be at least 1")
+	}
+	if config.MaxRunsPerClient < 0 {
+		return nil, errors.New("max-runs-per-client must not be negative")
+	}
+	if config.MaxStreamSize < 0 {
+		return nil, errors.New("max-stream-size must not be negative")
+	}
+	if config.MaxRouters < 0 || config.MaxServices < 0 || config.MaxMiddlewares < 0 {
+		return nil, errors.New("max-routers, max-services and max-middlewares must not be negative")
+	}
+	if config.DBMaxOpenConns < 0 || config.DBMaxIdleConns < 0 || config.DBConnMaxLifetime < 0 {
+		return nil, errors.New("db-max-open-conns, db-max-idle-conns and db-conn-max-lifetime must not be negative")
+	}
+	if config.DBMaxRetries < 0 {
+		return nil, errors.New("db-max-retries must not be negative")
+	}
+	if config.DatabaseConnString == "" && config.MemoryStoreSize < 1 {
+		return nil, errors.New("memory-store-size must be at least 1")
+	}
+	if config.ResultCacheSize < 0 {
+		return nil, errors.New("result-cache-size must not be negative")
+	}
+	if config.SharedCacheSize < 0 {
+		return nil, errors.New("shared-cache-size must not be negative")
+	}
+	if config.ReadTimeout <= 0 {
+		return nil, errors.New("read-timeout must be positive")
+	}
+	if config.IdleTimeout <= 0 {
+		return nil, errors.New("idle-timeout must be positive")
+	}
 	if config.TesterTimeout < time.Second {
 		return nil, errors.New("tester-timeout must be at least 1s")
 	}
+	if config.MaxRunDuration < 0 {
+		return nil, errors.New("max-run-duration must not be negative")
+	}
+	if config.MaxRunDuration > 0 && config.MaxRunDuration < config.TesterTimeout {
+		return nil, errors.New("max-run-duration must be greater or equal to tester-timeout")
+	}
+
+	var defaultDynamicConfig []byte
+	if config.DefaultDynamicConfigFile != "" {
+		var err error
+		if defaultDynamicConfig, err = os.ReadFile(config.DefaultDynamicConfigFile); err != nil {
+			return nil, fmt.Errorf("reading default-config: %w", err)
+		}
+	}
 
 	return &Server{
-		config: config,
+		config:               config,
+		defaultDynamicConfig: string(defaultDynamicConfig),
 	}, nil
 }
 
 // Start starts the server.
 func (s *Server) Start(ctx context.Context) error {
-	// Initialize the database.
-	db, err := sql.Open("postgres", s.config.DatabaseConnString)
+	// Initialize the store.
+	store, err := s.openStore()
 	if err != nil {
-		return fmt.Errorf("opening database connection: %w", err)
+		return err
 	}
 
-	defer func() { _ = db.Close() }()
-
-	if err = migrations.Migrate(db); err != nil {
-		return fmt.Errorf("migrating database: %w", err)
+	if s.db != nil {
+		defer func() { _ = s.db.Close() }()
 	}
 
 	// Initialize handlers.
-	store := experiment.NewStore(db)
 	pool := command.NewWorkerPool(s.config.MaxProcesses, s.config.MaxPendingCommands)
-	traefikRunner := experiment.NewTraefik(pool, s.config.TesterTimeout)
-	controller := experiment.NewController(store, traefikRunner)
 
-	appHandler, err := app.New(controller, s.config.SecretKey)
+	s.startedAt = time.Now()
+	s.pool = pool
+
+	limits := command.ResourceLimits{
+		MaxMemory:  s.config.MaxCommandMemory,
+		MaxCPUTime: s.config.MaxCommandCPUTime,
+	}
+
+	var warmPool *traefik.WarmPool
+	if s.config.PrewarmedTesters > 0 {
+		warmPool = traefik.NewWarmPool(s.config.PrewarmedTesters, s.config.MaxTesterRuns, limits, s.config.CommandPassEnv, s.config.TesterTimeout)
+		warmPool.Prewarm()
+
+		defer warmPool.Close()
+	}
+
+	traefikRunner := experiment.NewTraefik(pool, experiment.TraefikConfig{
+		Timeout:          s.config.TesterTimeout,
+		MaxLogSize:       s.config.MaxLogSize,
+		NoiseLogPrefixes: s.config.NoiseLogPrefixes,
+		ResourceLimits:   limits,
+		PassEnv:          s.config.CommandPassEnv,
+		WarmPool:         warmPool,
+	})
+
+	var resultCache experiment.ResultCache
+	if s.config.ResultCacheSize > 0 {
+		resultCache = experiment.NewLRUCache(s.config.ResultCacheSize, s.config.ResultCacheTTL)
+	}
+
+	controller := experiment.NewController(store, traefikRunner, experiment.ControllerConfig{
+		Cache:            resultCache,
+		MaxRunsPerClient: s.config.MaxRunsPerClient,
+		MaxStreamSize:    int64(s.config.MaxStreamSize),
+		MaxRunDuration:   s.config.MaxRunDuration,
+		Limits: experiment.Limits{
+			MaxRouters:            s.config.MaxRouters,
+			MaxServices:           s.config.MaxServices,
+			MaxMiddlewares:        s.config.MaxMiddlewares,
+			RestrictBackendHosts:  s.config.RestrictBackendHosts,
+			AllowedBackendHosts:   s.config.AllowedBackendHosts,
+			RestrictRequestHosts:  s.config.RestrictRequestHosts,
+			AllowedRequestHosts:   s.config.AllowedRequestHosts,
+			AllowedRequestSchemes: s.config.AllowedRequestSchemes,
+		},
+	})
+
+	var sharedCache *app.SharedCache
+	if s.config.SharedCacheSize > 0 {
+		sharedCache = app.NewSharedCache(s.config.SharedCacheSize, s.config.SharedCacheTTL)
+	}
+
+	appHandler, err := app.New(controller, app.Options{
+		SecretKey:            s.config.SecretKey,
+		OldSecretKeys:        s.config.OldSecretKeys,
+		SignShareURLs:        s.config.SignShareURLs,
+		SharedCache:          sharedCache,
+		DefaultDynamicConfig: s.defaultDynamicConfig,
+	})
 	if err != nil {
 		return err
 	}
@@ -79,16 +288,15 @@ func (s *Server) Start(ctx context.Context) error {
 	mux := http.NewServeMux()
 	mux.HandleFunc("GET /health", healthHandler)
 
+	if s.config.DebugToken != "" {
+		mux.Handle("GET /debug/stats", s.protectDebug(http.HandlerFunc(s.debugStatsHandler)))
+		mux.Handle("GET /debug/experiments", s.protectDebug(http.HandlerFunc(s.debugExperimentsHandler)))
+	}
+
 	appHandler.MountOn(mux)
 
 	// Start the server.
-	server := &http.Server{
-		Addr:         s.config.Addr,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
-		IdleTimeout:  60 * time.Second,
-		Handler:      mux,
-	}
+	server := newHTTPServer(mux, s.config)
 
 	ctx, stopAll := context.WithCancel(ctx)
 	defer stopAll()
@@ -129,6 +337,198 @@ func (s *Server) Start(ctx context.Context) error {
 	return nil
 }
 
+// openStore opens the store of shared experiments. They are kept in memory when no database is configured,
+// otherwise the database is migrated and kept open in s.db.
+func (s *Server) openStore() (experiment.Storer, error) {
+	if s.config.DatabaseConnString == "" {
+		log.Warn().Msg("No database configured, shared experiments are kept in memory and lost on restart")
+
+		return experiment.NewMemoryStore(s.config.MemoryStoreSize, s.shortCodes()), nil
+	}
+
+	db, dialect, err := database.Open(s.config.DatabaseConnString)
+	if err != nil {
+		return nil, fmt.Errorf("opening database connection: %w", err)
+	}
+
+	configureDBPool(db, s.config)
+
+	if err = database.Migrate(db, dialect); err != nil {
+		_ = db.Close()
+
+		return nil, fmt.Errorf("migrating database: %w", err)
+	}
+
+	s.db = db
+	s.store = experiment.NewStore(db, experiment.StoreConfig{
+		Dialect:      dialect,
+		MaxRetries:   s.config.DBMaxRetries,
+		ClientIPSalt: s.config.ClientIPSalt,
+		ShortCodes:   s.shortCodes(),
+	})
+
+	return s.store, nil
+}
+
+// shortCodes tells whether shared experiments are handed out with their short code. Being short, they are easy to
+// guess, and only handed out when asked to or when share URLs are signed.
+func (s *Server) shortCodes() bool {
+	return s.config.ShortCodes || s.config.SignShareURLs
+}
+
+// dbPool is the connection pool of a database.
+type dbPool interface {
+	SetMaxOpenConns(n int)
+	SetMaxIdleConns(n int)
+	SetConnMaxLifetime(d time.Duration)
+}
+
+// configureDBPool applies the connection pool settings of the given configuration.
+func configureDBPool(db dbPool, config Config) {
+	db.SetMaxOpenConns(config.DBMaxOpenConns)
+	db.SetMaxIdleConns(config.DBMaxIdleConns)
+	db.SetConnMaxLifetime(config.DBConnMaxLifetime)
+}
+
+// serverTimeoutMargin is the time given to handle an experiment on top of the longest it can run,
+// to wait for a worker and render the response.
+const serverTimeoutMargin = 8 * time.Second
+
+// newHTTPServer creates the HTTP server. Its write timeout is derived from the longest an experiment can run,
+// bounded by the tester timeout and the maximum run duration, so that slow experiments aren't cut off before
+// completing.
+func newHTTPServer(handler http.Handler, config Config) *http.Server {
+	return &http.Server{
+		Addr:         config.Addr,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: max(10*time.Second, max(config.TesterTimeout, config.MaxRunDuration)+serverTimeoutMargin),
+		IdleTimeout:  config.IdleTimeout,
+		Handler:      handler,
+	}
+}
+
 func healthHandler(rw http.ResponseWriter, req *http.Request) {
 	rw.WriteHeader(http.StatusOK)
 }
+
+// statsTopUserAgents is the number of user agents reported by Stats.
+const statsTopUserAgents = 10
+
+// Stats is a snapshot of the server resources usage.
+type Stats struct {
+	Uptime     string            `json:"uptime"`
+	WorkerPool command.PoolStats `json:"workerPool"`
+	DB         *DBStats          `json:"db,omitempty"`
+	// TopUserAgents are the user agents which shared the most experiments, to investigate abuses.
+	TopUserAgents []experiment.UserAgentCount `json:"topUserAgents,omitempty"`
+}
+
+// DBStats is a snapshot of the database connection pool usage.
+type DBStats struct {
+	MaxOpenConnections int    `json:"maxOpenConnections"`
+	OpenConnections    int    `json:"openConnections"`
+	InUse              int    `json:"inUse"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"waitCount"`
+	WaitDuration       string `json:"waitDuration"`
+}
+
+// Stats returns a snapshot of the server resources usage. It must only be called once the server started.
+// Database stats and user agents are omitted when shared experiments are kept in memory.
+func (s *Server) Stats(ctx context.Context) Stats {
+	stats := Stats{
+		Uptime:     time.Since(s.startedAt).Round(time.Second).String(),
+		WorkerPool: s.pool.Stats(),
+	}
+
+	if s.db != nil {
+		dbStat := s.db.Stats()
+
+		stats.DB = &DBStats{
+			MaxOpenConnections: dbStat.MaxOpenConnections,
+			OpenConnections:    dbStat.OpenConnections,
+			InUse:              dbStat.InUse,
+			Idle:               dbStat.Idle,
+			WaitCount:          dbStat.WaitCount,
+			WaitDuration:       dbStat.WaitDuration.String(),
+		}
+	}
+
+	if s.store != nil {
+		var err error
+		if stats.TopUserAgents, err = s.store.TopUserAgents(ctx, statsTopUserAgents); err != nil {
+			log.Ctx(ctx).Error().Err(err).Msg("Unable to get the top user agents")
+		}
+	}
+
+	return stats
+}
+
+// debugStatsHandler serves a human-readable snapshot of the server resources usage.
+func (s *Server) debugStatsHandler(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "application/json")
+
+	encoder := json.NewEncoder(rw)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(s.Stats(req.Context())); err != nil {
+		log.Error().Err(err).Msg("Unable to write stats")
+	}
+}
+
+// maxListedExperiments is the maximum number of shared experiments listed by debugExperimentsHandler.
+const maxListedExperiments = 500
+
+// debugExperimentsHandler lists the most recently shared experiments, only the ones with the label given as
+// "label" query parameter if any. The "limit" query parameter sets how many are listed, 50 by default.
+// Shared experiments kept in memory can't be listed.
+func (s *Server) debugExperimentsHandler(rw http.ResponseWriter, req *http.Request) {
+	if s.store == nil {
+		http.Error(rw, "shared experiments are kept in memory and can't be listed", http.StatusNotFound)
+
+		return
+	}
+
+	limit := 50
+	if rawLimit := req.URL.Query().Get("limit"); rawLimit != "" {
+		var err error
+		if limit, err = strconv.Atoi(rawLimit); err != nil || limit < 1 || limit > maxListedExperiments {
+			http.Error(rw, fmt.Sprintf("limit must be between 1 and %d", maxListedExperiments), http.StatusBadRequest)
+
+			return
+		}
+	}
+
+	experiments, err := s.store.List(req.Context(), req.URL.Query().Get("label"), limit)
+	if err != nil {
+		log.Ctx(req.Context()).Error().Err(err).Msg("Unable to list shared experiments")
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+
+	encoder := json.NewEncoder(rw)
+	encoder.SetIndent("", "  ")
+
+	if err = encoder.Encode(experiments); err != nil {
+		log.Error().Err(err).Msg("Unable to write shared experiments")
+	}
+}
+
+// protectDebug rejects the requests which don't hold the debug token as bearer token.
+func (s *Server) protectDebug(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		token, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer ")
+
+		// Compare in constant time to not leak the token length of the matching prefix.
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.DebugToken)) != 1 {
+			http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+			return
+		}
+
+		next.ServeHTTP(rw, req)
+	})
+}
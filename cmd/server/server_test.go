@@ -0,0 +1,260 @@
+package server
+
+import (
+	"database/sql"
+	"encoding/json"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/jspdown/traefik-playground/internal/command"
+	"github.com/jspdown/traefik-playground/internal/experiment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewHTTPServer_timeouts(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name           string
+		testerTimeout  time.Duration
+		maxRunDuration time.Duration
+		wantMinimum    time.Duration
+	}{
+		{name: "default tester timeout", testerTimeout: 2 * time.Second, wantMinimum: 2 * time.Second},
+		{name: "tester timeout close to the default write timeout", testerTimeout: 9 * time.Second, wantMinimum: 9 * time.Second},
+		{name: "long tester timeout", testerTimeout: time.Minute, wantMinimum: time.Minute},
+		{
+			name:           "max run duration longer than the tester timeout",
+			testerTimeout:  2 * time.Second,
+			maxRunDuration: 30 * time.Second,
+			wantMinimum:    30 * time.Second,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			server := newHTTPServer(http.NotFoundHandler(), Config{
+				Addr:           ":8080",
+				ReadTimeout:    5 * time.Second,
+				IdleTimeout:    time.Minute,
+				TesterTimeout:  test.testerTimeout,
+				MaxRunDuration: test.maxRunDuration,
+			})
+
+			assert.GreaterOrEqual(t, server.WriteTimeout, test.wantMinimum+serverTimeoutMargin)
+			assert.GreaterOrEqual(t, server.WriteTimeout, 10*time.Second)
+			assert.Equal(t, 5*time.Second, server.ReadTimeout)
+			assert.Equal(t, time.Minute, server.IdleTimeout)
+		})
+	}
+}
+
+func TestNew_defaultDynamicConfigFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "default.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("http: {}\n"), 0o600))
+
+	config := Config{
+		MemoryStoreSize:          1,
+		ReadTimeout:              10 * time.Second,
+		IdleTimeout:              time.Minute,
+		TesterTimeout:            time.Second,
+		DefaultDynamicConfigFile: path,
+	}
+
+	s, err := New(config)
+	require.NoError(t, err)
+	assert.Equal(t, "http: {}\n", s.defaultDynamicConfig)
+
+	config.DefaultDynamicConfigFile = filepath.Join(t.TempDir(), "missing.yaml")
+
+	_, err = New(config)
+	require.ErrorContains(t, err, "reading default-config")
+}
+
+func TestServer_debugStatsHandler(t *testing.T) {
+	t.Parallel()
+
+	db, err := sql.Open("postgres", "postgres://localhost/unused")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	s := &Server{
+		config:    Config{DebugToken: "token"},
+		startedAt: time.Now().Add(-time.Minute),
+		db:        db,
+		pool:      command.NewWorkerPool(2, 4),
+	}
+	handler := s.protectDebug(http.HandlerFunc(s.debugStatsHandler))
+
+	tests := []struct {
+		desc          string
+		authorization string
+		wantStatus    int
+	}{
+		{desc: "missing token", wantStatus: http.StatusUnauthorized},
+		{desc: "invalid token", authorization: "Bearer invalid", wantStatus: http.StatusUnauthorized},
+		{desc: "valid token", authorization: "Bearer token", wantStatus: http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/debug/stats", nil)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+
+			rw := httptest.NewRecorder()
+			handler.ServeHTTP(rw, req)
+
+			assert.Equal(t, test.wantStatus, rw.Code)
+		})
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/stats", nil)
+	req.Header.Set("Authorization", "Bearer token")
+
+	rw := httptest.NewRecorder()
+	handler.ServeHTTP(rw, req)
+
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+
+	var got struct {
+		Uptime     string         `json:"uptime"`
+		WorkerPool map[string]any `json:"workerPool"`
+		DB         map[string]any `json:"db"`
+	}
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &got))
+
+	assert.Equal(t, "1m0s", got.Uptime)
+	assert.Equal(t, map[string]any{
+		"inFlight":      float64(0),
+		"maxInFlight":   float64(2),
+		"queueDepth":    float64(0),
+		"maxQueueDepth": float64(4),
+		"outcomes": map[string]any{
+			"succeeded": float64(0),
+			"failed":    float64(0),
+			"timedOut":  float64(0),
+			"rejected":  float64(0),
+		},
+	}, got.WorkerPool)
+	assert.ElementsMatch(t,
+		[]string{"maxOpenConnections", "openConnections", "inUse", "idle", "waitCount", "waitDuration"},
+		slices.Collect(maps.Keys(got.DB)))
+}
+
+func TestServer_Stats_topUserAgents(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{
+		config: Config{DatabaseConnString: "sqlite://" + filepath.Join(t.TempDir(), "test.db")},
+		pool:   command.NewWorkerPool(1, 1),
+	}
+
+	store, err := s.openStore()
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = s.db.Close() })
+
+	_, err = store.Save(t.Context(), experiment.Experiment{
+		DynamicConfig: "dynamicConfig",
+		Request:       experiment.HTTPRequest{Method: http.MethodGet, URL: "https://example.com"},
+	}, experiment.Result{}, "127.0.0.1", "curl/8.5.0")
+	require.NoError(t, err)
+
+	assert.Equal(t, []experiment.UserAgentCount{{UserAgent: "curl/8.5.0", Count: 1}}, s.Stats(t.Context()).TopUserAgents)
+}
+
+func TestServer_debugExperimentsHandler(t *testing.T) {
+	t.Parallel()
+
+	s := &Server{
+		config: Config{DatabaseConnString: "sqlite://" + filepath.Join(t.TempDir(), "test.db")},
+		pool:   command.NewWorkerPool(1, 1),
+	}
+
+	store, err := s.openStore()
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = s.db.Close() })
+
+	for i, label := range []string{"retry-test", "other", "retry-test"} {
+		_, err = store.Save(t.Context(), experiment.Experiment{
+			DynamicConfig: "dynamicConfig",
+			Request:       experiment.HTTPRequest{Method: http.MethodGet, URL: "https://example.com/" + strconv.Itoa(i)},
+			Label:         label,
+		}, experiment.Result{}, "127.0.0.1", "")
+		require.NoError(t, err)
+	}
+
+	rw := httptest.NewRecorder()
+	s.debugExperimentsHandler(rw, httptest.NewRequest(http.MethodGet, "/debug/experiments?label=retry-test", nil))
+
+	require.Equal(t, http.StatusOK, rw.Code)
+	assert.Equal(t, "application/json", rw.Header().Get("Content-Type"))
+
+	var got []experiment.ListedExperiment
+	require.NoError(t, json.Unmarshal(rw.Body.Bytes(), &got))
+	require.Len(t, got, 2)
+
+	for _, exp := range got {
+		assert.Equal(t, "retry-test", exp.Label)
+	}
+
+	rw = httptest.NewRecorder()
+	s.debugExperimentsHandler(rw, httptest.NewRequest(http.MethodGet, "/debug/experiments?limit=0", nil))
+	assert.Equal(t, http.StatusBadRequest, rw.Code)
+
+	rw = httptest.NewRecorder()
+	(&Server{}).debugExperimentsHandler(rw, httptest.NewRequest(http.MethodGet, "/debug/experiments", nil))
+	assert.Equal(t, http.StatusNotFound, rw.Code)
+}
+
+type fakeDBPool struct {
+	maxOpenConns    int
+	maxIdleConns    int
+	connMaxLifetime time.Duration
+}
+
+func (p *fakeDBPool) SetMaxOpenConns(n int)              { p.maxOpenConns = n }
+func (p *fakeDBPool) SetMaxIdleConns(n int)              { p.maxIdleConns = n }
+func (p *fakeDBPool) SetConnMaxLifetime(d time.Duration) { p.connMaxLifetime = d }
+
+func TestConfigureDBPool(t *testing.T) {
+	t.Parallel()
+
+	config := Config{
+		DBMaxOpenConns:    20,
+		DBMaxIdleConns:    5,
+		DBConnMaxLifetime: 30 * time.Minute,
+	}
+
+	pool := &fakeDBPool{}
+	configureDBPool(pool, config)
+
+	assert.Equal(t, &fakeDBPool{maxOpenConns: 20, maxIdleConns: 5, connMaxLifetime: 30 * time.Minute}, pool)
+
+	db, err := sql.Open("postgres", "postgres://localhost/unused")
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = db.Close() })
+
+	configureDBPool(db, config)
+	assert.Equal(t, 20, db.Stats().MaxOpenConnections)
+}
@@ -3,7 +3,10 @@ package tester
 import (
 	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	stdlog "log"
 	"net/http"
 	"os"
@@ -21,8 +24,19 @@ import (
 )
 
 const (
-	flagLogLevel = "log-level"
-	flagRequest  = "request"
+	flagLogLevel     = "log-level"
+	flagRequest      = "request"
+	flagDatagram     = "datagram"
+	flagRemoteAddr   = "remote-addr"
+	flagStream       = "stream"
+	flagTimeout      = "timeout"
+	flagBurst        = "burst"
+	flagKeepCookies  = "keep-cookies"
+	flagConcurrent   = "concurrent"
+	flagDelay        = "delay"
+	flagTrustedIP    = "trusted-ip"
+	flagStaticConfig = "static-config"
+	flagServe        = "serve"
 )
 
 // NewCommand creates the tester CLI command.
@@ -37,26 +51,102 @@ func NewCommand() *cli.Command {
 				Value: "INFO",
 			},
 			&cli.StringFlag{
-				Name:     flagRequest,
-				Usage:    "HTTP request to pass to the handler",
-				Sources:  cli.EnvVars(strcase.ToSNAKE(flagRequest)),
-				Required: true,
+				Name:    flagRequest,
+				Usage:   "HTTP request to pass to the handler",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagRequest)),
+			},
+			&cli.StringFlag{
+				Name:    flagRemoteAddr,
+				Usage:   "Remote address the HTTP request originates from",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagRemoteAddr)),
+			},
+			&cli.BoolFlag{
+				Name:  flagStream,
+				Usage: "Write the HTTP response as it's produced, with a chunked body, instead of once complete",
+			},
+			&cli.IntFlag{
+				Name:  flagBurst,
+				Usage: "Number of times the HTTP request is sent back to back, only the last response is written",
+				Value: 1,
+			},
+			&cli.BoolFlag{
+				Name:  flagKeepCookies,
+				Usage: "Send the cookies set by the responses along with the next requests of the burst",
+			},
+			&cli.BoolFlag{
+				Name:  flagConcurrent,
+				Usage: "Send the requests of the burst at once rather than back to back",
+			},
+			&cli.DurationFlag{
+				Name:  flagDelay,
+				Usage: "Delay before the HTTP request body is sent, to simulate a slow client",
+			},
+			&cli.StringSliceFlag{
+				Name:  flagTrustedIP,
+				Usage: "IP or CIDR whose forwarded headers, such as X-Forwarded-For, are trusted by the entrypoints",
+			},
+			&cli.StringFlag{
+				Name:  flagStaticConfig,
+				Usage: "Static configuration, in YAML, merged into the defaults of the Traefik instance",
+			},
+			&cli.DurationFlag{
+				Name:  flagTimeout,
+				Usage: "Duration before the test is canceled",
+				Value: 2 * time.Second,
+			},
+			&cli.BoolFlag{
+				Name: flagServe,
+				Usage: "Run the tests received on the standard input one after the other, as JSON lines, " +
+					"each within the timeout, and write their results on the standard output",
+			},
+			&cli.StringFlag{
+				Name:    flagDatagram,
+				Usage:   "UDP datagram to send to the udp entrypoint instead of an HTTP request",
+				Sources: cli.EnvVars(strcase.ToSNAKE(flagDatagram)),
 			},
 		},
-		Action: func(ctx context.Context, cmd *cli.Command) error {
-			if err := initializeTraefikLogger(cmd.String(flagLogLevel)); err != nil {
+		Action: exitOnTimeout(func(ctx context.Context, cmd *cli.Command) error {
+			if cmd.Bool(flagServe) {
+				logs := &traefik.JobLogs{}
+				if err := initializeTraefikLogger(cmd.String(flagLogLevel), logs); err != nil {
+					return err
+				}
+
+				return traefik.Serve(ctx, os.Stdin, os.Stdout, logs, cmd.Duration(flagTimeout))
+			}
+
+			if err := initializeTraefikLogger(cmd.String(flagLogLevel), os.Stderr); err != nil {
 				return err
 			}
 
+			if !cmd.IsSet(flagDatagram) && !cmd.Bool(flagStream) {
+				return runJob(ctx, cmd)
+			}
+
 			var dynamicConfig dynamic.Configuration
 			if err := yaml.NewDecoder(os.Stdin).Decode(&dynamicConfig); err != nil {
 				return fmt.Errorf("decoding dynamic configuration: %w", err)
 			}
 
-			ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+			ctx, cancel := context.WithTimeout(ctx, cmd.Duration(flagTimeout))
 			defer cancel()
 
+			instance, err := traefik.NewTraefik(&dynamicConfig, traefik.Options{
+				TrustedIPs:   cmd.StringSlice(flagTrustedIP),
+				StaticConfig: cmd.String(flagStaticConfig),
+			})
+			if err != nil {
+				return fmt.Errorf("initializing Traefik instance: %w", err)
+			}
+
+			if cmd.IsSet(flagDatagram) {
+				return sendDatagram(ctx, instance, []byte(cmd.String(flagDatagram)))
+			}
+
 			rawRequest := cmd.String(flagRequest)
+			if rawRequest == "" {
+				return fmt.Errorf("one of --%s or --%s is required", flagRequest, flagDatagram)
+			}
 
 			req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(rawRequest)))
 			if err != nil {
@@ -64,42 +154,148 @@ func NewCommand() *cli.Command {
 			}
 
 			req = req.WithContext(ctx)
+			req.RemoteAddr = cmd.String(flagRemoteAddr)
 
-			instance, err := traefik.NewTraefik(&dynamicConfig)
-			if err != nil {
-				return fmt.Errorf("initializing Traefik instance: %w", err)
+			if delay := cmd.Duration(flagDelay); delay > 0 {
+				if err = traefik.DelayRequest(req, delay); err != nil {
+					return fmt.Errorf("delaying request: %w", err)
+				}
 			}
 
-			errCh := make(chan error)
-			instance.OnReady(func() {
-				res, sendErr := instance.Send(req)
-				if sendErr != nil {
-					errCh <- sendErr
+			if cmd.IsSet(flagBurst) || cmd.IsSet(flagKeepCookies) || cmd.IsSet(flagConcurrent) {
+				return fmt.Errorf("--%s, --%s and --%s can't be used with --%s", flagBurst, flagKeepCookies, flagConcurrent, flagStream)
+			}
 
-					return
-				}
+			return streamRequest(ctx, instance, req)
+		}),
+	}
+}
 
-				defer func() { _ = res.Body.Close() }()
+// exitOnTimeout makes the given action exit with traefik.TesterTimeoutExitCode when it fails as its timeout elapsed,
+// so that the Command running the tester tells it apart from other failures.
+func exitOnTimeout(action cli.ActionFunc) cli.ActionFunc {
+	return func(ctx context.Context, cmd *cli.Command) error {
+		err := action(ctx, cmd)
+		if errors.Is(err, context.DeadlineExceeded) {
+			return cli.Exit(err, traefik.TesterTimeoutExitCode)
+		}
 
-				errCh <- res.Write(os.Stdout)
-			})
+		return err
+	}
+}
 
-			if err = instance.Start(ctx); err != nil {
-				return fmt.Errorf("starting Traefik instance: %w", err)
-			}
+// runJob sends the HTTP request given on the command line to a Traefik instance using the dynamic configuration
+// read on the standard input, and writes the report on the standard output, followed by the HTTP response.
+func runJob(ctx context.Context, cmd *cli.Command) error {
+	dynamicConfig, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("reading dynamic configuration: %w", err)
+	}
 
-			select {
-			case <-ctx.Done():
-				return ctx.Err()
-			case err = <-errCh:
-				return err
-			}
+	rawRequest := cmd.String(flagRequest)
+	if rawRequest == "" {
+		return fmt.Errorf("one of --%s or --%s is required", flagRequest, flagDatagram)
+	}
+
+	burst := cmd.Int(flagBurst)
+	if burst < 1 {
+		return fmt.Errorf("--%s must be positive", flagBurst)
+	}
+	if cmd.Bool(flagConcurrent) && cmd.Bool(flagKeepCookies) {
+		return fmt.Errorf("--%s can't be used with --%s", flagKeepCookies, flagConcurrent)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, cmd.Duration(flagTimeout))
+	defer cancel()
+
+	return traefik.RunJob(ctx, traefik.Job{
+		DynamicConfig: string(dynamicConfig),
+		Request:       rawRequest,
+		RemoteAddr:    cmd.String(flagRemoteAddr),
+		CommandOptions: traefik.CommandOptions{
+			Burst:        burst,
+			KeepCookies:  cmd.Bool(flagKeepCookies),
+			Concurrent:   cmd.Bool(flagConcurrent),
+			Delay:        cmd.Duration(flagDelay),
+			TrustedIPs:   cmd.StringSlice(flagTrustedIP),
+			StaticConfig: cmd.String(flagStaticConfig),
 		},
+	}, os.Stdout)
+}
+
+// sendDatagram sends a UDP datagram to the given Traefik instance and writes the reply on the standard output.
+func sendDatagram(ctx context.Context, instance *traefik.Traefik, datagram []byte) error {
+	errCh := make(chan error)
+	instance.OnReady(func() {
+		reply, err := instance.SendUDP(ctx, datagram)
+		if err != nil {
+			errCh <- err
+
+			return
+		}
+
+		_, err = os.Stdout.Write(reply)
+		errCh <- err
+	})
+
+	if err := instance.Start(ctx); err != nil {
+		return fmt.Errorf("starting Traefik instance: %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errCh:
+		return err
+	}
+}
+
+// streamRequest sends an HTTP request to the given Traefik instance and writes the report on the standard output,
+// followed by the HTTP response as it's produced. The report lacks the metrics, which are only known once the
+// response is complete.
+func streamRequest(ctx context.Context, instance *traefik.Traefik, req *http.Request) error {
+	errCh := make(chan error)
+	instance.OnReady(func() {
+		report := instance.Route(req)
+
+		var err error
+		if report.ResolvedConfig, err = instance.ResolvedConfig(); err != nil {
+			errCh <- fmt.Errorf("resolving configuration: %w", err)
+
+			return
+		}
+
+		// The report is written on the first line, followed by the HTTP response.
+		if err = json.NewEncoder(os.Stdout).Encode(report); err != nil {
+			errCh <- fmt.Errorf("writing report: %w", err)
+
+			return
+		}
+
+		streamWriter := traefik.NewStreamWriter(os.Stdout)
+		if err = instance.Stream(streamWriter, req); err != nil {
+			errCh <- err
+
+			return
+		}
+
+		errCh <- streamWriter.Close()
+	})
+
+	if err := instance.Start(ctx); err != nil {
+		return fmt.Errorf("starting Traefik instance: %w", err)
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errCh:
+		return err
 	}
 }
 
-func initializeTraefikLogger(logLevel string) error {
-	logCtx := zerolog.New(os.Stderr).With().Timestamp()
+func initializeTraefikLogger(logLevel string, output io.Writer) error {
+	logCtx := zerolog.New(output).With().Timestamp()
 
 	level, err := zerolog.ParseLevel(strings.ToLower(logLevel))
 	if err != nil {
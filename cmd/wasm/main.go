@@ -0,0 +1,61 @@
+//go:build js && wasm
+
+// Command wasm exposes the routing evaluation of the playground to JavaScript, so that the router matching a request
+// can be found in the browser, without a round-trip to the server. Build it with GOOS=js GOARCH=wasm, and load it
+// with the wasm_exec.js support file of the Go distribution it was built with.
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+
+	"github.com/jspdown/traefik-playground/internal/matcher"
+)
+
+// routeFuncName is the name of the global JavaScript function evaluating the routing of a request.
+const routeFuncName = "traefikPlaygroundRoute"
+
+func main() {
+	register()
+
+	// Keep the function callable for as long as the page lives.
+	select {}
+}
+
+// register sets the global JavaScript function evaluating the routing of a request.
+func register() {
+	js.Global().Set(routeFuncName, js.FuncOf(route))
+}
+
+// routeResponse is what route returns, JSON encoded: the matcher.Result, or why it couldn't be evaluated.
+type routeResponse struct {
+	Result *matcher.Result `json:"result,omitempty"`
+	Error  string          `json:"error,omitempty"`
+}
+
+// route evaluates the routing of a request. It takes the dynamic configuration in YAML and the JSON encoded
+// matcher.Request, and returns the JSON encoded routeResponse.
+func route(_ js.Value, args []js.Value) any {
+	if len(args) != 2 || args[0].Type() != js.TypeString || args[1].Type() != js.TypeString {
+		return encodeResponse(routeResponse{Error: "expected a dynamic configuration and a JSON encoded request"})
+	}
+
+	var req matcher.Request
+	if err := json.Unmarshal([]byte(args[1].String()), &req); err != nil {
+		return encodeResponse(routeResponse{Error: "decoding request: " + err.Error()})
+	}
+
+	result, err := matcher.Evaluate(args[0].String(), req)
+	if err != nil {
+		return encodeResponse(routeResponse{Error: err.Error()})
+	}
+
+	return encodeResponse(routeResponse{Result: &result})
+}
+
+func encodeResponse(res routeResponse) string {
+	// A routeResponse only holds strings, integers and booleans, it always encodes.
+	b, _ := json.Marshal(res)
+
+	return string(b)
+}
@@ -0,0 +1,71 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+	"testing"
+
+	"github.com/jspdown/traefik-playground/internal/matcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Run with: GOOS=js GOARCH=wasm go test -exec="$(go env GOROOT)/lib/wasm/go_js_wasm_exec" ./cmd/wasm
+func TestRoute(t *testing.T) {
+	register()
+
+	dynamicConfig := "http:\n  routers:\n    api:\n      rule: PathPrefix(`/api`)\n      service: whoami@playground\n"
+
+	tests := []struct {
+		name string
+		args []any
+		want routeResponse
+	}{
+		{
+			name: "matched router",
+			args: []any{dynamicConfig, `{"method": "GET", "url": "http://example.com/api/users"}`},
+			want: routeResponse{Result: &matcher.Result{
+				Matched:    true,
+				EntryPoint: "web",
+				Router:     "api@file",
+				Rule:       "PathPrefix(`/api`)",
+				Priority:   18,
+				Service:    "whoami@playground",
+			}},
+		},
+		{
+			name: "no matching router",
+			args: []any{dynamicConfig, `{"method": "GET", "url": "http://example.com/"}`},
+			want: routeResponse{Result: &matcher.Result{}},
+		},
+		{
+			name: "invalid dynamic configuration",
+			args: []any{"http: [", `{"method": "GET", "url": "http://example.com/"}`},
+			want: routeResponse{Error: "decoding dynamic configuration: yaml: line 1: did not find expected node content"},
+		},
+		{
+			name: "invalid request",
+			args: []any{dynamicConfig, `{"method": 1}`},
+			want: routeResponse{Error: "decoding request: json: cannot unmarshal number into Go struct field Request.method of type string"},
+		},
+		{
+			name: "missing request",
+			args: []any{dynamicConfig},
+			want: routeResponse{Error: "expected a dynamic configuration and a JSON encoded request"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := js.Global().Call(routeFuncName, test.args...)
+			require.Equal(t, js.TypeString, got.Type())
+
+			var res routeResponse
+			require.NoError(t, json.Unmarshal([]byte(got.String()), &res))
+
+			assert.Equal(t, test.want, res)
+		})
+	}
+}
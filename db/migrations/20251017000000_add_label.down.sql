@@ -0,0 +1,2 @@
+-- Drop the label of shared experiments.
+ALTER TABLE shared_experiments DROP COLUMN IF EXISTS label;
@@ -0,0 +1,3 @@
+-- Drop the short code of shared experiments.
+DROP INDEX IF EXISTS shared_experiments_short_code;
+ALTER TABLE shared_experiments DROP COLUMN IF EXISTS short_code;
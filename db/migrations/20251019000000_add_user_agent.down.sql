@@ -0,0 +1,2 @@
+-- Drop the user agent of the clients sharing experiments.
+ALTER TABLE shared_experiments DROP COLUMN IF EXISTS user_agent;
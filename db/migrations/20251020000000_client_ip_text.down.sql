@@ -0,0 +1,2 @@
+-- Store the client IPs as INET again. This fails if hashed client IPs are stored.
+ALTER TABLE shared_experiments ALTER COLUMN client_ip TYPE INET USING client_ip::inet;
@@ -0,0 +1,2 @@
+-- Store the client IPs as text, so that they can be replaced by their salted hash.
+ALTER TABLE shared_experiments ALTER COLUMN client_ip TYPE TEXT;
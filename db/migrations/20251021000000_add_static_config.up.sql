@@ -0,0 +1,2 @@
+-- Add the optional static configuration of shared experiments.
+ALTER TABLE shared_experiments ADD COLUMN static_config TEXT NOT NULL DEFAULT '';
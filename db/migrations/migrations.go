@@ -5,32 +5,60 @@ import (
 	"embed"
 	"errors"
 	"fmt"
+	"io/fs"
 
 	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
+	"github.com/golang-migrate/migrate/v4/database/sqlite"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 )
 
 //go:embed *.sql
 var migrationFS embed.FS
 
-// Migrate migrates the database.
+// sqliteMigrationFS holds the same migrations as migrationFS, written in the SQLite dialect.
+//
+//go:embed sqlite/*.sql
+var sqliteMigrationFS embed.FS
+
+// Migrate migrates the PostgreSQL database.
 func Migrate(db *sql.DB) error {
-	// Migrate the database.
-	migrationSource, err := iofs.New(migrationFS, ".")
+	driver, err := postgres.WithInstance(db, &postgres.Config{
+		MigrationsTable: "migrations",
+	})
 	if err != nil {
-		return fmt.Errorf("reading migrations: %w", err)
+		return fmt.Errorf("creating driver: %w", err)
 	}
-	defer func() { _ = migrationSource.Close() }()
 
-	driver, err := postgres.WithInstance(db, &postgres.Config{
+	return up(migrationFS, "postgres", driver)
+}
+
+// MigrateSQLite migrates the SQLite database.
+func MigrateSQLite(db *sql.DB) error {
+	driver, err := sqlite.WithInstance(db, &sqlite.Config{
 		MigrationsTable: "migrations",
 	})
 	if err != nil {
 		return fmt.Errorf("creating driver: %w", err)
 	}
 
-	migrator, err := migrate.NewWithInstance("iofs", migrationSource, "postgres", driver)
+	migrations, err := fs.Sub(sqliteMigrationFS, "sqlite")
+	if err != nil {
+		return fmt.Errorf("reading migrations: %w", err)
+	}
+
+	return up(migrations, "sqlite", driver)
+}
+
+func up(migrations fs.FS, driverName string, driver database.Driver) error {
+	migrationSource, err := iofs.New(migrations, ".")
+	if err != nil {
+		return fmt.Errorf("reading migrations: %w", err)
+	}
+	defer func() { _ = migrationSource.Close() }()
+
+	migrator, err := migrate.NewWithInstance("iofs", migrationSource, driverName, driver)
 	if err != nil {
 		return fmt.Errorf("creating migrator: %w", err)
 	}
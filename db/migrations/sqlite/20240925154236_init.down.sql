@@ -0,0 +1,2 @@
+-- Drop the shared_experiments table.
+DROP TABLE IF EXISTS shared_experiments;
@@ -0,0 +1,17 @@
+-- Create a table to store shared experiments.
+CREATE TABLE IF NOT EXISTS shared_experiments (
+  public_id           TEXT  PRIMARY KEY,
+
+  created_at          TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+  last_retrieved_at   TIMESTAMP,
+
+  hash TEXT UNIQUE NOT NULL,
+
+  -- IP address of the person sharing the experiment.
+  client_ip      TEXT NOT NULL,
+
+  dynamic_config  TEXT NOT NULL,
+  -- JSON encoded request and result.
+  request        BLOB NOT NULL,
+  result         BLOB NOT NULL
+);
@@ -0,0 +1,2 @@
+-- Add an optional label annotating shared experiments.
+ALTER TABLE shared_experiments ADD COLUMN label TEXT NOT NULL DEFAULT '' CHECK (length(label) <= 50);
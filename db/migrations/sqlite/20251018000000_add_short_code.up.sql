@@ -0,0 +1,3 @@
+-- Add a short code identifying shared experiments, easier to read and type than their public ID.
+ALTER TABLE shared_experiments ADD COLUMN short_code TEXT;
+CREATE UNIQUE INDEX shared_experiments_short_code ON shared_experiments (short_code);
@@ -0,0 +1,2 @@
+-- Add the user agent of the client sharing an experiment, kept to investigate abuses.
+ALTER TABLE shared_experiments ADD COLUMN user_agent TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,2 @@
+-- Client IPs are stored as text in SQLite whether or not they are hashed.
+SELECT 1;
@@ -0,0 +1,2 @@
+-- Client IPs are already stored as text in SQLite, so that they can be replaced by their salted hash.
+SELECT 1;
@@ -0,0 +1,2 @@
+-- Drop the static configuration of shared experiments.
+ALTER TABLE shared_experiments DROP COLUMN static_config;
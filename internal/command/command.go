@@ -2,7 +2,13 @@ package command
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"time"
 )
 
@@ -17,16 +23,68 @@ type MountPoint struct {
 	Target string
 }
 
-// NewIsolatedCommand runs a Command in isolation using BubbleWrap.
-func NewIsolatedCommand(ctx context.Context, mountPoints []MountPoint, args ...string) *exec.Cmd {
+func (m MountPoint) validate() error {
+	if !filepath.IsAbs(m.Host) {
+		return fmt.Errorf("mount point host path %q must be absolute", m.Host)
+	}
+
+	if _, err := os.Stat(m.Host); err != nil {
+		return fmt.Errorf("mount point host path %q: %w", m.Host, err)
+	}
+
+	return nil
+}
+
+// ResourceLimits defines the resources an isolated command can use. Zero values mean unlimited.
+type ResourceLimits struct {
+	// MaxMemory is the maximum size of the virtual memory of the command, in bytes.
+	MaxMemory int
+	// MaxCPUTime is the maximum CPU time the command can consume. It is rounded up to the second.
+	MaxCPUTime time.Duration
+}
+
+// args returns the prlimit arguments enforcing the limits, or nil when there is nothing to limit.
+func (l ResourceLimits) args() []string {
+	var args []string
+	if l.MaxMemory > 0 {
+		args = append(args, "--as="+strconv.Itoa(l.MaxMemory))
+	}
+	if l.MaxCPUTime > 0 {
+		args = append(args, "--cpu="+strconv.Itoa(int(math.Ceil(l.MaxCPUTime.Seconds()))))
+	}
+
+	return args
+}
+
+// NewIsolatedCommand runs a Command in isolation using BubbleWrap. The resource limits are set with prlimit
+// before starting BubbleWrap, and are inherited by the Command. The environment is cleared, except for the
+// variables listed in passEnv which are forwarded from the current process when set. An error is returned
+// if the host path of a MountPoint is relative or doesn't exist.
+func NewIsolatedCommand(ctx context.Context, mountPoints []MountPoint, limits ResourceLimits, passEnv []string, args ...string) (*exec.Cmd, error) {
 	commandArgs := make([]string, 0, len(mountPoints))
 	for _, mountPoint := range mountPoints {
+		if err := mountPoint.validate(); err != nil {
+			return nil, err
+		}
+
 		commandArgs = append(commandArgs, "--ro-bind", mountPoint.Host, mountPoint.Target)
 	}
 	commandArgs = append(commandArgs, "--unshare-all", "--clearenv", "--new-session")
+	for _, name := range passEnv {
+		if value, ok := os.LookupEnv(name); ok {
+			commandArgs = append(commandArgs, "--setenv", name, value)
+		}
+	}
 	commandArgs = append(commandArgs, args...)
 
-	return exec.CommandContext(ctx, "bwrap", commandArgs...) //nolint:gosec // Args are sanitized.
+	limitArgs := limits.args()
+	if len(limitArgs) == 0 {
+		return exec.CommandContext(ctx, "bwrap", commandArgs...), nil //nolint:gosec // Args are sanitized.
+	}
+
+	limitArgs = append(limitArgs, "--", "bwrap")
+
+	return exec.CommandContext(ctx, "prlimit", append(limitArgs, commandArgs...)...), nil //nolint:gosec // Args are sanitized.
 }
 
 // WithTimeout is a helper Command that wraps a Command and adds an execution timeout.
@@ -43,10 +101,22 @@ func NewWithTimeout(command Command, timeout time.Duration) WithTimeout {
 	}
 }
 
-// Exec calls the underlying Command with a timeout.
+// Exec calls the underlying Command with a timeout. An error wrapping context.DeadlineExceeded is returned when the
+// timeout expires before the Command completes, even if the Command ignores it, such as a killed process.
 func (c WithTimeout) Exec(ctx context.Context) error {
-	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	timeoutCtx, cancel := context.WithTimeout(ctx, c.timeout)
 	defer cancel()
 
-	return c.command.Exec(ctx)
+	err := c.command.Exec(timeoutCtx)
+
+	timedOut := ctx.Err() == nil && errors.Is(timeoutCtx.Err(), context.DeadlineExceeded)
+	if !timedOut || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+
+	if err == nil {
+		return fmt.Errorf("command timed out after %s: %w", c.timeout, context.DeadlineExceeded)
+	}
+
+	return fmt.Errorf("command timed out after %s: %w: %w", c.timeout, context.DeadlineExceeded, err)
 }
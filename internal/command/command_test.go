@@ -0,0 +1,194 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewIsolatedCommand_resourceLimits(t *testing.T) {
+	t.Parallel()
+
+	host := t.TempDir()
+	mountPoints := []MountPoint{{Host: host, Target: "/app"}}
+
+	tests := []struct {
+		name     string
+		limits   ResourceLimits
+		wantArgs []string
+	}{
+		{
+			name:   "no limits",
+			limits: ResourceLimits{},
+			wantArgs: []string{
+				"bwrap", "--ro-bind", host, "/app", "--unshare-all", "--clearenv", "--new-session",
+				"/app/traefik-playground", "tester",
+			},
+		},
+		{
+			name:   "memory and CPU time limits",
+			limits: ResourceLimits{MaxMemory: 512 << 20, MaxCPUTime: 1500 * time.Millisecond},
+			wantArgs: []string{
+				"prlimit", "--as=536870912", "--cpu=2", "--",
+				"bwrap", "--ro-bind", host, "/app", "--unshare-all", "--clearenv", "--new-session",
+				"/app/traefik-playground", "tester",
+			},
+		},
+		{
+			name:   "CPU time limit only",
+			limits: ResourceLimits{MaxCPUTime: 10 * time.Second},
+			wantArgs: []string{
+				"prlimit", "--cpu=10", "--",
+				"bwrap", "--ro-bind", host, "/app", "--unshare-all", "--clearenv", "--new-session",
+				"/app/traefik-playground", "tester",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			cmd, err := NewIsolatedCommand(context.Background(), mountPoints, test.limits, nil, "/app/traefik-playground", "tester")
+			require.NoError(t, err)
+
+			assert.Equal(t, test.wantArgs, cmd.Args)
+		})
+	}
+}
+
+func TestNewIsolatedCommand_mountPoints(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		host    string
+		wantErr string
+	}{
+		{
+			name: "valid mount point",
+			host: t.TempDir(),
+		},
+		{
+			name:    "relative host path",
+			host:    "app",
+			wantErr: `mount point host path "app" must be absolute`,
+		},
+		{
+			name:    "nonexistent host path",
+			host:    "/nonexistent/traefik-playground",
+			wantErr: `mount point host path "/nonexistent/traefik-playground": stat /nonexistent/traefik-playground: no such file or directory`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			cmd, err := NewIsolatedCommand(context.Background(), []MountPoint{{Host: test.host, Target: "/app"}}, ResourceLimits{}, nil, "true")
+			if test.wantErr != "" {
+				require.EqualError(t, err, test.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Contains(t, cmd.Args, test.host)
+		})
+	}
+}
+
+//nolint:paralleltest // Modifies the environment.
+func TestNewIsolatedCommand_passEnv(t *testing.T) {
+	t.Setenv("TZ", "Europe/Paris")
+	t.Setenv("PLAYGROUND_SECRET", "secret")
+
+	cmd, err := NewIsolatedCommand(context.Background(), nil, ResourceLimits{}, []string{"TZ", "PLAYGROUND_UNSET"}, "true")
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		"bwrap", "--unshare-all", "--clearenv", "--new-session",
+		"--setenv", "TZ", "Europe/Paris",
+		"true",
+	}, cmd.Args)
+}
+
+func TestWithTimeout_Exec(t *testing.T) {
+	t.Parallel()
+
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		desc    string
+		command Command
+		wantErr []error
+	}{
+		{
+			desc:    "completed in time",
+			command: funcCommand(func(context.Context) error { return nil }),
+		},
+		{
+			desc:    "failed in time",
+			command: funcCommand(func(context.Context) error { return errFailed }),
+			wantErr: []error{errFailed},
+		},
+		{
+			desc: "timed out",
+			command: funcCommand(func(ctx context.Context) error {
+				<-ctx.Done()
+
+				return ctx.Err()
+			}),
+			wantErr: []error{context.DeadlineExceeded},
+		},
+		{
+			desc: "timed out, ignoring the context error",
+			command: funcCommand(func(ctx context.Context) error {
+				<-ctx.Done()
+
+				return nil
+			}),
+			wantErr: []error{context.DeadlineExceeded},
+		},
+		{
+			desc: "timed out, with another error",
+			command: funcCommand(func(ctx context.Context) error {
+				<-ctx.Done()
+
+				return errFailed
+			}),
+			wantErr: []error{context.DeadlineExceeded, errFailed},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			err := NewWithTimeout(test.command, 10*time.Millisecond).Exec(context.Background())
+			if len(test.wantErr) == 0 {
+				require.NoError(t, err)
+
+				return
+			}
+
+			for _, wantErr := range test.wantErr {
+				require.ErrorIs(t, err, wantErr)
+			}
+		})
+	}
+}
+
+func TestWithTimeout_Exec_canceled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := NewWithTimeout(funcCommand(func(context.Context) error { return nil }), time.Second).Exec(ctx)
+	require.NoError(t, err)
+}
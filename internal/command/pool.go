@@ -2,18 +2,69 @@ package command
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"sync"
+	"time"
 )
 
+// ErrNoWorkerAvailable indicates that a command couldn't be started because no worker became available in time.
+var ErrNoWorkerAvailable = errors.New("no worker available")
+
+// Priority is the priority of a Command waiting for a worker.
+type Priority int
+
+// List of supported Priority values.
+const (
+	// PriorityLow is the priority of background or bulk commands, which can wait for the interactive ones.
+	PriorityLow Priority = iota
+	// PriorityHigh is the priority of interactive commands, such as the experiments run from the UI.
+	PriorityHigh
+)
+
+// maxPriorityWait is how long a command can wait for a worker before getting the next one regardless of its
+// Priority, so that a steady flow of high priority commands can't starve the low priority ones.
+const maxPriorityWait = 5 * time.Second
+
+type priorityKey struct{}
+
+// WithPriority returns a copy of the given context holding the given Priority, for the commands spawned on its
+// behalf, see PriorityFromContext.
+func WithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// PriorityFromContext returns the Priority held by the given context, PriorityHigh if none.
+func PriorityFromContext(ctx context.Context) Priority {
+	if priority, ok := ctx.Value(priorityKey{}).(Priority); ok {
+		return priority
+	}
+
+	return PriorityHigh
+}
+
 // WorkerPool is a pool of worker for executing commands limiting the maximum number
-// of concurrent commands.
+// of concurrent commands. Waiting commands get a worker in order of Priority, then in order of arrival, unless
+// they have been waiting for too long.
 type WorkerPool struct {
-	spawnSlots chan struct{}
-
+	maxSlots          int
 	maxWaitQueueDepth int
-	waitQueueDepth    int
-	waitQueueDepthMu  sync.Mutex
+	// maxPriorityWait is how long a command waits before getting the next worker regardless of its Priority.
+	maxPriorityWait time.Duration
+
+	mu       sync.Mutex
+	inFlight int
+	// waitQueues holds the commands waiting for a worker, by Priority.
+	waitQueues [PriorityHigh + 1][]*waiter
+	// outcomes counts the spawned commands by outcome.
+	outcomes Outcomes
+}
+
+// waiter is a command waiting for a worker. Its ready channel is closed once a worker is handed over to it.
+type waiter struct {
+	ready    chan struct{}
+	queuedAt time.Time
 }
 
 // NewWorkerPool creates a new WorkerPool.
@@ -21,47 +72,179 @@ type WorkerPool struct {
 // - maxSlots controls the maximum number of concurrent workers.
 // - maxWaitQueueDepth controls how many commands can wait for a worker to be available.
 func NewWorkerPool(maxSlots int, maxWaitQueueDepth int) *WorkerPool {
-	spawnSlots := make(chan struct{}, maxSlots)
-	for range maxSlots {
-		spawnSlots <- struct{}{}
-	}
-
 	return &WorkerPool{
-		spawnSlots:        spawnSlots,
+		maxSlots:          maxSlots,
 		maxWaitQueueDepth: maxWaitQueueDepth,
+		maxPriorityWait:   maxPriorityWait,
+	}
+}
+
+// Spawn spawns a Command with the given Priority. Waiting commands with a higher Priority get a worker first,
+// unless a lower priority one has been waiting for too long, and running commands are never interrupted.
+// ErrNoWorkerAvailable is returned if the Command couldn't be started, either because the queue is full or because
+// the context ended while waiting for a worker.
+// The outcome of the Command is counted in the Outcomes of the pool.
+func (s *WorkerPool) Spawn(ctx context.Context, command Command, priority Priority) error {
+	if err := s.acquire(ctx, priority); err != nil {
+		s.count(&s.outcomes.Rejected)
+
+		return err
+	}
+	defer s.release()
+
+	err := command.Exec(ctx)
+
+	switch {
+	case err == nil:
+		s.count(&s.outcomes.Succeeded)
+	case errors.Is(err, context.DeadlineExceeded):
+		s.count(&s.outcomes.TimedOut)
+	default:
+		s.count(&s.outcomes.Failed)
 	}
+
+	return err
+}
+
+// count increments the given counter of the pool.
+func (s *WorkerPool) count(counter *int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	*counter++
 }
 
-// Spawn spawns a Command.
-func (s *WorkerPool) Spawn(ctx context.Context, command Command) error {
+// Outcomes returns the number of commands spawned by the pool, by outcome.
+func (s *WorkerPool) Outcomes() Outcomes {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.outcomes
+}
+
+func (s *WorkerPool) acquire(ctx context.Context, priority Priority) error {
+	priority = max(PriorityLow, min(priority, PriorityHigh))
+
+	s.mu.Lock()
+	if s.inFlight < s.maxSlots {
+		s.inFlight++
+		s.mu.Unlock()
+
+		return nil
+	}
+
 	// Make sure it's worth trying to wait in the queue, otherwise abort immediately.
-	s.waitQueueDepthMu.Lock()
-	if s.waitQueueDepth >= s.maxWaitQueueDepth {
-		s.waitQueueDepthMu.Unlock()
+	if s.queueDepth() >= s.maxWaitQueueDepth {
+		s.mu.Unlock()
 
-		return fmt.Errorf("too many commands in the queue: %w", context.DeadlineExceeded)
+		return fmt.Errorf("%w: too many commands in the queue: %w", ErrNoWorkerAvailable, context.DeadlineExceeded)
 	}
-	s.waitQueueDepth++
-	s.waitQueueDepthMu.Unlock()
 
-	var err error
+	w := &waiter{ready: make(chan struct{}), queuedAt: time.Now()}
+	s.waitQueues[priority] = append(s.waitQueues[priority], w)
+	s.mu.Unlock()
+
 	select {
+	case <-w.ready:
+		return nil
 	case <-ctx.Done():
-		err = ctx.Err()
-	case <-s.spawnSlots:
 	}
 
-	s.waitQueueDepthMu.Lock()
-	s.waitQueueDepth--
-	s.waitQueueDepthMu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	if err != nil {
-		return err
+	queue := s.waitQueues[priority]
+	if i := slices.Index(queue, w); i >= 0 {
+		s.waitQueues[priority] = slices.Delete(queue, i, i+1)
+
+		return fmt.Errorf("%w: %w", ErrNoWorkerAvailable, ctx.Err())
 	}
 
-	defer func() {
-		s.spawnSlots <- struct{}{}
-	}()
+	// A worker was handed over while the context ended, hand it over to the next command instead.
+	s.handOver()
+
+	return fmt.Errorf("%w: %w", ErrNoWorkerAvailable, ctx.Err())
+}
+
+func (s *WorkerPool) release() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.handOver()
+}
+
+// handOver hands the worker of a command which completed over to the next waiting command, or frees it if none
+// is waiting. The command which waited the longest goes first if it waited for more than maxPriorityWait, the one
+// with the highest Priority otherwise. The mu lock must be held.
+func (s *WorkerPool) handOver() {
+	next := -1
+	for priority := PriorityHigh; priority >= PriorityLow; priority-- {
+		queue := s.waitQueues[priority]
+		if len(queue) == 0 {
+			continue
+		}
+
+		if next < 0 || queue[0].queuedAt.Before(s.waitQueues[next][0].queuedAt) &&
+			time.Since(queue[0].queuedAt) > s.maxPriorityWait {
+			next = int(priority)
+		}
+	}
 
-	return command.Exec(ctx)
+	if next < 0 {
+		s.inFlight--
+
+		return
+	}
+
+	queue := s.waitQueues[next]
+	s.waitQueues[next] = queue[1:]
+	close(queue[0].ready)
+}
+
+// queueDepth returns the number of commands waiting for a worker. The mu lock must be held.
+func (s *WorkerPool) queueDepth() int {
+	var depth int
+	for _, queue := range s.waitQueues {
+		depth += len(queue)
+	}
+
+	return depth
+}
+
+// PoolStats is a snapshot of the usage of a WorkerPool.
+type PoolStats struct {
+	// InFlight is the number of commands being executed, out of MaxInFlight.
+	InFlight    int `json:"inFlight"`
+	MaxInFlight int `json:"maxInFlight"`
+	// QueueDepth is the number of commands waiting for a worker, out of MaxQueueDepth.
+	QueueDepth    int `json:"queueDepth"`
+	MaxQueueDepth int `json:"maxQueueDepth"`
+	// Outcomes counts the commands spawned since the pool was created, by outcome.
+	Outcomes Outcomes `json:"outcomes"`
+}
+
+// Outcomes counts the commands spawned by a WorkerPool, by outcome.
+type Outcomes struct {
+	// Succeeded is the number of commands which completed without error.
+	Succeeded int64 `json:"succeeded"`
+	// Failed is the number of commands which completed with an error, other than a timeout.
+	Failed int64 `json:"failed"`
+	// TimedOut is the number of commands which didn't complete in time, such as the ones run with WithTimeout.
+	TimedOut int64 `json:"timedOut"`
+	// Rejected is the number of commands which never started because no worker was available.
+	Rejected int64 `json:"rejected"`
+}
+
+// Stats returns a snapshot of the usage of the pool.
+func (s *WorkerPool) Stats() PoolStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return PoolStats{
+		InFlight:      s.inFlight,
+		MaxInFlight:   s.maxSlots,
+		QueueDepth:    s.queueDepth(),
+		MaxQueueDepth: s.maxWaitQueueDepth,
+		Outcomes:      s.outcomes,
+	}
 }
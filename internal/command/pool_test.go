@@ -2,6 +2,7 @@ package command
 
 import (
 	"context"
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -36,7 +37,7 @@ func TestWorkerPool_Spawn_singleCommand(t *testing.T) {
 	pool := NewWorkerPool(1, 1)
 	cmd := &mockCommand{}
 
-	err := pool.Spawn(context.Background(), cmd)
+	err := pool.Spawn(context.Background(), cmd, PriorityHigh)
 	require.NoError(t, err)
 	assert.True(t, cmd.Executed, "command should have been executed")
 }
@@ -61,7 +62,7 @@ func TestWorkerPool_Spawn_concurrentCommands(t *testing.T) {
 			cmd := &mockCommand{delay: 50 * time.Millisecond}
 			commands[i] = cmd
 
-			if err := pool.Spawn(context.Background(), cmd); err != nil {
+			if err := pool.Spawn(context.Background(), cmd, PriorityHigh); err != nil {
 				errsMu.Lock()
 				errs = append(errs, err)
 				errsMu.Unlock()
@@ -87,15 +88,16 @@ func TestWorkerPool_Spawn_queueFull(t *testing.T) {
 
 	// Block the only worker.
 	longCmd := &mockCommand{delay: time.Second}
-	go func() { _ = pool.Spawn(ctx, longCmd) }()
+	go func() { _ = pool.Spawn(ctx, longCmd, PriorityHigh) }()
 
 	// Wait a bit to ensure the first command is running.
 	time.Sleep(50 * time.Millisecond)
 
 	// Try to spawn more commands than queue can handle.
 	cmd := &mockCommand{}
-	err := pool.Spawn(context.Background(), cmd)
+	err := pool.Spawn(context.Background(), cmd, PriorityHigh)
 	require.Error(t, err, "should return error when queue is full")
+	assert.ErrorIs(t, err, ErrNoWorkerAvailable)
 	assert.ErrorIs(t, err, context.DeadlineExceeded)
 }
 
@@ -106,7 +108,7 @@ func TestWorkerPool_Spawn_contextCancellation(t *testing.T) {
 
 	// Block the only worker.
 	longCmd := &mockCommand{delay: time.Second}
-	go func() { _ = pool.Spawn(context.Background(), longCmd) }()
+	go func() { _ = pool.Spawn(context.Background(), longCmd, PriorityHigh) }()
 
 	// Wait a bit to ensure the first command is running.
 	time.Sleep(50 * time.Millisecond)
@@ -115,8 +117,250 @@ func TestWorkerPool_Spawn_contextCancellation(t *testing.T) {
 	cancel()
 
 	cmd := &mockCommand{}
-	err := pool.Spawn(ctx, cmd)
+	err := pool.Spawn(ctx, cmd, PriorityHigh)
 	require.Error(t, err)
 	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorIs(t, err, ErrNoWorkerAvailable)
 	assert.False(t, cmd.Executed)
 }
+
+func TestWorkerPool_Stats(t *testing.T) {
+	t.Parallel()
+
+	pool := NewWorkerPool(1, 2)
+	assert.Equal(t, PoolStats{MaxInFlight: 1, MaxQueueDepth: 2}, pool.Stats())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Block the only worker, and queue a second command behind it.
+	go func() { _ = pool.Spawn(ctx, &mockCommand{delay: time.Second}, PriorityHigh) }()
+
+	assert.Eventually(t, func() bool { return pool.Stats().InFlight == 1 }, time.Second, 10*time.Millisecond)
+
+	go func() { _ = pool.Spawn(ctx, &mockCommand{}, PriorityHigh) }()
+
+	assert.Eventually(t, func() bool {
+		return pool.Stats() == PoolStats{InFlight: 1, MaxInFlight: 1, QueueDepth: 1, MaxQueueDepth: 2}
+	}, time.Second, 10*time.Millisecond)
+}
+
+type funcCommand func(ctx context.Context) error
+
+func (f funcCommand) Exec(ctx context.Context) error {
+	return f(ctx)
+}
+
+func TestWorkerPool_Spawn_priority(t *testing.T) {
+	t.Parallel()
+
+	pool := NewWorkerPool(1, 3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Block the only worker until the other commands are queued.
+	unblock := make(chan struct{})
+	go func() {
+		_ = pool.Spawn(ctx, funcCommand(func(context.Context) error {
+			<-unblock
+
+			return nil
+		}), PriorityHigh)
+	}()
+
+	assert.Eventually(t, func() bool { return pool.Stats().InFlight == 1 }, time.Second, 10*time.Millisecond)
+
+	var (
+		wg      sync.WaitGroup
+		orderMu sync.Mutex
+		order   []string
+	)
+
+	spawn := func(name string, priority Priority) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			err := pool.Spawn(ctx, funcCommand(func(context.Context) error {
+				orderMu.Lock()
+				defer orderMu.Unlock()
+
+				order = append(order, name)
+
+				return nil
+			}), priority)
+			assert.NoError(t, err)
+		}()
+	}
+
+	// Queue the commands one at a time, so that their order of arrival is known.
+	for i, command := range []struct {
+		name     string
+		priority Priority
+	}{
+		{name: "low-1", priority: PriorityLow},
+		{name: "low-2", priority: PriorityLow},
+		{name: "high", priority: PriorityHigh},
+	} {
+		spawn(command.name, command.priority)
+
+		assert.Eventually(t, func() bool { return pool.Stats().QueueDepth == i+1 }, time.Second, 10*time.Millisecond)
+	}
+
+	close(unblock)
+	wg.Wait()
+
+	assert.Equal(t, []string{"high", "low-1", "low-2"}, order)
+	assert.Equal(t, PoolStats{MaxInFlight: 1, MaxQueueDepth: 3, Outcomes: Outcomes{Succeeded: 4}}, pool.Stats())
+}
+
+func TestWorkerPool_Spawn_priorityAging(t *testing.T) {
+	t.Parallel()
+
+	pool := NewWorkerPool(1, 3)
+	pool.maxPriorityWait = 50 * time.Millisecond
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Block the only worker until the other commands are queued.
+	unblock := make(chan struct{})
+	go func() {
+		_ = pool.Spawn(ctx, funcCommand(func(context.Context) error {
+			<-unblock
+
+			return nil
+		}), PriorityHigh)
+	}()
+
+	assert.Eventually(t, func() bool { return pool.Stats().InFlight == 1 }, time.Second, 10*time.Millisecond)
+
+	var (
+		wg      sync.WaitGroup
+		orderMu sync.Mutex
+		order   []string
+	)
+
+	spawn := func(name string, priority Priority) {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			err := pool.Spawn(ctx, funcCommand(func(context.Context) error {
+				orderMu.Lock()
+				defer orderMu.Unlock()
+
+				order = append(order, name)
+
+				return nil
+			}), priority)
+			assert.NoError(t, err)
+		}()
+	}
+
+	spawn("low", PriorityLow)
+	assert.Eventually(t, func() bool { return pool.Stats().QueueDepth == 1 }, time.Second, 10*time.Millisecond)
+
+	// The low priority command has waited for too long once the high priority ones are queued.
+	time.Sleep(2 * pool.maxPriorityWait)
+
+	spawn("high-1", PriorityHigh)
+	assert.Eventually(t, func() bool { return pool.Stats().QueueDepth == 2 }, time.Second, 10*time.Millisecond)
+	spawn("high-2", PriorityHigh)
+	assert.Eventually(t, func() bool { return pool.Stats().QueueDepth == 3 }, time.Second, 10*time.Millisecond)
+
+	close(unblock)
+	wg.Wait()
+
+	assert.Equal(t, []string{"low", "high-1", "high-2"}, order)
+}
+
+func TestPriorityFromContext(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, PriorityHigh, PriorityFromContext(context.Background()))
+	assert.Equal(t, PriorityLow, PriorityFromContext(WithPriority(context.Background(), PriorityLow)))
+}
+
+func TestWorkerPool_Spawn_canceledWhileQueued(t *testing.T) {
+	t.Parallel()
+
+	pool := NewWorkerPool(1, 2)
+
+	// Block the only worker.
+	unblock := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		_ = pool.Spawn(context.Background(), funcCommand(func(context.Context) error {
+			<-unblock
+
+			return nil
+		}), PriorityHigh)
+	}()
+
+	assert.Eventually(t, func() bool { return pool.Stats().InFlight == 1 }, time.Second, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	cmd := &mockCommand{}
+	err := pool.Spawn(ctx, cmd, PriorityLow)
+	require.ErrorIs(t, err, ErrNoWorkerAvailable)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.False(t, cmd.Executed)
+
+	// The canceled command left the queue, and the worker is freed once the blocking command completes.
+	assert.Equal(t, PoolStats{InFlight: 1, MaxInFlight: 1, MaxQueueDepth: 2, Outcomes: Outcomes{Rejected: 1}}, pool.Stats())
+
+	close(unblock)
+	<-done
+
+	assert.Equal(t, PoolStats{MaxInFlight: 1, MaxQueueDepth: 2, Outcomes: Outcomes{Succeeded: 1, Rejected: 1}}, pool.Stats())
+}
+
+func TestWorkerPool_Spawn_outcomes(t *testing.T) {
+	t.Parallel()
+
+	pool := NewWorkerPool(1, 0)
+
+	errFailed := errors.New("failed")
+
+	err := pool.Spawn(context.Background(), funcCommand(func(context.Context) error { return nil }), PriorityHigh)
+	require.NoError(t, err)
+
+	err = pool.Spawn(context.Background(), funcCommand(func(context.Context) error { return errFailed }), PriorityHigh)
+	require.ErrorIs(t, err, errFailed)
+
+	err = pool.Spawn(context.Background(), NewWithTimeout(&mockCommand{delay: time.Second}, 10*time.Millisecond), PriorityHigh)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// Block the only worker, so that the next command is rejected as the queue can't hold any command.
+	unblock := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+
+		_ = pool.Spawn(context.Background(), funcCommand(func(context.Context) error {
+			<-unblock
+
+			return nil
+		}), PriorityHigh)
+	}()
+
+	assert.Eventually(t, func() bool { return pool.Stats().InFlight == 1 }, time.Second, 10*time.Millisecond)
+
+	err = pool.Spawn(context.Background(), &mockCommand{}, PriorityHigh)
+	require.ErrorIs(t, err, ErrNoWorkerAvailable)
+
+	close(unblock)
+	<-done
+
+	want := Outcomes{Succeeded: 2, Failed: 1, TimedOut: 1, Rejected: 1}
+	assert.Equal(t, want, pool.Outcomes())
+	assert.Equal(t, want, pool.Stats().Outcomes)
+}
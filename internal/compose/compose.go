@@ -3,13 +3,83 @@ package compose
 
 import (
 	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
 	"strings"
+
+	"github.com/jspdown/traefik-playground/internal/version"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	// tcpEntryPoint is the entrypoint added for TCP routers, it has no equivalent in the playground.
+	tcpEntryPoint = "tcp"
+	tcpPort       = "8000"
+	// udpEntryPoint matches the name of the UDP entrypoint of the playground.
+	udpEntryPoint = "udp"
+	udpPort       = "53"
 )
 
 // Generate creates a docker-compose YAML configuration to test the given Traefik dynamic configuration.
-func Generate(dynamicConfig string) string {
+// It fails if the configuration relies on playground backends which have no docker-compose equivalent.
+func Generate(dynamicConfig string) (string, error) {
+	if unsupported := unsupportedPlaygroundBackends(dynamicConfig); len(unsupported) > 0 {
+		return "", fmt.Errorf("playground backends with no docker-compose equivalent: %s", strings.Join(unsupported, ", "))
+	}
+
+	hasTCP, hasUDP := detectSections(dynamicConfig)
+
 	dynamicConfig = transformDynamicConfigForDocker(dynamicConfig)
 
+	var entryPoints, ports, backends strings.Builder
+
+	for _, backend := range userBackends(dynamicConfig) {
+		dynamicConfig = backend.rewrite(dynamicConfig)
+
+		fmt.Fprintf(&backends, `
+  %s:
+    image: traefik/whoami
+`, backend.container)
+		if backend.port != "80" {
+			fmt.Fprintf(&backends, "    command: [\"--port\", \"%s\"]\n", backend.port)
+		}
+		if backend.serverURL.Scheme == "https" {
+			backends.WriteString("    # Serves plain HTTP: mount a certificate and pass it with --cert and --key to serve HTTPS.\n")
+		}
+		backends.WriteString(`    networks:
+      - traefik-network
+`)
+	}
+
+	if hasTCP {
+		fmt.Fprintf(&entryPoints, "      - --entrypoints.%s.address=:%s\n", tcpEntryPoint, tcpPort)
+		fmt.Fprintf(&ports, "      - \"%s:%s\"\n", tcpPort, tcpPort)
+		backends.WriteString(`
+  whoami-tcp:
+    image: traefik/whoamitcp
+    networks:
+      - traefik-network
+    labels:
+      - "traefik.enable=true"
+      - "traefik.tcp.services.whoami-tcp.loadbalancer.server.port=8080"
+`)
+	}
+
+	if hasUDP {
+		fmt.Fprintf(&entryPoints, "      - --entrypoints.%s.address=:%s/udp\n", udpEntryPoint, udpPort)
+		fmt.Fprintf(&ports, "      - \"%s:%s/udp\"\n", udpPort, udpPort)
+		backends.WriteString(`
+  whoami-udp:
+    image: traefik/whoamiudp
+    networks:
+      - traefik-network
+    labels:
+      - "traefik.enable=true"
+      - "traefik.udp.services.whoami-udp.loadbalancer.server.port=8080"
+`)
+	}
+
 	return fmt.Sprintf(`configs:
   traefik-dynamic:
     content: |
@@ -17,18 +87,18 @@ func Generate(dynamicConfig string) string {
 
 services:
   traefik:
-    image: traefik:v3.4.4
+    image: traefik:%s
     command:
       - --api.insecure=true
       - --providers.file.filename=/etc/traefik/dynamic.yaml
       - --providers.docker=true
       - --providers.docker.exposedByDefault=false
       - --entrypoints.web.address=:80
-      - --log.level=debug
+%s      - --log.level=debug
     ports:
       - "80:80"
       - "8080:8080"
-    volumes:
+%s    volumes:
       - /var/run/docker.sock:/var/run/docker.sock:ro
     configs:
       - source: traefik-dynamic
@@ -43,11 +113,180 @@ services:
     labels:
       - "traefik.enable=true"
       - "traefik.http.services.whoami.loadbalancer.server.port=80"
-
+%s
 networks:
   traefik-network:
     driver: bridge
-`, indentContent(dynamicConfig, "      "))
+`, indentContent(dynamicConfig, "      "), version.TraefikVersion, entryPoints.String(), ports.String(), backends.String()), nil
+}
+
+// unsupportedPlaygroundBackends lists the playground services and URLs referenced by the given dynamic configuration
+// which have no docker-compose equivalent, such as auth@playground. Only the whoami backends have one.
+func unsupportedPlaygroundBackends(dynamicConfig string) []string {
+	var unsupported []string
+
+	for _, match := range playgroundServiceRegexp.FindAllStringSubmatch(dynamicConfig, -1) {
+		if match[1] != "whoami" && match[1] != "whoami-udp" && !slices.Contains(unsupported, match[0]) {
+			unsupported = append(unsupported, match[0])
+		}
+	}
+
+	for _, match := range playgroundBackendURLRegexp.FindAllStringSubmatch(dynamicConfig, -1) {
+		if match[1] != "10" && !slices.Contains(unsupported, match[0]) {
+			unsupported = append(unsupported, match[0])
+		}
+	}
+
+	return unsupported
+}
+
+// detectSections reports whether the given dynamic configuration declares TCP and UDP sections.
+func detectSections(dynamicConfig string) (hasTCP, hasUDP bool) {
+	var sections map[string]any
+	if err := yaml.Unmarshal([]byte(dynamicConfig), &sections); err != nil {
+		return false, false
+	}
+
+	return sections["tcp"] != nil, sections["udp"] != nil
+}
+
+// userBackend is a placeholder container standing for a server of a user-defined HTTP service.
+type userBackend struct {
+	container string
+	serverURL *url.URL
+	// port is the port the container listens on, the one of the server.
+	port string
+}
+
+// rewrite replaces the URL of the server with the one of the container, keeping its scheme and port.
+func (b userBackend) rewrite(dynamicConfig string) string {
+	containerURL := *b.serverURL
+	containerURL.Host = b.container
+	if port := b.serverURL.Port(); port != "" {
+		containerURL.Host += ":" + port
+	}
+
+	return replaceURL(dynamicConfig, b.serverURL.String(), containerURL.String())
+}
+
+// replaceURL replaces the given URL with the given replacement in the given dynamic configuration. Only full URLs are
+// replaced, so "http://api" doesn't rewrite "http://api2".
+func replaceURL(dynamicConfig, oldURL, newURL string) string {
+	var result strings.Builder
+
+	for {
+		i := strings.Index(dynamicConfig, oldURL)
+		if i < 0 {
+			break
+		}
+
+		end := i + len(oldURL)
+		result.WriteString(dynamicConfig[:i])
+		if end < len(dynamicConfig) && isURLChar(dynamicConfig[end]) {
+			result.WriteString(oldURL)
+		} else {
+			result.WriteString(newURL)
+		}
+
+		dynamicConfig = dynamicConfig[end:]
+	}
+
+	result.WriteString(dynamicConfig)
+
+	return result.String()
+}
+
+// isURLChar reports whether the given character can continue the host or the port of a URL.
+func isURLChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("_.:/-", c) >= 0
+}
+
+// userBackends lists the placeholder containers required by the HTTP services of the given dynamic
+// configuration, one per server, sorted by service name. Servers already targeting the whoami container don't need
+// one. A server URL shared by several services is served by the container of the first of them.
+func userBackends(dynamicConfig string) []userBackend {
+	var config struct {
+		HTTP struct {
+			Services map[string]struct {
+				LoadBalancer *struct {
+					Servers []struct {
+						URL string `yaml:"url"`
+					} `yaml:"servers"`
+				} `yaml:"loadBalancer"`
+			} `yaml:"services"`
+		} `yaml:"http"`
+	}
+	if err := yaml.Unmarshal([]byte(dynamicConfig), &config); err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(config.HTTP.Services))
+	for name := range config.HTTP.Services {
+		names = append(names, name)
+	}
+
+	slices.Sort(names)
+
+	var backends []userBackend
+
+	seenURLs := make(map[string]struct{})
+	containers := make(map[string]struct{})
+	for _, name := range names {
+		loadBalancer := config.HTTP.Services[name].LoadBalancer
+		if loadBalancer == nil {
+			continue
+		}
+
+		for _, server := range loadBalancer.Servers {
+			if _, ok := seenURLs[server.URL]; ok || server.URL == "" {
+				continue
+			}
+
+			u, err := url.Parse(server.URL)
+			if err != nil || u.Host == "" || u.Hostname() == "whoami" {
+				continue
+			}
+
+			port := u.Port()
+			if port == "" {
+				port = "80"
+				if u.Scheme == "https" {
+					port = "443"
+				}
+			}
+
+			seenURLs[server.URL] = struct{}{}
+			backends = append(backends, userBackend{
+				container: uniqueContainerName("backend-"+containerName(name), containers),
+				serverURL: u,
+				port:      port,
+			})
+		}
+	}
+
+	return backends
+}
+
+// uniqueContainerName returns the given container name, suffixed with a number if it's already in the given set of
+// names, and adds it to the set. Service names such as "my_api" and "my-api" would otherwise share a container.
+func uniqueContainerName(name string, names map[string]struct{}) string {
+	unique := name
+	for i := 2; ; i++ {
+		if _, ok := names[unique]; !ok {
+			break
+		}
+
+		unique = fmt.Sprintf("%s-%d", name, i)
+	}
+
+	names[unique] = struct{}{}
+
+	return unique
+}
+
+// containerName turns the given service name into a valid container name.
+func containerName(serviceName string) string {
+	return strings.Trim(invalidContainerNameRegexp.ReplaceAllString(strings.ToLower(serviceName), "-"), "-")
 }
 
 func indentContent(content, indent string) string {
@@ -65,17 +304,36 @@ func indentContent(content, indent string) string {
 	return strings.Join(indentedLines, "\n")
 }
 
+var (
+	playgroundURLRegexp        = regexp.MustCompile(`http://10\.10\.10\.10(:\d+)?\b`) //nolint:gochecknoglobals // Compiled once.
+	playgroundAddressRegexp    = regexp.MustCompile(`\b10\.10\.10\.10:(\d+)\b`)       //nolint:gochecknoglobals // Compiled once.
+	invalidContainerNameRegexp = regexp.MustCompile(`[^a-z0-9]+`)                     //nolint:gochecknoglobals // Compiled once.
+	playgroundServiceRegexp    = regexp.MustCompile(`\b([\w-]+)@playground\b`)        //nolint:gochecknoglobals // Compiled once.
+	playgroundBackendURLRegexp = regexp.MustCompile(`http://10\.10\.10\.(\d+)\b`)     //nolint:gochecknoglobals // Compiled once.
+)
+
 func transformDynamicConfigForDocker(dynamicConfig string) string {
 	// Replace the playground service references with docker container references
 	// In the playground:
 	//   - Services reference "http://10.10.10.10" for whoami (internal URL)
+	//   - UDP services reference "10.10.10.10:53" for the UDP whoami, TCP services any other port
 	//   - Service names use "whoami@playground" format
 	// In docker-compose:
 	//   - Services should reference "http://whoami:80" (container name:port)
+	//   - UDP and TCP services reference "whoami-udp:8080" and "whoami-tcp:8080"
 	//   - Service names "whoami" comes from the docker provider.
 
-	dynamicConfig = strings.ReplaceAll(dynamicConfig, "http://10.10.10.10", "http://whoami:80")
+	dynamicConfig = playgroundURLRegexp.ReplaceAllString(dynamicConfig, "http://whoami:80")
+	dynamicConfig = playgroundAddressRegexp.ReplaceAllStringFunc(dynamicConfig, func(address string) string {
+		if strings.HasSuffix(address, ":"+udpPort) {
+			return "whoami-udp:8080"
+		}
+
+		return "whoami-tcp:8080"
+	})
+
 	dynamicConfig = strings.ReplaceAll(dynamicConfig, "whoami@playground", "whoami@docker")
+	dynamicConfig = strings.ReplaceAll(dynamicConfig, "whoami-udp@playground", "whoami-udp@docker")
 
 	return dynamicConfig
 }
@@ -8,16 +8,20 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/jspdown/traefik-playground/internal/compose"
+	"github.com/jspdown/traefik-playground/internal/version"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go"
 	tccompose "github.com/testcontainers/testcontainers-go/modules/compose"
 	"github.com/testcontainers/testcontainers-go/wait"
+	// Link the Traefik module, as the playground does, for its version to be in the build info.
+	_ "github.com/traefik/traefik/v3/pkg/config/dynamic"
 )
 
 type httpExpectation struct {
@@ -63,11 +67,47 @@ func TestGenerate(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:       "multiple user-defined services",
+			inputFile:  "multiple-services.dynamic.yaml",
+			outputFile: "multiple-services.expected.yaml",
+			expectations: []httpExpectation{
+				{
+					method:     "GET",
+					path:       "/api",
+					statusCode: 200,
+					contains:   []string{"Hostname:"},
+				},
+			},
+		},
+		{
+			name:       "colliding service names",
+			inputFile:  "colliding-services.dynamic.yaml",
+			outputFile: "colliding-services.expected.yaml",
+			expectations: []httpExpectation{
+				{
+					method:     "GET",
+					path:       "/api",
+					statusCode: 200,
+					contains:   []string{"Hostname:"},
+				},
+			},
+		},
 		{
 			name:       "empty",
 			inputFile:  "empty.dynamic.yaml",
 			outputFile: "empty.expected.yaml",
 		},
+		{
+			name:       "tcp router",
+			inputFile:  "tcp.dynamic.yaml",
+			outputFile: "tcp.expected.yaml",
+		},
+		{
+			name:       "udp router",
+			inputFile:  "udp.dynamic.yaml",
+			outputFile: "udp.expected.yaml",
+		},
 	}
 
 	for _, test := range tests {
@@ -78,7 +118,8 @@ func TestGenerate(t *testing.T) {
 			dynamicConfig, err := os.ReadFile(inputPath)
 			require.NoError(t, err)
 
-			result := compose.Generate(string(dynamicConfig))
+			result, err := compose.Generate(string(dynamicConfig))
+			require.NoError(t, err)
 			assert.NotEmpty(t, result)
 
 			expectedPath := filepath.Join("testdata", test.outputFile)
@@ -102,6 +143,48 @@ func TestGenerate(t *testing.T) {
 	}
 }
 
+func TestGenerate_traefikVersion(t *testing.T) {
+	t.Parallel()
+
+	goMod, err := os.ReadFile(filepath.Join("..", "..", "go.mod"))
+	require.NoError(t, err)
+
+	matches := regexp.MustCompile(`(?m)^\s*github\.com/traefik/traefik/v3 (\S+)`).FindSubmatch(goMod)
+	require.Len(t, matches, 2)
+
+	// The generated docker-compose must run the Traefik version the playground is built against.
+	assert.Equal(t, string(matches[1]), version.TraefikVersion)
+
+	result, err := compose.Generate("")
+	require.NoError(t, err)
+	assert.Contains(t, result, "    image: traefik:"+version.TraefikVersion+"\n")
+}
+
+func TestGenerate_unsupportedPlaygroundBackends(t *testing.T) {
+	t.Parallel()
+
+	dynamicConfig := `http:
+  routers:
+    api:
+      rule: PathPrefix(` + "`/`" + `)
+      service: whoami-large@playground
+      middlewares:
+        - auth
+  middlewares:
+    auth:
+      forwardAuth:
+        address: http://10.10.10.11/basic
+  services:
+    api:
+      loadBalancer:
+        servers:
+          - url: http://10.10.10.10
+`
+
+	_, err := compose.Generate(dynamicConfig)
+	require.EqualError(t, err, "playground backends with no docker-compose equivalent: whoami-large@playground, http://10.10.10.11")
+}
+
 func runIntegrationTest(t *testing.T, dockerComposeContent string, expectations []httpExpectation) {
 	t.Helper()
 
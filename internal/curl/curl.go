@@ -0,0 +1,461 @@
+// Package curl converts curl commands from and into experiment requests.
+package curl
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/jspdown/traefik-playground/internal/experiment"
+)
+
+// MaxCommandLength is the maximum length of an imported curl command.
+const MaxCommandLength = 4096
+
+// longOptions lists the supported options, and whether they take a value.
+//
+//nolint:gochecknoglobals // Read-only lookup table.
+var longOptions = map[string]bool{
+	"--url":         true,
+	"--request":     true,
+	"--header":      true,
+	"--data":        true,
+	"--data-ascii":  true,
+	"--data-binary": true,
+	"--data-raw":    true,
+	"--json":        true,
+	"--user":        true,
+	"--user-agent":  true,
+	"--referer":     true,
+	"--cookie":      true,
+
+	// Options which don't alter the request.
+	"--silent":     false,
+	"--show-error": false,
+	"--location":   false,
+	"--insecure":   false,
+	"--verbose":    false,
+	"--include":    false,
+	"--fail":       false,
+	"--globoff":    false,
+	"--compressed": false,
+
+	"--http1.0": false,
+	"--http1.1": false,
+}
+
+// shortOptions maps the short options to their long name.
+//
+//nolint:gochecknoglobals // Read-only lookup table.
+var shortOptions = map[string]string{
+	"-X": "--request",
+	"-H": "--header",
+	"-d": "--data",
+	"-u": "--user",
+	"-A": "--user-agent",
+	"-e": "--referer",
+	"-b": "--cookie",
+	"-s": "--silent",
+	"-S": "--show-error",
+	"-L": "--location",
+	"-k": "--insecure",
+	"-v": "--verbose",
+	"-i": "--include",
+	"-f": "--fail",
+	"-g": "--globoff",
+	"-0": "--http1.0",
+}
+
+// Parse parses the given curl command into an HTTPRequest. The options -X, -H, -d and their variants,
+// -u, -A, -e, -b, -0, --http1.1 and --json are supported.
+// Options which don't alter the request, such as -s or -L, are ignored.
+func Parse(command string) (experiment.HTTPRequest, error) {
+	if len(command) > MaxCommandLength {
+		return experiment.HTTPRequest{}, fmt.Errorf("command is too long (max: %d)", MaxCommandLength)
+	}
+
+	args, err := split(command)
+	if err != nil {
+		return experiment.HTTPRequest{}, err
+	}
+
+	if len(args) > 0 && args[0] == "curl" {
+		args = args[1:]
+	}
+
+	var (
+		raw        experiment.RawHTTPRequest
+		headers    []string
+		data       []string
+		hasContent bool
+	)
+
+	addHeader := func(name, value string) {
+		headers = append(headers, name+": "+value)
+	}
+
+	for len(args) > 0 {
+		name, value, rest, err := nextOption(args)
+		if err != nil {
+			return experiment.HTTPRequest{}, err
+		}
+
+		args = rest
+
+		switch name {
+		case "", "--url":
+			if raw.URL != "" {
+				return experiment.HTTPRequest{}, errors.New("only one URL is supported")
+			}
+
+			raw.URL = value
+		case "--request":
+			raw.Method = strings.ToUpper(value)
+		case "--header":
+			headerName, headerValue, ok := strings.Cut(value, ":")
+			if !ok {
+				return experiment.HTTPRequest{}, fmt.Errorf(`invalid header %q, want "name: value"`, value)
+			}
+
+			headerName = strings.TrimSpace(headerName)
+			headerValue = strings.TrimSpace(headerValue)
+
+			switch {
+			case headerValue == "":
+				// curl removes the header from the request.
+				continue
+			case strings.EqualFold(headerName, "Host"):
+				raw.Host = headerValue
+			case strings.EqualFold(headerName, "Content-Type"):
+				hasContent = true
+
+				addHeader(headerName, headerValue)
+			default:
+				addHeader(headerName, headerValue)
+			}
+		case "--data", "--data-ascii", "--data-binary":
+			if strings.HasPrefix(value, "@") {
+				return experiment.HTTPRequest{}, errors.New("reading data from a file is not supported")
+			}
+
+			data = append(data, value)
+		case "--data-raw":
+			data = append(data, value)
+		case "--json":
+			if strings.HasPrefix(value, "@") {
+				return experiment.HTTPRequest{}, errors.New("reading data from a file is not supported")
+			}
+
+			data = append(data, value)
+			hasContent = true
+
+			addHeader("Content-Type", "application/json")
+			addHeader("Accept", "application/json")
+		case "--http1.0":
+			raw.Proto = "HTTP/1.0"
+		case "--http1.1":
+			raw.Proto = "HTTP/1.1"
+		case "--user":
+			raw.Username, raw.Password, _ = strings.Cut(value, ":")
+		case "--user-agent":
+			addHeader("User-Agent", value)
+		case "--referer":
+			addHeader("Referer", value)
+		case "--cookie":
+			if !strings.Contains(value, "=") {
+				return experiment.HTTPRequest{}, errors.New("reading cookies from a file is not supported")
+			}
+
+			addHeader("Cookie", value)
+		}
+	}
+
+	if raw.URL == "" {
+		return experiment.HTTPRequest{}, errors.New("url is required")
+	}
+
+	// Like curl, default to HTTP when the URL has no scheme.
+	if !strings.Contains(raw.URL, "://") {
+		raw.URL = "http://" + raw.URL
+	}
+
+	if len(data) > 0 {
+		raw.Body = strings.Join(data, "&")
+
+		if !hasContent {
+			addHeader("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+
+	if raw.Method == "" {
+		raw.Method = http.MethodGet
+		if len(data) > 0 {
+			raw.Method = http.MethodPost
+		}
+	}
+
+	raw.Headers = strings.Join(headers, "\n")
+
+	req, err := experiment.MakeHTTPRequest(raw)
+	if err != nil {
+		return experiment.HTTPRequest{}, fmt.Errorf("invalid request: %w", err)
+	}
+
+	return req, nil
+}
+
+// nextOption consumes the next option of the given arguments, and returns its long name along with its value.
+// Positional arguments are returned with an empty name.
+func nextOption(args []string) (name, value string, rest []string, err error) {
+	arg, rest := args[0], args[1:]
+
+	switch {
+	case strings.HasPrefix(arg, "--"):
+		takesValue, ok := longOptions[arg]
+		if !ok {
+			return "", "", nil, fmt.Errorf("unsupported option %q", arg)
+		}
+
+		name = arg
+		if !takesValue {
+			return name, "", rest, nil
+		}
+	case strings.HasPrefix(arg, "-") && len(arg) > 1:
+		long, ok := shortOptions[arg[:2]]
+		if !ok {
+			return "", "", nil, fmt.Errorf("unsupported option %q", arg[:2])
+		}
+
+		name = long
+		switch {
+		case !longOptions[name] && len(arg) > 2:
+			// Short options without value can be grouped, as in "-sSL".
+			return name, "", append([]string{"-" + arg[2:]}, rest...), nil
+		case !longOptions[name]:
+			return name, "", rest, nil
+		case len(arg) > 2:
+			// The value can be attached to a short option, as in "-XPOST".
+			return name, arg[2:], rest, nil
+		}
+	default:
+		return "", arg, rest, nil
+	}
+
+	if len(rest) == 0 {
+		return "", "", nil, fmt.Errorf("missing value for option %q", arg)
+	}
+
+	return name, rest[0], rest[1:], nil
+}
+
+// split splits the given command into arguments following the POSIX shell quoting rules. ANSI-C quoted
+// strings ($'...'), produced by most browsers when copying requests as curl, are supported.
+func split(command string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool
+	)
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r == '\\':
+			if i+1 >= len(runes) {
+				return nil, errors.New("unterminated escape sequence")
+			}
+
+			i++
+			// A backslash followed by a newline continues the command on the next line.
+			if runes[i] == '\n' {
+				continue
+			}
+
+			if runes[i] == '\r' && i+1 < len(runes) && runes[i+1] == '\n' {
+				i++
+
+				continue
+			}
+
+			current.WriteRune(runes[i])
+			inArg = true
+		case r == '\'':
+			end := indexRune(runes, i+1, '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+
+			current.WriteString(string(runes[i+1 : end]))
+			inArg = true
+			i = end
+		case r == '$' && i+1 < len(runes) && runes[i+1] == '\'':
+			end, err := readANSIC(runes, i+2, &current)
+			if err != nil {
+				return nil, err
+			}
+
+			inArg = true
+			i = end
+		case r == '"':
+			end, err := readDoubleQuoted(runes, i+1, &current)
+			if err != nil {
+				return nil, err
+			}
+
+			inArg = true
+			i = end
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+
+	if inArg {
+		args = append(args, current.String())
+	}
+
+	return args, nil
+}
+
+// readDoubleQuoted reads a double-quoted string starting at the given index into b. It returns the index
+// of the closing quote.
+func readDoubleQuoted(runes []rune, start int, b *strings.Builder) (int, error) {
+	for i := start; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '"':
+			return i, nil
+		case '\\':
+			if i+1 >= len(runes) {
+				return 0, errors.New("unterminated double quote")
+			}
+
+			// Within double quotes, the backslash only escapes a few characters.
+			switch next := runes[i+1]; next {
+			case '"', '\\', '$', '`':
+				b.WriteRune(next)
+				i++
+			case '\n':
+				i++
+			default:
+				b.WriteRune(r)
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+
+	return 0, errors.New("unterminated double quote")
+}
+
+// readANSIC reads an ANSI-C quoted string starting at the given index into b. It returns the index
+// of the closing quote.
+func readANSIC(runes []rune, start int, b *strings.Builder) (int, error) {
+	for i := start; i < len(runes); i++ {
+		r := runes[i]
+		if r == '\'' {
+			return i, nil
+		}
+
+		if r != '\\' {
+			b.WriteRune(r)
+
+			continue
+		}
+
+		if i+1 >= len(runes) {
+			break
+		}
+
+		i++
+		switch runes[i] {
+		case 'n':
+			b.WriteRune('\n')
+		case 'r':
+			b.WriteRune('\r')
+		case 't':
+			b.WriteRune('\t')
+		case '\\', '\'', '"', '?':
+			b.WriteRune(runes[i])
+		default:
+			b.WriteRune('\\')
+			b.WriteRune(runes[i])
+		}
+	}
+
+	return 0, errors.New("unterminated single quote")
+}
+
+func indexRune(runes []rune, start int, r rune) int {
+	for i := start; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Format formats the given HTTPRequest as a curl command. The client IP has no curl equivalent and is omitted.
+func Format(req experiment.HTTPRequest) string {
+	args := []string{"curl", "-X", req.Method, quote(req.URL)}
+
+	if req.Proto == "HTTP/1.0" {
+		args = append(args, "--http1.0")
+	}
+
+	if req.Host != "" {
+		args = append(args, "-H", quote("Host: "+req.Host))
+	}
+
+	// The scheme Traefik sees is forwarded as a header, overriding the one of the request like when it runs.
+	if req.Scheme != "" {
+		args = append(args, "-H", quote("X-Forwarded-Proto: "+req.Scheme))
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(req.Headers)) {
+		if req.Scheme != "" && http.CanonicalHeaderKey(name) == "X-Forwarded-Proto" {
+			continue
+		}
+
+		for _, value := range req.Headers[name] {
+			args = append(args, "-H", quote(name+": "+value))
+		}
+	}
+
+	if req.Username != "" {
+		user := req.Username
+		if req.Password != "" {
+			user += ":" + req.Password
+		}
+
+		args = append(args, "-u", quote(user))
+	}
+
+	if req.Body != "" {
+		// With --data, a body starting with "@" would be read from a file.
+		dataOption := "--data"
+		if strings.HasPrefix(req.Body, "@") {
+			dataOption = "--data-raw"
+		}
+
+		args = append(args, dataOption, quote(req.Body))
+	}
+
+	return strings.Join(args, " ")
+}
+
+// quote quotes the given value for a POSIX shell.
+func quote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
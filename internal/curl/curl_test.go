@@ -0,0 +1,277 @@
+package curl_test
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/jspdown/traefik-playground/internal/curl"
+	"github.com/jspdown/traefik-playground/internal/experiment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		command string
+
+		want    experiment.HTTPRequest
+		wantErr error
+	}{
+		{
+			name: "headers and JSON body",
+			command: `curl -X POST 'https://example.com/api/users?active=true' \
+  -H 'Content-Type: application/json' \
+  -H "Authorization: Bearer my-token" \
+  -H 'X-Request-Id: 42' \
+  -d '{"name": "John \"Doe\"", "tags": ["a", "b"]}'`,
+			want: experiment.HTTPRequest{
+				Method: http.MethodPost,
+				URL:    "https://example.com/api/users?active=true",
+				Headers: http.Header{
+					"Content-Type":  {"application/json"},
+					"Authorization": {"Bearer my-token"},
+					"X-Request-Id":  {"42"},
+				},
+				Body: `{"name": "John \"Doe\"", "tags": ["a", "b"]}`,
+			},
+		},
+		{
+			name:    "defaults to GET",
+			command: "curl https://example.com",
+			want: experiment.HTTPRequest{
+				Method:  http.MethodGet,
+				URL:     "https://example.com",
+				Headers: http.Header{},
+			},
+		},
+		{
+			name:    "defaults to POST with form data",
+			command: "curl --data a=1 --data-raw b=@2 https://example.com",
+			want: experiment.HTTPRequest{
+				Method:  http.MethodPost,
+				URL:     "https://example.com",
+				Headers: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+				Body:    "a=1&b=@2",
+			},
+		},
+		{
+			name:    "attached and grouped short options",
+			command: `curl -sSL -XPUT -H"X-Foo: foo" example.com/foo`,
+			want: experiment.HTTPRequest{
+				Method:  http.MethodPut,
+				URL:     "http://example.com/foo",
+				Headers: http.Header{"X-Foo": {"foo"}},
+			},
+		},
+		{
+			name:    "host header and credentials",
+			command: `curl --url http://10.10.10.10/ -H 'Host: whoami.localhost' -u john:secret`,
+			want: experiment.HTTPRequest{
+				Method:   http.MethodGet,
+				URL:      "http://10.10.10.10/",
+				Host:     "whoami.localhost",
+				Headers:  http.Header{},
+				Username: "john",
+				Password: "secret",
+			},
+		},
+		{
+			name:    "ANSI-C quoting",
+			command: `curl 'https://example.com' --data-raw $'line1\nit\'s'`,
+			want: experiment.HTTPRequest{
+				Method:  http.MethodPost,
+				URL:     "https://example.com",
+				Headers: http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+				Body:    "line1\nit's",
+			},
+		},
+		{
+			name:    "JSON option",
+			command: `curl --json '{"a":1}' https://example.com`,
+			want: experiment.HTTPRequest{
+				Method: http.MethodPost,
+				URL:    "https://example.com",
+				Headers: http.Header{
+					"Content-Type": {"application/json"},
+					"Accept":       {"application/json"},
+				},
+				Body: `{"a":1}`,
+			},
+		},
+		{
+			name:    "HTTP/1.0",
+			command: `curl -0 https://example.com`,
+			want: experiment.HTTPRequest{
+				Method:  http.MethodGet,
+				URL:     "https://example.com",
+				Proto:   "HTTP/1.0",
+				Headers: http.Header{},
+			},
+		},
+		{
+			name:    "unterminated quote",
+			command: `curl 'https://example.com`,
+			wantErr: errors.New("unterminated single quote"),
+		},
+		{
+			name:    "unsupported option",
+			command: `curl -o out.html https://example.com`,
+			wantErr: errors.New(`unsupported option "-o"`),
+		},
+		{
+			name:    "missing option value",
+			command: `curl https://example.com -H`,
+			wantErr: errors.New(`missing value for option "-H"`),
+		},
+		{
+			name:    "data from a file",
+			command: `curl -d @body.json https://example.com`,
+			wantErr: errors.New("reading data from a file is not supported"),
+		},
+		{
+			name:    "missing URL",
+			command: `curl -X GET`,
+			wantErr: errors.New("url is required"),
+		},
+		{
+			name:    "multiple URLs",
+			command: `curl https://example.com https://example.org`,
+			wantErr: errors.New("only one URL is supported"),
+		},
+		{
+			name:    "invalid request",
+			command: `curl -X TRACE https://example.com`,
+			wantErr: errors.New("invalid request: method TRACE not allowed"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := curl.Parse(test.command)
+			if test.wantErr != nil {
+				require.EqualError(t, err, test.wantErr.Error())
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	t.Parallel()
+
+	got := curl.Format(experiment.HTTPRequest{
+		Method: http.MethodPost,
+		URL:    "https://example.com/foo?bar=baz",
+		Host:   "whoami.localhost",
+		Headers: http.Header{
+			"X-Foo":        {"it's"},
+			"Content-Type": {"application/json"},
+		},
+		Body:     `{"a": 1}`,
+		Username: "john",
+		Password: "secret",
+	})
+
+	assert.Equal(t, `curl -X POST 'https://example.com/foo?bar=baz' -H 'Host: whoami.localhost' `+
+		`-H 'Content-Type: application/json' -H 'X-Foo: it'\''s' -u 'john:secret' --data '{"a": 1}'`, got)
+}
+
+func TestFormat_scheme(t *testing.T) {
+	t.Parallel()
+
+	got := curl.Format(experiment.HTTPRequest{
+		Method: http.MethodGet,
+		URL:    "http://example.com",
+		Scheme: "https",
+	})
+	assert.Equal(t, `curl -X GET 'http://example.com' -H 'X-Forwarded-Proto: https'`, got)
+
+	// The scheme overrides the header set on the request, as it does when the experiment runs.
+	got = curl.Format(experiment.HTTPRequest{
+		Method:  http.MethodGet,
+		URL:     "http://example.com",
+		Scheme:  "https",
+		Headers: http.Header{"X-Forwarded-Proto": {"http"}},
+	})
+	assert.Equal(t, `curl -X GET 'http://example.com' -H 'X-Forwarded-Proto: https'`, got)
+}
+
+func TestFormat_roundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		req  experiment.HTTPRequest
+	}{
+		{
+			name: "simple GET",
+			req: experiment.HTTPRequest{
+				Method:  http.MethodGet,
+				URL:     "http://10.10.10.10/",
+				Headers: http.Header{},
+			},
+		},
+		{
+			name: "quotes and newlines",
+			req: experiment.HTTPRequest{
+				Method: http.MethodPatch,
+				URL:    "https://example.com/it's",
+				Host:   "example.org",
+				Headers: http.Header{
+					"Content-Type": {"text/plain"},
+					"X-Quote":      {`"double" and 'single'`},
+				},
+				Body: "line1\nline2 with $HOME and `cmd` \\ backslash",
+			},
+		},
+		{
+			name: "body starting with @",
+			req: experiment.HTTPRequest{
+				Method:  http.MethodPut,
+				URL:     "https://example.com",
+				Headers: http.Header{"Content-Type": {"text/plain"}},
+				Body:    "@not-a-file",
+			},
+		},
+		{
+			name: "HTTP/1.0",
+			req: experiment.HTTPRequest{
+				Method:  http.MethodGet,
+				URL:     "https://example.com",
+				Proto:   "HTTP/1.0",
+				Headers: http.Header{},
+			},
+		},
+		{
+			name: "credentials",
+			req: experiment.HTTPRequest{
+				Method:   http.MethodDelete,
+				URL:      "https://example.com",
+				Headers:  http.Header{},
+				Username: "john",
+				Password: "p@ss:word",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := curl.Parse(curl.Format(test.req))
+			require.NoError(t, err)
+
+			assert.Equal(t, test.req, got)
+		})
+	}
+}
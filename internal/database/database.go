@@ -0,0 +1,65 @@
+// Package database opens the database shared experiments are stored in.
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/jspdown/traefik-playground/db/migrations"
+	_ "github.com/lib/pq"  // Registers the postgres driver.
+	_ "modernc.org/sqlite" // Registers the sqlite driver.
+)
+
+// Dialect is the SQL dialect of a database.
+type Dialect string
+
+// Supported dialects.
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+// sqliteScheme is the scheme of the connection strings opening a SQLite database.
+const sqliteScheme = "sqlite://"
+
+// Open opens the database of the given connection string. Connection strings with the "sqlite://" scheme open
+// the SQLite database file at the given path, created if missing. Any other connection string is handed to the
+// PostgreSQL driver.
+func Open(connString string) (*sql.DB, Dialect, error) {
+	path, ok := strings.CutPrefix(connString, sqliteScheme)
+	if !ok {
+		db, err := sql.Open("postgres", connString)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return db, Postgres, nil
+	}
+
+	if path == "" {
+		return nil, "", fmt.Errorf("missing SQLite database path in %q", connString)
+	}
+
+	// Writers wait for each other rather than failing immediately, and readers don't block writers.
+	dsn := "file:" + path + "?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)"
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return db, SQLite, nil
+}
+
+// Migrate migrates the given database to the latest schema.
+func Migrate(db *sql.DB, dialect Dialect) error {
+	switch dialect {
+	case Postgres:
+		return migrations.Migrate(db)
+	case SQLite:
+		return migrations.MigrateSQLite(db)
+	default:
+		return fmt.Errorf("unsupported dialect %q", dialect)
+	}
+}
@@ -0,0 +1,117 @@
+package experiment
+
+import (
+	"container/list"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// ResultCache caches the Results of ran Experiments.
+type ResultCache interface {
+	Get(key string) (Result, bool)
+	Add(key string, res Result)
+}
+
+// LRUCache is an in-memory ResultCache holding a bounded number of Results for a limited time.
+// When full, the least recently used Result is evicted. Results are kept JSON encoded, the way the Store persists
+// them, so that the callers modifying a Result never alter the cached one.
+type LRUCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type lruCacheEntry struct {
+	key string
+	// res is the JSON encoded Result.
+	res       []byte
+	expiresAt time.Time
+}
+
+// NewLRUCache creates a new LRUCache holding at most size Results, each for the given TTL.
+func NewLRUCache(size int, ttl time.Duration) *LRUCache {
+	return &LRUCache{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+// Get returns the Result cached under the given key, if any and not expired.
+func (c *LRUCache) Get(key string) (Result, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return Result{}, false
+	}
+
+	entry := entryOf(elem)
+	if time.Now().After(entry.expiresAt) {
+		c.remove(elem)
+
+		return Result{}, false
+	}
+
+	var res Result
+	if err := json.Unmarshal(entry.res, &res); err != nil {
+		c.remove(elem)
+
+		return Result{}, false
+	}
+
+	c.order.MoveToFront(elem)
+
+	return res, true
+}
+
+// Add caches the given Result under the given key.
+func (c *LRUCache) Add(key string, res Result) {
+	if c.size <= 0 {
+		return
+	}
+
+	encoded, err := json.Marshal(res)
+	if err != nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expiresAt := time.Now().Add(c.ttl)
+
+	if elem, ok := c.entries[key]; ok {
+		entry := entryOf(elem)
+		entry.res = encoded
+		entry.expiresAt = expiresAt
+		c.order.MoveToFront(elem)
+
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruCacheEntry{
+		key:       key,
+		res:       encoded,
+		expiresAt: expiresAt,
+	})
+
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *LRUCache) remove(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, entryOf(elem).key)
+}
+
+func entryOf(elem *list.Element) *lruCacheEntry {
+	return elem.Value.(*lruCacheEntry) //nolint:forcetypeassert // Only entries are stored in the list.
+}
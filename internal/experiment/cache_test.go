@@ -0,0 +1,111 @@
+package experiment_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jspdown/traefik-playground/internal/experiment"
+	"github.com/jspdown/traefik-playground/internal/traefik"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLRUCache(t *testing.T) {
+	t.Parallel()
+
+	cache := experiment.NewLRUCache(2, time.Minute)
+
+	cache.Add("a", experiment.Result{MatchedRouter: "a"})
+	cache.Add("b", experiment.Result{MatchedRouter: "b"})
+
+	// Use "a" so that "b" becomes the least recently used entry.
+	res, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, "a", res.MatchedRouter)
+
+	cache.Add("c", experiment.Result{MatchedRouter: "c"})
+
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+
+	_, ok = cache.Get("a")
+	assert.True(t, ok)
+
+	_, ok = cache.Get("c")
+	assert.True(t, ok)
+}
+
+func TestLRUCache_expired(t *testing.T) {
+	t.Parallel()
+
+	cache := experiment.NewLRUCache(2, 10*time.Millisecond)
+
+	cache.Add("a", experiment.Result{MatchedRouter: "a"})
+
+	_, ok := cache.Get("a")
+	assert.True(t, ok)
+
+	time.Sleep(20 * time.Millisecond)
+
+	_, ok = cache.Get("a")
+	assert.False(t, ok)
+}
+
+func TestLRUCache_copiesResults(t *testing.T) {
+	t.Parallel()
+
+	cache := experiment.NewLRUCache(2, time.Minute)
+
+	newResult := func() experiment.Result {
+		return experiment.Result{
+			Response: experiment.HTTPResponse{
+				StatusCode: http.StatusOK,
+				Headers:    http.Header{"X-Foo": {"foo"}},
+				Body:       []byte("body"),
+				Trailers:   http.Header{"X-Checksum": {"abc"}},
+			},
+			Matched:           true,
+			MiddlewareChain:   []string{"auth@file"},
+			RouterEvaluations: []traefik.RouterEvaluation{{Router: "api@file", Matched: true}},
+			HeaderChanges:     []traefik.HeaderChange{{Name: "X-Real-Ip", Values: []string{"10.0.0.1"}}},
+			Logs: []traefik.Log{{
+				Message: "message",
+				Fields:  map[string]interface{}{"nested": map[string]interface{}{"key": "value"}},
+			}},
+			ResolvedConfig: json.RawMessage(`{"routers":{}}`),
+			Burst:          []traefik.BurstResponse{{StatusCode: http.StatusOK}},
+			CircuitBreaker: []traefik.CircuitBreakerTransition{{Middleware: "cb@file"}},
+			Warnings:       []string{"warning"},
+		}
+	}
+
+	res := newResult()
+	cache.Add("a", res)
+
+	// Modifying the added Result doesn't alter the cached one.
+	res.Response.Headers.Set("X-Foo", "changed")
+	res.Logs[0].Fields["nested"].(map[string]interface{})["key"] = "changed" //nolint:forcetypeassert // Set above.
+	res.MiddlewareChain[0] = "changed"
+
+	got, ok := cache.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, newResult(), got)
+
+	// Modifying a returned Result doesn't alter the cached one either.
+	got.Response.Body[0] = 'B'
+	got.Response.Trailers.Set("X-Checksum", "changed")
+	got.MiddlewareChain[0] = "changed"
+	got.RouterEvaluations[0].Router = "changed"
+	got.HeaderChanges[0].Values[0] = "changed"
+	got.Logs[0].Fields["added"] = true
+	got.ResolvedConfig[0] = '['
+	got.Burst[0].StatusCode = http.StatusTeapot
+	got.CircuitBreaker[0].Middleware = "changed"
+	got.Warnings[0] = "changed"
+
+	got, ok = cache.Get("a")
+	require.True(t, ok)
+	assert.Equal(t, newResult(), got)
+}
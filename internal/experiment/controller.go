@@ -5,55 +5,178 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/jspdown/traefik-playground/internal/command"
 	"github.com/jspdown/traefik-playground/internal/traefik"
 )
 
+// clientPort is the port used along with the client IP of an experiment to build the remote address of the request.
+const clientPort = "1234"
+
 // ErrRunTimeout indicates that the ran experiment has timed out.
 var ErrRunTimeout = errors.New("timed out while waiting for response")
 
-// TraefikRunner can run requests through a fake Traefik instance.
+// ErrStreamTooLarge indicates that a streamed response body exceeds the maximum size.
+var ErrStreamTooLarge = errors.New("streamed response body is too large")
+
+// ErrBusy indicates that the experiment couldn't start as all the workers were busy running other experiments.
+var ErrBusy = errors.New("no worker available to run the experiment")
+
+// ErrTooManyRuns indicates that the client is already running too many experiments simultaneously.
+var ErrTooManyRuns = errors.New("too many experiments running for this client")
+
+// TraefikRunner can run requests through a fake Traefik instance, as described by the given CommandOptions.
+// A streamed response is returned as soon as its headers are received, without logs, and closing its body stops the
+// run.
 type TraefikRunner interface {
-	Run(ctx context.Context, dynamicConfig string, req *http.Request) (*http.Response, []traefik.Log, error)
+	Run(ctx context.Context, dynamicConfig string, req *http.Request, opts traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error)
 }
 
 // Storer can store Experiments and Results.
 type Storer interface {
 	Get(ctx context.Context, id string) (Experiment, Result, error)
-	Save(ctx context.Context, exp Experiment, res Result, clientIP string) (string, error)
+	Save(ctx context.Context, exp Experiment, res Result, clientIP, userAgent string) (string, error)
 }
 
 // Controller controls Experiments.
 type Controller struct {
-	store   Storer
-	traefik TraefikRunner
+	store            Storer
+	traefik          TraefikRunner
+	cache            ResultCache
+	maxRunsPerClient int
+	limits           Limits
+	maxStreamSize    int64
+	maxRunDuration   time.Duration
+
+	inFlightMu sync.Mutex
+	inFlight   map[string]int
+}
+
+// ControllerConfig holds the Controller configuration.
+type ControllerConfig struct {
+	// Cache caches the Results of ran Experiments, nil disables caching.
+	Cache ResultCache
+	// MaxRunsPerClient limits the number of Experiments a client IP can run simultaneously, zero means unlimited.
+	MaxRunsPerClient int
+	// Limits caps the number of objects the dynamic configuration of an Experiment can declare.
+	Limits Limits
+	// MaxStreamSize limits the number of bytes of a streamed response body, zero means unlimited.
+	MaxStreamSize int64
+	// MaxRunDuration limits the wall-clock time of a run as a whole, from waiting for a worker to reading the
+	// response, across all the requests it sends, such as in burst or when retried. The timeout of the TraefikRunner
+	// still applies to each of its runs. Zero means unlimited.
+	MaxRunDuration time.Duration
 }
 
 // NewController creates a new Controller.
-func NewController(store Storer, traefik TraefikRunner) *Controller {
+func NewController(store Storer, traefik TraefikRunner, config ControllerConfig) *Controller {
 	return &Controller{
-		store:   store,
-		traefik: traefik,
+		store:            store,
+		traefik:          traefik,
+		cache:            config.Cache,
+		maxRunsPerClient: config.MaxRunsPerClient,
+		limits:           config.Limits,
+		maxStreamSize:    config.MaxStreamSize,
+		maxRunDuration:   config.MaxRunDuration,
+		inFlight:         make(map[string]int),
 	}
 }
 
-// Run runs the given experiment.
-func (c *Controller) Run(ctx context.Context, exp Experiment) (Result, error) {
-	testReq := httptest.NewRequestWithContext(ctx, exp.Request.Method, exp.Request.URL, strings.NewReader(exp.Request.Body))
-	testReq.Header = exp.Request.Headers
+// Limits returns the Limits the dynamic configuration of an Experiment must comply with.
+func (c *Controller) Limits() Limits {
+	return c.limits
+}
+
+// Run runs the given experiment on behalf of the given client IP. The Result of an identical experiment
+// is reused if still cached. ErrTooManyRuns is returned if the client is already running too many experiments, and
+// ErrRunTimeout if the run exceeds its maximum duration.
+func (c *Controller) Run(ctx context.Context, exp Experiment, clientIP string) (Result, error) {
+	if c.cache == nil {
+		return c.runLimited(ctx, exp, clientIP)
+	}
+
+	key, err := exp.Hash()
+	if err != nil {
+		return Result{}, fmt.Errorf("hashing experiment: %w", err)
+	}
+
+	if res, ok := c.cache.Get(key); ok {
+		return res, nil
+	}
+
+	res, err := c.runLimited(ctx, exp, clientIP)
+	if err != nil {
+		return Result{}, err
+	}
+
+	c.cache.Add(key, res)
+
+	return res, nil
+}
+
+// runLimited runs the given experiment if the client IP doesn't exceed its number of simultaneous runs.
+func (c *Controller) runLimited(ctx context.Context, exp Experiment, clientIP string) (Result, error) {
+	if !c.acquire(clientIP) {
+		return Result{}, ErrTooManyRuns
+	}
+
+	defer c.release(clientIP)
+
+	if c.maxRunDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.maxRunDuration)
+		defer cancel()
+	}
+
+	return c.run(ctx, exp)
+}
+
+func (c *Controller) acquire(clientIP string) bool {
+	if c.maxRunsPerClient <= 0 || clientIP == "" {
+		return true
+	}
+
+	c.inFlightMu.Lock()
+	defer c.inFlightMu.Unlock()
+
+	if c.inFlight[clientIP] >= c.maxRunsPerClient {
+		return false
+	}
+
+	c.inFlight[clientIP]++
+
+	return true
+}
+
+func (c *Controller) release(clientIP string) {
+	if c.maxRunsPerClient <= 0 || clientIP == "" {
+		return
+	}
 
-	res, logs, err := c.traefik.Run(ctx, exp.DynamicConfig, testReq)
+	c.inFlightMu.Lock()
+	defer c.inFlightMu.Unlock()
+
+	c.inFlight[clientIP]--
+	if c.inFlight[clientIP] <= 0 {
+		delete(c.inFlight, clientIP)
+	}
+}
+
+func (c *Controller) run(ctx context.Context, exp Experiment) (Result, error) {
+	res, report, logs, err := c.traefik.Run(ctx, exp.DynamicConfig, newTestRequest(ctx, exp), commandOptions(exp))
 	if err != nil {
-		if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
-			return Result{}, ErrRunTimeout
+		// Once the run is canceled, it can fail in other ways, such as with its process being killed.
+		if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+			err = fmt.Errorf("%w: %w", err, ctxErr)
 		}
 
-		return Result{}, fmt.Errorf("running Traefik experiment: %w", err)
+		return Result{}, runError(ctx, err)
 	}
 
 	defer func() { _ = res.Body.Close() }()
@@ -63,21 +186,274 @@ func (c *Controller) Run(ctx context.Context, exp Experiment) (Result, error) {
 		return Result{}, fmt.Errorf("reading Traefik result response body: %w", err)
 	}
 
+	response := HTTPResponse{
+		Proto:      res.Proto,
+		StatusCode: res.StatusCode,
+		Headers:    res.Header,
+		Body:       body,
+		Trailers:   responseTrailers(res),
+	}
+
+	// Decode compressed bodies to make them readable, while keeping the bytes as received.
+	contentEncoding := res.Header.Get("Content-Encoding")
+	if decoded, ok := decodeBody(contentEncoding, body); ok {
+		response.Body = decoded
+		response.RawBody = body
+		response.ContentEncoding = contentEncoding
+	}
+
+	response.ContentType = parseMediaType(res.Header.Get("Content-Type"))
+	response.IsText = isTextBody(response.ContentType, response.Body)
+
 	return Result{
+		Response:          response,
+		Matched:           report.Router != "",
+		MatchedRouter:     report.Router,
+		MatchedRule:       report.Rule,
+		MatchedPriority:   report.Priority,
+		MiddlewareChain:   report.Middlewares,
+		RouterEvaluations: report.RouterEvaluations,
+		HeaderChanges:     report.HeaderChanges,
+		Metrics:           report.Metrics,
+		Logs:              logs,
+		ResolvedConfig:    report.ResolvedConfig,
+		Backend:           report.Backend,
+		Burst:             report.Burst,
+		LimitedRequests:   limitedRequests(report.Burst),
+		CircuitBreaker:    traefik.CircuitBreakerTransitions(logs),
+		Warnings:          pluginWarnings(report.StubbedPlugins),
+	}, nil
+}
+
+// limitedRequests returns the number of the given requests sent in burst which Traefik rejected.
+func limitedRequests(burst []traefik.BurstResponse) int {
+	var limited int
+	for _, res := range burst {
+		if res.Limited {
+			limited++
+		}
+	}
+
+	return limited
+}
+
+// pluginWarnings returns a warning for each of the given stubbed plugins.
+func pluginWarnings(stubbedPlugins []string) []string {
+	var warnings []string
+	for _, pluginType := range stubbedPlugins {
+		warnings = append(warnings, fmt.Sprintf("The plugin %q can't be loaded by the playground: its middlewares forward the requests unchanged", pluginType))
+	}
+
+	return warnings
+}
+
+// StreamedResult is the Result of an Experiment whose response body is streamed rather than buffered.
+type StreamedResult struct {
+	// Response is the response, without its body.
+	Response        HTTPResponse
+	Matched         bool
+	MatchedRouter   string
+	MatchedRule     string
+	MatchedPriority int
+	MiddlewareChain []string
+	Warnings        []string
+
+	// Body streams the response body as it's produced, as received. It fails with ErrStreamTooLarge once the
+	// maximum stream size is exceeded, and must be closed.
+	Body io.ReadCloser
+}
+
+// Stream runs the given experiment on behalf of the given client IP, and returns its response as soon as its
+// headers are received. Unlike Run, the Result is neither cached nor meant to be shared.
+// ErrTooManyRuns is returned if the client is already running too many experiments. The maximum run duration covers reading the body:
+// once exceeded, the body ends with an error.
+func (c *Controller) Stream(ctx context.Context, exp Experiment, clientIP string) (StreamedResult, error) {
+	if exp.Request.Burst > 1 {
+		return StreamedResult{}, newValidationError("burst", "requests sent in burst can't be streamed")
+	}
+
+	if !c.acquire(clientIP) {
+		return StreamedResult{}, ErrTooManyRuns
+	}
+
+	// The run lasts until the body is closed, the context is canceled then rather than when Stream returns.
+	cancel := context.CancelFunc(func() {})
+	if c.maxRunDuration > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.maxRunDuration)
+	}
+
+	opts := commandOptions(exp)
+	opts.Stream = true
+
+	res, report, _, err := c.traefik.Run(ctx, exp.DynamicConfig, newTestRequest(ctx, exp), opts)
+	if err != nil {
+		cancel()
+		c.release(clientIP)
+
+		return StreamedResult{}, runError(ctx, err)
+	}
+
+	var body io.Reader = res.Body
+	if c.maxStreamSize > 0 {
+		body = &limitedReader{reader: res.Body, remaining: c.maxStreamSize}
+	}
+
+	return StreamedResult{
 		Response: HTTPResponse{
-			Proto:      res.Proto,
-			StatusCode: res.StatusCode,
-			Headers:    res.Header,
-			Body:       body,
+			Proto:       res.Proto,
+			StatusCode:  res.StatusCode,
+			Headers:     res.Header,
+			ContentType: parseMediaType(res.Header.Get("Content-Type")),
+		},
+		Matched:         report.Router != "",
+		MatchedRouter:   report.Router,
+		MatchedRule:     report.Rule,
+		MatchedPriority: report.Priority,
+		MiddlewareChain: report.Middlewares,
+		Warnings:        pluginWarnings(report.StubbedPlugins),
+		Body: &releasingBody{
+			Reader: body,
+			close: sync.OnceValue(func() error {
+				defer c.release(clientIP)
+				defer cancel()
+
+				return res.Body.Close()
+			}),
 		},
-		Logs: logs,
 	}, nil
 }
 
+// limitedReader reads from the wrapped reader until the remaining number of bytes is exceeded, then fails with
+// ErrStreamTooLarge.
+type limitedReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+func (r *limitedReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		// Tell a body of exactly the maximum size apart from a larger one.
+		var probe [1]byte
+		if n, err := r.reader.Read(probe[:]); n == 0 {
+			return 0, err
+		}
+
+		return 0, ErrStreamTooLarge
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+
+	return n, err
+}
+
+// releasingBody is the body of a StreamedResult, releasing the run of the client once closed.
+type releasingBody struct {
+	io.Reader
+
+	close func() error
+}
+
+func (b *releasingBody) Close() error {
+	return b.close()
+}
+
+// runError converts the errors of a TraefikRunner, running with the given context, into the errors returned by the
+// Controller.
+func runError(ctx context.Context, err error) error {
+	// Tell whether the experiment never started or whether it's the experiment itself which was too slow. An
+	// experiment whose maximum run duration elapsed while waiting for a worker timed out all the same.
+	if errors.Is(err, command.ErrNoWorkerAvailable) && !errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return ErrBusy
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return ErrRunTimeout
+	}
+
+	return fmt.Errorf("running Traefik experiment: %w", err)
+}
+
+// newTestRequest creates the request of the given experiment, to send to the fake Traefik instance. The headers it
+// adds to those of the experiment must be listed by HTTPRequest.AddedHeaders.
+func newTestRequest(ctx context.Context, exp Experiment) *http.Request {
+	testReq := httptest.NewRequestWithContext(ctx, exp.Request.Method, exp.Request.URL, strings.NewReader(exp.Request.Body))
+	testReq.Header = exp.Request.Headers.Clone()
+	if testReq.Header == nil {
+		testReq.Header = make(http.Header)
+	}
+
+	if exp.Request.Host != "" {
+		testReq.Host = exp.Request.Host
+	}
+
+	if exp.Request.Proto != "" {
+		testReq.Proto = exp.Request.Proto
+		testReq.ProtoMajor, testReq.ProtoMinor, _ = http.ParseHTTPVersion(exp.Request.Proto)
+	}
+
+	if exp.Request.Scheme != "" {
+		testReq.URL.Scheme = exp.Request.Scheme
+		testReq.Header.Set("X-Forwarded-Proto", exp.Request.Scheme)
+	}
+
+	// Drop the placeholder remote address set by httptest, it is only forwarded when a client IP is given.
+	testReq.RemoteAddr = ""
+	if exp.Request.ClientIP != "" {
+		testReq.RemoteAddr = net.JoinHostPort(exp.Request.ClientIP, clientPort)
+
+		// An X-Forwarded-For given by the user is kept, so that the entrypoints can trust it or replace it.
+		if testReq.Header.Get("X-Forwarded-For") == "" {
+			testReq.Header.Set("X-Forwarded-For", exp.Request.ClientIP)
+		}
+	}
+
+	if authorization := exp.Request.authorization(); authorization != "" {
+		testReq.Header.Set("Authorization", authorization)
+	}
+
+	return testReq
+}
+
+// commandOptions returns the CommandOptions describing how to send the request of the given experiment.
+func commandOptions(exp Experiment) traefik.CommandOptions {
+	return traefik.CommandOptions{
+		Burst:        exp.Request.Burst,
+		KeepCookies:  exp.Request.KeepCookies,
+		Concurrent:   exp.Request.Concurrent,
+		Delay:        time.Duration(exp.Request.DelayMs) * time.Millisecond,
+		TrustedIPs:   exp.Request.TrustedIPs,
+		StaticConfig: exp.StaticConfig,
+	}
+}
+
+// responseTrailers returns the trailers received with the given response once its body has been read.
+// Announced trailers which were never sent are left out.
+func responseTrailers(res *http.Response) http.Header {
+	var trailers http.Header
+	for name, values := range res.Trailer {
+		if len(values) == 0 {
+			continue
+		}
+
+		if trailers == nil {
+			trailers = make(http.Header)
+		}
+
+		trailers[name] = values
+	}
+
+	return trailers
+}
+
 // Share saves an experiment with its result to the store. The returned string is a unique
-// ID that can be used to retrieve the experiment later with Shared.
-func (c *Controller) Share(ctx context.Context, exp Experiment, res Result, clientIP string) (string, error) {
-	return c.store.Save(ctx, exp, res, clientIP)
+// ID that can be used to retrieve the experiment later with Shared. The client IP and user agent of the client
+// sharing the experiment are kept to investigate abuses, they are never returned by Shared.
+func (c *Controller) Share(ctx context.Context, exp Experiment, res Result, clientIP, userAgent string) (string, error) {
+	return c.store.Save(ctx, exp, res, clientIP, userAgent)
 }
 
 // Shared retrieves a previously shared experiment and its result from the store using the given ID.
@@ -90,32 +466,128 @@ func (c *Controller) Shared(ctx context.Context, id string) (exp Experiment, res
 type Traefik struct {
 	workerPool *command.WorkerPool
 	timeout    time.Duration
+	maxLogSize int
+	limits     command.ResourceLimits
+	passEnv    []string
+	logFilter  traefik.LogFilter
+	warmPool   *traefik.WarmPool
+}
+
+// TraefikConfig holds the Traefik runner configuration.
+type TraefikConfig struct {
+	// Timeout specifies how long to wait before canceling commands.
+	Timeout time.Duration
+	// MaxLogSize limits the number of bytes of logs kept per experiment, zero means unlimited.
+	MaxLogSize int
+	// NoiseLogPrefixes lists the prefixes of log messages to flag as noise.
+	NoiseLogPrefixes []string
+	// ResourceLimits caps the memory and CPU time each command can use.
+	ResourceLimits command.ResourceLimits
+	// PassEnv lists the environment variables forwarded to each command.
+	PassEnv []string
+	// WarmPool, when set, runs the experiments on tester processes started ahead of time, except the streamed ones.
+	WarmPool *traefik.WarmPool
 }
 
 // NewTraefik creates a new Traefik runner.
-// Timeout specifies how long to wait before canceling commands.
-func NewTraefik(workerPool *command.WorkerPool, timeout time.Duration) *Traefik {
+func NewTraefik(workerPool *command.WorkerPool, config TraefikConfig) *Traefik {
 	return &Traefik{
 		workerPool: workerPool,
-		timeout:    timeout,
+		timeout:    config.Timeout,
+		maxLogSize: config.MaxLogSize,
+		limits:     config.ResourceLimits,
+		passEnv:    config.PassEnv,
+		logFilter:  traefik.NewLogFilter(config.NoiseLogPrefixes),
+		warmPool:   config.WarmPool,
 	}
 }
 
-// Run executes a request against a fakeTraefik with the provided configuration.
-func (r *Traefik) Run(ctx context.Context, dynamicConfig string, req *http.Request) (*http.Response, []traefik.Log, error) {
-	cmd, err := traefik.NewCommand(dynamicConfig, req)
+// Run executes a request against a fake Traefik instance with the provided configuration, as described by the given
+// CommandOptions. The runner settings override those of the CommandOptions. A streamed response is returned as soon
+// as its headers are received: its body is streamed as it's produced until the runner timeout, and closing it stops
+// the command.
+func (r *Traefik) Run(ctx context.Context, dynamicConfig string, req *http.Request, opts traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+	opts.MaxLogSize = r.maxLogSize
+	opts.Limits = r.limits
+	opts.PassEnv = r.passEnv
+	opts.Timeout = r.timeout
+
+	cmd, err := traefik.NewCommand(dynamicConfig, req, opts)
 	if err != nil {
-		return nil, nil, fmt.Errorf("creating Traefik command: %w", err)
+		return nil, traefik.Report{}, nil, fmt.Errorf("creating Traefik command: %w", err)
+	}
+
+	cmd.UseWarmPool(r.warmPool)
+
+	if opts.Stream {
+		res, report, err := r.stream(ctx, cmd)
+
+		return res, report, nil, err
+	}
+
+	if err = r.spawn(ctx, cmd); err != nil {
+		return nil, traefik.Report{}, nil, err
 	}
 
-	if err = r.workerPool.Spawn(ctx, command.NewWithTimeout(cmd, r.timeout)); err != nil {
-		return nil, nil, err
+	res, report, logs, err := cmd.Result()
+	if err != nil {
+		return nil, traefik.Report{}, nil, fmt.Errorf("getting Traefik result: %w", err)
 	}
 
-	res, logs, err := cmd.Result()
+	return res, report, r.logFilter.Apply(logs), nil
+}
+
+// spawn executes the given command on a worker, within the runner timeout.
+func (r *Traefik) spawn(ctx context.Context, cmd *traefik.Command) error {
+	return r.workerPool.Spawn(ctx, command.NewWithTimeout(cmd, r.timeout), command.PriorityFromContext(ctx))
+}
+
+// stream executes the given command streaming its response in the background, and returns the response as soon as
+// its headers are received.
+func (r *Traefik) stream(ctx context.Context, cmd *traefik.Command) (*http.Response, traefik.Report, error) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	spawnErrCh := make(chan error, 1)
+	go func() {
+		spawnErr := r.spawn(ctx, cmd)
+
+		// The stream is already closed if the command was executed, but not if it couldn't be started.
+		cmd.CloseStream(spawnErr)
+		spawnErrCh <- spawnErr
+	}()
+
+	res, report, err := cmd.Stream()
 	if err != nil {
-		return nil, nil, fmt.Errorf("getting Traefik result: %w", err)
+		cancel()
+
+		if spawnErr := <-spawnErrCh; spawnErr != nil {
+			return nil, traefik.Report{}, spawnErr
+		}
+
+		return nil, traefik.Report{}, fmt.Errorf("getting Traefik result: %w", err)
+	}
+
+	res.Body = &commandBody{
+		ReadCloser: res.Body,
+		stop: sync.OnceFunc(func() {
+			cancel()
+			<-spawnErrCh
+		}),
 	}
 
-	return res, logs, nil
+	return res, report, nil
+}
+
+// commandBody is the body of a streamed response, stopping the command it comes from once closed.
+type commandBody struct {
+	io.ReadCloser
+
+	stop func()
+}
+
+func (b *commandBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.stop()
+
+	return err
 }
@@ -2,20 +2,31 @@ package experiment_test
 
 import (
 	"bytes"
+	"compress/gzip"
+	"compress/zlib"
 	"context"
 	"errors"
+	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	"net/http/httptest"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/jspdown/traefik-playground/internal/command"
 	"github.com/jspdown/traefik-playground/internal/experiment"
 	"github.com/jspdown/traefik-playground/internal/traefik"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/traefik/traefik/v3/pkg/config/dynamic"
 )
 
+const testClientIP = "192.0.2.1"
+
 // fakeStore implements a simple in-memory store for testing.
 type fakeStore struct {
 	experiments map[string]storedExperiment
@@ -23,9 +34,10 @@ type fakeStore struct {
 }
 
 type storedExperiment struct {
-	exp      experiment.Experiment
-	res      experiment.Result
-	clientIP string
+	exp       experiment.Experiment
+	res       experiment.Result
+	clientIP  string
+	userAgent string
 }
 
 func newFakeStore() *fakeStore {
@@ -35,8 +47,8 @@ func newFakeStore() *fakeStore {
 	}
 }
 
-func (s *fakeStore) Save(_ context.Context, exp experiment.Experiment, res experiment.Result, clientIP string) (string, error) {
-	s.experiments[s.nextID] = storedExperiment{exp, res, clientIP}
+func (s *fakeStore) Save(_ context.Context, exp experiment.Experiment, res experiment.Result, clientIP, userAgent string) (string, error) {
+	s.experiments[s.nextID] = storedExperiment{exp, res, clientIP, userAgent}
 
 	return s.nextID, nil
 }
@@ -50,10 +62,10 @@ func (s *fakeStore) Get(_ context.Context, id string) (experiment.Experiment, ex
 }
 
 // fakeTraefik implements a test double for the traefikRunner interface.
-type fakeTraefik func(ctx context.Context, dynamicConfig string, req *http.Request) (*http.Response, []traefik.Log, error)
+type fakeTraefik func(ctx context.Context, dynamicConfig string, req *http.Request, opts traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error)
 
-func (f fakeTraefik) Run(ctx context.Context, dynamicConfig string, req *http.Request) (*http.Response, []traefik.Log, error) {
-	return f(ctx, dynamicConfig, req)
+func (f fakeTraefik) Run(ctx context.Context, dynamicConfig string, req *http.Request, opts traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+	return f(ctx, dynamicConfig, req, opts)
 }
 
 func TestController_Run(t *testing.T) {
@@ -71,9 +83,9 @@ func TestController_Run(t *testing.T) {
 		}
 	}`
 
-	fakeTraefik := fakeTraefik(func(_ context.Context, config string, req *http.Request) (*http.Response, []traefik.Log, error) {
+	fakeTraefik := fakeTraefik(func(_ context.Context, config string, req *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
 		if config != dynamicConfig {
-			return nil, nil, errors.New("unexpected dynamic config")
+			return nil, traefik.Report{}, nil, errors.New("unexpected dynamic config")
 		}
 
 		if strings.HasPrefix(req.URL.Path, "/foo") {
@@ -82,13 +94,21 @@ func TestController_Run(t *testing.T) {
 				StatusCode: http.StatusOK,
 				Body:       io.NopCloser(bytes.NewBufferString("response")),
 				Header:     http.Header{"X-Foo": {"Value"}},
+			}, traefik.Report{
+				Router:   "api@file",
+				Rule:     "PathPrefix(`/foo`)",
+				Priority: 18,
+				RouterEvaluations: []traefik.RouterEvaluation{
+					{Router: "api@file", Rule: "PathPrefix(`/foo`)", Priority: 18, Evaluated: true, Matched: true, Selected: true},
+				},
+				HeaderChanges: []traefik.HeaderChange{{Name: "X-Forwarded-For", Values: []string{"192.0.2.1"}}},
 			}, []traefik.Log{{Message: "found"}}, nil
 		}
 
-		return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody}, nil, nil
+		return &http.Response{StatusCode: http.StatusNotFound, Body: http.NoBody}, traefik.Report{}, nil, nil
 	})
 
-	controller := experiment.NewController(newFakeStore(), fakeTraefik)
+	controller := experiment.NewController(newFakeStore(), fakeTraefik, experiment.ControllerConfig{})
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
 	defer cancel()
@@ -99,7 +119,7 @@ func TestController_Run(t *testing.T) {
 			Method: "GET",
 			URL:    "https://example.com/foo/bar",
 		},
-	})
+	}, testClientIP)
 
 	require.NoError(t, err)
 	assert.Equal(t, experiment.Result{
@@ -108,19 +128,737 @@ func TestController_Run(t *testing.T) {
 			StatusCode: http.StatusOK,
 			Headers:    map[string][]string{"X-Foo": {"Value"}},
 			Body:       []byte("response"),
+			IsText:     true,
 		},
-		Logs: []traefik.Log{{Message: "found"}},
+		Matched:         true,
+		MatchedRouter:   "api@file",
+		MatchedRule:     "PathPrefix(`/foo`)",
+		MatchedPriority: 18,
+		RouterEvaluations: []traefik.RouterEvaluation{
+			{Router: "api@file", Rule: "PathPrefix(`/foo`)", Priority: 18, Evaluated: true, Matched: true, Selected: true},
+		},
+		HeaderChanges: []traefik.HeaderChange{{Name: "X-Forwarded-For", Values: []string{"192.0.2.1"}}},
+		Logs:          []traefik.Log{{Message: "found"}},
 	}, result)
 }
 
+func TestController_Run_NoRouterMatched(t *testing.T) {
+	t.Parallel()
+
+	fakeTraefik := fakeTraefik(func(_ context.Context, _ string, _ *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		return &http.Response{
+			Proto:      "HTTP/1.1",
+			StatusCode: http.StatusNotFound,
+			Body:       io.NopCloser(bytes.NewBufferString("404 page not found\n")),
+		}, traefik.Report{}, nil, nil
+	})
+
+	controller := experiment.NewController(newFakeStore(), fakeTraefik, experiment.ControllerConfig{})
+
+	result, err := controller.Run(t.Context(), experiment.Experiment{
+		DynamicConfig: "{}",
+		Request: experiment.HTTPRequest{
+			Method: http.MethodGet,
+			URL:    "http://example.com/unknown",
+		},
+	}, testClientIP)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusNotFound, result.Response.StatusCode)
+	assert.False(t, result.Matched)
+	assert.Empty(t, result.MatchedRouter)
+}
+
+func TestController_Run_HostOverride(t *testing.T) {
+	t.Parallel()
+
+	var gotHost string
+	fakeTraefik := fakeTraefik(func(_ context.Context, _ string, req *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		gotHost = req.Host
+
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, traefik.Report{}, nil, nil
+	})
+
+	controller := experiment.NewController(newFakeStore(), fakeTraefik, experiment.ControllerConfig{})
+
+	_, err := controller.Run(t.Context(), experiment.Experiment{
+		DynamicConfig: "{}",
+		Request: experiment.HTTPRequest{
+			Method: http.MethodGet,
+			URL:    "http://localhost/foo",
+			Host:   "api.example.com",
+		},
+	}, testClientIP)
+	require.NoError(t, err)
+
+	assert.Equal(t, "api.example.com", gotHost)
+}
+
+func TestController_Run_Proto(t *testing.T) {
+	t.Parallel()
+
+	var gotReq *http.Request
+	fakeTraefik := fakeTraefik(func(_ context.Context, _ string, req *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		gotReq = req
+
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, traefik.Report{}, nil, nil
+	})
+
+	controller := experiment.NewController(newFakeStore(), fakeTraefik, experiment.ControllerConfig{})
+
+	_, err := controller.Run(t.Context(), experiment.Experiment{
+		DynamicConfig: "{}",
+		Request: experiment.HTTPRequest{
+			Method: http.MethodGet,
+			URL:    "http://example.com/foo",
+			Proto:  "HTTP/1.0",
+		},
+	}, testClientIP)
+	require.NoError(t, err)
+
+	require.NotNil(t, gotReq)
+	assert.Equal(t, "HTTP/1.0", gotReq.Proto)
+	assert.False(t, gotReq.ProtoAtLeast(1, 1))
+}
+
+func TestController_Run_ClientIP(t *testing.T) {
+	t.Parallel()
+
+	var gotReq *http.Request
+	fakeTraefik := fakeTraefik(func(_ context.Context, _ string, req *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		gotReq = req
+
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, traefik.Report{}, nil, nil
+	})
+
+	controller := experiment.NewController(newFakeStore(), fakeTraefik, experiment.ControllerConfig{})
+
+	_, err := controller.Run(t.Context(), experiment.Experiment{
+		DynamicConfig: "{}",
+		Request: experiment.HTTPRequest{
+			Method:   http.MethodGet,
+			URL:      "http://localhost/foo",
+			ClientIP: "2001:db8::1",
+		},
+	}, testClientIP)
+	require.NoError(t, err)
+
+	assert.Equal(t, "[2001:db8::1]:1234", gotReq.RemoteAddr)
+	assert.Equal(t, "2001:db8::1", gotReq.Header.Get("X-Forwarded-For"))
+
+	// The X-Forwarded-For given by the user is kept, for the entrypoints to trust it or not.
+	_, err = controller.Run(t.Context(), experiment.Experiment{
+		DynamicConfig: "{}",
+		Request: experiment.HTTPRequest{
+			Method:     http.MethodGet,
+			URL:        "http://localhost/foo",
+			ClientIP:   "10.0.0.1",
+			TrustedIPs: []string{"10.0.0.0/8"},
+			Headers:    http.Header{"X-Forwarded-For": {"203.0.113.1"}},
+		},
+	}, testClientIP)
+	require.NoError(t, err)
+
+	assert.Equal(t, "10.0.0.1:1234", gotReq.RemoteAddr)
+	assert.Equal(t, "203.0.113.1", gotReq.Header.Get("X-Forwarded-For"))
+}
+
+func TestController_Run_AddedHeaders(t *testing.T) {
+	t.Parallel()
+
+	var gotReq *http.Request
+	fakeTraefik := fakeTraefik(func(_ context.Context, _ string, req *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		gotReq = req
+
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, traefik.Report{}, nil, nil
+	})
+
+	controller := experiment.NewController(newFakeStore(), fakeTraefik, experiment.ControllerConfig{})
+
+	req := experiment.HTTPRequest{
+		Method:   http.MethodGet,
+		URL:      "http://localhost/foo",
+		Scheme:   "https",
+		ClientIP: "10.0.0.1",
+		Headers:  http.Header{"X-Foo": {"foo"}},
+		Username: "user",
+		Password: "pass",
+	}
+
+	_, err := controller.Run(t.Context(), experiment.Experiment{DynamicConfig: "{}", Request: req}, testClientIP)
+	require.NoError(t, err)
+
+	// The headers of the request sent to Traefik are those of the experiment and those reported as added.
+	wantHeaders := append(slices.Collect(maps.Keys(req.Headers)), req.AddedHeaders()...)
+	assert.ElementsMatch(t, wantHeaders, slices.Collect(maps.Keys(gotReq.Header)))
+}
+
+func TestController_Run_HeaderChanges(t *testing.T) {
+	t.Parallel()
+
+	runner := inProcessTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {Rule: "PathPrefix(`/`)", Service: "whoami@playground"},
+			},
+		},
+	})
+
+	controller := experiment.NewController(newFakeStore(), runner, experiment.ControllerConfig{})
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+
+	res, err := controller.Run(ctx, experiment.Experiment{
+		DynamicConfig: "{}",
+		Request: experiment.HTTPRequest{
+			Method:   http.MethodGet,
+			URL:      "http://localhost/foo",
+			ClientIP: "203.0.113.1",
+			Headers:  http.Header{"X-Foo": {"foo"}},
+		},
+	}, testClientIP)
+	require.NoError(t, err)
+
+	// Traefik appends the address of the client to the X-Forwarded-For header set by the playground, other headers are
+	// forwarded untouched.
+	assert.Equal(t, []traefik.HeaderChange{
+		{Name: "X-Forwarded-For", Values: []string{"203.0.113.1, 203.0.113.1"}, Original: []string{"203.0.113.1"}},
+	}, res.HeaderChanges)
+}
+
+func TestController_Run_StaticConfig(t *testing.T) {
+	t.Parallel()
+
+	runner := inProcessTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"slow": {Rule: "PathPrefix(`/`)", Service: "slow@playground"},
+			},
+		},
+	})
+
+	controller := experiment.NewController(newFakeStore(), runner, experiment.ControllerConfig{})
+
+	tests := []struct {
+		desc           string
+		staticConfig   string
+		wantStatusCode int
+	}{
+		{
+			desc:           "default timeouts",
+			wantStatusCode: http.StatusOK,
+		},
+		{
+			desc:           "response header timeout exceeded",
+			staticConfig:   "serversTransport:\n  forwardingTimeouts:\n    responseHeaderTimeout: 50ms\n",
+			wantStatusCode: http.StatusGatewayTimeout,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithTimeout(t.Context(), 2*time.Second)
+			defer cancel()
+
+			res, err := controller.Run(ctx, experiment.Experiment{
+				DynamicConfig: "{}",
+				StaticConfig:  test.staticConfig,
+				Request: experiment.HTTPRequest{
+					Method: http.MethodGet,
+					URL:    "http://localhost/?delay=300ms",
+				},
+			}, testClientIP)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.wantStatusCode, res.Response.StatusCode)
+		})
+	}
+}
+
+func TestController_Run_Scheme(t *testing.T) {
+	t.Parallel()
+
+	// Traefik v3 has no scheme matcher, the scheme is matched through the X-Forwarded-Proto header.
+	runner := inProcessTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"https": {Rule: "Header(`X-Forwarded-Proto`, `https`)", Service: "whoami@playground"},
+			},
+		},
+	})
+
+	controller := experiment.NewController(newFakeStore(), runner, experiment.ControllerConfig{})
+
+	tests := []struct {
+		scheme         string
+		wantStatusCode int
+	}{
+		{scheme: "", wantStatusCode: http.StatusNotFound},
+		{scheme: "http", wantStatusCode: http.StatusNotFound},
+		{scheme: "https", wantStatusCode: http.StatusTeapot},
+	}
+
+	for _, test := range tests {
+		ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+
+		res, err := controller.Run(ctx, experiment.Experiment{
+			DynamicConfig: "{}",
+			Request: experiment.HTTPRequest{
+				Method: http.MethodGet,
+				URL:    "http://localhost/foo",
+				Scheme: test.scheme,
+			},
+		}, testClientIP)
+		cancel()
+		require.NoError(t, err, test.scheme)
+
+		assert.Equal(t, test.wantStatusCode, res.Response.StatusCode, test.scheme)
+		assert.Equal(t, test.wantStatusCode == http.StatusTeapot, res.Matched, test.scheme)
+	}
+}
+
+func TestController_Run_CORS(t *testing.T) {
+	t.Parallel()
+
+	runner := inProcessTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {Rule: "PathPrefix(`/`)", Service: "whoami@playground", Middlewares: []string{"cors"}},
+			},
+			Middlewares: map[string]*dynamic.Middleware{
+				"cors": {
+					Headers: &dynamic.Headers{
+						AccessControlAllowOriginList: []string{"https://allowed.example.com"},
+						AccessControlAllowMethods:    []string{http.MethodGet, http.MethodPost},
+						AccessControlAllowHeaders:    []string{"Content-Type"},
+						AccessControlMaxAge:          600,
+					},
+				},
+			},
+		},
+	})
+
+	controller := experiment.NewController(newFakeStore(), runner, experiment.ControllerConfig{})
+
+	preflight, actual := runCORS(t, controller, "https://allowed.example.com", http.MethodPost)
+
+	assert.Equal(t, http.StatusOK, preflight.Response.StatusCode)
+	assert.Equal(t, "https://allowed.example.com", preflight.Response.Headers.Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET,POST", preflight.Response.Headers.Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type", preflight.Response.Headers.Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", preflight.Response.Headers.Get("Access-Control-Max-Age"))
+
+	assert.Equal(t, http.StatusTeapot, actual.Response.StatusCode)
+	assert.Equal(t, "https://allowed.example.com", actual.Response.Headers.Get("Access-Control-Allow-Origin"))
+
+	// Origins which aren't allowed are never echoed.
+	preflight, actual = runCORS(t, controller, "https://denied.example.com", http.MethodPost)
+
+	assert.Empty(t, preflight.Response.Headers.Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, actual.Response.Headers.Get("Access-Control-Allow-Origin"))
+}
+
+func TestController_Run_BasicAuth(t *testing.T) {
+	t.Parallel()
+
+	var gotAuthorization string
+	fakeTraefik := fakeTraefik(func(_ context.Context, _ string, req *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		gotAuthorization = req.Header.Get("Authorization")
+
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, traefik.Report{}, nil, nil
+	})
+
+	controller := experiment.NewController(newFakeStore(), fakeTraefik, experiment.ControllerConfig{})
+
+	_, err := controller.Run(t.Context(), experiment.Experiment{
+		DynamicConfig: "{}",
+		Request: experiment.HTTPRequest{
+			Method:   http.MethodGet,
+			URL:      "http://localhost/foo",
+			Username: "user",
+			Password: "pass",
+		},
+	}, testClientIP)
+	require.NoError(t, err)
+
+	assert.Equal(t, "Basic dXNlcjpwYXNz", gotAuthorization)
+}
+
+func TestController_Run_Trailers(t *testing.T) {
+	t.Parallel()
+
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Trailer", "Grpc-Status, X-Unsent")
+		rw.WriteHeader(http.StatusOK)
+		_, _ = rw.Write([]byte("body"))
+
+		rw.Header().Set("Grpc-Status", "0")
+	}))
+	t.Cleanup(backend.Close)
+
+	fakeTraefik := fakeTraefik(func(ctx context.Context, _ string, _ *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, backend.URL, http.NoBody)
+		if err != nil {
+			return nil, traefik.Report{}, nil, err
+		}
+
+		res, err := backend.Client().Do(req)
+
+		return res, traefik.Report{}, nil, err
+	})
+
+	controller := experiment.NewController(newFakeStore(), fakeTraefik, experiment.ControllerConfig{})
+
+	res, err := controller.Run(t.Context(), experiment.Experiment{
+		DynamicConfig: "{}",
+		Request: experiment.HTTPRequest{
+			Method: http.MethodGet,
+			URL:    "http://localhost/foo",
+		},
+	}, testClientIP)
+	require.NoError(t, err)
+
+	assert.Equal(t, "body", string(res.Response.Body))
+	assert.Equal(t, http.Header{"Grpc-Status": []string{"0"}}, res.Response.Trailers)
+}
+
+func TestController_Run_ContentType(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc        string
+		contentType string
+		body        []byte
+
+		wantContentType string
+		wantIsText      bool
+	}{
+		{
+			desc:            "json",
+			contentType:     "application/json; charset=utf-8",
+			body:            []byte(`{"foo":"bar"}`),
+			wantContentType: "application/json",
+			wantIsText:      true,
+		},
+		{
+			desc:            "structured syntax suffix",
+			contentType:     "application/problem+json",
+			body:            []byte(`{"title":"bad"}`),
+			wantContentType: "application/problem+json",
+			wantIsText:      true,
+		},
+		{
+			desc:            "binary",
+			contentType:     "application/octet-stream",
+			body:            []byte{0x00, 0x01, 0xff},
+			wantContentType: "application/octet-stream",
+		},
+		{
+			desc:       "missing content-type with text body",
+			body:       []byte("hello\nworld"),
+			wantIsText: true,
+		},
+		{
+			desc: "missing content-type with binary body",
+			body: []byte{0x1f, 0x8b, 0x08, 0x00},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			fakeTraefik := fakeTraefik(func(_ context.Context, _ string, _ *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+				res := &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     make(http.Header),
+					Body:       io.NopCloser(bytes.NewReader(test.body)),
+				}
+				if test.contentType != "" {
+					res.Header.Set("Content-Type", test.contentType)
+				}
+
+				return res, traefik.Report{}, nil, nil
+			})
+
+			controller := experiment.NewController(newFakeStore(), fakeTraefik, experiment.ControllerConfig{})
+
+			res, err := controller.Run(t.Context(), experiment.Experiment{
+				DynamicConfig: "{}",
+				Request: experiment.HTTPRequest{
+					Method: http.MethodGet,
+					URL:    "http://localhost/foo",
+				},
+			}, testClientIP)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.body, res.Response.Body)
+			assert.Equal(t, test.wantContentType, res.Response.ContentType)
+			assert.Equal(t, test.wantIsText, res.Response.IsText)
+		})
+	}
+}
+
+func TestController_Run_DecodeBody(t *testing.T) {
+	t.Parallel()
+
+	var gzipped bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipped)
+	_, err := gzipWriter.Write([]byte(`{"foo":"bar"}`))
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Close())
+
+	var deflated bytes.Buffer
+	zlibWriter := zlib.NewWriter(&deflated)
+	_, err = zlibWriter.Write([]byte(`{"foo":"bar"}`))
+	require.NoError(t, err)
+	require.NoError(t, zlibWriter.Close())
+
+	tests := []struct {
+		desc            string
+		contentEncoding string
+		body            []byte
+
+		wantBody            []byte
+		wantContentEncoding string
+		wantRawBody         []byte
+	}{
+		{
+			desc:                "gzip",
+			contentEncoding:     "gzip",
+			body:                gzipped.Bytes(),
+			wantBody:            []byte(`{"foo":"bar"}`),
+			wantContentEncoding: "gzip",
+			wantRawBody:         gzipped.Bytes(),
+		},
+		{
+			desc:                "deflate",
+			contentEncoding:     "deflate",
+			body:                deflated.Bytes(),
+			wantBody:            []byte(`{"foo":"bar"}`),
+			wantContentEncoding: "deflate",
+			wantRawBody:         deflated.Bytes(),
+		},
+		{
+			desc:            "unsupported encoding",
+			contentEncoding: "br",
+			body:            []byte("compressed"),
+			wantBody:        []byte("compressed"),
+		},
+		{
+			desc:            "invalid gzip",
+			contentEncoding: "gzip",
+			body:            []byte("not gzip"),
+			wantBody:        []byte("not gzip"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			fakeTraefik := fakeTraefik(func(_ context.Context, _ string, _ *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header: http.Header{
+						"Content-Type":     {"application/json"},
+						"Content-Encoding": {test.contentEncoding},
+					},
+					Body: io.NopCloser(bytes.NewReader(test.body)),
+				}, traefik.Report{}, nil, nil
+			})
+
+			controller := experiment.NewController(newFakeStore(), fakeTraefik, experiment.ControllerConfig{})
+
+			res, err := controller.Run(t.Context(), experiment.Experiment{
+				DynamicConfig: "{}",
+				Request: experiment.HTTPRequest{
+					Method: http.MethodGet,
+					URL:    "http://localhost/foo",
+				},
+			}, testClientIP)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.wantBody, res.Response.Body)
+			assert.Equal(t, test.wantContentEncoding, res.Response.ContentEncoding)
+			assert.Equal(t, test.wantRawBody, res.Response.RawBody)
+		})
+	}
+}
+
+func TestController_Run_Compress(t *testing.T) {
+	t.Parallel()
+
+	runner := inProcessTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {
+					Rule:        "PathPrefix(`/`)",
+					Service:     "whoami-large@playground",
+					Middlewares: []string{"compress"},
+				},
+			},
+			Middlewares: map[string]*dynamic.Middleware{
+				"compress": {Compress: &dynamic.Compress{Encodings: []string{"gzip"}}},
+			},
+		},
+	})
+
+	controller := experiment.NewController(newFakeStore(), runner, experiment.ControllerConfig{})
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+
+	res, err := controller.Run(ctx, experiment.Experiment{
+		DynamicConfig: "{}",
+		Request: experiment.HTTPRequest{
+			Method:  http.MethodGet,
+			URL:     "http://localhost/foo",
+			Headers: http.Header{"Accept-Encoding": {"gzip"}},
+		},
+	}, testClientIP)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusTeapot, res.Response.StatusCode)
+	assert.Equal(t, "gzip", res.Response.Headers.Get("Content-Encoding"))
+	assert.Equal(t, "gzip", res.Response.ContentEncoding)
+	assert.True(t, res.Response.IsText)
+	assert.Contains(t, string(res.Response.Body), "The quick brown fox jumps over the lazy dog.")
+	assert.Less(t, len(res.Response.RawBody), len(res.Response.Body))
+}
+
+func TestController_Run_Cache(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+	fakeTraefik := fakeTraefik(func(_ context.Context, _ string, _ *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		calls++
+
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("response"))}, traefik.Report{}, nil, nil
+	})
+
+	controller := experiment.NewController(newFakeStore(), fakeTraefik, experiment.ControllerConfig{Cache: experiment.NewLRUCache(10, time.Minute)})
+
+	exp := experiment.Experiment{
+		DynamicConfig: "{}",
+		Request: experiment.HTTPRequest{
+			Method: http.MethodGet,
+			URL:    "http://localhost/foo",
+		},
+	}
+
+	first, err := controller.Run(t.Context(), exp, testClientIP)
+	require.NoError(t, err)
+
+	second, err := controller.Run(t.Context(), exp, testClientIP)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, first, second)
+
+	// Credentials must be part of the cache key.
+	exp.Request.Username = "user"
+	exp.Request.Password = "pass"
+
+	_, err = controller.Run(t.Context(), exp, testClientIP)
+	require.NoError(t, err)
+
+	exp.Request.Password = "other"
+
+	_, err = controller.Run(t.Context(), exp, testClientIP)
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, calls)
+}
+
+func TestController_Run_CacheSkipsErrors(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+	fakeTraefik := fakeTraefik(func(_ context.Context, _ string, _ *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		calls++
+
+		return nil, traefik.Report{}, nil, errors.New("boom")
+	})
+
+	controller := experiment.NewController(newFakeStore(), fakeTraefik, experiment.ControllerConfig{Cache: experiment.NewLRUCache(10, time.Minute)})
+
+	exp := experiment.Experiment{
+		DynamicConfig: "{}",
+		Request: experiment.HTTPRequest{
+			Method: http.MethodGet,
+			URL:    "http://localhost/foo",
+		},
+	}
+
+	_, err := controller.Run(t.Context(), exp, testClientIP)
+	require.Error(t, err)
+
+	_, err = controller.Run(t.Context(), exp, testClientIP)
+	require.Error(t, err)
+
+	assert.Equal(t, 2, calls)
+}
+
+func TestController_Run_MaxRunsPerClient(t *testing.T) {
+	t.Parallel()
+
+	startedCh := make(chan struct{})
+	releaseCh := make(chan struct{})
+	fakeTraefik := fakeTraefik(func(_ context.Context, _ string, req *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		if req.URL.Path == "/slow" {
+			close(startedCh)
+			<-releaseCh
+		}
+
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, traefik.Report{}, nil, nil
+	})
+
+	controller := experiment.NewController(newFakeStore(), fakeTraefik, experiment.ControllerConfig{MaxRunsPerClient: 1})
+
+	makeExperiment := func(path string) experiment.Experiment {
+		return experiment.Experiment{
+			DynamicConfig: "{}",
+			Request: experiment.HTTPRequest{
+				Method: http.MethodGet,
+				URL:    "http://localhost" + path,
+			},
+		}
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		_, err := controller.Run(t.Context(), makeExperiment("/slow"), "192.0.2.1")
+		errCh <- err
+	}()
+
+	<-startedCh
+
+	_, err := controller.Run(t.Context(), makeExperiment("/fast"), "192.0.2.1")
+	require.ErrorIs(t, err, experiment.ErrTooManyRuns)
+
+	_, err = controller.Run(t.Context(), makeExperiment("/fast"), "192.0.2.2")
+	require.NoError(t, err)
+
+	close(releaseCh)
+	require.NoError(t, <-errCh)
+
+	// Once the first run completed, the client can run experiments again.
+	_, err = controller.Run(t.Context(), makeExperiment("/fast"), "192.0.2.1")
+	require.NoError(t, err)
+}
+
 func TestController_Run_ContextCanceled(t *testing.T) {
 	t.Parallel()
 
-	traefik := fakeTraefik(func(ctx context.Context, dynamicConfig string, req *http.Request) (*http.Response, []traefik.Log, error) {
-		return &http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, nil, ctx.Err()
+	traefik := fakeTraefik(func(ctx context.Context, dynamicConfig string, req *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		return &http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, traefik.Report{}, nil, ctx.Err()
 	})
 
-	controller := experiment.NewController(newFakeStore(), traefik)
+	controller := experiment.NewController(newFakeStore(), traefik, experiment.ControllerConfig{})
 
 	// Create a context and immediately cancel it.
 	ctx, cancel := context.WithCancel(context.Background())
@@ -132,7 +870,7 @@ func TestController_Run_ContextCanceled(t *testing.T) {
 			Method: "GET",
 			URL:    "http://example.com/foo/bar",
 		},
-	})
+	}, testClientIP)
 	require.Error(t, err)
 	assert.ErrorIs(t, err, experiment.ErrRunTimeout)
 }
@@ -140,17 +878,17 @@ func TestController_Run_ContextCanceled(t *testing.T) {
 func TestController_Run_Timeout(t *testing.T) {
 	t.Parallel()
 
-	traefik := fakeTraefik(func(ctx context.Context, dynamicConfig string, req *http.Request) (*http.Response, []traefik.Log, error) {
+	traefik := fakeTraefik(func(ctx context.Context, dynamicConfig string, req *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
 		// Simulate slow response.
 		select {
 		case <-time.After(time.Second):
-			return &http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, nil, nil
+			return &http.Response{StatusCode: http.StatusInternalServerError, Body: http.NoBody}, traefik.Report{}, nil, nil
 		case <-ctx.Done():
-			return nil, nil, ctx.Err()
+			return nil, traefik.Report{}, nil, ctx.Err()
 		}
 	})
 
-	controller := experiment.NewController(newFakeStore(), traefik)
+	controller := experiment.NewController(newFakeStore(), traefik, experiment.ControllerConfig{})
 
 	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
 	defer cancel()
@@ -161,16 +899,306 @@ func TestController_Run_Timeout(t *testing.T) {
 			Method: "GET",
 			URL:    "http://example.com/foo/bar",
 		},
-	})
+	}, testClientIP)
 
 	require.Error(t, err)
 	assert.ErrorIs(t, err, experiment.ErrRunTimeout)
 }
 
+func TestController_Run_MaxRunDuration(t *testing.T) {
+	t.Parallel()
+
+	var attempts atomic.Int64
+
+	// Simulate a run retrying a slow request many times, each attempt staying below the runner timeout.
+	runner := fakeTraefik(func(ctx context.Context, _ string, _ *http.Request, opts traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		for range opts.Burst {
+			select {
+			case <-time.After(50 * time.Millisecond):
+				attempts.Add(1)
+			case <-ctx.Done():
+				return nil, traefik.Report{}, nil, errors.New("signal: killed")
+			}
+		}
+
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, traefik.Report{}, nil, nil
+	})
+
+	controller := experiment.NewController(newFakeStore(), runner, experiment.ControllerConfig{
+		MaxRunDuration: 200 * time.Millisecond,
+	})
+
+	start := time.Now()
+
+	_, err := controller.Run(t.Context(), experiment.Experiment{
+		DynamicConfig: "{}",
+		Request: experiment.HTTPRequest{
+			Method: "GET",
+			URL:    "http://example.com/foo/bar",
+			Burst:  20,
+		},
+	}, testClientIP)
+
+	require.ErrorIs(t, err, experiment.ErrRunTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Less(t, attempts.Load(), int64(20))
+}
+
+func TestController_MaxRunDuration_queued(t *testing.T) {
+	t.Parallel()
+
+	exp := experiment.Experiment{
+		DynamicConfig: "{}",
+		Request: experiment.HTTPRequest{
+			Method: "GET",
+			URL:    "http://example.com/foo/bar",
+		},
+	}
+
+	// Keep the only worker of the pool busy, so that the runs wait in the queue until their deadline.
+	pool := command.NewWorkerPool(1, 10)
+
+	started, done := make(chan struct{}), make(chan struct{})
+	defer close(done)
+
+	go func() {
+		_ = pool.Spawn(t.Context(), busyCommand{started: started, done: done}, command.PriorityHigh)
+	}()
+	<-started
+
+	controller := experiment.NewController(newFakeStore(), experiment.NewTraefik(pool, experiment.TraefikConfig{
+		Timeout: time.Second,
+	}), experiment.ControllerConfig{
+		MaxRunDuration: 50 * time.Millisecond,
+	})
+
+	_, err := controller.Run(t.Context(), exp, testClientIP)
+	require.ErrorIs(t, err, experiment.ErrRunTimeout)
+	assert.NotErrorIs(t, err, experiment.ErrBusy)
+
+	_, err = controller.Stream(t.Context(), exp, testClientIP)
+	require.ErrorIs(t, err, experiment.ErrRunTimeout)
+	assert.NotErrorIs(t, err, experiment.ErrBusy)
+}
+
+// busyCommand is a command.Command keeping its worker busy until done is closed.
+type busyCommand struct {
+	started chan<- struct{}
+	done    <-chan struct{}
+}
+
+func (c busyCommand) Exec(context.Context) error {
+	close(c.started)
+	<-c.done
+
+	return nil
+}
+
+func TestController_Run_Busy(t *testing.T) {
+	t.Parallel()
+
+	traefik := fakeTraefik(func(ctx context.Context, dynamicConfig string, req *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		return nil, traefik.Report{}, nil, fmt.Errorf("%w: %w", command.ErrNoWorkerAvailable, context.DeadlineExceeded)
+	})
+
+	controller := experiment.NewController(newFakeStore(), traefik, experiment.ControllerConfig{})
+
+	_, err := controller.Run(t.Context(), experiment.Experiment{
+		DynamicConfig: "{}",
+		Request: experiment.HTTPRequest{
+			Method: "GET",
+			URL:    "http://example.com/foo/bar",
+		},
+	}, testClientIP)
+
+	require.ErrorIs(t, err, experiment.ErrBusy)
+	assert.NotErrorIs(t, err, experiment.ErrRunTimeout)
+}
+
+func TestController_Run_Burst(t *testing.T) {
+	t.Parallel()
+
+	runner := fakeTraefik(func(_ context.Context, _ string, _ *http.Request, opts traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		burst := make([]traefik.BurstResponse, opts.Burst)
+		for i := range burst {
+			burst[i] = traefik.BurstResponse{StatusCode: http.StatusOK}
+			if i >= 2 {
+				burst[i] = traefik.BurstResponse{StatusCode: http.StatusTooManyRequests, Limited: true}
+			}
+		}
+
+		return &http.Response{
+			StatusCode: burst[opts.Burst-1].StatusCode,
+			Body:       http.NoBody,
+		}, traefik.Report{Router: "api@file", Burst: burst}, nil, nil
+	})
+
+	controller := experiment.NewController(newFakeStore(), runner, experiment.ControllerConfig{})
+
+	result, err := controller.Run(t.Context(), experiment.Experiment{
+		DynamicConfig: "{}",
+		Request: experiment.HTTPRequest{
+			Method: "GET",
+			URL:    "http://example.com/foo/bar",
+			Burst:  5,
+		},
+	}, testClientIP)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusTooManyRequests, result.Response.StatusCode)
+	assert.Equal(t, []traefik.BurstResponse{
+		{StatusCode: http.StatusOK},
+		{StatusCode: http.StatusOK},
+		{StatusCode: http.StatusTooManyRequests, Limited: true},
+		{StatusCode: http.StatusTooManyRequests, Limited: true},
+		{StatusCode: http.StatusTooManyRequests, Limited: true},
+	}, result.Burst)
+	assert.Equal(t, 3, result.LimitedRequests)
+}
+
+func TestController_Run_StubbedPlugins(t *testing.T) {
+	t.Parallel()
+
+	runner := fakeTraefik(func(_ context.Context, _ string, _ *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		return &http.Response{
+			StatusCode: http.StatusTeapot,
+			Body:       http.NoBody,
+		}, traefik.Report{Router: "api@file", StubbedPlugins: []string{"blockpath"}}, nil, nil
+	})
+
+	controller := experiment.NewController(newFakeStore(), runner, experiment.ControllerConfig{})
+
+	result, err := controller.Run(t.Context(), experiment.Experiment{
+		DynamicConfig: "http:\n  middlewares:\n    block:\n      plugin:\n        blockpath: {}\n",
+		Request: experiment.HTTPRequest{
+			Method: "GET",
+			URL:    "http://example.com/api",
+		},
+	}, testClientIP)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`The plugin "blockpath" can't be loaded by the playground: its middlewares forward the requests unchanged`,
+	}, result.Warnings)
+}
+
+func TestController_Stream(t *testing.T) {
+	t.Parallel()
+
+	bodyReader, bodyWriter := io.Pipe()
+
+	streamer := fakeTraefik(func(_ context.Context, _ string, _ *http.Request, opts traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		if !opts.Stream {
+			return nil, traefik.Report{}, nil, errors.New("unexpected buffered run")
+		}
+
+		return &http.Response{
+			Proto:      "HTTP/1.1",
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/event-stream"}},
+			Body:       bodyReader,
+		}, traefik.Report{Router: "api@file", Middlewares: []string{"headers@file"}}, nil, nil
+	})
+
+	controller := experiment.NewController(newFakeStore(), streamer, experiment.ControllerConfig{MaxRunsPerClient: 1})
+
+	exp := experiment.Experiment{
+		DynamicConfig: "{}",
+		Request: experiment.HTTPRequest{
+			Method: "GET",
+			URL:    "http://example.com/foo/bar",
+		},
+	}
+
+	res, err := controller.Stream(t.Context(), exp, testClientIP)
+	require.NoError(t, err)
+
+	assert.Equal(t, experiment.HTTPResponse{
+		Proto:       "HTTP/1.1",
+		StatusCode:  http.StatusOK,
+		Headers:     http.Header{"Content-Type": {"text/event-stream"}},
+		ContentType: "text/event-stream",
+	}, res.Response)
+	assert.True(t, res.Matched)
+	assert.Equal(t, "api@file", res.MatchedRouter)
+	assert.Equal(t, []string{"headers@file"}, res.MiddlewareChain)
+
+	// The first chunk must be readable while the backend is still writing.
+	go func() { _, _ = bodyWriter.Write([]byte("data: 1\n\n")) }()
+
+	buf := make([]byte, 64)
+	n, err := res.Body.Read(buf)
+	require.NoError(t, err)
+	assert.Equal(t, "data: 1\n\n", string(buf[:n]))
+
+	// The client can't run another experiment until the body is closed.
+	_, err = controller.Stream(t.Context(), exp, testClientIP)
+	require.ErrorIs(t, err, experiment.ErrTooManyRuns)
+
+	require.NoError(t, res.Body.Close())
+
+	_, err = controller.Stream(t.Context(), exp, testClientIP)
+	require.NoError(t, err)
+}
+
+func TestController_Stream_TooLarge(t *testing.T) {
+	t.Parallel()
+
+	streamer := fakeTraefik(func(_ context.Context, _ string, _ *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("0123456789")),
+		}, traefik.Report{}, nil, nil
+	})
+
+	controller := experiment.NewController(newFakeStore(), streamer, experiment.ControllerConfig{MaxStreamSize: 4})
+
+	res, err := controller.Stream(t.Context(), experiment.Experiment{
+		DynamicConfig: "{}",
+		Request: experiment.HTTPRequest{
+			Method: "GET",
+			URL:    "http://example.com/foo/bar",
+		},
+	}, testClientIP)
+	require.NoError(t, err)
+
+	defer func() { _ = res.Body.Close() }()
+
+	body, err := io.ReadAll(res.Body)
+	require.ErrorIs(t, err, experiment.ErrStreamTooLarge)
+	assert.Equal(t, "0123", string(body))
+}
+
+func TestController_Stream_Errors(t *testing.T) {
+	t.Parallel()
+
+	exp := experiment.Experiment{
+		DynamicConfig: "{}",
+		Request: experiment.HTTPRequest{
+			Method: "GET",
+			URL:    "http://example.com/foo/bar",
+		},
+	}
+
+	streamer := fakeTraefik(func(_ context.Context, _ string, _ *http.Request, _ traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		return nil, traefik.Report{}, nil, fmt.Errorf("%w: %w", command.ErrNoWorkerAvailable, context.DeadlineExceeded)
+	})
+
+	controller := experiment.NewController(newFakeStore(), streamer, experiment.ControllerConfig{MaxRunsPerClient: 1})
+
+	_, err := controller.Stream(t.Context(), exp, testClientIP)
+	require.ErrorIs(t, err, experiment.ErrBusy)
+
+	// A failed stream doesn't count as a running experiment.
+	_, err = controller.Stream(t.Context(), exp, testClientIP)
+	require.ErrorIs(t, err, experiment.ErrBusy)
+}
+
 func TestController_Share(t *testing.T) {
 	t.Parallel()
 
-	controller := experiment.NewController(newFakeStore(), nil)
+	store := newFakeStore()
+	controller := experiment.NewController(store, nil, experiment.ControllerConfig{})
 
 	exp := experiment.Experiment{
 		Request: experiment.HTTPRequest{
@@ -184,11 +1212,83 @@ func TestController_Share(t *testing.T) {
 		},
 	}
 
-	id, err := controller.Share(context.Background(), exp, res, "127.0.0.1")
+	id, err := controller.Share(context.Background(), exp, res, "127.0.0.1", "curl/8.5.0")
 	require.NoError(t, err)
+	assert.Equal(t, "curl/8.5.0", store.experiments[id].userAgent)
 
 	storedExp, storedRes, err := controller.Shared(context.Background(), id)
 	require.NoError(t, err)
 	assert.Equal(t, exp, storedExp)
 	assert.Equal(t, res, storedRes)
 }
+
+// inProcessTraefik runs the experiments against an in-process Traefik instance with the given dynamic configuration
+// and the Options of their CommandOptions, instead of spawning the tester.
+func inProcessTraefik(dynamicConfig *dynamic.Configuration) fakeTraefik {
+	return func(ctx context.Context, _ string, req *http.Request, opts traefik.CommandOptions) (*http.Response, traefik.Report, []traefik.Log, error) {
+		instance, err := traefik.NewTraefik(dynamicConfig, opts.TraefikOptions())
+		if err != nil {
+			return nil, traefik.Report{}, nil, err
+		}
+
+		type sendResult struct {
+			res    *http.Response
+			report traefik.Report
+			err    error
+		}
+
+		resultCh := make(chan sendResult, 1)
+		instance.OnReady(func() {
+			res, report, sendErr := instance.Send(req)
+			resultCh <- sendResult{res: res, report: report, err: sendErr}
+		})
+
+		if err = instance.Start(ctx); err != nil {
+			return nil, traefik.Report{}, nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, traefik.Report{}, nil, ctx.Err()
+		case result := <-resultCh:
+			return result.res, result.report, nil, result.err
+		}
+	}
+}
+
+// runCORS runs the CORS preflight request asking whether the given origin may send a request with the given method,
+// then the request itself, the way browsers do.
+func runCORS(t *testing.T, controller *experiment.Controller, origin, method string) (preflight, actual experiment.Result) {
+	t.Helper()
+
+	run := func(req experiment.HTTPRequest) experiment.Result {
+		t.Helper()
+
+		ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+		defer cancel()
+
+		res, err := controller.Run(ctx, experiment.Experiment{DynamicConfig: "{}", Request: req}, testClientIP)
+		require.NoError(t, err)
+
+		return res
+	}
+
+	preflight = run(experiment.HTTPRequest{
+		Method: http.MethodOptions,
+		URL:    "http://localhost/foo",
+		Headers: http.Header{
+			"Origin":                         {origin},
+			"Access-Control-Request-Method":  {method},
+			"Access-Control-Request-Headers": {"Content-Type"},
+		},
+	})
+
+	actual = run(experiment.HTTPRequest{
+		Method:  method,
+		URL:     "http://localhost/foo",
+		Headers: http.Header{"Origin": {origin}, "Content-Type": {"application/json"}},
+		Body:    "{}",
+	})
+
+	return preflight, actual
+}
@@ -1,14 +1,28 @@
 package experiment
 
 import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
 	"database/sql/driver"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"maps"
+	"mime"
+	"net"
 	"net/http"
+	"net/netip"
 	stdurl "net/url"
 	"slices"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/jspdown/traefik-playground/internal/header"
 	"github.com/jspdown/traefik-playground/internal/traefik"
@@ -18,47 +32,516 @@ import (
 
 const (
 	maxDynamicConfigLength = 10 * 1024
+	maxStaticConfigLength  = 2 * 1024
 
 	maxURLLength  = 1024
+	maxHostLength = 255
 	maxBodyLength = 1024
 
 	maxHeaders           = 10
 	maxHeaderNameLength  = 100
 	maxHeaderValueLength = 200
+
+	// maxOutgoingHeaders is the maximum number of headers of the request sent to Traefik, counting those added by
+	// the playground on top of the ones given by the user, such as X-Forwarded-For or a detected Content-Type.
+	maxOutgoingHeaders = 12
+
+	maxCredentialLength = 100
+
+	maxBurst = 20
+
+	maxDelayMs = 10_000
+
+	maxTrustedIPs = 10
+
+	maxLabelLength = 50
 )
 
+// MaxFieldsLength is the maximum total length of the fields describing an Experiment: its dynamic configuration and
+// its variables, its static configuration, its request, its label and the raw HTTP request it can be imported from.
+const MaxFieldsLength = maxDynamicConfigLength + maxStaticConfigLength + maxRawRequestLength +
+	maxURLLength + maxHostLength + maxBodyLength +
+	maxHeaders*(maxHeaderNameLength+maxHeaderValueLength) +
+	2*maxCredentialLength + maxLabelLength + maxVarsLength
+
+// ErrTooLarge indicates that a field of an Experiment exceeds its maximum size.
+var ErrTooLarge = errors.New("too large")
+
+// ValidationError is returned when a field of an Experiment is invalid.
+type ValidationError struct {
+	// Field is the name of the invalid field, such as "url", "headers" or "dynamicConfig".
+	Field   string
+	Message string
+
+	// Err is the cause of the error, such as ErrTooLarge, if any.
+	Err error
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func (e *ValidationError) Unwrap() error {
+	return e.Err
+}
+
+func newValidationError(field, format string, args ...any) *ValidationError {
+	return &ValidationError{
+		Field:   field,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func newTooLargeError(field, name string, maxSize int) *ValidationError {
+	return &ValidationError{
+		Field:   field,
+		Message: fmt.Sprintf("%s is too large (max: %d bytes)", name, maxSize),
+		Err:     ErrTooLarge,
+	}
+}
+
 // Experiment is an experiment to run.
 type Experiment struct {
-	DynamicConfig string      `json:"dynamicConfig"`
-	Request       HTTPRequest `json:"request"`
+	DynamicConfig string `json:"dynamicConfig"`
+	// StaticConfig is an optional static configuration merged into the defaults of the Traefik instance, see
+	// MakeStaticConfig.
+	StaticConfig string      `json:"staticConfig,omitempty"`
+	Request      HTTPRequest `json:"request"`
+
+	// Label is an optional label annotating a shared Experiment. It is stored alongside
+	// the Experiment, can filter the listed Experiments, and doesn't affect how it runs.
+	Label string `json:"-"`
+
+	// ID is the public ID a shared Experiment is stored under, set when it's retrieved from a Storer whatever the
+	// ID it's retrieved with.
+	ID string `json:"-"`
 }
 
-// MakeExperiment makes a valid Experiment.
-func MakeExperiment(dynamicConfig, method, url, headers, body string) (Experiment, error) {
-	if len(dynamicConfig) > maxDynamicConfigLength {
-		return Experiment{}, fmt.Errorf("dynamic config too long (max: %d)", maxDynamicConfigLength)
+// Hash returns a hash identifying the Experiment. Like the stored Experiment, the hash
+// covers the request credentials through their Authorization header.
+func (e Experiment) Hash() (string, error) {
+	return hashJSON(struct {
+		Experiment Experiment `json:"experiment"`
+	}{
+		Experiment: e,
+	})
+}
+
+// Limits caps the number of objects a dynamic configuration can declare across its HTTP, TCP and UDP sections.
+// Zero means unlimited.
+type Limits struct {
+	MaxRouters     int
+	MaxServices    int
+	MaxMiddlewares int
+
+	// RestrictBackendHosts rejects the HTTP and UDP servers, and the forwardAuth addresses, pointing at hosts other
+	// than the playground backends and the AllowedBackendHosts, as Traefik would connect to them from the server.
+	RestrictBackendHosts bool
+	// AllowedBackendHosts are the host names or IPs, without port, reachable despite RestrictBackendHosts.
+	AllowedBackendHosts []string
+
+	// RestrictRequestHosts rejects the requests whose URL points at localhost or at a loopback, private, link-local
+	// or unspecified IP, such as a cloud metadata endpoint, other than the playground backends and the
+	// AllowedRequestHosts. Host names are resolved, a host resolving to any such IP is rejected.
+	RestrictRequestHosts bool
+	// AllowedRequestHosts are the host names or IPs, without port, requests can target despite RestrictRequestHosts.
+	AllowedRequestHosts []string
+	// Resolver resolves the request hosts checked by RestrictRequestHosts, net.DefaultResolver when nil.
+	Resolver HostResolver
+	// AllowedRequestSchemes are the schemes the request URLs can use, http and https when empty.
+	AllowedRequestSchemes []string
+}
+
+// requestHostResolveTimeout is the maximum time spent resolving the host of a request URL.
+const requestHostResolveTimeout = 2 * time.Second
+
+// HostResolver resolves host names into IPs.
+type HostResolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+func (l Limits) check(config dynamic.Configuration) error {
+	var routers, services, middlewares int
+
+	if config.HTTP != nil {
+		routers += len(config.HTTP.Routers)
+		services += len(config.HTTP.Services)
+		middlewares += len(config.HTTP.Middlewares)
 	}
 
-	var unmarshalledDynamicConfig dynamic.Configuration
-	if err := yaml.Unmarshal([]byte(dynamicConfig), &unmarshalledDynamicConfig); err != nil {
-		return Experiment{}, fmt.Errorf("invalid dynamic configuration: %w", err)
+	if config.TCP != nil {
+		routers += len(config.TCP.Routers)
+		services += len(config.TCP.Services)
+		middlewares += len(config.TCP.Middlewares)
+	}
+
+	if config.UDP != nil {
+		routers += len(config.UDP.Routers)
+		services += len(config.UDP.Services)
+	}
+
+	if l.RestrictBackendHosts {
+		if err := l.checkBackendHosts(config); err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case l.MaxRouters > 0 && routers > l.MaxRouters:
+		return newValidationError("dynamicConfig", "too many routers: %d (max: %d)", routers, l.MaxRouters)
+	case l.MaxServices > 0 && services > l.MaxServices:
+		return newValidationError("dynamicConfig", "too many services: %d (max: %d)", services, l.MaxServices)
+	case l.MaxMiddlewares > 0 && middlewares > l.MaxMiddlewares:
+		return newValidationError("dynamicConfig", "too many middlewares: %d (max: %d)", middlewares, l.MaxMiddlewares)
+	}
+
+	return nil
+}
+
+// checkBackendHosts checks the servers of the HTTP and UDP services, and the forwardAuth addresses, point at the
+// playground backends or at the AllowedBackendHosts. TCP services aren't run by the playground.
+func (l Limits) checkBackendHosts(config dynamic.Configuration) error {
+	if config.HTTP != nil {
+		for _, name := range slices.Sorted(maps.Keys(config.HTTP.Services)) {
+			s := config.HTTP.Services[name]
+			if s == nil || s.LoadBalancer == nil {
+				continue
+			}
+
+			for _, server := range s.LoadBalancer.Servers {
+				if traefik.IsPlaygroundServerURL(server.URL) || l.allowedURL(server.URL) {
+					continue
+				}
+
+				return newValidationError("dynamicConfig", "service %q: server %q is outside the playground, use one of the playground backends", name, server.URL)
+			}
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(config.HTTP.Middlewares)) {
+			m := config.HTTP.Middlewares[name]
+			if m == nil || m.ForwardAuth == nil {
+				continue
+			}
+
+			if traefik.IsPlaygroundForwardAuthAddress(m.ForwardAuth.Address) || l.allowedURL(m.ForwardAuth.Address) {
+				continue
+			}
+
+			return newValidationError("dynamicConfig", "middleware %q: address %q is outside the playground, use one of the playground backends", name, m.ForwardAuth.Address)
+		}
+	}
+
+	if config.UDP != nil {
+		for _, name := range slices.Sorted(maps.Keys(config.UDP.Services)) {
+			s := config.UDP.Services[name]
+			if s == nil || s.LoadBalancer == nil {
+				continue
+			}
+
+			for _, server := range s.LoadBalancer.Servers {
+				if traefik.IsPlaygroundUDPAddress(server.Address) {
+					continue
+				}
+
+				if host, _, err := net.SplitHostPort(server.Address); err == nil && l.allowedHost(host) {
+					continue
+				}
+
+				return newValidationError("dynamicConfig", "service %q: server %q is outside the playground, use one of the playground backends", name, server.Address)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkRequestHost checks the given request URL doesn't point at an internal host, unless it's a playground
+// backend or one of the AllowedRequestHosts. Host names are resolved: they are rejected if any of their IPs is
+// internal, and accepted if they can't be resolved.
+func (l Limits) checkRequestHost(rawURL string) error {
+	u, err := stdurl.Parse(rawURL)
+	if err != nil {
+		return newValidationError("url", "url is invalid")
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if traefik.IsPlaygroundHost(host) || slices.ContainsFunc(l.AllowedRequestHosts, func(allowed string) bool {
+		return strings.EqualFold(allowed, host)
+	}) {
+		return nil
+	}
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return newValidationError("url", "url points at an internal host: %s", host)
+	}
+
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if internalIP(addr) {
+			return newValidationError("url", "url points at an internal host: %s", host)
+		}
+
+		return nil
+	}
+
+	resolver := l.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestHostResolveTimeout)
+	defer cancel()
+
+	addrs, err := resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		// Hosts that can't be resolved can't be reached either.
+		return nil //nolint:nilerr // Not a validation error.
+	}
+
+	if slices.ContainsFunc(addrs, internalIP) {
+		return newValidationError("url", "url points at an internal host: %s resolves to an internal IP", host)
 	}
 
-	req, err := MakeHTTPRequest(method, url, headers, body)
+	return nil
+}
+
+// checkRequestScheme checks the scheme of the given request URL is one of the AllowedRequestSchemes, such as to
+// reject file or gopher URLs which make no sense for an HTTP request.
+func (l Limits) checkRequestScheme(rawURL string) error {
+	schemes := l.AllowedRequestSchemes
+	if len(schemes) == 0 {
+		schemes = []string{"http", "https"}
+	}
+
+	u, err := stdurl.Parse(rawURL)
 	if err != nil {
+		return newValidationError("url", "url is invalid")
+	}
+
+	if !slices.ContainsFunc(schemes, func(scheme string) bool {
+		return strings.EqualFold(scheme, u.Scheme)
+	}) {
+		return newValidationError("url", "url scheme must be one of: %s", strings.Join(schemes, ", "))
+	}
+
+	return nil
+}
+
+// internalIP tells whether the given IP is a loopback, private, link-local or unspecified IP.
+func internalIP(addr netip.Addr) bool {
+	addr = addr.Unmap()
+
+	return addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsUnspecified()
+}
+
+// allowedURL tells whether the host of the given URL is one of the AllowedBackendHosts.
+func (l Limits) allowedURL(rawURL string) bool {
+	u, err := stdurl.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	return l.allowedHost(u.Hostname())
+}
+
+// allowedHost tells whether the given host is one of the AllowedBackendHosts.
+func (l Limits) allowedHost(host string) bool {
+	if host == "" {
+		return false
+	}
+
+	return slices.ContainsFunc(l.AllowedBackendHosts, func(allowed string) bool {
+		return strings.EqualFold(allowed, host)
+	})
+}
+
+// MakeExperiment makes a valid Experiment whose dynamic configuration complies with the given Limits.
+// When variables are given, one "name=value" per line, the "${name}" placeholders of the dynamic configuration are
+// substituted with their value first, and a placeholder without a variable is an error.
+// The trailing blank lines of the dynamic configuration are dropped, and it ends with a single newline.
+// A ValidationError is returned when the variables, the dynamic configuration or the request are invalid.
+func MakeExperiment(dynamicConfig, rawVars string, rawReq RawHTTPRequest, limits Limits) (Experiment, error) {
+	dynamicConfig = trimTrailingBlankLines(dynamicConfig)
+
+	if len(rawVars) > maxVarsLength {
+		return Experiment{}, newTooLargeError("vars", "vars", maxVarsLength)
+	}
+
+	if strings.TrimSpace(rawVars) != "" {
+		vars, err := parseVars(rawVars)
+		if err != nil {
+			return Experiment{}, &ValidationError{Field: "vars", Message: err.Error()}
+		}
+
+		if dynamicConfig, err = expandPlaceholders(dynamicConfig, vars); err != nil {
+			return Experiment{}, err
+		}
+	}
+
+	if err := ValidateDynamicConfig(dynamicConfig, limits); err != nil {
+		return Experiment{}, err
+	}
+
+	if err := validateDynamicConfigSchema(dynamicConfig); err != nil {
+		return Experiment{}, err
+	}
+
+	req, err := MakeHTTPRequest(rawReq)
+	if err != nil {
+		return Experiment{}, fmt.Errorf("request: %w", err)
+	}
+
+	if err = limits.checkRequestScheme(req.URL); err != nil {
 		return Experiment{}, fmt.Errorf("request: %w", err)
 	}
 
+	if limits.RestrictRequestHosts {
+		if err = limits.checkRequestHost(req.URL); err != nil {
+			return Experiment{}, fmt.Errorf("request: %w", err)
+		}
+	}
+
 	return Experiment{
 		DynamicConfig: dynamicConfig,
 		Request:       req,
 	}, nil
 }
 
+// trimTrailingBlankLines drops the blank lines ending the given dynamic configuration, and makes sure it ends with a
+// single newline. The lines holding content are left untouched, as their indentation and even their trailing spaces
+// can be meaningful, such as in block scalars. The leading blank lines are kept, so that the line numbers reported
+// in errors still match what was submitted.
+func trimTrailingBlankLines(dynamicConfig string) string {
+	lines := strings.Split(dynamicConfig, "\n")
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// ValidateDynamicConfig checks the given dynamic configuration can be used in an Experiment.
+func ValidateDynamicConfig(dynamicConfig string, limits Limits) error {
+	if len(dynamicConfig) > maxDynamicConfigLength {
+		return newTooLargeError("dynamicConfig", "dynamic config", maxDynamicConfigLength)
+	}
+
+	var unmarshalledDynamicConfig dynamic.Configuration
+	if err := yaml.Unmarshal([]byte(dynamicConfig), &unmarshalledDynamicConfig); err != nil {
+		return newValidationError("dynamicConfig", "invalid dynamic configuration: %s", err)
+	}
+
+	return limits.check(unmarshalledDynamicConfig)
+}
+
+// TokenizeDynamicConfig parses the given dynamic configuration as a tree keeping comments and positions.
+// A ValidationError is returned when the dynamic configuration is too large or isn't valid YAML.
+func TokenizeDynamicConfig(dynamicConfig string) (traefik.ConfigNode, error) {
+	if len(dynamicConfig) > maxDynamicConfigLength {
+		return traefik.ConfigNode{}, newTooLargeError("dynamicConfig", "dynamic config", maxDynamicConfigLength)
+	}
+
+	tree, err := traefik.TokenizeDynamicConfig(dynamicConfig)
+	if err != nil {
+		return traefik.ConfigNode{}, newValidationError("dynamicConfig", "invalid dynamic configuration: %s", err)
+	}
+
+	return tree, nil
+}
+
+// MakeStaticConfig makes a valid Experiment static configuration from the given one, in YAML. Only the few options
+// taking effect in the playground can be set, such as the read timeout or the forwarded headers settings of the
+// entrypoints, see traefik.ValidateStaticConfig. A blank static configuration is left empty.
+func MakeStaticConfig(staticConfig string) (string, error) {
+	if len(staticConfig) > maxStaticConfigLength {
+		return "", newTooLargeError("staticConfig", "static configuration", maxStaticConfigLength)
+	}
+
+	staticConfig = trimTrailingBlankLines(staticConfig)
+	if strings.TrimSpace(staticConfig) == "" {
+		return "", nil
+	}
+
+	if err := traefik.ValidateStaticConfig(staticConfig); err != nil {
+		return "", &ValidationError{Field: "staticConfig", Message: err.Error(), Err: err}
+	}
+
+	return staticConfig, nil
+}
+
+// MakeLabel makes a valid Experiment label from the given one. Surrounding spaces are trimmed, and only letters,
+// digits, spaces, '-', '_' and '.' are allowed.
+func MakeLabel(label string) (string, error) {
+	label = strings.TrimSpace(label)
+
+	if err := validateLabel(label); err != nil {
+		return "", err
+	}
+
+	return label, nil
+}
+
+func validateLabel(label string) error {
+	if utf8.RuneCountInString(label) > maxLabelLength {
+		// The label is limited in characters rather than in bytes.
+		return &ValidationError{
+			Field:   "label",
+			Message: fmt.Sprintf("label is too long (max: %d)", maxLabelLength),
+			Err:     ErrTooLarge,
+		}
+	}
+
+	validRune := func(r rune) bool {
+		return unicode.IsLetter(r) || unicode.IsDigit(r) || r == ' ' || r == '-' || r == '_' || r == '.'
+	}
+
+	if strings.IndexFunc(label, func(r rune) bool { return !validRune(r) }) >= 0 {
+		return newValidationError("label", "label must only contain letters, digits, spaces, '-', '_' and '.'")
+	}
+
+	return nil
+}
+
 // Result is the result of a ran experiment.
 type Result struct {
-	Response HTTPResponse  `json:"response"`
-	Logs     []traefik.Log `json:"logs"`
+	Response HTTPResponse `json:"response"`
+	// Matched tells whether a router matched the request. When false, the response comes from Traefik.
+	Matched       bool   `json:"matched"`
+	MatchedRouter string `json:"matchedRouter,omitempty"`
+	// MatchedRule and MatchedPriority are the rule and the priority of the matched router, helping to tell why
+	// it won over the other routers matching the request.
+	MatchedRule     string `json:"matchedRule,omitempty"`
+	MatchedPriority int    `json:"matchedPriority,omitempty"`
+	// MiddlewareChain lists the middlewares run by the matched router, in execution order.
+	MiddlewareChain []string `json:"middlewareChain,omitempty"`
+	// RouterEvaluations explains, for every router, whether its rule matched the request and why it was or wasn't
+	// selected, the routers tried first coming first.
+	RouterEvaluations []traefik.RouterEvaluation `json:"routerEvaluations,omitempty"`
+	// HeaderChanges lists the request headers Traefik added, changed or removed before forwarding the request to the
+	// Backend, such as X-Forwarded-For, telling them apart from the headers of the experiment.
+	HeaderChanges []traefik.HeaderChange `json:"headerChanges,omitempty"`
+	// Metrics are the counters measured by Traefik while handling the request.
+	Metrics traefik.Metrics `json:"metrics"`
+	Logs    []traefik.Log   `json:"logs"`
+	// ResolvedConfig is the JSON encoded runtime configuration Traefik resolved from the dynamic configuration.
+	ResolvedConfig json.RawMessage `json:"resolvedConfig,omitempty"`
+	// Backend is the public URL of the playground backend the request was last sent to, empty if it reached none.
+	Backend string `json:"backend,omitempty"`
+	// Burst lists the outcome of each request when the request is sent in burst, in order, along with the backend
+	// each one reached. The Response is the response to the last request.
+	Burst []traefik.BurstResponse `json:"burst,omitempty"`
+	// LimitedRequests is the number of requests sent in burst which Traefik rejected before they reached a backend,
+	// such as the rateLimit and inFlightReq middlewares do.
+	LimitedRequests int `json:"limitedRequests,omitempty"`
+	// CircuitBreaker lists the state transitions of the circuitBreaker middlewares, in order. Send the request in
+	// burst to give a breaker enough requests to open.
+	CircuitBreaker []traefik.CircuitBreakerTransition `json:"circuitBreaker,omitempty"`
+	// Warnings explain where the playground behaves differently from a Traefik instance, such as when plugins
+	// are stubbed.
+	Warnings []string `json:"warnings,omitempty"`
 }
 
 // Value implements driver.Valuer interface.
@@ -78,10 +561,99 @@ func (r *Result) Scan(value interface{}) error {
 
 // HTTPRequest is an HTTP request to send as part of the Experiment.
 type HTTPRequest struct {
-	Method  string      `json:"method"`
-	URL     string      `json:"url"`
-	Headers http.Header `json:"headers"`
-	Body    string      `json:"body"`
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Proto is the protocol version of the request. It's empty for the default HTTP/1.1.
+	Proto string `json:"proto,omitempty"`
+	// Scheme is the scheme Traefik sees the request with, http or https, forwarded as X-Forwarded-Proto.
+	// It's empty to leave the scheme of the URL untouched.
+	Scheme string `json:"scheme,omitempty"`
+	// Host overrides the host derived from the URL when set.
+	Host string `json:"host,omitempty"`
+	// ClientIP is the IP address the request originates from.
+	ClientIP string `json:"clientIP,omitempty"`
+	// TrustedIPs are the IPs and CIDRs the entrypoints trust the forwarded headers of, such as X-Forwarded-For, as
+	// with their forwardedHeaders.trustedIPs option. The forwarded headers of the other clients are replaced. Without
+	// trusted IPs, the forwarded headers are left untouched.
+	TrustedIPs []string    `json:"trustedIPs,omitempty"`
+	Headers    http.Header `json:"headers"`
+	Body       string      `json:"body"`
+
+	// Username and Password are the basic authentication credentials of the request.
+	// The password is never marshaled in plaintext, see MarshalJSON.
+	Username string `json:"username,omitempty"`
+	Password string `json:"-"`
+
+	// Burst is the number of times the request is sent back to back to the same Traefik instance, such as to
+	// exceed a rate limit. Zero and one send it once.
+	Burst int `json:"burst,omitempty"`
+	// KeepCookies makes the requests sent in burst send the cookies set by the previous responses, as a browser
+	// would, such as to stick to a server of a sticky session.
+	KeepCookies bool `json:"keepCookies,omitempty"`
+	// Concurrent makes the requests sent in burst be sent at once rather than back to back, such as to exceed the
+	// limit of an inFlightReq middleware. Cookies can't be kept across them.
+	Concurrent bool `json:"concurrent,omitempty"`
+
+	// DelayMs is the number of milliseconds the client waits before sending the request body, such as to simulate
+	// a slow client. Requests without a body are sent after the delay. The delay counts towards the run timeout.
+	DelayMs int `json:"delayMs,omitempty"`
+
+	// NoContentTypeDetection leaves the Content-Type header unset when the body is JSON, instead of setting it
+	// to application/json.
+	NoContentTypeDetection bool `json:"noContentTypeDetection,omitempty"`
+}
+
+// MarshalJSON implements the json.Marshaler interface. The credentials are marshaled as the Authorization header
+// sent with the request rather than as a plaintext password, so that stored and shared requests keep them.
+func (r HTTPRequest) MarshalJSON() ([]byte, error) {
+	type plainHTTPRequest HTTPRequest
+
+	return json.Marshal(struct {
+		plainHTTPRequest
+
+		Authorization string `json:"authorization,omitempty"`
+	}{
+		plainHTTPRequest: plainHTTPRequest(r),
+		Authorization:    r.authorization(),
+	})
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. The password is read back from the Authorization header.
+func (r *HTTPRequest) UnmarshalJSON(data []byte) error {
+	type plainHTTPRequest HTTPRequest
+
+	var v struct {
+		plainHTTPRequest
+
+		Authorization string `json:"authorization"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*r = HTTPRequest(v.plainHTTPRequest)
+
+	if v.Authorization != "" {
+		authReq := http.Request{Header: http.Header{"Authorization": {v.Authorization}}}
+
+		username, password, ok := authReq.BasicAuth()
+		if !ok || username != r.Username {
+			return errors.New("invalid authorization header")
+		}
+
+		r.Password = password
+	}
+
+	return nil
+}
+
+// authorization returns the Authorization header carrying the credentials of the request, if any.
+func (r HTTPRequest) authorization() string {
+	if r.Username == "" {
+		return ""
+	}
+
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(r.Username+":"+r.Password))
 }
 
 // Value implements driver.Valuer interface.
@@ -99,44 +671,267 @@ func (r *HTTPRequest) Scan(value interface{}) error {
 	return json.Unmarshal(b, &r)
 }
 
-// MakeHTTPRequest makes a valid HTTP request.
-func MakeHTTPRequest(method, url, headers, body string) (HTTPRequest, error) {
+// RawHTTPRequest holds the unvalidated fields of an HTTPRequest.
+type RawHTTPRequest struct {
+	Method   string
+	URL      string
+	Proto    string
+	Scheme   string
+	Host     string
+	ClientIP string
+	// TrustedIPs holds the IPs and CIDRs trusted by the entrypoints, separated by commas or spaces.
+	TrustedIPs string
+	// Headers holds one "name: value" header per line. Lines starting with a space or a tab continue the value of
+	// the previous header.
+	Headers  string
+	Body     string
+	Username string
+	Password string
+	// Burst is the number of times the request is sent, empty to send it once.
+	Burst string
+	// DelayMs is the number of milliseconds to wait before sending the request body, empty to send it right away.
+	DelayMs string
+	// KeepCookies is a boolean keeping the cookies across the requests sent in burst, empty to not keep them.
+	KeepCookies string
+	// Concurrent is a boolean sending the requests of a burst at once, empty to send them back to back.
+	Concurrent string
+	// NoContentTypeDetection is a boolean disabling the Content-Type detection, empty to detect it.
+	NoContentTypeDetection string
+}
+
+// MakeHTTPRequest makes a valid HTTP request. A ValidationError is returned when a field is invalid.
+func MakeHTTPRequest(rawReq RawHTTPRequest) (HTTPRequest, error) {
 	availableMethods := []string{
 		http.MethodGet,
 		http.MethodPost,
 		http.MethodPut,
 		http.MethodDelete,
 		http.MethodPatch,
+		// OPTIONS allows sending CORS preflight requests.
+		http.MethodOptions,
 	}
 
+	availableProtos := []string{"HTTP/1.0", "HTTP/1.1"}
+	availableSchemes := []string{"http", "https"}
+
 	switch {
-	case method == "":
-		return HTTPRequest{}, errors.New("method is required")
-	case !slices.Contains(availableMethods, method):
-		return HTTPRequest{}, fmt.Errorf("method %s not allowed", method)
-	case url == "":
-		return HTTPRequest{}, errors.New("url is required")
-	case len(url) > maxURLLength:
-		return HTTPRequest{}, fmt.Errorf("url is too long (max: %d)", maxURLLength)
-	case len(body) > maxBodyLength:
-		return HTTPRequest{}, fmt.Errorf("body is too long (max: %d)", maxBodyLength)
+	case rawReq.Method == "":
+		return HTTPRequest{}, newValidationError("method", "method is required")
+	case !slices.Contains(availableMethods, rawReq.Method):
+		return HTTPRequest{}, newValidationError("method", "method %s not allowed", rawReq.Method)
+	case rawReq.Proto != "" && !slices.Contains(availableProtos, rawReq.Proto):
+		return HTTPRequest{}, newValidationError("proto", "protocol %s not allowed", rawReq.Proto)
+	case rawReq.Scheme != "" && !slices.Contains(availableSchemes, rawReq.Scheme):
+		return HTTPRequest{}, newValidationError("scheme", "scheme must be http or https")
+	case rawReq.URL == "":
+		return HTTPRequest{}, newValidationError("url", "url is required")
+	case len(rawReq.URL) > maxURLLength:
+		return HTTPRequest{}, newTooLargeError("url", "url", maxURLLength)
+	case len(rawReq.Host) > maxHostLength:
+		return HTTPRequest{}, newTooLargeError("host", "host", maxHostLength)
+	case len(rawReq.Body) > maxBodyLength:
+		return HTTPRequest{}, newTooLargeError("body", "body", maxBodyLength)
+	case len(rawReq.Username) > maxCredentialLength:
+		return HTTPRequest{}, newTooLargeError("username", "username", maxCredentialLength)
+	case len(rawReq.Password) > maxCredentialLength:
+		return HTTPRequest{}, newTooLargeError("password", "password", maxCredentialLength)
+	case strings.Contains(rawReq.Username, ":"):
+		return HTTPRequest{}, newValidationError("username", "username must not contain a colon")
+	case rawReq.Password != "" && rawReq.Username == "":
+		return HTTPRequest{}, newValidationError("username", "username is required when a password is set")
+	}
+
+	if _, err := stdurl.ParseRequestURI(rawReq.URL); err != nil {
+		return HTTPRequest{}, newValidationError("url", "url is invalid")
+	}
+
+	host := strings.TrimSpace(rawReq.Host)
+	if host != "" && !validHost(host) {
+		return HTTPRequest{}, newValidationError("host", "host is invalid")
 	}
 
-	if _, err := stdurl.ParseRequestURI(url); err != nil {
-		return HTTPRequest{}, errors.New("url is invalid")
+	clientIP := strings.TrimSpace(rawReq.ClientIP)
+	if clientIP != "" {
+		addr, err := netip.ParseAddr(clientIP)
+		if err != nil {
+			return HTTPRequest{}, newValidationError("clientIP", "client IP is invalid")
+		}
+
+		clientIP = addr.String()
 	}
 
-	parsedHeaders, err := parseHeaders(headers)
+	trustedIPs, err := parseTrustedIPs(rawReq.TrustedIPs)
 	if err != nil {
-		return HTTPRequest{}, err
+		return HTTPRequest{}, &ValidationError{Field: "trustedIPs", Message: err.Error()}
 	}
 
-	return HTTPRequest{
-		Method:  method,
-		URL:     url,
-		Headers: parsedHeaders,
-		Body:    body,
-	}, nil
+	// Leave the default protocol out, so that it doesn't set the experiment apart from those created before.
+	proto := rawReq.Proto
+	if proto == "HTTP/1.1" {
+		proto = ""
+	}
+
+	parsedHeaders, err := parseHeaders(rawReq.Headers)
+	if err != nil {
+		var validationErr *ValidationError
+		if errors.As(err, &validationErr) {
+			return HTTPRequest{}, validationErr
+		}
+
+		return HTTPRequest{}, &ValidationError{Field: "headers", Message: err.Error()}
+	}
+
+	var burst int
+	if rawBurst := strings.TrimSpace(rawReq.Burst); rawBurst != "" {
+		burst, err = strconv.Atoi(rawBurst)
+		if err != nil || burst < 1 || burst > maxBurst {
+			return HTTPRequest{}, newValidationError("burst", "burst must be between 1 and %d", maxBurst)
+		}
+	}
+
+	// Leave a single request out, so that it doesn't set the experiment apart from those created before.
+	if burst == 1 {
+		burst = 0
+	}
+
+	var delayMs int
+	if rawDelayMs := strings.TrimSpace(rawReq.DelayMs); rawDelayMs != "" {
+		delayMs, err = strconv.Atoi(rawDelayMs)
+		if err != nil || delayMs < 0 || delayMs > maxDelayMs {
+			return HTTPRequest{}, newValidationError("delayMs", "delay must be between 0 and %d milliseconds", maxDelayMs)
+		}
+	}
+
+	var keepCookies bool
+	if rawKeepCookies := strings.TrimSpace(rawReq.KeepCookies); rawKeepCookies != "" {
+		keepCookies, err = strconv.ParseBool(rawKeepCookies)
+		if err != nil {
+			return HTTPRequest{}, newValidationError("keepCookies", "cookies toggle must be a boolean")
+		}
+	}
+	if keepCookies && burst == 0 {
+		return HTTPRequest{}, newValidationError("keepCookies", "cookies can only be kept across requests sent in burst")
+	}
+
+	var concurrent bool
+	if rawConcurrent := strings.TrimSpace(rawReq.Concurrent); rawConcurrent != "" {
+		concurrent, err = strconv.ParseBool(rawConcurrent)
+		if err != nil {
+			return HTTPRequest{}, newValidationError("concurrent", "concurrency toggle must be a boolean")
+		}
+	}
+	if concurrent && burst == 0 {
+		return HTTPRequest{}, newValidationError("concurrent", "only requests sent in burst can be sent at once")
+	}
+	if concurrent && keepCookies {
+		return HTTPRequest{}, newValidationError("concurrent", "cookies can't be kept across requests sent at once")
+	}
+
+	var noContentTypeDetection bool
+	if rawNoDetection := strings.TrimSpace(rawReq.NoContentTypeDetection); rawNoDetection != "" {
+		noContentTypeDetection, err = strconv.ParseBool(rawNoDetection)
+		if err != nil {
+			return HTTPRequest{}, newValidationError("noContentTypeDetection", "content type detection toggle must be a boolean")
+		}
+	}
+
+	// An explicit Content-Type is never overridden, even when it doesn't match the body.
+	if !noContentTypeDetection && parsedHeaders.Get("Content-Type") == "" {
+		if contentType := detectContentType(rawReq.Body); contentType != "" {
+			parsedHeaders.Set("Content-Type", contentType)
+		}
+	}
+
+	req := HTTPRequest{
+		Method:     rawReq.Method,
+		URL:        rawReq.URL,
+		Proto:      proto,
+		Scheme:     rawReq.Scheme,
+		Host:       host,
+		ClientIP:   clientIP,
+		TrustedIPs: trustedIPs,
+		Headers:    parsedHeaders,
+		Body:       rawReq.Body,
+		Username:   rawReq.Username,
+		Password:   rawReq.Password,
+		Burst:      burst,
+		DelayMs:    delayMs,
+
+		KeepCookies:            keepCookies,
+		Concurrent:             concurrent,
+		NoContentTypeDetection: noContentTypeDetection,
+	}
+
+	// The user headers are limited on their own, but the headers added by the playground come on top of them.
+	if addedHeaders := req.AddedHeaders(); len(req.Headers)+len(addedHeaders) > maxOutgoingHeaders {
+		return HTTPRequest{}, newValidationError("headers", "too many headers once those added by the playground (%s) "+
+			"are counted: %d (max %d)", strings.Join(addedHeaders, ", "), len(req.Headers)+len(addedHeaders), maxOutgoingHeaders)
+	}
+
+	return req, nil
+}
+
+// AddedHeaders returns the names of the headers added to the request when it's sent to Traefik, on top of its
+// Headers: X-Forwarded-Proto for the Scheme, X-Forwarded-For for the ClientIP, Authorization for the credentials
+// and Cookie for the cookies kept across a burst. The headers already given by the user are left out: X-Forwarded-For
+// is kept as given, Cookie is extended and the others are replaced.
+func (r HTTPRequest) AddedHeaders() []string {
+	var added []string
+	addHeader := func(name string, set bool) {
+		if _, ok := r.Headers[name]; set && !ok {
+			added = append(added, name)
+		}
+	}
+
+	addHeader("X-Forwarded-Proto", r.Scheme != "")
+	addHeader("X-Forwarded-For", r.ClientIP != "")
+	addHeader("Authorization", r.Username != "")
+	addHeader("Cookie", r.KeepCookies)
+
+	return added
+}
+
+// parseTrustedIPs parses the given IPs and CIDRs, separated by commas or spaces, into their canonical form.
+func parseTrustedIPs(rawTrustedIPs string) ([]string, error) {
+	fields := strings.FieldsFunc(rawTrustedIPs, func(r rune) bool {
+		return r == ',' || unicode.IsSpace(r)
+	})
+	if len(fields) > maxTrustedIPs {
+		return nil, fmt.Errorf("too many trusted IPs (max: %d)", maxTrustedIPs)
+	}
+
+	var trustedIPs []string
+	for _, field := range fields {
+		if prefix, err := netip.ParsePrefix(field); err == nil {
+			trustedIPs = append(trustedIPs, prefix.Masked().String())
+
+			continue
+		}
+
+		addr, err := netip.ParseAddr(field)
+		if err != nil {
+			return nil, fmt.Errorf("trusted IP %q is not an IP or a CIDR", field)
+		}
+
+		trustedIPs = append(trustedIPs, addr.String())
+	}
+
+	return trustedIPs, nil
+}
+
+// detectContentType returns the content type of the given request body, or an empty string if it can't be told.
+// Only JSON objects and arrays are detected, as a body like "true" or "1" is as likely to be plain text.
+func detectContentType(body string) string {
+	trimmed := strings.TrimSpace(body)
+	if trimmed == "" || (trimmed[0] != '{' && trimmed[0] != '[') {
+		return ""
+	}
+
+	if !json.Valid([]byte(trimmed)) {
+		return ""
+	}
+
+	return "application/json"
 }
 
 // HTTPResponse is the HTTP response obtained from a ran experiment.
@@ -145,24 +940,200 @@ type HTTPResponse struct {
 	StatusCode int         `json:"statusCode"`
 	Headers    http.Header `json:"headers"`
 	Body       []byte      `json:"body"`
+	Trailers   http.Header `json:"trailers,omitempty"`
+
+	// ContentType is the media type of the response, without parameters.
+	ContentType string `json:"contentType,omitempty"`
+	// IsText reports whether the body is meant to be displayed as text.
+	IsText bool `json:"isText,omitempty"`
+
+	// ContentEncoding is the encoding the body was decoded from, if any.
+	// When set, RawBody holds the body as received.
+	ContentEncoding string `json:"contentEncoding,omitempty"`
+	RawBody         []byte `json:"-"`
+}
+
+// MarshalJSON implements the json.Marshaler interface. A decoded body is marshaled as received only,
+// so that stored responses don't hold it twice.
+func (r HTTPResponse) MarshalJSON() ([]byte, error) {
+	type plainHTTPResponse HTTPResponse
+
+	v := plainHTTPResponse(r)
+	if v.ContentEncoding != "" {
+		v.Body = v.RawBody
+	}
+
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface. A body marshaled as received is decoded back.
+func (r *HTTPResponse) UnmarshalJSON(data []byte) error {
+	type plainHTTPResponse HTTPResponse
+
+	var v plainHTTPResponse
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	*r = HTTPResponse(v)
+
+	if r.ContentEncoding != "" {
+		decoded, ok := decodeBody(r.ContentEncoding, r.Body)
+		if !ok {
+			return fmt.Errorf("invalid %s encoded body", r.ContentEncoding)
+		}
+
+		r.RawBody = r.Body
+		r.Body = decoded
+	}
+
+	return nil
+}
+
+// maxDecodedBodySize is the maximum size of a decoded response body. Larger bodies are kept encoded.
+const maxDecodedBodySize = 1024 * 1024
+
+// decodeBody decodes the given body according to the Content-Encoding header value.
+// Only gzip and deflate are supported, ok is false when the body could not be decoded.
+func decodeBody(contentEncoding string, body []byte) (decoded []byte, ok bool) {
+	var (
+		reader io.ReadCloser
+		err    error
+	)
+
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		reader, err = zlib.NewReader(bytes.NewReader(body))
+	default:
+		return nil, false
+	}
+
+	if err != nil {
+		return nil, false
+	}
+
+	defer func() { _ = reader.Close() }()
+
+	decoded, err = io.ReadAll(io.LimitReader(reader, maxDecodedBodySize+1))
+	if err != nil || len(decoded) > maxDecodedBodySize {
+		return nil, false
+	}
+
+	return decoded, true
+}
+
+// isTextBody guesses whether a body with the given media type should be displayed as text.
+// When the media type is unknown, the body is considered as text if it is valid UTF-8 without control characters.
+func isTextBody(mediaType string, body []byte) bool {
+	// Media types, outside of "text/*", whose bodies are text.
+	textMediaTypes := []string{
+		"application/json",
+		"application/xml",
+		"application/javascript",
+		"application/x-www-form-urlencoded",
+		"application/yaml",
+		"image/svg+xml",
+	}
+
+	switch {
+	case strings.HasPrefix(mediaType, "text/"),
+		strings.HasSuffix(mediaType, "+json"),
+		strings.HasSuffix(mediaType, "+xml"),
+		slices.Contains(textMediaTypes, mediaType):
+		return true
+	case mediaType != "":
+		return false
+	}
+
+	if !utf8.Valid(body) {
+		return false
+	}
+
+	return !bytes.ContainsFunc(body, func(r rune) bool {
+		return unicode.IsControl(r) && r != '\n' && r != '\r' && r != '\t'
+	})
+}
+
+// parseMediaType returns the media type of the given Content-Type header value.
+func parseMediaType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return ""
+	}
+
+	return mediaType
 }
 
+// validHost checks whether the given host, with an optional port, can be used as a Host header.
+func validHost(host string) bool {
+	if !header.ValidHeaderValue(host) || strings.ContainsAny(host, " \t/?#@") {
+		return false
+	}
+
+	u, err := stdurl.Parse("http://" + host)
+
+	return err == nil && u.Host == host
+}
+
+// unfoldHeaderLines joins the continuation lines, starting with a space or a tab, to the header line they continue
+// with a single space, so that long values can be written on several lines. Indented lines which read as a
+// "name: value" header aren't continuation lines, so that an indented block of headers is kept as is. Blank lines
+// are dropped.
+func unfoldHeaderLines(lines []string) []string {
+	var unfolded []string
+	for _, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			continue
+		}
+
+		if len(unfolded) > 0 && (line[0] == ' ' || line[0] == '\t') && !isHeaderLine(trimmed) {
+			last := len(unfolded) - 1
+			unfolded[last] = strings.TrimRight(unfolded[last], " \t\r") + " " + trimmed
+
+			continue
+		}
+
+		unfolded = append(unfolded, line)
+	}
+
+	return unfolded
+}
+
+// isHeaderLine tells whether the given line reads as a "name: value" header, with a valid header name.
+func isHeaderLine(line string) bool {
+	name, _, ok := strings.Cut(line, ":")
+
+	return ok && name != "" && header.ValidHeaderField(name)
+}
+
+// parseHeaders parses the given "name: value" header lines. A header written on several lines is sent with as many
+// values, in the order they are written.
 func parseHeaders(rawHeaders string) (http.Header, error) {
-	headerLines := strings.Split(rawHeaders, "\n")
+	headerLines := unfoldHeaderLines(strings.Split(rawHeaders, "\n"))
 
 	headers := make(http.Header)
+
+	var count int
 	for _, line := range headerLines {
 		if strings.TrimSpace(line) == "" {
 			continue
 		}
 
-		parts := strings.Split(line, ":")
-		if len(parts) != 2 {
+		// Values may hold colons, such as URLs in Origin or Referer headers.
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
 			return nil, fmt.Errorf(`invalid header format, want "name: value", got: %q`, line)
 		}
 
-		name := strings.TrimSpace(parts[0])
-		value := strings.TrimSpace(parts[1])
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
 
 		if name == "" {
 			return nil, fmt.Errorf("missing header name on line: %q", line)
@@ -171,10 +1142,10 @@ func parseHeaders(rawHeaders string) (http.Header, error) {
 		}
 
 		if len(name) > maxHeaderNameLength {
-			return nil, fmt.Errorf(`header name is too long for "%s..." (max %d)`, name[:10], maxHeaderNameLength)
+			return nil, newTooLargeError("headers", fmt.Sprintf(`header name "%s..."`, name[:10]), maxHeaderNameLength)
 		}
 		if len(value) > maxHeaderValueLength {
-			return nil, fmt.Errorf("header value is too long for %q (max %d)", name, maxHeaderValueLength)
+			return nil, newTooLargeError("headers", fmt.Sprintf("header value of %q", name), maxHeaderValueLength)
 		}
 
 		if !header.ValidHeaderField(name) {
@@ -184,11 +1155,12 @@ func parseHeaders(rawHeaders string) (http.Header, error) {
 			return nil, fmt.Errorf("invalid header value for %q", name)
 		}
 
-		if len(headers) >= maxHeaders {
+		if count >= maxHeaders {
 			return nil, fmt.Errorf("too many headers (max %d)", maxHeaders)
 		}
+		count++
 
-		headers.Set(name, value)
+		headers.Add(name, value)
 	}
 
 	return headers, nil
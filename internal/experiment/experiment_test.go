@@ -1,8 +1,16 @@
 package experiment_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"net"
 	"net/http"
+	"net/netip"
+	"strings"
 	"testing"
 
 	"github.com/jspdown/traefik-playground/internal/experiment"
@@ -26,7 +34,7 @@ func TestMakeExperiment(t *testing.T) {
 	}{
 		{
 			name:          "valid experiment",
-			dynamicConfig: `http: {}`,
+			dynamicConfig: "http: {}\n",
 			method:        http.MethodGet,
 			url:           "http://example.com",
 			headers:       "Content-Type: application/json",
@@ -51,7 +59,7 @@ func TestMakeExperiment(t *testing.T) {
 			dynamicConfig: string(make([]byte, 65537)), // 65KB + 1 byte
 			method:        http.MethodGet,
 			url:           "http://example.com",
-			wantErr:       errors.New("dynamic config too long (max: 10240)"),
+			wantErr:       errors.New("dynamic config is too large (max: 10240 bytes)"),
 		},
 	}
 
@@ -59,7 +67,12 @@ func TestMakeExperiment(t *testing.T) {
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 
-			got, err := experiment.MakeExperiment(test.dynamicConfig, test.method, test.url, test.headers, test.body)
+			got, err := experiment.MakeExperiment(test.dynamicConfig, "", experiment.RawHTTPRequest{
+				Method:  test.method,
+				URL:     test.url,
+				Headers: test.headers,
+				Body:    test.body,
+			}, experiment.Limits{})
 			if test.wantErr != nil {
 				require.EqualError(t, err, test.wantErr.Error())
 			} else {
@@ -76,24 +89,858 @@ func TestMakeExperiment(t *testing.T) {
 	}
 }
 
-func TestMakeHTTPRequest(t *testing.T) {
+func TestMakeExperiment_validationError(t *testing.T) {
+	t.Parallel()
+
+	valid := experiment.RawHTTPRequest{
+		Method: http.MethodGet,
+		URL:    "http://example.com",
+	}
+
+	tests := []struct {
+		name          string
+		dynamicConfig string
+		update        func(req *experiment.RawHTTPRequest)
+		wantField     string
+	}{
+		{
+			name:          "invalid dynamic config",
+			dynamicConfig: "invalid yaml",
+			update:        func(*experiment.RawHTTPRequest) {},
+			wantField:     "dynamicConfig",
+		},
+		{
+			name:      "invalid method",
+			update:    func(req *experiment.RawHTTPRequest) { req.Method = "INVALID" },
+			wantField: "method",
+		},
+		{
+			name:      "invalid url",
+			update:    func(req *experiment.RawHTTPRequest) { req.URL = "not-a-url" },
+			wantField: "url",
+		},
+		{
+			name:      "invalid scheme",
+			update:    func(req *experiment.RawHTTPRequest) { req.Scheme = "ftp" },
+			wantField: "scheme",
+		},
+		{
+			name:      "invalid host",
+			update:    func(req *experiment.RawHTTPRequest) { req.Host = "example.com/foo" },
+			wantField: "host",
+		},
+		{
+			name:      "invalid client IP",
+			update:    func(req *experiment.RawHTTPRequest) { req.ClientIP = "invalid" },
+			wantField: "clientIP",
+		},
+		{
+			name:      "invalid trusted IPs",
+			update:    func(req *experiment.RawHTTPRequest) { req.TrustedIPs = "invalid" },
+			wantField: "trustedIPs",
+		},
+		{
+			name:      "invalid headers",
+			update:    func(req *experiment.RawHTTPRequest) { req.Headers = "Invalid-Header" },
+			wantField: "headers",
+		},
+		{
+			name:      "body too long",
+			update:    func(req *experiment.RawHTTPRequest) { req.Body = strings.Repeat("a", 1025) },
+			wantField: "body",
+		},
+		{
+			name:      "password without username",
+			update:    func(req *experiment.RawHTTPRequest) { req.Password = "pass" },
+			wantField: "username",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			dynamicConfig := test.dynamicConfig
+			if dynamicConfig == "" {
+				dynamicConfig = "http: {}"
+			}
+
+			req := valid
+			test.update(&req)
+
+			_, err := experiment.MakeExperiment(dynamicConfig, "", req, experiment.Limits{})
+
+			var validationErr *experiment.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, test.wantField, validationErr.Field)
+			assert.NotEmpty(t, validationErr.Message)
+		})
+	}
+}
+
+func TestMakeExperiment_limits(t *testing.T) {
+	t.Parallel()
+
+	limits := experiment.Limits{
+		MaxRouters:     3,
+		MaxServices:    2,
+		MaxMiddlewares: 2,
+	}
+
+	tests := []struct {
+		name          string
+		dynamicConfig string
+		limits        experiment.Limits
+		wantErr       error
+	}{
+		{
+			name: "at the limit",
+			dynamicConfig: `
+http:
+  routers:
+    a: {rule: "Path(` + "`/a`" + `)", service: a}
+    b: {rule: "Path(` + "`/b`" + `)", service: a}
+  services:
+    a: {loadBalancer: {servers: [{url: "http://whoami"}]}}
+  middlewares:
+    a: {stripPrefix: {prefixes: [/a]}}
+    b: {stripPrefix: {prefixes: [/b]}}
+tcp:
+  routers:
+    a: {rule: "HostSNI(` + "`*`" + `)", service: a}
+  services:
+    a: {loadBalancer: {servers: [{address: "whoami:8080"}]}}
+`,
+			limits: limits,
+		},
+		{
+			name: "too many routers",
+			dynamicConfig: `
+http:
+  routers:
+    a: {rule: "Path(` + "`/a`" + `)", service: a}
+    b: {rule: "Path(` + "`/b`" + `)", service: a}
+tcp:
+  routers:
+    a: {rule: "HostSNI(` + "`*`" + `)", service: a}
+udp:
+  routers:
+    a: {service: a}
+`,
+			limits:  limits,
+			wantErr: errors.New("too many routers: 4 (max: 3)"),
+		},
+		{
+			name: "too many services",
+			dynamicConfig: `
+http:
+  services:
+    a: {loadBalancer: {servers: [{url: "http://whoami"}]}}
+tcp:
+  services:
+    a: {loadBalancer: {servers: [{address: "whoami:8080"}]}}
+udp:
+  services:
+    a: {loadBalancer: {servers: [{address: "whoami:8080"}]}}
+`,
+			limits:  limits,
+			wantErr: errors.New("too many services: 3 (max: 2)"),
+		},
+		{
+			name: "too many middlewares",
+			dynamicConfig: `
+http:
+  middlewares:
+    a: {stripPrefix: {prefixes: [/a]}}
+    b: {stripPrefix: {prefixes: [/b]}}
+tcp:
+  middlewares:
+    a: {inFlightConn: {amount: 10}}
+`,
+			limits:  limits,
+			wantErr: errors.New("too many middlewares: 3 (max: 2)"),
+		},
+		{
+			name: "unlimited",
+			dynamicConfig: `
+http:
+  middlewares:
+    a: {stripPrefix: {prefixes: [/a]}}
+    b: {stripPrefix: {prefixes: [/b]}}
+    c: {stripPrefix: {prefixes: [/c]}}
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := experiment.MakeExperiment(test.dynamicConfig, "", experiment.RawHTTPRequest{
+				Method: http.MethodGet,
+				URL:    "http://example.com",
+			}, test.limits)
+			if test.wantErr == nil {
+				require.NoError(t, err)
+
+				return
+			}
+
+			require.EqualError(t, err, test.wantErr.Error())
+
+			var validationErr *experiment.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, "dynamicConfig", validationErr.Field)
+		})
+	}
+}
+
+func TestMakeExperiment_backendHosts(t *testing.T) {
 	t.Parallel()
 
+	limits := experiment.Limits{
+		RestrictBackendHosts: true,
+		AllowedBackendHosts:  []string{"api.internal"},
+	}
+
+	tests := []struct {
+		name          string
+		dynamicConfig string
+		limits        experiment.Limits
+		wantErr       error
+	}{
+		{
+			name: "playground backends",
+			dynamicConfig: `
+http:
+  services:
+    a: {loadBalancer: {servers: [{url: "http://10.10.10.10"}, {url: "http://10.10.10.15"}]}}
+  middlewares:
+    a: {forwardAuth: {address: "http://10.10.10.11/allow?header=X-Token"}}
+udp:
+  services:
+    a: {loadBalancer: {servers: [{address: "10.10.10.10:53"}]}}
+`,
+			limits: limits,
+		},
+		{
+			name: "playground backends written differently",
+			dynamicConfig: `
+http:
+  services:
+    a: {loadBalancer: {servers: [{url: "http://10.10.10.10/"}, {url: "HTTP://10.10.10.15:80"}]}}
+`,
+			limits: limits,
+		},
+		{
+			name: "allowed hosts",
+			dynamicConfig: `
+http:
+  services:
+    a: {loadBalancer: {servers: [{url: "http://API.internal:8080"}]}}
+  middlewares:
+    a: {forwardAuth: {address: "https://api.internal/auth"}}
+udp:
+  services:
+    a: {loadBalancer: {servers: [{address: "api.internal:53"}]}}
+`,
+			limits: limits,
+		},
+		{
+			name: "metadata server",
+			dynamicConfig: `
+http:
+  services:
+    metadata: {loadBalancer: {servers: [{url: "http://169.254.169.254"}]}}
+`,
+			limits:  limits,
+			wantErr: errors.New(`service "metadata": server "http://169.254.169.254" is outside the playground, use one of the playground backends`),
+		},
+		{
+			name: "playground backend with another port",
+			dynamicConfig: `
+http:
+  services:
+    a: {loadBalancer: {servers: [{url: "http://10.10.10.10:8080"}]}}
+`,
+			limits:  limits,
+			wantErr: errors.New(`service "a": server "http://10.10.10.10:8080" is outside the playground, use one of the playground backends`),
+		},
+		{
+			name: "forwardAuth address",
+			dynamicConfig: `
+http:
+  middlewares:
+    auth: {forwardAuth: {address: "http://169.254.169.254/latest"}}
+`,
+			limits:  limits,
+			wantErr: errors.New(`middleware "auth": address "http://169.254.169.254/latest" is outside the playground, use one of the playground backends`),
+		},
+		{
+			name: "UDP server",
+			dynamicConfig: `
+udp:
+  services:
+    dns: {loadBalancer: {servers: [{address: "169.254.169.254:53"}]}}
+`,
+			limits:  limits,
+			wantErr: errors.New(`service "dns": server "169.254.169.254:53" is outside the playground, use one of the playground backends`),
+		},
+		{
+			name: "unrestricted",
+			dynamicConfig: `
+http:
+  services:
+    metadata: {loadBalancer: {servers: [{url: "http://169.254.169.254"}]}}
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := experiment.MakeExperiment(test.dynamicConfig, "", experiment.RawHTTPRequest{
+				Method: http.MethodGet,
+				URL:    "http://example.com",
+			}, test.limits)
+			if test.wantErr == nil {
+				require.NoError(t, err)
+
+				return
+			}
+
+			require.EqualError(t, err, test.wantErr.Error())
+
+			var validationErr *experiment.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, "dynamicConfig", validationErr.Field)
+		})
+	}
+}
+
+func TestMakeExperiment_requestHosts(t *testing.T) {
+	t.Parallel()
+
+	limits := experiment.Limits{
+		RestrictRequestHosts: true,
+		AllowedRequestHosts:  []string{"192.168.1.10"},
+		Resolver: fakeResolver{
+			"example.com":          {netip.MustParseAddr("93.184.215.14")},
+			"metadata.example.com": {netip.MustParseAddr("93.184.215.14"), netip.MustParseAddr("169.254.169.254")},
+		},
+	}
+
 	tests := []struct {
-		name    string
-		method  string
 		url     string
-		headers string
-		body    string
+		limits  experiment.Limits
+		wantErr string
+	}{
+		{url: "http://example.com/", limits: limits},
+		{url: "http://10.10.10.10/", limits: limits},
+		{url: "http://192.168.1.10:8080/", limits: limits},
+		{url: "http://169.254.169.254/", limits: limits, wantErr: "request: url points at an internal host: 169.254.169.254"},
+		{url: "http://localhost/", limits: limits, wantErr: "request: url points at an internal host: localhost"},
+		{url: "http://api.localhost/", limits: limits, wantErr: "request: url points at an internal host: api.localhost"},
+		{url: "http://127.0.0.1:8080/", limits: limits, wantErr: "request: url points at an internal host: 127.0.0.1"},
+		{url: "http://[::ffff:10.0.0.1]/", limits: limits, wantErr: "request: url points at an internal host: ::ffff:10.0.0.1"},
+		{url: "http://[fe80::1]/", limits: limits, wantErr: "request: url points at an internal host: fe80::1"},
+		{url: "http://0.0.0.0/", limits: limits, wantErr: "request: url points at an internal host: 0.0.0.0"},
+		{url: "http://metadata.example.com/", limits: limits, wantErr: "request: url points at an internal host: metadata.example.com resolves to an internal IP"},
+		{url: "http://unknown.example.com/", limits: limits},
+		{url: "http://localhost/"},
+	}
 
-		wantErr error
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := experiment.MakeExperiment("http: {}", "", experiment.RawHTTPRequest{
+				Method: http.MethodGet,
+				URL:    test.url,
+			}, test.limits)
+			if test.wantErr == "" {
+				require.NoError(t, err)
+
+				return
+			}
+
+			require.EqualError(t, err, test.wantErr)
+
+			var validationErr *experiment.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, "url", validationErr.Field)
+		})
+	}
+}
+
+// fakeResolver resolves the host names it holds, and fails to resolve any other.
+type fakeResolver map[string][]netip.Addr
+
+func (r fakeResolver) LookupNetIP(_ context.Context, _, host string) ([]netip.Addr, error) {
+	addrs, ok := r[host]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+	}
+
+	return addrs, nil
+}
+
+func TestMakeExperiment_requestSchemes(t *testing.T) {
+	t.Parallel()
+
+	limits := experiment.Limits{AllowedRequestSchemes: []string{"https"}}
+
+	tests := []struct {
+		url     string
+		limits  experiment.Limits
+		wantErr string
+	}{
+		{url: "http://example.com/"},
+		{url: "https://example.com/"},
+		{url: "HTTPS://example.com/"},
+		{url: "file:///etc/passwd", wantErr: "request: url scheme must be one of: http, https"},
+		{url: "gopher://example.com:70/", wantErr: "request: url scheme must be one of: http, https"},
+		{url: "ftp://example.com/", wantErr: "request: url scheme must be one of: http, https"},
+		{url: "/foo", wantErr: "request: url scheme must be one of: http, https"},
+		{url: "https://example.com/", limits: limits},
+		{url: "http://example.com/", limits: limits, wantErr: "request: url scheme must be one of: https"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.url, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := experiment.MakeExperiment("http: {}", "", experiment.RawHTTPRequest{
+				Method: http.MethodGet,
+				URL:    test.url,
+			}, test.limits)
+			if test.wantErr == "" {
+				require.NoError(t, err)
+
+				return
+			}
+
+			require.EqualError(t, err, test.wantErr)
+
+			var validationErr *experiment.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, "url", validationErr.Field)
+		})
+	}
+}
+
+func TestMakeExperiment_schema(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		dynamicConfig string
+		wantErr       error
+	}{
+		{
+			name: "valid",
+			dynamicConfig: `
+http:
+  routers:
+    a: {rule: "Path(` + "`/a`" + `)", service: a, middlewares: [a]}
+  services:
+    a: {loadBalancer: {servers: [{url: "http://whoami"}], healthCheck: {path: /health, interval: 10s, timeout: 1}}}
+  middlewares:
+    a: {stripPrefix: {prefixes: [/a]}}
+tcp:
+`,
+		},
+		{
+			name: "unknown field",
+			dynamicConfig: `
+http:
+  routers:
+    a: {rul: "Path(` + "`/a`" + `)", service: a}
+`,
+			wantErr: errors.New("invalid dynamic configuration: http.routers.a: Additional property rul is not allowed"),
+		},
+		{
+			name: "invalid types",
+			dynamicConfig: `
+http:
+  routers:
+    a: {rule: true, service: 1}
+`,
+			wantErr: errors.New("invalid dynamic configuration: http.routers.a.rule: Invalid type. Expected: string, given: boolean, " +
+				"http.routers.a.service: Invalid type. Expected: string, given: integer"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := experiment.MakeExperiment(test.dynamicConfig, "", experiment.RawHTTPRequest{
+				Method: http.MethodGet,
+				URL:    "http://example.com",
+			}, experiment.Limits{})
+			if test.wantErr == nil {
+				require.NoError(t, err)
+
+				return
+			}
+
+			require.EqualError(t, err, test.wantErr.Error())
+
+			var validationErr *experiment.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, "dynamicConfig", validationErr.Field)
+		})
+	}
+}
+
+func TestMakeExperiment_trailingBlankLines(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		dynamicConfig string
+		want          string
+	}{
+		{
+			name:          "missing trailing newline",
+			dynamicConfig: "http: {}",
+			want:          "http: {}\n",
+		},
+		{
+			name:          "trailing blank lines",
+			dynamicConfig: "http: {}\n\n  \n\t\n",
+			want:          "http: {}\n",
+		},
+		{
+			name:          "CRLF line endings",
+			dynamicConfig: "http: {}\r\n\r\n",
+			want:          "http: {}\r\n",
+		},
+		{
+			name:          "indentation",
+			dynamicConfig: "\n  http:\n    routers:\n      api:\n        rule: Path(`/`)\n        service: api  \n\n",
+			want:          "\n  http:\n    routers:\n      api:\n        rule: Path(`/`)\n        service: api  \n",
+		},
+		{
+			name: "block scalar",
+			dynamicConfig: "http:\n  middlewares:\n    headers:\n      headers:\n        customResponseHeaders:\n" +
+				"          X-Note: |\n            line  \n",
+			want: "http:\n  middlewares:\n    headers:\n      headers:\n        customResponseHeaders:\n" +
+				"          X-Note: |\n            line  \n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := experiment.RawHTTPRequest{Method: http.MethodGet, URL: "http://example.com"}
+
+			got, err := experiment.MakeExperiment(test.dynamicConfig, "", req, experiment.Limits{})
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got.DynamicConfig)
+
+			// Normalizing twice leaves the dynamic configuration unchanged.
+			again, err := experiment.MakeExperiment(got.DynamicConfig, "", req, experiment.Limits{})
+			require.NoError(t, err)
+			assert.Equal(t, got.DynamicConfig, again.DynamicConfig)
+		})
+	}
+}
+
+func TestMakeExperiment_trailingBlankLines_sizeCheck(t *testing.T) {
+	t.Parallel()
+
+	// Only the trailing blank lines exceed the maximum size.
+	dynamicConfig := "http: {}\n" + strings.Repeat("\n", 10*1024)
+
+	got, err := experiment.MakeExperiment(dynamicConfig, "", experiment.RawHTTPRequest{
+		Method: http.MethodGet,
+		URL:    "http://example.com",
+	}, experiment.Limits{})
+	require.NoError(t, err)
+	assert.Equal(t, "http: {}\n", got.DynamicConfig)
+}
+
+func TestMakeHTTPRequest(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		proto      string
+		scheme     string
+		host       string
+		clientIP   string
+		trustedIPs string
+		headers    string
+		body       string
+		username   string
+		password   string
+		burst      string
+		delayMs    string
+
+		keepCookies string
+		concurrent  string
+
+		wantProto       string
+		wantHost        string
+		wantClientIP    string
+		wantTrustedIPs  []string
+		wantBurst       int
+		wantDelayMs     int
+		wantKeepCookies bool
+		wantConcurrent  bool
+		wantErr         error
 	}{
 		{
-			name:    "valid request",
+			name:    "valid request",
+			method:  http.MethodGet,
+			url:     "http://example.com",
+			headers: "Content-Type: application/json\nAccept: text/plain",
+			body:    "test body",
+		},
+		{
+			name:      "HTTP/1.0",
+			method:    http.MethodGet,
+			url:       "http://example.com",
+			proto:     "HTTP/1.0",
+			wantProto: "HTTP/1.0",
+		},
+		{
+			name:   "default protocol",
+			method: http.MethodGet,
+			url:    "http://example.com",
+			proto:  "HTTP/1.1",
+		},
+		{
+			name:    "protocol not allowed",
+			method:  http.MethodGet,
+			url:     "http://example.com",
+			proto:   "HTTP/2.0",
+			wantErr: errors.New("protocol HTTP/2.0 not allowed"),
+		},
+		{
+			name:   "https scheme",
+			method: http.MethodGet,
+			url:    "http://example.com",
+			scheme: "https",
+		},
+		{
+			name:    "scheme not allowed",
+			method:  http.MethodGet,
+			url:     "http://example.com",
+			scheme:  "ftp",
+			wantErr: errors.New("scheme must be http or https"),
+		},
+		{
+			name:      "burst",
+			method:    http.MethodGet,
+			url:       "http://example.com",
+			burst:     " 5 ",
+			wantBurst: 5,
+		},
+		{
+			name:   "single request burst",
+			method: http.MethodGet,
+			url:    "http://example.com",
+			burst:  "1",
+		},
+		{
+			name:    "burst too large",
+			method:  http.MethodGet,
+			url:     "http://example.com",
+			burst:   "21",
+			wantErr: errors.New("burst must be between 1 and 20"),
+		},
+		{
+			name:    "invalid burst",
+			method:  http.MethodGet,
+			url:     "http://example.com",
+			burst:   "many",
+			wantErr: errors.New("burst must be between 1 and 20"),
+		},
+		{
+			name:        "delay",
+			method:      http.MethodPost,
+			url:         "http://example.com",
+			body:        "slow body",
+			delayMs:     " 500 ",
+			wantDelayMs: 500,
+		},
+		{
+			name:    "delay too long",
+			method:  http.MethodGet,
+			url:     "http://example.com",
+			delayMs: "10001",
+			wantErr: errors.New("delay must be between 0 and 10000 milliseconds"),
+		},
+		{
+			name:    "invalid delay",
+			method:  http.MethodGet,
+			url:     "http://example.com",
+			delayMs: "-1",
+			wantErr: errors.New("delay must be between 0 and 10000 milliseconds"),
+		},
+		{
+			name:            "cookies kept across a burst",
+			method:          http.MethodGet,
+			url:             "http://example.com",
+			burst:           "3",
+			keepCookies:     "true",
+			wantBurst:       3,
+			wantKeepCookies: true,
+		},
+		{
+			name:        "cookies kept without burst",
+			method:      http.MethodGet,
+			url:         "http://example.com",
+			keepCookies: "true",
+			wantErr:     errors.New("cookies can only be kept across requests sent in burst"),
+		},
+		{
+			name:        "invalid cookies toggle",
+			method:      http.MethodGet,
+			url:         "http://example.com",
+			burst:       "3",
+			keepCookies: "maybe",
+			wantErr:     errors.New("cookies toggle must be a boolean"),
+		},
+		{
+			name:           "burst sent at once",
+			method:         http.MethodGet,
+			url:            "http://example.com",
+			burst:          "3",
+			concurrent:     "true",
+			wantBurst:      3,
+			wantConcurrent: true,
+		},
+		{
+			name:       "sent at once without burst",
+			method:     http.MethodGet,
+			url:        "http://example.com",
+			concurrent: "true",
+			wantErr:    errors.New("only requests sent in burst can be sent at once"),
+		},
+		{
+			name:        "cookies kept across a burst sent at once",
+			method:      http.MethodGet,
+			url:         "http://example.com",
+			burst:       "3",
+			keepCookies: "true",
+			concurrent:  "true",
+			wantErr:     errors.New("cookies can't be kept across requests sent at once"),
+		},
+		{
+			name:       "invalid concurrency toggle",
+			method:     http.MethodGet,
+			url:        "http://example.com",
+			burst:      "3",
+			concurrent: "maybe",
+			wantErr:    errors.New("concurrency toggle must be a boolean"),
+		},
+		{
+			name:     "host override",
+			method:   http.MethodGet,
+			url:      "http://localhost/foo",
+			host:     " api.example.com ",
+			wantHost: "api.example.com",
+		},
+		{
+			name:     "host override with port",
+			method:   http.MethodGet,
+			url:      "http://localhost/foo",
+			host:     "api.example.com:8080",
+			wantHost: "api.example.com:8080",
+		},
+		{
+			name:    "invalid host",
 			method:  http.MethodGet,
-			url:     "http://example.com",
-			headers: "Content-Type: application/json\nAccept: text/plain",
-			body:    "test body",
+			url:     "http://localhost/foo",
+			host:    "api.example.com/foo",
+			wantErr: errors.New("host is invalid"),
+		},
+		{
+			name:    "host too long",
+			method:  http.MethodGet,
+			url:     "http://localhost/foo",
+			host:    strings.Repeat("a", 256),
+			wantErr: errors.New("host is too large (max: 255 bytes)"),
+		},
+		{
+			name:         "client IPv4",
+			method:       http.MethodGet,
+			url:          "http://localhost/foo",
+			clientIP:     " 10.0.0.1 ",
+			wantClientIP: "10.0.0.1",
+		},
+		{
+			name:         "client IPv6",
+			method:       http.MethodGet,
+			url:          "http://localhost/foo",
+			clientIP:     "2001:DB8::1",
+			wantClientIP: "2001:db8::1",
+		},
+		{
+			name:     "invalid client IP",
+			method:   http.MethodGet,
+			url:      "http://localhost/foo",
+			clientIP: "10.0.0.1:80",
+			wantErr:  errors.New("client IP is invalid"),
+		},
+		{
+			name:           "trusted IPs",
+			method:         http.MethodGet,
+			url:            "http://localhost/foo",
+			trustedIPs:     " 10.0.0.1, 192.168.1.1/16\n2001:DB8::/32 ",
+			wantTrustedIPs: []string{"10.0.0.1", "192.168.0.0/16", "2001:db8::/32"},
+		},
+		{
+			name:       "invalid trusted IP",
+			method:     http.MethodGet,
+			url:        "http://localhost/foo",
+			trustedIPs: "10.0.0.1, example.com",
+			wantErr:    errors.New(`trusted IP "example.com" is not an IP or a CIDR`),
+		},
+		{
+			name:       "too many trusted IPs",
+			method:     http.MethodGet,
+			url:        "http://localhost/foo",
+			trustedIPs: "10.0.0.1,10.0.0.2,10.0.0.3,10.0.0.4,10.0.0.5,10.0.0.6,10.0.0.7,10.0.0.8,10.0.0.9,10.0.0.10,10.0.0.11",
+			wantErr:    errors.New("too many trusted IPs (max: 10)"),
+		},
+		{
+			name:     "basic auth",
+			method:   http.MethodGet,
+			url:      "http://localhost/foo",
+			username: "user",
+			password: "pass",
+		},
+		{
+			name:     "username with colon",
+			method:   http.MethodGet,
+			url:      "http://localhost/foo",
+			username: "us:er",
+			password: "pass",
+			wantErr:  errors.New("username must not contain a colon"),
+		},
+		{
+			name:     "password without username",
+			method:   http.MethodGet,
+			url:      "http://localhost/foo",
+			password: "pass",
+			wantErr:  errors.New("username is required when a password is set"),
+		},
+		{
+			name:     "password too long",
+			method:   http.MethodGet,
+			url:      "http://localhost/foo",
+			username: "user",
+			password: strings.Repeat("a", 101),
+			wantErr:  errors.New("password is too large (max: 100 bytes)"),
 		},
 		{
 			name:    "empty method",
@@ -101,6 +948,12 @@ func TestMakeHTTPRequest(t *testing.T) {
 			url:     "http://example.com",
 			wantErr: errors.New("method is required"),
 		},
+		{
+			name:    "CORS preflight",
+			method:  http.MethodOptions,
+			url:     "http://example.com",
+			headers: "Origin: https://example.com\nAccess-Control-Request-Method: POST",
+		},
 		{
 			name:    "invalid method",
 			method:  "INVALID",
@@ -117,14 +970,14 @@ func TestMakeHTTPRequest(t *testing.T) {
 			name:    "url too long",
 			method:  http.MethodGet,
 			url:     "http://" + string(make([]byte, 1024)),
-			wantErr: errors.New("url is too long (max: 1024)"),
+			wantErr: errors.New("url is too large (max: 1024 bytes)"),
 		},
 		{
 			name:    "body too long",
 			method:  http.MethodGet,
 			url:     "http://example.com",
 			body:    string(make([]byte, 1025)),
-			wantErr: errors.New("body is too long (max: 1024)"),
+			wantErr: errors.New("body is too large (max: 1024 bytes)"),
 		},
 		{
 			name:    "invalid url",
@@ -139,6 +992,12 @@ func TestMakeHTTPRequest(t *testing.T) {
 			headers: "Invalid-Header",
 			wantErr: errors.New(`invalid header format, want "name: value", got: "Invalid-Header"`),
 		},
+		{
+			name:    "header value with colons",
+			method:  http.MethodGet,
+			url:     "http://example.com",
+			headers: "Origin: https://example.com:8443",
+		},
 		{
 			name:    "empty header name",
 			method:  http.MethodGet,
@@ -158,14 +1017,14 @@ func TestMakeHTTPRequest(t *testing.T) {
 			method:  http.MethodGet,
 			url:     "http://example.com",
 			headers: string(make([]byte, 101)) + ": value",
-			wantErr: errors.New("header name is too long for \"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00...\" (max 100)"),
+			wantErr: errors.New("header name \"\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00...\" is too large (max: 100 bytes)"),
 		},
 		{
 			name:    "header value too long",
 			method:  http.MethodGet,
 			url:     "http://example.com",
 			headers: "Name: " + string(make([]byte, 201)),
-			wantErr: errors.New(`header value is too long for "Name" (max 200)`),
+			wantErr: errors.New(`header value of "Name" is too large (max: 200 bytes)`),
 		},
 		{
 			name:   "too many headers",
@@ -191,9 +1050,28 @@ X-Header-11: value`,
 		t.Run(test.name, func(t *testing.T) {
 			t.Parallel()
 
-			req, err := experiment.MakeHTTPRequest(test.method, test.url, test.headers, test.body)
+			req, err := experiment.MakeHTTPRequest(experiment.RawHTTPRequest{
+				Method:     test.method,
+				URL:        test.url,
+				Proto:      test.proto,
+				Scheme:     test.scheme,
+				Host:       test.host,
+				ClientIP:   test.clientIP,
+				TrustedIPs: test.trustedIPs,
+				Headers:    test.headers,
+				Body:       test.body,
+				Username:   test.username,
+				Password:   test.password,
+				Burst:      test.burst,
+				DelayMs:    test.delayMs,
+
+				KeepCookies: test.keepCookies,
+				Concurrent:  test.concurrent,
+			})
 			if test.wantErr != nil {
 				require.EqualError(t, err, test.wantErr.Error())
+				// Exceeded size limits are reported as such.
+				assert.Equal(t, strings.Contains(test.wantErr.Error(), "too large"), errors.Is(err, experiment.ErrTooLarge))
 			} else {
 				require.NoError(t, err)
 			}
@@ -201,12 +1079,416 @@ X-Header-11: value`,
 			if err == nil {
 				assert.Equal(t, test.method, req.Method)
 				assert.Equal(t, test.url, req.URL)
+				assert.Equal(t, test.wantProto, req.Proto)
+				assert.Equal(t, test.scheme, req.Scheme)
+				assert.Equal(t, test.wantHost, req.Host)
+				assert.Equal(t, test.wantClientIP, req.ClientIP)
+				assert.Equal(t, test.wantTrustedIPs, req.TrustedIPs)
 				assert.Equal(t, test.body, req.Body)
+				assert.Equal(t, test.username, req.Username)
+				assert.Equal(t, test.password, req.Password)
+				assert.Equal(t, test.wantBurst, req.Burst)
+				assert.Equal(t, test.wantDelayMs, req.DelayMs)
+				assert.Equal(t, test.wantKeepCookies, req.KeepCookies)
+				assert.Equal(t, test.wantConcurrent, req.Concurrent)
+			}
+		})
+	}
+}
+
+func TestMakeHTTPRequest_headers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		headers     string
+		wantHeaders http.Header
+		wantErr     error
+	}{
+		{
+			name:        "URL value",
+			headers:     "Location: http://x/y",
+			wantHeaders: http.Header{"Location": {"http://x/y"}},
+		},
+		{
+			name:        "value with colons",
+			headers:     "Date: Tue, 15 Nov 1994 08:12:31 GMT\nReferer: https://example.com:8443/a:b",
+			wantHeaders: http.Header{"Date": {"Tue, 15 Nov 1994 08:12:31 GMT"}, "Referer": {"https://example.com:8443/a:b"}},
+		},
+		{
+			name:        "host with port",
+			headers:     "X-Forwarded-Host: example.com:8080",
+			wantHeaders: http.Header{"X-Forwarded-Host": {"example.com:8080"}},
+		},
+		{
+			name:        "credentials with colons",
+			headers:     "Authorization: Bearer a:b",
+			wantHeaders: http.Header{"Authorization": {"Bearer a:b"}},
+		},
+		{
+			name:    "invalid value with colons",
+			headers: "X-Token: a:\x00:b",
+			wantErr: errors.New(`invalid header value for "X-Token"`),
+		},
+		{
+			name:    "continuation lines",
+			headers: "Content-Security-Policy: default-src 'self';\n  img-src *;\r\n\tscript-src 'none'\r\nAccept: text/plain",
+			wantHeaders: http.Header{
+				"Content-Security-Policy": {"default-src 'self'; img-src *; script-src 'none'"},
+				"Accept":                  {"text/plain"},
+			},
+		},
+		{
+			name:    "indented headers",
+			headers: "  Accept: a\n  X-Foo: b",
+			wantHeaders: http.Header{
+				"Accept": {"a"},
+				"X-Foo":  {"b"},
+			},
+		},
+		{
+			name:        "indented first line",
+			headers:     "  Accept: text/plain",
+			wantHeaders: http.Header{"Accept": {"text/plain"}},
+		},
+		{
+			name:    "line without colon after a header",
+			headers: "Accept: text/plain\nIndented-Without-Space",
+			wantErr: errors.New(`invalid header format, want "name: value", got: "Indented-Without-Space"`),
+		},
+		{
+			name:    "continued value too long",
+			headers: "Name: " + strings.Repeat("a", 150) + "\n " + strings.Repeat("a", 50),
+			wantErr: errors.New(`header value of "Name" is too large (max: 200 bytes)`),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			req, err := experiment.MakeHTTPRequest(experiment.RawHTTPRequest{
+				Method:  http.MethodGet,
+				URL:     "http://example.com",
+				Headers: test.headers,
+			})
+			if test.wantErr != nil {
+				require.EqualError(t, err, test.wantErr.Error())
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.wantHeaders, req.Headers)
+		})
+	}
+}
+
+func TestMakeHTTPRequest_addedHeaders(t *testing.T) {
+	t.Parallel()
+
+	// userHeaders returns the given number of distinct user headers, followed by the given extra lines.
+	userHeaders := func(count int, extra ...string) string {
+		lines := extra
+		for i := range count - len(extra) {
+			lines = append(lines, fmt.Sprintf("X-Header-%d: value", i+1))
+		}
+
+		return strings.Join(lines, "\n")
+	}
+
+	tests := []struct {
+		name             string
+		rawReq           experiment.RawHTTPRequest
+		wantAddedHeaders []string
+		wantErr          error
+	}{
+		{
+			name:   "user headers only",
+			rawReq: experiment.RawHTTPRequest{Headers: userHeaders(10)},
+		},
+		{
+			name: "added headers within the limit",
+			rawReq: experiment.RawHTTPRequest{
+				Headers:  userHeaders(10),
+				Scheme:   "https",
+				ClientIP: "10.0.0.1",
+			},
+			wantAddedHeaders: []string{"X-Forwarded-Proto", "X-Forwarded-For"},
+		},
+		{
+			name: "added headers over the limit",
+			rawReq: experiment.RawHTTPRequest{
+				Headers:  userHeaders(10),
+				Scheme:   "https",
+				ClientIP: "10.0.0.1",
+				Username: "user",
+			},
+			wantErr: errors.New("too many headers once those added by the playground " +
+				"(X-Forwarded-Proto, X-Forwarded-For, Authorization) are counted: 13 (max 12)"),
+		},
+		{
+			name: "detected content type",
+			rawReq: experiment.RawHTTPRequest{
+				Method:   http.MethodPost,
+				Headers:  userHeaders(10),
+				Body:     `{"foo": "bar"}`,
+				Scheme:   "https",
+				ClientIP: "10.0.0.1",
+			},
+			wantErr: errors.New("too many headers once those added by the playground " +
+				"(X-Forwarded-Proto, X-Forwarded-For) are counted: 13 (max 12)"),
+		},
+		{
+			name: "cookies kept across a burst",
+			rawReq: experiment.RawHTTPRequest{
+				Headers:     userHeaders(10),
+				Username:    "user",
+				Burst:       "2",
+				KeepCookies: "true",
+			},
+			wantAddedHeaders: []string{"Authorization", "Cookie"},
+		},
+		{
+			name: "added headers given by the user",
+			rawReq: experiment.RawHTTPRequest{
+				Headers:  userHeaders(10, "X-Forwarded-Proto: https", "X-Forwarded-For: 10.0.0.1", "Authorization: Basic dXNlcg=="),
+				Scheme:   "https",
+				ClientIP: "10.0.0.1",
+				Username: "user",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			rawReq := test.rawReq
+			if rawReq.Method == "" {
+				rawReq.Method = http.MethodGet
+			}
+			rawReq.URL = "http://example.com"
+
+			req, err := experiment.MakeHTTPRequest(rawReq)
+			if test.wantErr != nil {
+				require.EqualError(t, err, test.wantErr.Error())
+
+				var validationErr *experiment.ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.Equal(t, "headers", validationErr.Field)
+
+				return
 			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.wantAddedHeaders, req.AddedHeaders())
+		})
+	}
+}
+
+func TestMakeHTTPRequest_contentTypeDetection(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name                   string
+		headers                string
+		body                   string
+		noContentTypeDetection string
+
+		wantContentType string
+		wantErr         error
+	}{
+		{
+			name:            "JSON object",
+			body:            `{"a": 1}`,
+			wantContentType: "application/json",
+		},
+		{
+			name:            "JSON array",
+			body:            "\n [1, 2]\n",
+			wantContentType: "application/json",
+		},
+		{
+			name: "JSON scalar",
+			body: "true",
+		},
+		{
+			name: "invalid JSON",
+			body: `{"a": `,
+		},
+		{
+			name: "no body",
+		},
+		{
+			name:            "explicit content type",
+			headers:         "content-type: text/plain",
+			body:            `{"a": 1}`,
+			wantContentType: "text/plain",
+		},
+		{
+			name:                   "detection disabled",
+			body:                   `{"a": 1}`,
+			noContentTypeDetection: "true",
+		},
+		{
+			name:                   "invalid detection toggle",
+			body:                   `{"a": 1}`,
+			noContentTypeDetection: "maybe",
+			wantErr:                errors.New("content type detection toggle must be a boolean"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			req, err := experiment.MakeHTTPRequest(experiment.RawHTTPRequest{
+				Method:                 http.MethodPost,
+				URL:                    "http://example.com",
+				Headers:                test.headers,
+				Body:                   test.body,
+				NoContentTypeDetection: test.noContentTypeDetection,
+			})
+			if test.wantErr != nil {
+				require.EqualError(t, err, test.wantErr.Error())
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.wantContentType, req.Headers.Get("Content-Type"))
+			assert.Equal(t, test.noContentTypeDetection == "true", req.NoContentTypeDetection)
+		})
+	}
+}
+
+func TestMakeLabel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		label     string
+		wantLabel string
+		wantErr   error
+	}{
+		{
+			name:      "empty",
+			label:     "",
+			wantLabel: "",
+		},
+		{
+			name:      "trimmed",
+			label:     "  retry-test ",
+			wantLabel: "retry-test",
+		},
+		{
+			name:      "letters, digits and punctuation",
+			label:     "Test_v3.4 élan-2",
+			wantLabel: "Test_v3.4 élan-2",
+		},
+		{
+			name:      "max length",
+			label:     strings.Repeat("é", 50),
+			wantLabel: strings.Repeat("é", 50),
+		},
+		{
+			name:    "too long",
+			label:   strings.Repeat("a", 51),
+			wantErr: errors.New("label is too long (max: 50)"),
+		},
+		{
+			name:    "invalid characters",
+			label:   "<script>",
+			wantErr: errors.New("label must only contain letters, digits, spaces, '-', '_' and '.'"),
+		},
+		{
+			name:    "control characters",
+			label:   "foo\nbar",
+			wantErr: errors.New("label must only contain letters, digits, spaces, '-', '_' and '.'"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			label, err := experiment.MakeLabel(test.label)
+			if test.wantErr != nil {
+				require.EqualError(t, err, test.wantErr.Error())
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.wantLabel, label)
+		})
+	}
+}
+
+func TestMakeStaticConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name             string
+		staticConfig     string
+		wantStaticConfig string
+		wantErr          string
+	}{
+		{
+			name:             "empty",
+			staticConfig:     "",
+			wantStaticConfig: "",
+		},
+		{
+			name:             "blank",
+			staticConfig:     " \n\n",
+			wantStaticConfig: "",
+		},
+		{
+			name:             "entrypoint read timeout",
+			staticConfig:     "entryPoints:\n  web:\n    transport:\n      respondingTimeouts:\n        readTimeout: 1s\n\n",
+			wantStaticConfig: "entryPoints:\n  web:\n    transport:\n      respondingTimeouts:\n        readTimeout: 1s\n",
+		},
+		{
+			name:         "unsupported option",
+			staticConfig: "providers:\n  file:\n    directory: /etc\n",
+			wantErr:      `static configuration option "providers" isn't supported`,
+		},
+		{
+			name:         "invalid YAML",
+			staticConfig: "entryPoints: [",
+			wantErr:      "parsing static configuration",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			staticConfig, err := experiment.MakeStaticConfig(test.staticConfig)
+			if test.wantErr != "" {
+				var validationErr *experiment.ValidationError
+				require.ErrorAs(t, err, &validationErr)
+				assert.Equal(t, "staticConfig", validationErr.Field)
+				assert.Contains(t, err.Error(), test.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.wantStaticConfig, staticConfig)
 		})
 	}
 }
 
+func TestMakeStaticConfig_tooLarge(t *testing.T) {
+	t.Parallel()
+
+	_, err := experiment.MakeStaticConfig("# " + strings.Repeat("a", 2*1024))
+	require.ErrorIs(t, err, experiment.ErrTooLarge)
+}
+
 func TestResult_ValueAndScan(t *testing.T) {
 	t.Parallel()
 
@@ -216,6 +1498,7 @@ func TestResult_ValueAndScan(t *testing.T) {
 			StatusCode: 200,
 			Headers:    http.Header{"Content-Type": []string{"application/json"}},
 			Body:       []byte("test body"),
+			Trailers:   http.Header{"Grpc-Status": []string{"0"}},
 		},
 		Logs: []traefik.Log{{Message: "test log"}},
 	}
@@ -253,3 +1536,63 @@ func TestHTTPRequest_ValueAndScan(t *testing.T) {
 
 	assert.Equal(t, original, scanned)
 }
+
+func TestHTTPRequest_Value_encodesPassword(t *testing.T) {
+	t.Parallel()
+
+	req := experiment.HTTPRequest{
+		Method:   http.MethodGet,
+		URL:      "http://example.com",
+		Username: "user",
+		Password: "secret",
+	}
+
+	value, err := req.Value()
+	require.NoError(t, err)
+
+	data, ok := value.([]byte)
+	require.True(t, ok)
+
+	assert.NotContains(t, string(data), "secret")
+	assert.Contains(t, string(data), `"authorization":"Basic dXNlcjpzZWNyZXQ="`)
+
+	scanned := &experiment.HTTPRequest{}
+	require.NoError(t, scanned.Scan(value))
+
+	assert.Equal(t, req, *scanned)
+
+	require.Error(t, scanned.Scan([]byte(`{"username":"other","authorization":"Basic dXNlcjpzZWNyZXQ="}`)))
+}
+
+func TestHTTPResponse_MarshalJSON_storesBodyOnce(t *testing.T) {
+	t.Parallel()
+
+	var gzipped bytes.Buffer
+	gzipWriter := gzip.NewWriter(&gzipped)
+	_, err := gzipWriter.Write([]byte(`{"foo":"bar"}`))
+	require.NoError(t, err)
+	require.NoError(t, gzipWriter.Close())
+
+	res := experiment.HTTPResponse{
+		StatusCode:      http.StatusOK,
+		Headers:         http.Header{"Content-Encoding": {"gzip"}},
+		Body:            []byte(`{"foo":"bar"}`),
+		ContentEncoding: "gzip",
+		RawBody:         gzipped.Bytes(),
+	}
+
+	data, err := json.Marshal(res)
+	require.NoError(t, err)
+
+	var stored map[string]any
+	require.NoError(t, json.Unmarshal(data, &stored))
+
+	assert.NotContains(t, stored, "rawBody")
+
+	var got experiment.HTTPResponse
+	require.NoError(t, json.Unmarshal(data, &got))
+
+	assert.Equal(t, res, got)
+
+	require.Error(t, json.Unmarshal([]byte(`{"contentEncoding":"gzip","body":"bm90IGd6aXA="}`), &got))
+}
@@ -0,0 +1,33 @@
+package experiment
+
+// HeaderPreset is a set of request headers commonly sent together, offered in the request form.
+type HeaderPreset struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Headers are the headers of the preset, in the format of RawHTTPRequest.Headers.
+	Headers string `json:"headers"`
+}
+
+// HeaderPresets returns the header presets. Presets are only applied to the request form, the resulting
+// headers are validated like any others when the experiment is made.
+func HeaderPresets() []HeaderPreset {
+	return []HeaderPreset{
+		{
+			Name:        "JSON",
+			Description: "Send and accept a JSON body",
+			Headers:     "Content-Type: application/json\nAccept: application/json",
+		},
+		{
+			Name:        "Form",
+			Description: "Send a URL-encoded form body",
+			Headers:     "Content-Type: application/x-www-form-urlencoded",
+		},
+		{
+			Name:        "CORS preflight",
+			Description: "Ask whether a cross-origin POST request is allowed, to be sent with the OPTIONS method",
+			Headers: "Origin: https://example.com\n" +
+				"Access-Control-Request-Method: POST\n" +
+				"Access-Control-Request-Headers: Content-Type",
+		},
+	}
+}
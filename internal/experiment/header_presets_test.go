@@ -0,0 +1,27 @@
+package experiment
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderPresets(t *testing.T) {
+	t.Parallel()
+
+	presets := HeaderPresets()
+	require.NotEmpty(t, presets)
+
+	names := make(map[string]struct{})
+	for _, preset := range presets {
+		assert.NotContains(t, names, preset.Name, "duplicated preset")
+		names[preset.Name] = struct{}{}
+
+		assert.NotEmpty(t, preset.Description, preset.Name)
+
+		headers, err := parseHeaders(preset.Headers)
+		require.NoError(t, err, preset.Name)
+		assert.NotEmpty(t, headers, preset.Name)
+	}
+}
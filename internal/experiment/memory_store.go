@@ -0,0 +1,165 @@
+package experiment
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/lithammer/shortuuid/v4"
+)
+
+// MemoryStore is an in-memory Storer holding a bounded number of Experiments, lost when the process exits.
+// When full, the least recently saved or retrieved Experiment is evicted.
+type MemoryStore struct {
+	maxEntries int
+	shortCodes bool
+
+	// newShortCode generates the short codes of the saved Experiments.
+	newShortCode func() (string, error)
+
+	mu sync.Mutex
+	// entries indexes the Experiments by public ID and by short code.
+	entries map[string]*list.Element
+	byHash  map[string]*list.Element
+	order   *list.List
+}
+
+type memoryStoreEntry struct {
+	publicID  string
+	shortCode string
+	hash      string
+	// bundle is the JSON encoded Experiment and Result, so that they are stored the way the Store persists them.
+	bundle []byte
+	label  string
+}
+
+// NewMemoryStore creates a new MemoryStore holding at most maxEntries Experiments, which must be positive.
+// ShortCodes makes Save return short codes rather than public IDs, see StoreConfig.
+func NewMemoryStore(maxEntries int, shortCodes bool) *MemoryStore {
+	return &MemoryStore{
+		maxEntries:   maxEntries,
+		shortCodes:   shortCodes,
+		newShortCode: newShortCode,
+		entries:      make(map[string]*list.Element),
+		byHash:       make(map[string]*list.Element),
+		order:        list.New(),
+	}
+}
+
+// Save saves the given Experiment, a unique ID is returned. Like with the Store, the ID is the public ID of the
+// Experiment, or its short code when the MemoryStore hands out short codes. Saving the same Experiment twice returns
+// the same ID.
+func (s *MemoryStore) Save(_ context.Context, exp Experiment, res Result, _, _ string) (string, error) {
+	if err := validateLabel(exp.Label); err != nil {
+		return "", err
+	}
+
+	hash, err := hashExperiment(exp, res)
+	if err != nil {
+		return "", err
+	}
+
+	bundle, err := json.Marshal(storedExperiment{Experiment: exp, Result: res})
+	if err != nil {
+		return "", fmt.Errorf("marshaling experiment: %w", err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if elem, ok := s.byHash[hash]; ok {
+		s.order.MoveToFront(elem)
+
+		return s.id(memoryEntryOf(elem)), nil
+	}
+
+	entry := &memoryStoreEntry{
+		publicID: shortuuid.New(),
+		hash:     hash,
+		bundle:   bundle,
+		label:    exp.Label,
+	}
+
+	// Generate new short codes until one isn't used by another Experiment.
+	for entry.shortCode == "" || s.entries[entry.shortCode] != nil {
+		if entry.shortCode, err = s.newShortCode(); err != nil {
+			return "", err
+		}
+	}
+
+	elem := s.order.PushFront(entry)
+	s.entries[entry.publicID] = elem
+	s.entries[entry.shortCode] = elem
+	s.byHash[hash] = elem
+
+	for s.order.Len() > s.maxEntries {
+		s.remove(s.order.Back())
+	}
+
+	return s.id(entry), nil
+}
+
+// id returns the ID handed out for the given entry.
+func (s *MemoryStore) id(entry *memoryStoreEntry) string {
+	if s.shortCodes {
+		return entry.shortCode
+	}
+
+	return entry.publicID
+}
+
+// Get retrieves an Experiment from its short code or its public ID.
+func (s *MemoryStore) Get(_ context.Context, id string) (Experiment, Result, error) {
+	s.mu.Lock()
+
+	elem, ok := s.entries[id]
+	if !ok {
+		s.mu.Unlock()
+
+		return Experiment{}, Result{}, ErrNotFound
+	}
+
+	s.order.MoveToFront(elem)
+	entry := memoryEntryOf(elem)
+
+	s.mu.Unlock()
+
+	var b storedExperiment
+	if err := json.Unmarshal(entry.bundle, &b); err != nil {
+		return Experiment{}, Result{}, fmt.Errorf("unmarshaling experiment: %w", err)
+	}
+
+	b.Experiment.Label = entry.label
+	b.Experiment.ID = entry.publicID
+
+	return b.Experiment, b.Result, nil
+}
+
+// Len returns the number of Experiments held by the store.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.order.Len()
+}
+
+func (s *MemoryStore) remove(elem *list.Element) {
+	entry := memoryEntryOf(elem)
+
+	s.order.Remove(elem)
+	delete(s.entries, entry.publicID)
+	delete(s.entries, entry.shortCode)
+	delete(s.byHash, entry.hash)
+}
+
+// storedExperiment is an Experiment along with its Result.
+type storedExperiment struct {
+	Experiment Experiment `json:"experiment"`
+	Result     Result     `json:"result"`
+}
+
+func memoryEntryOf(elem *list.Element) *memoryStoreEntry {
+	return elem.Value.(*memoryStoreEntry) //nolint:forcetypeassert // Only entries are stored in the list.
+}
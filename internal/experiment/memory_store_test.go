@@ -0,0 +1,139 @@
+package experiment_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jspdown/traefik-playground/internal/experiment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStore(t *testing.T) {
+	t.Parallel()
+
+	s := experiment.NewMemoryStore(10, false)
+	ctx := context.Background()
+
+	exp := experiment.Experiment{
+		DynamicConfig: "dynamicConfig",
+		Request: experiment.HTTPRequest{
+			Method:   http.MethodPost,
+			URL:      "https://example.com/foo",
+			Headers:  http.Header{"Content-Type": []string{"application/json"}},
+			Body:     "body",
+			Username: "user",
+			Password: "secret",
+		},
+		Label: "label",
+	}
+	res := experiment.Result{
+		Response: experiment.HTTPResponse{StatusCode: http.StatusOK, Body: []byte("value")},
+	}
+
+	publicID, err := s.Save(ctx, exp, res, "127.0.0.1", "")
+	require.NoError(t, err)
+	assert.NotEmpty(t, publicID)
+
+	// Saving the same experiment doesn't store a new entry.
+	samePublicID, err := s.Save(ctx, exp, res, "127.0.0.2", "")
+	require.NoError(t, err)
+	assert.Equal(t, publicID, samePublicID)
+	assert.Equal(t, 1, s.Len())
+
+	gotExp, gotRes, err := s.Get(ctx, publicID)
+	require.NoError(t, err)
+
+	// The experiment is retrieved along with its public ID.
+	exp.ID = publicID
+
+	// Like with the Store, the password is kept through the Authorization header.
+	assert.Equal(t, exp, gotExp)
+	assert.Equal(t, res, gotRes)
+
+	_, _, err = s.Get(ctx, "unknown")
+	require.ErrorIs(t, err, experiment.ErrNotFound)
+
+	exp.Label = strings.Repeat("a", 51)
+	_, err = s.Save(ctx, exp, res, "127.0.0.1", "")
+	require.EqualError(t, err, "label is too long (max: 50)")
+}
+
+func TestMemoryStore_eviction(t *testing.T) {
+	t.Parallel()
+
+	s := experiment.NewMemoryStore(2, false)
+	ctx := context.Background()
+
+	save := func(i int) string {
+		t.Helper()
+
+		publicID, err := s.Save(ctx, experiment.Experiment{
+			DynamicConfig: "dynamicConfig",
+			Request: experiment.HTTPRequest{
+				Method: http.MethodGet,
+				URL:    "https://example.com/" + strconv.Itoa(i),
+			},
+		}, experiment.Result{}, "127.0.0.1", "")
+		require.NoError(t, err)
+
+		return publicID
+	}
+
+	first := save(1)
+	second := save(2)
+
+	// Retrieve the first experiment so that the second one becomes the least recently used.
+	_, _, err := s.Get(ctx, first)
+	require.NoError(t, err)
+
+	third := save(3)
+	assert.Equal(t, 2, s.Len())
+
+	_, _, err = s.Get(ctx, second)
+	require.ErrorIs(t, err, experiment.ErrNotFound)
+
+	for _, publicID := range []string{first, third} {
+		_, _, err = s.Get(ctx, publicID)
+		require.NoError(t, err)
+	}
+
+	// An evicted experiment is stored again under a new public ID.
+	assert.NotEqual(t, second, save(2))
+	assert.Equal(t, 2, s.Len())
+}
+
+func TestMemoryStore_shortCode(t *testing.T) {
+	t.Parallel()
+
+	s := experiment.NewMemoryStore(1000, true)
+	ctx := context.Background()
+
+	ids := make(map[string]struct{})
+	for i := range 500 {
+		id, err := s.Save(ctx, experiment.Experiment{
+			DynamicConfig: "dynamicConfig",
+			Request: experiment.HTTPRequest{
+				Method: http.MethodGet,
+				URL:    "https://example.com/" + strconv.Itoa(i),
+			},
+		}, experiment.Result{}, "127.0.0.1", "")
+		require.NoError(t, err)
+		assert.Regexp(t, `^[0-9a-hjkmnp-tv-z]{8}$`, id)
+		assert.NotContains(t, ids, id)
+
+		ids[id] = struct{}{}
+
+		gotExp, _, err := s.Get(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/"+strconv.Itoa(i), gotExp.Request.URL)
+
+		// The experiment can also be retrieved with its public ID.
+		gotExp, _, err = s.Get(ctx, gotExp.ID)
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com/"+strconv.Itoa(i), gotExp.Request.URL)
+	}
+}
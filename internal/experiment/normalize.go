@@ -0,0 +1,168 @@
+package experiment
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/traefik/traefik/v3/pkg/config/dynamic"
+	"gopkg.in/yaml.v3"
+)
+
+// NormalizeDynamicConfig rewrites the given dynamic configuration in a canonical and minimal YAML form: keys are
+// sorted, empty values and load-balancer options set to their default value are removed, and comments are dropped.
+// Normalizing a configuration twice yields the same configuration.
+// A ValidationError is returned if the configuration is invalid, or if it holds fields Traefik doesn't know, as
+// the normalization would silently drop them.
+func NormalizeDynamicConfig(dynamicConfig string) (string, error) {
+	if err := ValidateDynamicConfig(dynamicConfig, Limits{}); err != nil {
+		return "", err
+	}
+
+	var config dynamic.Configuration
+
+	decoder := yaml.NewDecoder(strings.NewReader(dynamicConfig))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			return "", newValidationError("dynamicConfig", "normalizing would drop unknown fields: %s",
+				strings.Join(typeErr.Errors, ", "))
+		}
+
+		return "", newValidationError("dynamicConfig", "invalid dynamic configuration: %s", err)
+	}
+
+	removeDefaults(&config)
+
+	if isEmptyConfig(config) {
+		return "", nil
+	}
+
+	var root yaml.Node
+	if err := root.Encode(config); err != nil {
+		return "", fmt.Errorf("marshaling dynamic configuration: %w", err)
+	}
+
+	// Fields without the omitempty option are marshaled even if unset. Pruning compares against the marshaled
+	// configuration rather than the given one, which may hold empty lists or maps omitted once marshaled.
+	var marshaled dynamic.Configuration
+	if err := root.Decode(&marshaled); err != nil {
+		return "", fmt.Errorf("unmarshaling normalized dynamic configuration: %w", err)
+	}
+
+	if err := pruneZeroValues(&root, &root, marshaled); err != nil {
+		return "", fmt.Errorf("pruning dynamic configuration: %w", err)
+	}
+
+	var buf bytes.Buffer
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(&root); err != nil {
+		return "", fmt.Errorf("marshaling dynamic configuration: %w", err)
+	}
+
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("marshaling dynamic configuration: %w", err)
+	}
+
+	return buf.String(), nil
+}
+
+// removeDefaults unsets the load-balancer options set to the default value Traefik uses when they are left unset.
+func removeDefaults(config *dynamic.Configuration) {
+	if config.HTTP == nil {
+		return
+	}
+
+	var defaultResponseForwarding dynamic.ResponseForwarding
+	defaultResponseForwarding.SetDefaults()
+
+	for _, s := range config.HTTP.Services {
+		if s == nil || s.LoadBalancer == nil {
+			continue
+		}
+
+		if s.LoadBalancer.PassHostHeader != nil && *s.LoadBalancer.PassHostHeader == dynamic.DefaultPassHostHeader {
+			s.LoadBalancer.PassHostHeader = nil
+		}
+
+		if s.LoadBalancer.Strategy == dynamic.BalancerStrategyWRR {
+			s.LoadBalancer.Strategy = ""
+		}
+
+		if s.LoadBalancer.ResponseForwarding != nil &&
+			reflect.DeepEqual(*s.LoadBalancer.ResponseForwarding, defaultResponseForwarding) {
+			s.LoadBalancer.ResponseForwarding = nil
+		}
+	}
+}
+
+// pruneZeroValues removes from the given node the fields holding a null, false, zero or empty scalar, as long as
+// the root document still decodes to the given configuration without them.
+func pruneZeroValues(root, node *yaml.Node, config dynamic.Configuration) error {
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i < len(node.Content); i += 2 {
+			if !isZeroScalar(node.Content[i+1]) {
+				continue
+			}
+
+			content := node.Content
+			node.Content = slices.Delete(slices.Clone(content), i, i+2)
+
+			var pruned dynamic.Configuration
+			if err := root.Decode(&pruned); err != nil {
+				return err
+			}
+
+			if !reflect.DeepEqual(pruned, config) {
+				node.Content = content
+
+				continue
+			}
+
+			i -= 2
+		}
+	}
+
+	for _, child := range node.Content {
+		if err := pruneZeroValues(root, child, config); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isZeroScalar reports whether the given node is a null, false, zero or empty scalar.
+func isZeroScalar(node *yaml.Node) bool {
+	if node.Kind != yaml.ScalarNode {
+		return false
+	}
+
+	switch node.ShortTag() {
+	case "!!null":
+		return true
+	case "!!bool":
+		return node.Value == "false"
+	case "!!int", "!!float":
+		return node.Value == "0"
+	case "!!str":
+		return node.Value == ""
+	default:
+		return false
+	}
+}
+
+// isEmptyConfig reports whether the given configuration declares nothing, in which case it normalizes to
+// an empty document rather than "{}".
+func isEmptyConfig(config dynamic.Configuration) bool {
+	return reflect.DeepEqual(config, dynamic.Configuration{})
+}
@@ -0,0 +1,168 @@
+package experiment_test
+
+import (
+	"testing"
+
+	"github.com/jspdown/traefik-playground/internal/experiment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeDynamicConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc          string
+		dynamicConfig string
+		want          string
+	}{
+		{
+			desc:          "empty",
+			dynamicConfig: "# Nothing yet\n",
+			want:          "",
+		},
+		{
+			desc: "sorts keys and removes comments",
+			dynamicConfig: `
+http:
+  services:
+    whoami:
+      loadBalancer:
+        servers: [{url: "http://10.10.10.10"}]
+  # The API router.
+  routers:
+    web: {rule: "PathPrefix(` + "`/`" + `)", service: whoami, priority: 1}
+    api: {rule: "PathPrefix(` + "`/api`" + `)", service: whoami, middlewares: []}
+`,
+			want: `http:
+  routers:
+    api:
+      service: whoami
+      rule: PathPrefix(` + "`/api`" + `)
+    web:
+      service: whoami
+      rule: PathPrefix(` + "`/`" + `)
+      priority: 1
+  services:
+    whoami:
+      loadBalancer:
+        servers:
+          - url: http://10.10.10.10
+`,
+		},
+		{
+			desc: "removes defaults",
+			dynamicConfig: `
+http:
+  services:
+    whoami:
+      loadBalancer:
+        strategy: wrr
+        passHostHeader: true
+        responseForwarding:
+          flushInterval: 100ms
+        servers:
+          - url: http://10.10.10.10
+`,
+			want: `http:
+  services:
+    whoami:
+      loadBalancer:
+        servers:
+          - url: http://10.10.10.10
+`,
+		},
+		{
+			desc: "keeps meaningful zero values",
+			dynamicConfig: `
+http:
+  middlewares:
+    retry:
+      retry:
+        attempts: 0
+  services:
+    whoami:
+      loadBalancer:
+        passHostHeader: false
+        servers:
+          - url: http://10.10.10.10
+    weighted:
+      weighted:
+        services:
+          - name: whoami
+            weight: 0
+`,
+			want: `http:
+  services:
+    weighted:
+      weighted:
+        services:
+          - name: whoami
+            weight: 0
+    whoami:
+      loadBalancer:
+        servers:
+          - url: http://10.10.10.10
+        passHostHeader: false
+  middlewares:
+    retry:
+      retry: {}
+`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := experiment.NormalizeDynamicConfig(test.dynamicConfig)
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+
+			// Normalizing a normalized configuration must not change it.
+			again, err := experiment.NormalizeDynamicConfig(got)
+			require.NoError(t, err)
+			assert.Equal(t, got, again)
+		})
+	}
+}
+
+func TestNormalizeDynamicConfig_errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc          string
+		dynamicConfig string
+		wantErr       string
+	}{
+		{
+			desc:          "invalid",
+			dynamicConfig: "http: [",
+			wantErr:       "invalid dynamic configuration: yaml: line 1: did not find expected node content",
+		},
+		{
+			desc: "unknown field",
+			dynamicConfig: `
+http:
+  routers:
+    api:
+      rul: PathPrefix(` + "`/api`" + `)
+      service: whoami
+`,
+			wantErr: "normalizing would drop unknown fields: line 5: field rul not found in type dynamic.Router",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := experiment.NormalizeDynamicConfig(test.dynamicConfig)
+
+			var validationErr *experiment.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, "dynamicConfig", validationErr.Field)
+			assert.Equal(t, test.wantErr, validationErr.Message)
+		})
+	}
+}
@@ -0,0 +1,113 @@
+package experiment
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// maxRawRequestLength is the maximum length of a raw HTTP request, enough to hold a request with the longest URL,
+// the maximum number of headers and the longest body.
+const maxRawRequestLength = 16 * 1024
+
+// ParseRawHTTPRequest parses the given HTTP/1.x request, as sent on the wire, into an HTTPRequest.
+// The URL is made absolute using the Host header, unless the request URI already is, and basic authentication
+// credentials are extracted from the Authorization header. Headers are kept as written: a repeated header is sent
+// once per line, with its values in order, rather than joined. Only the case of the names and the order between
+// headers of different names are lost, as Traefik reads them through the canonical Go representation of headers.
+// A ValidationError is returned when the request is malformed or exceeds the limits of an HTTPRequest.
+func ParseRawHTTPRequest(raw string) (HTTPRequest, error) {
+	if len(raw) > maxRawRequestLength {
+		return HTTPRequest{}, newTooLargeError("rawRequest", "raw request", maxRawRequestLength)
+	}
+
+	// Requests without a body are commonly pasted without the blank line ending the header section.
+	if !strings.Contains(raw, "\r\n\r\n") && !strings.Contains(raw, "\n\n") {
+		raw = strings.TrimRight(raw, "\r\n") + "\r\n\r\n"
+	}
+
+	r := bufio.NewReader(strings.NewReader(raw))
+
+	req, err := http.ReadRequest(r)
+	if err != nil {
+		return HTTPRequest{}, newValidationError("rawRequest", "raw request is invalid: %s", err)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxBodyLength+1))
+	if err != nil {
+		return HTTPRequest{}, newValidationError("rawRequest", "raw request is invalid: reading body: %s", err)
+	}
+
+	// Without a Content-Length or a chunked Transfer-Encoding, the body isn't read and would be silently dropped.
+	if _, err = r.ReadByte(); !errors.Is(err, io.EOF) {
+		return HTTPRequest{}, newValidationError("rawRequest", "raw request has unexpected data after its body, check the Content-Length header")
+	}
+
+	if req.Host == "" {
+		return HTTPRequest{}, newValidationError("rawRequest", "raw request is missing a Host header")
+	}
+
+	url := req.RequestURI
+	if !req.URL.IsAbs() {
+		url = "http://" + req.Host + req.RequestURI
+	}
+
+	rawReq := RawHTTPRequest{
+		Method: req.Method,
+		URL:    url,
+		Proto:  req.Proto,
+		Body:   string(body),
+		// The request is meant to be sent as written.
+		NoContentTypeDetection: "true",
+	}
+
+	// The host is part of the URL, and the framing headers are set according to the body when the request is sent.
+	skipped := []string{"Host", "Content-Length", "Transfer-Encoding"}
+
+	if username, password, ok := req.BasicAuth(); ok {
+		rawReq.Username = username
+		rawReq.Password = password
+
+		skipped = append(skipped, "Authorization")
+	}
+
+	rawReq.Headers = rawHeaderLines(raw, skipped)
+
+	return MakeHTTPRequest(rawReq)
+}
+
+// rawHeaderLines returns the header lines of the given raw request as written, without the headers of the given
+// names and their continuation lines. The request is expected to have been read successfully.
+func rawHeaderLines(raw string, skipped []string) string {
+	raw = strings.ReplaceAll(raw, "\r\n", "\n")
+
+	headerSection, _, _ := strings.Cut(raw, "\n\n")
+	_, headerSection, _ = strings.Cut(headerSection, "\n")
+
+	var (
+		lines []string
+		skip  bool
+	)
+	for line := range strings.SplitSeq(headerSection, "\n") {
+		if line != "" && (line[0] == ' ' || line[0] == '\t') {
+			if !skip {
+				lines = append(lines, line)
+			}
+
+			continue
+		}
+
+		name, _, _ := strings.Cut(line, ":")
+		skip = slices.ContainsFunc(skipped, func(s string) bool {
+			return strings.EqualFold(strings.TrimSpace(name), s)
+		})
+		if !skip {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,140 @@
+package experiment_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jspdown/traefik-playground/internal/experiment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRawHTTPRequest(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc    string
+		raw     string
+		want    experiment.HTTPRequest
+		wantErr string
+	}{
+		{
+			desc: "request without body",
+			raw:  "GET /foo?bar=baz HTTP/1.1\r\nHost: example.com\r\nX-Foo: foo\r\n\r\n",
+			want: experiment.HTTPRequest{
+				NoContentTypeDetection: true,
+				Method:                 http.MethodGet,
+				URL:                    "http://example.com/foo?bar=baz",
+				Headers:                http.Header{"X-Foo": {"foo"}},
+			},
+		},
+		{
+			desc: "missing blank line and bare line feeds",
+			raw:  "OPTIONS / HTTP/1.0\nHost: example.com\nOrigin: https://example.org",
+			want: experiment.HTTPRequest{
+				NoContentTypeDetection: true,
+				Method:                 http.MethodOptions,
+				URL:                    "http://example.com/",
+				Proto:                  "HTTP/1.0",
+				Headers:                http.Header{"Origin": {"https://example.org"}},
+			},
+		},
+		{
+			desc: "request with body",
+			raw:  "POST /foo HTTP/1.1\r\nHost: example.com\r\nContent-Type: application/json\r\nContent-Length: 8\r\n\r\n{\"a\": 1}",
+			want: experiment.HTTPRequest{
+				NoContentTypeDetection: true,
+				Method:                 http.MethodPost,
+				URL:                    "http://example.com/foo",
+				Headers:                http.Header{"Content-Type": {"application/json"}},
+				Body:                   `{"a": 1}`,
+			},
+		},
+		{
+			desc: "chunked body",
+			raw:  "PUT /foo HTTP/1.1\r\nHost: example.com\r\nTransfer-Encoding: chunked\r\n\r\n3\r\nfoo\r\n0\r\n\r\n",
+			want: experiment.HTTPRequest{
+				NoContentTypeDetection: true,
+				Method:                 http.MethodPut,
+				URL:                    "http://example.com/foo",
+				Headers:                http.Header{},
+				Body:                   "foo",
+			},
+		},
+		{
+			desc: "absolute URL and basic authentication",
+			// The host of an absolute request URI takes precedence over the Host header.
+			raw: "GET https://example.com/foo HTTP/1.1\r\nHost: example.org\r\nAuthorization: Basic dXNlcjpzZWNyZXQ=\r\nAccept: a\r\nAccept: b\r\n\r\n",
+			want: experiment.HTTPRequest{
+				NoContentTypeDetection: true,
+				Method:                 http.MethodGet,
+				URL:                    "https://example.com/foo",
+				Headers:                http.Header{"Accept": {"a", "b"}},
+				Username:               "user",
+				Password:               "secret",
+			},
+		},
+		{
+			desc: "repeated headers",
+			// Cookies are joined with "; " rather than ", ": each header line is sent as written.
+			raw: "GET / HTTP/1.1\r\nhost: example.com\r\nCookie: a=1\r\nx-foo: foo\r\nCookie: b=2\r\nX-Folded: foo\r\n  bar\r\n\r\n",
+			want: experiment.HTTPRequest{
+				NoContentTypeDetection: true,
+				Method:                 http.MethodGet,
+				URL:                    "http://example.com/",
+				Headers: http.Header{
+					"Cookie":   {"a=1", "b=2"},
+					"X-Foo":    {"foo"},
+					"X-Folded": {"foo bar"},
+				},
+			},
+		},
+		{
+			desc:    "malformed request line",
+			raw:     "GET /foo\r\nHost: example.com\r\n\r\n",
+			wantErr: `raw request is invalid: malformed HTTP request "GET /foo"`,
+		},
+		{
+			desc:    "missing host",
+			raw:     "GET /foo HTTP/1.1\r\nX-Foo: foo\r\n\r\n",
+			wantErr: "raw request is missing a Host header",
+		},
+		{
+			desc:    "body without content length",
+			raw:     "POST /foo HTTP/1.1\r\nHost: example.com\r\n\r\nbody",
+			wantErr: "raw request has unexpected data after its body, check the Content-Length header",
+		},
+		{
+			desc:    "method not allowed",
+			raw:     "CONNECT example.com:443 HTTP/1.1\r\nHost: example.com\r\n\r\n",
+			wantErr: "method CONNECT not allowed",
+		},
+		{
+			desc:    "body too long",
+			raw:     "POST /foo HTTP/1.1\r\nHost: example.com\r\nContent-Length: 1025\r\n\r\n" + strings.Repeat("a", 1025),
+			wantErr: "body is too large (max: 1024 bytes)",
+		},
+		{
+			desc:    "raw request too long",
+			raw:     "GET / HTTP/1.1\r\nHost: example.com\r\nX-Foo: " + strings.Repeat("a", 16*1024) + "\r\n\r\n",
+			wantErr: "raw request is too large (max: 16384 bytes)",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := experiment.ParseRawHTTPRequest(test.raw)
+			if test.wantErr != "" {
+				require.EqualError(t, err, test.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
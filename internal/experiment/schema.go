@@ -0,0 +1,103 @@
+package experiment
+
+import (
+	_ "embed"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/xeipuuv/gojsonschema"
+	"gopkg.in/yaml.v3"
+)
+
+// dynamicConfigSchema is the JSON schema of the dynamic configuration generated by tools/json-schema-gen, also
+// served to the editor.
+//
+//go:embed traefik-v3.schema.json
+var dynamicConfigSchema []byte
+
+// DynamicConfigSchema returns the JSON schema the dynamic configurations are validated against. The returned
+// slice must not be modified.
+func DynamicConfigSchema() []byte {
+	return dynamicConfigSchema
+}
+
+// maxSchemaErrors is the maximum number of schema violations reported at once.
+const maxSchemaErrors = 5
+
+//nolint:gochecknoglobals // The schema is compiled once, when first used.
+var loadDynamicConfigSchema = sync.OnceValues(func() (*gojsonschema.Schema, error) {
+	return gojsonschema.NewSchema(gojsonschema.NewBytesLoader(dynamicConfigSchema))
+})
+
+// validateDynamicConfigSchema checks the given dynamic configuration against the JSON schema. Unlike unmarshaling,
+// it reports unknown fields, and scalars given where a string is expected.
+// The dynamic configuration is expected to be valid YAML.
+func validateDynamicConfigSchema(dynamicConfig string) error {
+	schema, err := loadDynamicConfigSchema()
+	if err != nil {
+		return fmt.Errorf("loading dynamic configuration schema: %w", err)
+	}
+
+	var document any
+	if err = yaml.Unmarshal([]byte(dynamicConfig), &document); err != nil {
+		return newValidationError("dynamicConfig", "invalid dynamic configuration: %s", err)
+	}
+
+	// Null values are left unset when unmarshaling, they are not validated.
+	document = removeNulls(document)
+	if document == nil {
+		return nil
+	}
+
+	res, err := schema.Validate(gojsonschema.NewGoLoader(document))
+	if err != nil {
+		return newValidationError("dynamicConfig", "invalid dynamic configuration: %s", err)
+	}
+
+	if res.Valid() {
+		return nil
+	}
+
+	var violations []string
+	for _, resErr := range res.Errors() {
+		// Sub-schemas violations are reported along with the violation of their parent.
+		if resErr.Type() == "number_one_of" {
+			continue
+		}
+
+		violations = append(violations, fmt.Sprintf("%s: %s", resErr.Field(), resErr.Description()))
+	}
+
+	slices.Sort(violations)
+	violations = slices.Compact(violations)
+
+	if len(violations) > maxSchemaErrors {
+		violations = append(violations[:maxSchemaErrors], fmt.Sprintf("and %d more", len(violations)-maxSchemaErrors))
+	}
+
+	return newValidationError("dynamicConfig", "invalid dynamic configuration: %s", strings.Join(violations, ", "))
+}
+
+// removeNulls removes the null values of the mappings of the given YAML document.
+func removeNulls(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if item == nil {
+				delete(v, key)
+
+				continue
+			}
+
+			v[key] = removeNulls(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = removeNulls(item)
+		}
+	}
+
+	return value
+}
@@ -0,0 +1,54 @@
+package experiment
+
+import "bytes"
+
+// maxTextMatches is the maximum number of matches FindMatches returns.
+const maxTextMatches = 1000
+
+// TextMatch is the position of a match in a text, as byte offsets. End is exclusive.
+type TextMatch struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// FindMatches returns the positions of the occurrences of the given term in the given text, in order. Letters are
+// matched regardless of their ASCII case, and overlapping occurrences are all returned, such as the three
+// occurrences of "aa" in "aaaa". Only the first occurrences are returned when there are too many.
+func FindMatches(text []byte, term string) []TextMatch {
+	if term == "" {
+		return nil
+	}
+
+	// Folding the ASCII case leaves the length of the text untouched, the offsets found in the folded text are
+	// also those of the original text.
+	folded := asciiLower(text)
+	foldedTerm := asciiLower([]byte(term))
+
+	var matches []TextMatch
+	for offset := 0; len(matches) < maxTextMatches; {
+		i := bytes.Index(folded[offset:], foldedTerm)
+		if i < 0 {
+			break
+		}
+
+		start := offset + i
+		matches = append(matches, TextMatch{Start: start, End: start + len(foldedTerm)})
+
+		offset = start + 1
+	}
+
+	return matches
+}
+
+// asciiLower returns a copy of the given bytes with the ASCII upper case letters in lower case.
+func asciiLower(b []byte) []byte {
+	lower := make([]byte, len(b))
+	for i, c := range b {
+		if 'A' <= c && c <= 'Z' {
+			c += 'a' - 'A'
+		}
+		lower[i] = c
+	}
+
+	return lower
+}
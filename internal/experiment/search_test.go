@@ -0,0 +1,81 @@
+package experiment_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jspdown/traefik-playground/internal/experiment"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindMatches(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc string
+		text string
+		term string
+		want []experiment.TextMatch
+	}{
+		{
+			desc: "empty term",
+			text: "GET / HTTP/1.1",
+		},
+		{
+			desc: "no match",
+			text: "GET / HTTP/1.1\r\nHost: example.com\r\n",
+			term: "Authorization",
+		},
+		{
+			desc: "single match",
+			text: "GET / HTTP/1.1\r\nHost: example.com\r\n",
+			term: "Host",
+			want: []experiment.TextMatch{{Start: 16, End: 20}},
+		},
+		{
+			desc: "case-insensitive matches",
+			text: "X-Real-Ip: 10.0.0.1\r\nx-real-ip: 10.0.0.2\r\n",
+			term: "X-REAL-IP",
+			want: []experiment.TextMatch{{Start: 0, End: 9}, {Start: 21, End: 30}},
+		},
+		{
+			desc: "overlapping matches",
+			text: "aaaa",
+			term: "aa",
+			want: []experiment.TextMatch{{Start: 0, End: 2}, {Start: 1, End: 3}, {Start: 2, End: 4}},
+		},
+		{
+			desc: "term longer than the text",
+			text: "GET",
+			term: "GET /",
+		},
+		{
+			desc: "non-ASCII text",
+			text: "Café: café",
+			term: "CAFé",
+			want: []experiment.TextMatch{{Start: 0, End: 5}, {Start: 7, End: 12}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			got := experiment.FindMatches([]byte(test.text), test.term)
+			assert.Equal(t, test.want, got)
+
+			for _, match := range got {
+				assert.True(t, strings.EqualFold(test.term, test.text[match.Start:match.End]))
+			}
+		})
+	}
+}
+
+func TestFindMatches_tooManyMatches(t *testing.T) {
+	t.Parallel()
+
+	got := experiment.FindMatches([]byte(strings.Repeat("a", 2000)), "a")
+
+	assert.Len(t, got, 1000)
+	assert.Equal(t, experiment.TextMatch{Start: 999, End: 1000}, got[999])
+}
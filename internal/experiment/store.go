@@ -2,82 +2,255 @@ package experiment
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/jspdown/traefik-playground/internal/database"
+	"github.com/lib/pq"
 	"github.com/lithammer/shortuuid/v4"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 var ErrNotFound = errors.New("not found")
 
+// defaultRetryBackoff is the delay before retrying a query for the first time when none is configured.
+const defaultRetryBackoff = 100 * time.Millisecond
+
+// shortCodeAlphabet is the alphabet of the short codes: the lower case Crockford's base32 alphabet, without the
+// letters easily mistaken for digits.
+const shortCodeAlphabet = "0123456789abcdefghjkmnpqrstvwxyz"
+
+// shortCodeLength is the number of characters of a short code, giving 2^40 possible codes.
+const shortCodeLength = 8
+
+// maxUserAgentLength is the maximum number of bytes of the user agents kept by Save, longer ones are truncated.
+const maxUserAgentLength = 512
+
+// maxSaveAttempts is the number of times Save generates new IDs when they collide with the IDs of a saved Experiment.
+const maxSaveAttempts = 5
+
 // Store stores Experiments.
 type Store struct {
-	db *sql.DB
+	db      *sql.DB
+	dialect database.Dialect
+
+	maxRetries   int
+	retryBackoff time.Duration
+	clientIPSalt string
+	shortCodes   bool
+
+	// newShortCode generates the short codes of the saved Experiments.
+	newShortCode func() (string, error)
+}
+
+// StoreConfig holds the Store configuration.
+type StoreConfig struct {
+	// Dialect is the SQL dialect of the database. It defaults to PostgreSQL.
+	Dialect database.Dialect
+	// MaxRetries defines how many times Save and Get retry a query failing with a transient error, such as a
+	// connection reset or a serialization failure. Zero disables retries.
+	MaxRetries int
+	// RetryBackoff defines the delay before the first retry, doubled on each subsequent retry. It defaults
+	// to 100ms.
+	RetryBackoff time.Duration
+	// ClientIPSalt, when set, makes the Store keep a hash of the client IPs salted with it, instead of the client IPs
+	// themselves. The same salt must be used to delete the Experiments shared from a client IP. The Experiments
+	// shared before the salt was set keep their raw client IP, and are deleted along with the others.
+	ClientIPSalt string
+	// ShortCodes makes Save return the short code of the saved Experiments rather than their public ID. Short codes
+	// are easier to read and type, but to guess as well: they should only be handed out along with signed share URLs.
+	ShortCodes bool
 }
 
 // NewStore creates a new Store.
-func NewStore(db *sql.DB) *Store {
+func NewStore(db *sql.DB, config StoreConfig) *Store {
+	retryBackoff := config.RetryBackoff
+	if retryBackoff <= 0 {
+		retryBackoff = defaultRetryBackoff
+	}
+
+	dialect := config.Dialect
+	if dialect == "" {
+		dialect = database.Postgres
+	}
+
 	return &Store{
-		db: db,
+		db:           db,
+		dialect:      dialect,
+		maxRetries:   config.MaxRetries,
+		retryBackoff: retryBackoff,
+		clientIPSalt: config.ClientIPSalt,
+		shortCodes:   config.ShortCodes,
+		newShortCode: newShortCode,
 	}
 }
 
-// Save saves the given Experiment, a unique public ID is returned.
-func (s *Store) Save(ctx context.Context, exp Experiment, res Result, clientIP string) (string, error) {
-	publicID := shortuuid.New()
+// Save saves the given Experiment, a unique ID is returned. The ID is the public ID of the Experiment, or its short
+// code when the Store is configured with ShortCodes. Experiments saved before short codes were introduced keep their
+// public ID as ID. Both IDs retrieve the Experiment. The client IP and user agent are kept for abuse investigations, see TopUserAgents.
+func (s *Store) Save(ctx context.Context, exp Experiment, res Result, clientIP, userAgent string) (string, error) {
+	if err := validateLabel(exp.Label); err != nil {
+		return "", err
+	}
+
+	userAgent = sanitizeUserAgent(userAgent)
+	clientIP = s.storedClientIP(clientIP)
 
-	// Generate a hash of the experiment and result.
 	// This hash is used to prevent saving multiple time the same thing.
-	hashData, err := json.Marshal(struct {
-		Experiment Experiment `json:"experiment"`
-		Result     Result     `json:"result"`
-	}{
-		Experiment: exp,
-		Result:     res,
-	})
+	hash, err := hashExperiment(exp, res)
 	if err != nil {
-		return "", fmt.Errorf("marshaling hash data: %w", err)
+		return "", err
 	}
 
-	hash := fmt.Sprintf("%x", sha256.Sum256(hashData))
-
 	query := `
 		INSERT INTO shared_experiments (public_id,
+		                         		short_code,
 		                         		hash,
 		                         		dynamic_config,
+		                         		static_config,
 		                         		request,
 		                         		result,
-		                         		client_ip)
-		VALUES ($1, $2, $3, $4, $5, $6)
+		                         		label,
+		                         		client_ip,
+		                         		user_agent)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
 		ON CONFLICT(hash) DO UPDATE SET hash = shared_experiments.hash
-		RETURNING public_id
+		RETURNING public_id, short_code
 	`
-	err = s.db.QueryRowContext(ctx, query,
-		publicID,
-		hash,
-		exp.DynamicConfig,
-		&exp.Request,
-		&res,
-		clientIP,
-	).Scan(&publicID)
+
+	for attempt := 1; ; attempt++ {
+		var newShortCode string
+		if newShortCode, err = s.newShortCode(); err != nil {
+			return "", err
+		}
+
+		var (
+			publicID  string
+			shortCode sql.NullString
+		)
+
+		// Conflicts are resolved by returning the stored ID, the query can be retried safely.
+		err = s.retry(ctx, func() error {
+			return s.db.QueryRowContext(ctx, query,
+				shortuuid.New(),
+				newShortCode,
+				hash,
+				exp.DynamicConfig,
+				exp.StaticConfig,
+				&exp.Request,
+				&res,
+				exp.Label,
+				clientIP,
+				userAgent,
+			).Scan(&publicID, &shortCode)
+		})
+
+		// The generated IDs are already used by another Experiment, try again with new ones.
+		if isUniqueViolation(err) && attempt < maxSaveAttempts {
+			continue
+		}
+
+		if err != nil {
+			return "", fmt.Errorf("inserting experiment: %w", err)
+		}
+
+		if s.shortCodes && shortCode.Valid {
+			return shortCode.String, nil
+		}
+
+		return publicID, nil
+	}
+}
+
+// storedClientIP returns the value kept for the given client IP: the client IP itself, or its salted hash when the
+// Store is configured with a ClientIPSalt.
+func (s *Store) storedClientIP(clientIP string) string {
+	if s.clientIPSalt == "" {
+		return clientIP
+	}
+
+	mac := hmac.New(sha256.New, []byte(s.clientIPSalt))
+	mac.Write([]byte(clientIP))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// sanitizeUserAgent returns the given user agent truncated to maxUserAgentLength bytes, without the invalid UTF-8
+// sequences databases refuse to store.
+func sanitizeUserAgent(userAgent string) string {
+	userAgent = strings.ToValidUTF8(userAgent, "")
+	if len(userAgent) <= maxUserAgentLength {
+		return userAgent
+	}
+
+	// Don't cut a multibyte character in half.
+	return strings.ToValidUTF8(userAgent[:maxUserAgentLength], "")
+}
+
+// newShortCode generates a random short code.
+func newShortCode() (string, error) {
+	var code [shortCodeLength]byte
+	if _, err := rand.Read(code[:]); err != nil {
+		return "", fmt.Errorf("generating short code: %w", err)
+	}
+
+	// The alphabet has 32 characters, which divides 256: every character is equally likely.
+	for i, b := range code {
+		code[i] = shortCodeAlphabet[int(b)%len(shortCodeAlphabet)]
+	}
+
+	return string(code[:]), nil
+}
+
+// hashExperiment returns a hash of the given experiment, result and label.
+func hashExperiment(exp Experiment, res Result) (string, error) {
+	hash, err := hashJSON(struct {
+		Experiment Experiment `json:"experiment"`
+		Result     Result     `json:"result"`
+		Label      string     `json:"label,omitempty"`
+	}{
+		Experiment: exp,
+		Result:     res,
+		Label:      exp.Label,
+	})
 	if err != nil {
-		return "", fmt.Errorf("inserting experiment: %w", err)
+		return "", fmt.Errorf("hashing experiment: %w", err)
 	}
 
-	return publicID, nil
+	return hash, nil
 }
 
-// Get retrieves an Experiment from its public ID.
-func (s *Store) Get(ctx context.Context, publicID string) (exp Experiment, res Result, err error) {
+// hashJSON returns the hex encoded SHA-256 digest of the JSON representation of v.
+func hashJSON(v any) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("marshaling hash data: %w", err)
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// Get retrieves an Experiment from its short code or its public ID.
+func (s *Store) Get(ctx context.Context, id string) (exp Experiment, res Result, err error) {
 	query := `
-		UPDATE shared_experiments SET last_retrieved_at = NOW()
-        WHERE public_id = $1
-        RETURNING dynamic_config, request, result
+		UPDATE shared_experiments SET last_retrieved_at = CURRENT_TIMESTAMP
+        WHERE short_code = $1 OR public_id = $1
+        RETURNING public_id, dynamic_config, static_config, request, result, label
 	`
-	err = s.db.QueryRowContext(ctx, query, publicID).Scan(&exp.DynamicConfig, &exp.Request, &res)
+	err = s.retry(ctx, func() error {
+		return s.db.QueryRowContext(ctx, query, id).Scan(&exp.ID, &exp.DynamicConfig, &exp.StaticConfig, &exp.Request, &res, &exp.Label)
+	})
 	if errors.Is(err, sql.ErrNoRows) {
 		return Experiment{}, Result{}, ErrNotFound
 	} else if err != nil {
@@ -86,3 +259,179 @@ func (s *Store) Get(ctx context.Context, publicID string) (exp Experiment, res R
 
 	return
 }
+
+// UserAgentCount is the number of Experiments shared with a user agent.
+type UserAgentCount struct {
+	UserAgent string `json:"userAgent"`
+	Count     int64  `json:"count"`
+}
+
+// TopUserAgents returns the user agents which shared the most Experiments, by decreasing number of Experiments, at
+// most limit of them. Experiments shared before user agents were kept are counted under an empty user agent.
+func (s *Store) TopUserAgents(ctx context.Context, limit int) ([]UserAgentCount, error) {
+	query := `
+		SELECT user_agent, COUNT(*) AS count
+		FROM shared_experiments
+		GROUP BY user_agent
+		ORDER BY count DESC, user_agent
+		LIMIT $1
+	`
+	rows, err := s.db.QueryContext(ctx, query, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying user agents: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var counts []UserAgentCount
+	for rows.Next() {
+		var count UserAgentCount
+		if err = rows.Scan(&count.UserAgent, &count.Count); err != nil {
+			return nil, fmt.Errorf("scanning user agent: %w", err)
+		}
+
+		counts = append(counts, count)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading user agents: %w", err)
+	}
+
+	return counts, nil
+}
+
+// ListedExperiment is a shared Experiment as listed by List.
+type ListedExperiment struct {
+	// ID is the public ID of the Experiment.
+	ID        string    `json:"id"`
+	Label     string    `json:"label,omitempty"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// List returns the most recently shared Experiments, at most limit of them. When label isn't empty, only the
+// Experiments shared with this label are listed.
+func (s *Store) List(ctx context.Context, label string, limit int) ([]ListedExperiment, error) {
+	query := `
+		SELECT public_id, label, created_at
+		FROM shared_experiments
+		WHERE $1 = '' OR label = $1
+		ORDER BY created_at DESC, public_id
+		LIMIT $2
+	`
+	rows, err := s.db.QueryContext(ctx, query, label, limit)
+	if err != nil {
+		return nil, fmt.Errorf("querying experiments: %w", err)
+	}
+	defer func() { _ = rows.Close() }()
+
+	var experiments []ListedExperiment
+	for rows.Next() {
+		var exp ListedExperiment
+		if err = rows.Scan(&exp.ID, &exp.Label, &exp.CreatedAt); err != nil {
+			return nil, fmt.Errorf("scanning experiment: %w", err)
+		}
+
+		experiments = append(experiments, exp)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("reading experiments: %w", err)
+	}
+
+	return experiments, nil
+}
+
+// retry calls the given function until it succeeds, fails with a non-transient error or the retries are
+// exhausted. The last error is returned.
+func (s *Store) retry(ctx context.Context, fn func() error) error {
+	backoff := s.retryBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= s.maxRetries || !isTransient(err) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+// isTransient reports whether the given database error is likely to go away when retrying.
+func isTransient(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		// Connection exceptions, serialization failures and deadlocks.
+		return pqErr.Code.Class() == "08" || pqErr.Code == "40001" || pqErr.Code == "40P01"
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		// The database is locked by another connection for longer than the busy timeout.
+		code := sqliteErr.Code() & 0xff
+
+		return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+	}
+
+	return false
+}
+
+// isUniqueViolation reports whether the given database error is caused by a value already used by another row, in
+// a column which must hold unique values.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "23505"
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE || sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY
+	}
+
+	return false
+}
+
+// DeleteExpired deletes the Experiments shared before the given time, and returns the number of deleted Experiments.
+func (s *Store) DeleteExpired(ctx context.Context, before time.Time) (int64, error) {
+	query := `
+		DELETE FROM shared_experiments
+		WHERE created_at < $1
+	`
+	// SQLite stores timestamps as UTC text, which are compared as strings.
+	var param any = before
+	if s.dialect == database.SQLite {
+		param = before.UTC().Format(time.DateTime)
+	}
+
+	res, err := s.db.ExecContext(ctx, query, param)
+	if err != nil {
+		return 0, fmt.Errorf("deleting experiments: %w", err)
+	}
+
+	return res.RowsAffected()
+}
+
+// Delete deletes the Experiments shared from the given client IP, and returns the number of deleted Experiments.
+// Both the raw client IP and its salted hash are looked up, as Experiments shared before the Store was given a
+// ClientIPSalt hold the raw one.
+func (s *Store) Delete(ctx context.Context, clientIP string) (int64, error) {
+	query := `
+		DELETE FROM shared_experiments
+		WHERE client_ip IN ($1, $2)
+	`
+	res, err := s.db.ExecContext(ctx, query, s.storedClientIP(clientIP), clientIP)
+	if err != nil {
+		return 0, fmt.Errorf("deleting experiments: %w", err)
+	}
+
+	return res.RowsAffected()
+}
@@ -3,13 +3,21 @@ package experiment
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/jspdown/traefik-playground/db/migrations"
+	"github.com/jspdown/traefik-playground/internal/database"
 	"github.com/jspdown/traefik-playground/internal/traefik"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/testcontainers/testcontainers-go"
@@ -20,53 +28,616 @@ import (
 func TestStore_Save(t *testing.T) {
 	t.Parallel()
 
-	db := setupTestDB(t)
-	s := NewStore(db)
-
-	// Prepare test data.
-	experiment := Experiment{
-		DynamicConfig: "dynamicConfig",
-		Request: HTTPRequest{
-			Method:  http.MethodPost,
-			URL:     "https://example.com/foo",
-			Headers: http.Header{"Content-Type": []string{"application/json"}},
-			Body:    "body",
+	for _, backend := range testBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := backend.newStore(t)
+
+			// Prepare test data.
+			experiment := Experiment{
+				DynamicConfig: "dynamicConfig",
+				StaticConfig:  "staticConfig",
+				Request: HTTPRequest{
+					Method:  http.MethodPost,
+					URL:     "https://example.com/foo",
+					Headers: http.Header{"Content-Type": []string{"application/json"}},
+					Body:    "body",
+				},
+			}
+			result := Result{
+				Response: HTTPResponse{
+					Proto:      "HTTP/1.1",
+					StatusCode: http.StatusOK,
+					Headers:    http.Header{"X-Key": []string{"value"}},
+					Body:       []byte("value"),
+				},
+				Logs: []traefik.Log{
+					{
+						Timestamp: time.Now().String(),
+						Level:     traefik.LogLevelInfo,
+						Message:   "message",
+						Error:     "error",
+						Fields:    map[string]interface{}{"key": "value"},
+					},
+				},
+			}
+			ctx := context.Background()
+
+			// Save the experiment for the first time.
+			firstPublicID, err := s.Save(ctx, experiment, result, "127.0.0.1", "")
+			require.NoError(t, err)
+			assert.NotEmpty(t, firstPublicID)
+
+			// Make sure it doesn't save a new entry of the content is similar.
+			secondPublicID, err := s.Save(ctx, experiment, result, "127.0.0.2", "")
+			require.NoError(t, err)
+			assert.Equal(t, firstPublicID, secondPublicID)
+
+			gotExp, gotRes, err := s.Get(ctx, firstPublicID)
+			require.NoError(t, err)
+
+			// The experiment is retrieved along with its public ID.
+			experiment.ID = firstPublicID
+
+			//nolint:testifylint // False positive.
+			assert.Equal(t, experiment, gotExp)
+			assert.Equal(t, result, gotRes)
+		})
+	}
+}
+
+func TestStore_label(t *testing.T) {
+	t.Parallel()
+
+	for _, backend := range testBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := backend.newStore(t)
+
+			experiment := Experiment{
+				DynamicConfig: "dynamicConfig",
+				Request: HTTPRequest{
+					Method: http.MethodGet,
+					URL:    "https://example.com/foo",
+				},
+				Label: "retry-test",
+			}
+			result := Result{
+				Response: HTTPResponse{StatusCode: http.StatusOK},
+			}
+			ctx := context.Background()
+
+			publicID, err := s.Save(ctx, experiment, result, "127.0.0.1", "")
+			require.NoError(t, err)
+
+			gotExp, _, err := s.Get(ctx, publicID)
+			require.NoError(t, err)
+			assert.Equal(t, "retry-test", gotExp.Label)
+
+			// The same experiment shared with another label is saved separately.
+			experiment.Label = "other"
+			otherPublicID, err := s.Save(ctx, experiment, result, "127.0.0.1", "")
+			require.NoError(t, err)
+			assert.NotEqual(t, publicID, otherPublicID)
+
+			experiment.Label = strings.Repeat("a", 51)
+			_, err = s.Save(ctx, experiment, result, "127.0.0.1", "")
+			require.EqualError(t, err, "label is too long (max: 50)")
+		})
+	}
+}
+
+func TestStore_List(t *testing.T) {
+	t.Parallel()
+
+	for _, backend := range testBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := backend.newStore(t)
+			ctx := context.Background()
+
+			save := func(url, label string) string {
+				t.Helper()
+
+				id, err := s.Save(ctx, Experiment{
+					DynamicConfig: "dynamicConfig",
+					Request:       HTTPRequest{Method: http.MethodGet, URL: url},
+					Label:         label,
+				}, Result{}, "127.0.0.1", "")
+				require.NoError(t, err)
+
+				_, _, err = s.Get(ctx, id)
+				require.NoError(t, err)
+
+				return id
+			}
+
+			save("https://example.com/1", "retry-test")
+			save("https://example.com/2", "other")
+			save("https://example.com/3", "retry-test")
+			save("https://example.com/4", "")
+
+			got, err := s.List(ctx, "retry-test", 10)
+			require.NoError(t, err)
+			require.Len(t, got, 2)
+
+			for _, exp := range got {
+				assert.Equal(t, "retry-test", exp.Label)
+				assert.NotEmpty(t, exp.ID)
+				assert.False(t, exp.CreatedAt.IsZero())
+
+				_, _, err = s.Get(ctx, exp.ID)
+				require.NoError(t, err)
+			}
+
+			got, err = s.List(ctx, "", 10)
+			require.NoError(t, err)
+			assert.Len(t, got, 4)
+
+			got, err = s.List(ctx, "", 3)
+			require.NoError(t, err)
+			assert.Len(t, got, 3)
+
+			got, err = s.List(ctx, "unknown", 10)
+			require.NoError(t, err)
+			assert.Empty(t, got)
+		})
+	}
+}
+
+func TestStore_Delete(t *testing.T) {
+	t.Parallel()
+
+	for _, backend := range testBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := backend.newStore(t)
+			ctx := context.Background()
+
+			save := func(url, clientIP string) string {
+				t.Helper()
+
+				publicID, err := s.Save(ctx, Experiment{
+					DynamicConfig: "dynamicConfig",
+					Request:       HTTPRequest{Method: http.MethodGet, URL: url},
+				}, Result{}, clientIP, "")
+				require.NoError(t, err)
+
+				return publicID
+			}
+
+			firstID := save("https://example.com/first", "127.0.0.1")
+			secondID := save("https://example.com/second", "127.0.0.2")
+
+			deleted, err := s.Delete(ctx, "127.0.0.2")
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), deleted)
+
+			_, _, err = s.Get(ctx, secondID)
+			require.ErrorIs(t, err, ErrNotFound)
+
+			deleted, err = s.DeleteExpired(ctx, time.Now().Add(-time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, int64(0), deleted)
+
+			deleted, err = s.DeleteExpired(ctx, time.Now().Add(time.Hour))
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), deleted)
+
+			_, _, err = s.Get(ctx, firstID)
+			require.ErrorIs(t, err, ErrNotFound)
+		})
+	}
+}
+
+func TestStore_retry(t *testing.T) {
+	t.Parallel()
+
+	serializationFailure := &pq.Error{Code: "40001"}
+	syntaxError := &pq.Error{Code: "42601"}
+
+	tests := []struct {
+		desc        string
+		failures    int
+		err         error
+		maxRetries  int
+		wantErr     error
+		wantQueries int
+	}{
+		{
+			desc:        "no failure",
+			maxRetries:  2,
+			wantQueries: 1,
+		},
+		{
+			desc:        "transient failures",
+			failures:    2,
+			err:         serializationFailure,
+			maxRetries:  2,
+			wantQueries: 3,
+		},
+		{
+			desc:        "retries exhausted",
+			failures:    3,
+			err:         serializationFailure,
+			maxRetries:  2,
+			wantErr:     serializationFailure,
+			wantQueries: 3,
 		},
+		{
+			desc:        "retries disabled",
+			failures:    1,
+			err:         serializationFailure,
+			wantErr:     serializationFailure,
+			wantQueries: 1,
+		},
+		{
+			desc:        "non-transient failure",
+			failures:    1,
+			err:         syntaxError,
+			maxRetries:  2,
+			wantErr:     syntaxError,
+			wantQueries: 1,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+
+			saveConnector := &flakyConnector{failures: test.failures, err: test.err, rows: [][]driver.Value{{"public-id", nil}}}
+			s := NewStore(sql.OpenDB(saveConnector), StoreConfig{MaxRetries: test.maxRetries, RetryBackoff: time.Millisecond})
+
+			publicID, err := s.Save(ctx, Experiment{DynamicConfig: "dynamicConfig"}, Result{}, "127.0.0.1", "")
+			if test.wantErr != nil {
+				require.ErrorIs(t, err, test.wantErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "public-id", publicID)
+			}
+			assert.Equal(t, test.wantQueries, saveConnector.queries)
+
+			getConnector := &flakyConnector{failures: test.failures, err: test.err, rows: [][]driver.Value{{
+				"public-id",
+				"dynamicConfig",
+				"staticConfig",
+				[]byte(`{"method":"GET","url":"https://example.com","headers":null,"body":""}`),
+				[]byte(`{"response":{"statusCode":200}}`),
+				"label",
+			}}}
+			s = NewStore(sql.OpenDB(getConnector), StoreConfig{MaxRetries: test.maxRetries, RetryBackoff: time.Millisecond})
+
+			exp, res, err := s.Get(ctx, "public-id")
+			if test.wantErr != nil {
+				require.ErrorIs(t, err, test.wantErr)
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, "public-id", exp.ID)
+				assert.Equal(t, "https://example.com", exp.Request.URL)
+				assert.Equal(t, "staticConfig", exp.StaticConfig)
+				assert.Equal(t, "label", exp.Label)
+				assert.Equal(t, http.StatusOK, res.Response.StatusCode)
+			}
+			assert.Equal(t, test.wantQueries, getConnector.queries)
+		})
 	}
-	result := Result{
-		Response: HTTPResponse{
-			Proto:      "HTTP/1.1",
-			StatusCode: http.StatusOK,
-			Headers:    http.Header{"X-Key": []string{"value"}},
-			Body:       []byte("value"),
+}
+
+func TestStore_retry_notFound(t *testing.T) {
+	t.Parallel()
+
+	connector := &flakyConnector{failures: 1, err: &pq.Error{Code: "08006"}}
+	s := NewStore(sql.OpenDB(connector), StoreConfig{MaxRetries: 2, RetryBackoff: time.Millisecond})
+
+	// The connection failure is retried, but not the missing experiment.
+	_, _, err := s.Get(context.Background(), "public-id")
+	require.ErrorIs(t, err, ErrNotFound)
+	assert.Equal(t, 2, connector.queries)
+}
+
+// flakyConnector connects to a fake database failing the first queries with the given error, then answering
+// the following ones with the given rows.
+type flakyConnector struct {
+	failures int
+	err      error
+	rows     [][]driver.Value
+
+	mu      sync.Mutex
+	queries int
+}
+
+func (c *flakyConnector) Connect(context.Context) (driver.Conn, error) {
+	return &flakyConn{connector: c}, nil
+}
+
+func (c *flakyConnector) Driver() driver.Driver {
+	return nil
+}
+
+type flakyConn struct {
+	connector *flakyConnector
+}
+
+func (c *flakyConn) QueryContext(_ context.Context, _ string, _ []driver.NamedValue) (driver.Rows, error) {
+	c.connector.mu.Lock()
+	defer c.connector.mu.Unlock()
+
+	c.connector.queries++
+	if c.connector.queries <= c.connector.failures {
+		return nil, c.connector.err
+	}
+
+	return &fakeRows{rows: c.connector.rows}, nil
+}
+
+func (c *flakyConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *flakyConn) Begin() (driver.Tx, error) {
+	return nil, errors.New("not supported")
+}
+
+func (c *flakyConn) Close() error {
+	return nil
+}
+
+type fakeRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+
+	return nil
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+// testBackends returns the databases the Store is tested against.
+func TestStore_shortCode(t *testing.T) {
+	t.Parallel()
+
+	for _, backend := range testBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := backend.newStore(t)
+			s.shortCodes = true
+			ctx := context.Background()
+
+			newExperiment := func(i int) Experiment {
+				return Experiment{
+					DynamicConfig: "dynamicConfig",
+					Request: HTTPRequest{
+						Method: http.MethodGet,
+						URL:    "https://example.com/" + strconv.Itoa(i),
+					},
+				}
+			}
+
+			// Every saved experiment gets its own short code, and can be retrieved with it or with its public ID.
+			ids := make(map[string]struct{})
+			for i := range 200 {
+				exp := newExperiment(i)
+
+				id, err := s.Save(ctx, exp, Result{}, "127.0.0.1", "")
+				require.NoError(t, err)
+				assert.Regexp(t, `^[0-9a-hjkmnp-tv-z]{8}$`, id)
+				assert.NotContains(t, ids, id)
+
+				ids[id] = struct{}{}
+
+				var publicID string
+				err = s.db.QueryRowContext(ctx, `SELECT public_id FROM shared_experiments WHERE short_code = $1`, id).Scan(&publicID)
+				require.NoError(t, err)
+
+				gotExp, _, err := s.Get(ctx, id)
+				require.NoError(t, err)
+				assert.Equal(t, exp.Request.URL, gotExp.Request.URL)
+				assert.Equal(t, publicID, gotExp.ID)
+
+				gotExp, _, err = s.Get(ctx, publicID)
+				require.NoError(t, err)
+				assert.Equal(t, exp.Request.URL, gotExp.Request.URL)
+				assert.Equal(t, publicID, gotExp.ID)
+			}
+
+			// Colliding short codes are generated again.
+			codes := []string{"collided", "collided", "newcode0"}
+			s.newShortCode = func() (string, error) {
+				code := codes[0]
+				codes = codes[1:]
+
+				return code, nil
+			}
+
+			id, err := s.Save(ctx, newExperiment(1000), Result{}, "127.0.0.1", "")
+			require.NoError(t, err)
+			assert.Equal(t, "collided", id)
+
+			id, err = s.Save(ctx, newExperiment(1001), Result{}, "127.0.0.1", "")
+			require.NoError(t, err)
+			assert.Equal(t, "newcode0", id)
+
+			s.newShortCode = func() (string, error) { return "collided", nil }
+
+			_, err = s.Save(ctx, newExperiment(1002), Result{}, "127.0.0.1", "")
+			require.Error(t, err)
+
+			// Experiments saved before short codes were introduced keep their public ID as ID.
+			_, err = s.db.ExecContext(ctx, `UPDATE shared_experiments SET short_code = NULL WHERE short_code = $1`, "newcode0")
+			require.NoError(t, err)
+
+			id, err = s.Save(ctx, newExperiment(1001), Result{}, "127.0.0.1", "")
+			require.NoError(t, err)
+			assert.Len(t, id, 22)
+
+			_, _, err = s.Get(ctx, id)
+			require.NoError(t, err)
+
+			// Without short codes, the public ID is handed out.
+			s.shortCodes = false
+			s.newShortCode = newShortCode
+
+			id, err = s.Save(ctx, newExperiment(1003), Result{}, "127.0.0.1", "")
+			require.NoError(t, err)
+			assert.Len(t, id, 22)
+
+			gotExp, _, err := s.Get(ctx, id)
+			require.NoError(t, err)
+			assert.Equal(t, id, gotExp.ID)
+		})
+	}
+}
+
+func TestStore_TopUserAgents(t *testing.T) {
+	t.Parallel()
+
+	for _, backend := range testBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := backend.newStore(t)
+			ctx := context.Background()
+
+			save := func(url, userAgent string) {
+				t.Helper()
+
+				_, err := s.Save(ctx, Experiment{
+					DynamicConfig: "dynamicConfig",
+					Request:       HTTPRequest{Method: http.MethodGet, URL: url},
+				}, Result{}, "127.0.0.1", userAgent)
+				require.NoError(t, err)
+			}
+
+			save("https://example.com/1", "curl/8.5.0")
+			save("https://example.com/2", "Mozilla/5.0")
+			save("https://example.com/3", "curl/8.5.0")
+			save("https://example.com/4", "")
+
+			// Saving an experiment again keeps the user agent it was first shared with.
+			save("https://example.com/2", "curl/8.5.0")
+
+			// User agents are stored as valid UTF-8, truncated.
+			save("https://example.com/5", "bot/\xff"+strings.Repeat("a", 1000))
+
+			got, err := s.TopUserAgents(ctx, 3)
+			require.NoError(t, err)
+			assert.Equal(t, []UserAgentCount{
+				{UserAgent: "curl/8.5.0", Count: 2},
+				{UserAgent: "", Count: 1},
+				{UserAgent: "Mozilla/5.0", Count: 1},
+			}, got)
+
+			got, err = s.TopUserAgents(ctx, 10)
+			require.NoError(t, err)
+			require.Len(t, got, 4)
+			assert.Equal(t, "bot/"+strings.Repeat("a", 508), got[3].UserAgent)
+		})
+	}
+}
+
+func TestStore_hashedClientIP(t *testing.T) {
+	t.Parallel()
+
+	for _, backend := range testBackends() {
+		t.Run(backend.name, func(t *testing.T) {
+			t.Parallel()
+
+			s := backend.newStore(t)
+			ctx := context.Background()
+
+			// Shared before the salt was set, the experiment keeps the raw client IP.
+			_, err := s.Save(ctx, Experiment{
+				DynamicConfig: "dynamicConfig",
+				Request:       HTTPRequest{Method: http.MethodGet, URL: "https://example.com/raw"},
+			}, Result{}, "192.0.2.1", "")
+			require.NoError(t, err)
+
+			s.clientIPSalt = "salt"
+
+			id, err := s.Save(ctx, Experiment{
+				DynamicConfig: "dynamicConfig",
+				Request:       HTTPRequest{Method: http.MethodGet, URL: "https://example.com"},
+			}, Result{}, "192.0.2.1", "")
+			require.NoError(t, err)
+
+			var clientIP string
+			err = s.db.QueryRowContext(ctx, `SELECT client_ip FROM shared_experiments WHERE public_id = $1`, id).Scan(&clientIP)
+			require.NoError(t, err)
+
+			assert.NotContains(t, clientIP, "192.0.2.1")
+			assert.Regexp(t, `^[0-9a-f]{64}$`, clientIP)
+
+			// Experiments are deleted by client IP using the same salt, along with the ones holding the raw client IP.
+			deleted, err := s.Delete(ctx, "192.0.2.1")
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), deleted)
+		})
+	}
+}
+
+func testBackends() []struct {
+	name     string
+	newStore func(t *testing.T) *Store
+} {
+	return []struct {
+		name     string
+		newStore func(t *testing.T) *Store
+	}{
+		{
+			name: "postgres",
+			newStore: func(t *testing.T) *Store {
+				t.Helper()
+
+				return NewStore(setupTestDB(t), StoreConfig{})
+			},
 		},
-		Logs: []traefik.Log{
-			{
-				Timestamp: time.Now().String(),
-				Level:     traefik.LogLevelInfo,
-				Message:   "message",
-				Error:     "error",
-				Fields:    map[string]interface{}{"key": "value"},
+		{
+			name: "sqlite",
+			newStore: func(t *testing.T) *Store {
+				t.Helper()
+
+				return NewStore(setupSQLiteTestDB(t), StoreConfig{Dialect: database.SQLite})
 			},
 		},
 	}
-	ctx := context.Background()
+}
 
-	// Save the experiment for the first time.
-	firstPublicID, err := s.Save(ctx, experiment, result, "127.0.0.1")
-	require.NoError(t, err)
-	assert.NotEmpty(t, firstPublicID)
+// setupSQLiteTestDB initializes a SQLite test database in a temporary directory.
+func setupSQLiteTestDB(t *testing.T) *sql.DB {
+	t.Helper()
 
-	// Make sure it doesn't save a new entry of the content is similar.
-	secondPublicID, err := s.Save(ctx, experiment, result, "127.0.0.2")
+	db, dialect, err := database.Open("sqlite://" + filepath.Join(t.TempDir(), "test.db"))
 	require.NoError(t, err)
-	assert.Equal(t, firstPublicID, secondPublicID)
 
-	gotExp, gotRes, err := s.Get(ctx, firstPublicID)
-	require.NoError(t, err)
-	//nolint:testifylint // False positive.
-	assert.Equal(t, experiment, gotExp)
-	assert.Equal(t, result, gotRes)
+	t.Cleanup(func() { _ = db.Close() })
+
+	require.NoError(t, database.Migrate(db, dialect))
+
+	return db
 }
 
 // setupTestDB initializes a PostgreSQL test database inside a container.
@@ -0,0 +1,120 @@
+package experiment
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+const (
+	maxVars       = 20
+	maxVarsLength = 2048
+)
+
+// parseVars parses the variables substituted to the placeholders of a dynamic configuration, one "name=value" per
+// line. Names are made of letters, digits and underscores, and don't start with a digit.
+func parseVars(rawVars string) (map[string]string, error) {
+	vars := make(map[string]string)
+	for line := range strings.Lines(rawVars) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		// Values may hold equal signs, such as in query strings.
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf(`invalid variable format, want "name=value", got: %q`, strings.TrimSpace(line))
+		}
+
+		name = strings.TrimSpace(name)
+		value = strings.TrimSpace(value)
+
+		if !isVarName(name) {
+			return nil, fmt.Errorf("invalid variable name %q", name)
+		}
+		if _, ok = vars[name]; ok {
+			return nil, fmt.Errorf("variable %q is defined more than once", name)
+		}
+
+		if len(vars) >= maxVars {
+			return nil, fmt.Errorf("too many variables (max %d)", maxVars)
+		}
+
+		vars[name] = value
+	}
+
+	return vars, nil
+}
+
+// expandPlaceholders substitutes the "${name}" placeholders of the given dynamic configuration with the value of the
+// named variable. "$${" is written as a literal "${", and "${" not followed by a variable name and "}" is left as is,
+// such as the "${1}" references of the redirectRegex middleware. Values are inserted as is and never expanded in
+// turn, and the expansion stops as soon as the dynamic configuration exceeds its maximum length.
+func expandPlaceholders(dynamicConfig string, vars map[string]string) (string, error) {
+	var (
+		b          strings.Builder
+		unresolved []string
+	)
+
+	for rest := dynamicConfig; rest != ""; {
+		i := strings.IndexByte(rest, '$')
+		if i < 0 {
+			b.WriteString(rest)
+
+			break
+		}
+
+		b.WriteString(rest[:i])
+		rest = rest[i:]
+
+		switch {
+		case strings.HasPrefix(rest, "$${"):
+			b.WriteString("${")
+			rest = rest[3:]
+		case strings.HasPrefix(rest, "${"):
+			name, _, ok := strings.Cut(rest[2:], "}")
+			if !ok || !isVarName(name) {
+				b.WriteString("${")
+				rest = rest[2:]
+
+				continue
+			}
+
+			value, ok := vars[name]
+			if placeholder := "${" + name + "}"; !ok && !slices.Contains(unresolved, placeholder) {
+				unresolved = append(unresolved, placeholder)
+			}
+
+			b.WriteString(value)
+			rest = rest[len("${"+name+"}"):]
+		default:
+			b.WriteByte('$')
+			rest = rest[1:]
+		}
+
+		if b.Len() > maxDynamicConfigLength {
+			return "", newTooLargeError("dynamicConfig", "expanded dynamic config", maxDynamicConfigLength)
+		}
+	}
+
+	if len(unresolved) > 0 {
+		return "", newValidationError("dynamicConfig", "unresolved placeholders: %s", strings.Join(unresolved, ", "))
+	}
+
+	return b.String(), nil
+}
+
+// isVarName reports whether the given name is a valid variable name.
+func isVarName(name string) bool {
+	if name == "" || ('0' <= name[0] && name[0] <= '9') {
+		return false
+	}
+
+	for _, c := range name {
+		if c != '_' && !('a' <= c && c <= 'z') && !('A' <= c && c <= 'Z') && !('0' <= c && c <= '9') {
+			return false
+		}
+	}
+
+	return true
+}
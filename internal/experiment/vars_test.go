@@ -0,0 +1,155 @@
+package experiment_test
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/jspdown/traefik-playground/internal/experiment"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMakeExperiment_vars(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		dynamicConfig string
+		vars          string
+		want          string
+	}{
+		{
+			name:          "substituted placeholders",
+			dynamicConfig: "http:\n  routers:\n    ${name}:\n      rule: Host(`${host}`) && PathPrefix(`${path}`)\n      service: whoami@playground\n",
+			vars:          "host=example.com\n path = /api \n\nname=api\n",
+			want:          "http:\n  routers:\n    api:\n      rule: Host(`example.com`) && PathPrefix(`/api`)\n      service: whoami@playground\n",
+		},
+		{
+			name:          "repeated placeholder",
+			dynamicConfig: "http:\n  routers:\n    ${host}:\n      rule: Host(`${host}`)\n      service: whoami@playground\n",
+			vars:          "host=example.com",
+			want:          "http:\n  routers:\n    example.com:\n      rule: Host(`example.com`)\n      service: whoami@playground\n",
+		},
+		{
+			name:          "value holding an equal sign",
+			dynamicConfig: "http:\n  middlewares:\n    redirect:\n      redirectRegex:\n        regex: ^/$\n        replacement: ${target}\n",
+			vars:          "target=/search?q=traefik",
+			want:          "http:\n  middlewares:\n    redirect:\n      redirectRegex:\n        regex: ^/$\n        replacement: /search?q=traefik\n",
+		},
+		{
+			name:          "escaped and non-placeholder dollars",
+			dynamicConfig: "http:\n  middlewares:\n    redirect:\n      redirectRegex:\n        regex: ^/(.*)$\n        replacement: /${prefix}/${1}?v=$${version}\n",
+			vars:          "prefix=v2",
+			want:          "http:\n  middlewares:\n    redirect:\n      redirectRegex:\n        regex: ^/(.*)$\n        replacement: /v2/${1}?v=${version}\n",
+		},
+		{
+			name:          "values aren't expanded in turn",
+			dynamicConfig: "http:\n  middlewares:\n    headers:\n      headers:\n        customResponseHeaders:\n          X-Value: ${a}\n",
+			vars:          "a=${b}\nb=foo",
+			want:          "http:\n  middlewares:\n    headers:\n      headers:\n        customResponseHeaders:\n          X-Value: ${b}\n",
+		},
+		{
+			name:          "no variables",
+			dynamicConfig: "http:\n  middlewares:\n    headers:\n      headers:\n        customResponseHeaders:\n          X-Value: ${host}\n",
+			want:          "http:\n  middlewares:\n    headers:\n      headers:\n        customResponseHeaders:\n          X-Value: ${host}\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := experiment.MakeExperiment(test.dynamicConfig, test.vars, experiment.RawHTTPRequest{
+				Method: http.MethodGet,
+				URL:    "http://example.com",
+			}, experiment.Limits{})
+			require.NoError(t, err)
+
+			assert.Equal(t, test.want, got.DynamicConfig)
+		})
+	}
+}
+
+func TestMakeExperiment_invalidVars(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		dynamicConfig string
+		vars          string
+		wantField     string
+		wantErr       string
+		wantTooLarge  bool
+	}{
+		{
+			name:          "unresolved placeholders",
+			dynamicConfig: "http:\n  routers:\n    ${name}:\n      rule: Host(`${host}`) && Path(`${path}`) && Header(`X-Host`, `${host}`)\n",
+			vars:          "path=/",
+			wantField:     "dynamicConfig",
+			wantErr:       "unresolved placeholders: ${name}, ${host}",
+		},
+		{
+			name:          "missing equal sign",
+			dynamicConfig: "http: {}",
+			vars:          "host=example.com\nfoo",
+			wantField:     "vars",
+			wantErr:       `invalid variable format, want "name=value", got: "foo"`,
+		},
+		{
+			name:          "invalid name",
+			dynamicConfig: "http: {}",
+			vars:          "1host=example.com",
+			wantField:     "vars",
+			wantErr:       `invalid variable name "1host"`,
+		},
+		{
+			name:          "duplicated name",
+			dynamicConfig: "http: {}",
+			vars:          "host=example.com\nhost=example.org",
+			wantField:     "vars",
+			wantErr:       `variable "host" is defined more than once`,
+		},
+		{
+			name:          "too many variables",
+			dynamicConfig: "http: {}",
+			vars:          "a=1\nb=1\nc=1\nd=1\ne=1\nf=1\ng=1\nh=1\ni=1\nj=1\nk=1\nl=1\nm=1\nn=1\no=1\np=1\nq=1\nr=1\ns=1\nt=1\nu=1\n",
+			wantField:     "vars",
+			wantErr:       "too many variables (max 20)",
+		},
+		{
+			name:          "too large variables",
+			dynamicConfig: "http: {}",
+			vars:          "a=" + strings.Repeat("b", 2048),
+			wantField:     "vars",
+			wantErr:       "vars is too large (max: 2048 bytes)",
+			wantTooLarge:  true,
+		},
+		{
+			name:          "too large expansion",
+			dynamicConfig: "# " + strings.Repeat("${a}", 20) + "\nhttp: {}",
+			vars:          "a=" + strings.Repeat("b", 1000),
+			wantField:     "dynamicConfig",
+			wantErr:       "expanded dynamic config is too large (max: 10240 bytes)",
+			wantTooLarge:  true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := experiment.MakeExperiment(test.dynamicConfig, test.vars, experiment.RawHTTPRequest{
+				Method: http.MethodGet,
+				URL:    "http://example.com",
+			}, experiment.Limits{})
+			require.EqualError(t, err, test.wantErr)
+
+			var validationErr *experiment.ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			assert.Equal(t, test.wantField, validationErr.Field)
+			assert.Equal(t, test.wantTooLarge, errors.Is(err, experiment.ErrTooLarge))
+		})
+	}
+}
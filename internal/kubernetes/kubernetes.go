@@ -0,0 +1,248 @@
+// Package kubernetes generates Kubernetes manifests from experiments.
+package kubernetes
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultNamespace is the namespace used when none is provided.
+const DefaultNamespace = "default"
+
+const (
+	traefikAPIVersion = "traefik.io/v1alpha1"
+	backendImage      = "traefik/whoami"
+	backendPort       = 80
+	maxNameLength     = 63
+)
+
+var (
+	invalidNameRegexp = regexp.MustCompile(`[^a-z0-9]+`)                      //nolint:gochecknoglobals // Compiled once.
+	namespaceRegexp   = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]*[a-z0-9])?$`) //nolint:gochecknoglobals // Compiled once.
+)
+
+// manifest is a Kubernetes object.
+type manifest struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Kind       string   `yaml:"kind"`
+	Metadata   metadata `yaml:"metadata"`
+	Spec       any      `yaml:"spec"`
+}
+
+type metadata struct {
+	Name      string            `yaml:"name"`
+	Namespace string            `yaml:"namespace"`
+	Labels    map[string]string `yaml:"labels,omitempty"`
+}
+
+type dynamicConfiguration struct {
+	HTTP struct {
+		Routers     map[string]router         `yaml:"routers"`
+		Middlewares map[string]map[string]any `yaml:"middlewares"`
+		Services    map[string]map[string]any `yaml:"services"`
+	} `yaml:"http"`
+}
+
+type router struct {
+	EntryPoints []string       `yaml:"entryPoints"`
+	Rule        string         `yaml:"rule"`
+	Priority    int            `yaml:"priority"`
+	Middlewares []string       `yaml:"middlewares"`
+	Service     string         `yaml:"service"`
+	TLS         map[string]any `yaml:"tls"`
+}
+
+// Generate creates a multi-document YAML holding the Kubernetes manifests, in the given namespace, equivalent
+// to the HTTP routers and middlewares of the given Traefik dynamic configuration. Each service is backed by
+// a whoami Deployment, and the manifests can be applied with "kubectl apply -f".
+func Generate(dynamicConfig, namespace string) (string, error) {
+	if !ValidNamespace(namespace) {
+		return "", fmt.Errorf("invalid namespace %q", namespace)
+	}
+
+	var config dynamicConfiguration
+	if err := yaml.Unmarshal([]byte(dynamicConfig), &config); err != nil {
+		return "", fmt.Errorf("invalid dynamic configuration: %w", err)
+	}
+
+	var manifests []manifest
+
+	// Services referenced by routers are backed by a whoami Deployment, whether they are declared
+	// in the dynamic configuration or provided by the playground.
+	backends := make(map[string]struct{})
+	for name := range config.HTTP.Services {
+		backends[resourceName(name)] = struct{}{}
+	}
+
+	for _, r := range config.HTTP.Routers {
+		if r.Service != "" {
+			backends[resourceName(r.Service)] = struct{}{}
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(backends)) {
+		manifests = append(manifests, backendManifests(name, namespace)...)
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(config.HTTP.Middlewares)) {
+		manifests = append(manifests, manifest{
+			APIVersion: traefikAPIVersion,
+			Kind:       "Middleware",
+			Metadata:   metadata{Name: resourceName(name), Namespace: namespace},
+			Spec:       middlewareSpec(config.HTTP.Middlewares[name]),
+		})
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(config.HTTP.Routers)) {
+		manifests = append(manifests, manifest{
+			APIVersion: traefikAPIVersion,
+			Kind:       "IngressRoute",
+			Metadata:   metadata{Name: resourceName(name), Namespace: namespace},
+			Spec:       ingressRouteSpec(config.HTTP.Routers[name]),
+		})
+	}
+
+	return marshalManifests(manifests)
+}
+
+// ValidNamespace reports whether the given namespace is a valid Kubernetes namespace name.
+func ValidNamespace(namespace string) bool {
+	return len(namespace) <= maxNameLength && namespaceRegexp.MatchString(namespace)
+}
+
+func backendManifests(name, namespace string) []manifest {
+	labels := map[string]string{"app": name}
+
+	return []manifest{
+		{
+			APIVersion: "apps/v1",
+			Kind:       "Deployment",
+			Metadata:   metadata{Name: name, Namespace: namespace, Labels: labels},
+			Spec: map[string]any{
+				"replicas": 1,
+				"selector": map[string]any{"matchLabels": labels},
+				"template": map[string]any{
+					"metadata": map[string]any{"labels": labels},
+					"spec": map[string]any{
+						"containers": []any{
+							map[string]any{
+								"name":  name,
+								"image": backendImage,
+								"ports": []any{map[string]any{"containerPort": backendPort}},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			APIVersion: "v1",
+			Kind:       "Service",
+			Metadata:   metadata{Name: name, Namespace: namespace, Labels: labels},
+			Spec: map[string]any{
+				"selector": labels,
+				"ports":    []any{map[string]any{"port": backendPort, "targetPort": backendPort}},
+			},
+		},
+	}
+}
+
+// middlewareSpec converts a middleware of the dynamic configuration into a Middleware spec. Both share the same
+// structure, except for the references to other middlewares.
+func middlewareSpec(middleware map[string]any) map[string]any {
+	chain, ok := middleware["chain"].(map[string]any)
+	if !ok {
+		return middleware
+	}
+
+	names, _ := chain["middlewares"].([]any)
+
+	refs := make([]any, 0, len(names))
+	for _, name := range names {
+		refs = append(refs, map[string]any{"name": resourceName(fmt.Sprint(name))})
+	}
+
+	spec := maps.Clone(middleware)
+	spec["chain"] = map[string]any{"middlewares": refs}
+
+	return spec
+}
+
+func ingressRouteSpec(r router) map[string]any {
+	route := map[string]any{
+		"kind":  "Rule",
+		"match": r.Rule,
+	}
+
+	if r.Priority != 0 {
+		route["priority"] = r.Priority
+	}
+
+	if len(r.Middlewares) > 0 {
+		middlewares := make([]any, 0, len(r.Middlewares))
+		for _, name := range r.Middlewares {
+			middlewares = append(middlewares, map[string]any{"name": resourceName(name)})
+		}
+
+		route["middlewares"] = middlewares
+	}
+
+	if r.Service != "" {
+		route["services"] = []any{map[string]any{"name": resourceName(r.Service), "port": backendPort}}
+	}
+
+	spec := map[string]any{"routes": []any{route}}
+
+	if len(r.EntryPoints) > 0 {
+		spec["entryPoints"] = r.EntryPoints
+	}
+
+	if r.TLS != nil {
+		spec["tls"] = r.TLS
+	}
+
+	return spec
+}
+
+// resourceName turns the given dynamic configuration reference, such as "whoami@playground",
+// into a valid Kubernetes resource name.
+func resourceName(ref string) string {
+	name, _, _ := strings.Cut(ref, "@")
+	name = strings.Trim(invalidNameRegexp.ReplaceAllString(strings.ToLower(name), "-"), "-")
+
+	if len(name) > maxNameLength {
+		name = strings.TrimRight(name[:maxNameLength], "-")
+	}
+
+	return name
+}
+
+func marshalManifests(manifests []manifest) (string, error) {
+	if len(manifests) == 0 {
+		return "", errors.New("no HTTP router, middleware or service to export")
+	}
+
+	var buf bytes.Buffer
+
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+
+	for _, m := range manifests {
+		if err := encoder.Encode(m); err != nil {
+			return "", fmt.Errorf("encoding %s %q: %w", m.Kind, m.Metadata.Name, err)
+		}
+	}
+
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("closing encoder: %w", err)
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,83 @@
+package kubernetes_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jspdown/traefik-playground/internal/kubernetes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		inputFile  string
+		outputFile string
+	}{
+		{
+			name:       "references whoami@playground",
+			inputFile:  "whoami-playground.dynamic.yaml",
+			outputFile: "whoami-playground.expected.yaml",
+		},
+		{
+			name:       "chain middleware and user-defined service",
+			inputFile:  "chain.dynamic.yaml",
+			outputFile: "chain.expected.yaml",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			dynamicConfig, err := os.ReadFile(filepath.Join("testdata", test.inputFile))
+			require.NoError(t, err)
+
+			result, err := kubernetes.Generate(string(dynamicConfig), "playground")
+			require.NoError(t, err)
+
+			expected, err := os.ReadFile(filepath.Join("testdata", test.outputFile))
+			require.NoError(t, err)
+
+			assert.Equal(t, string(expected), result)
+		})
+	}
+}
+
+func TestGenerate_errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		dynamicConfig string
+		namespace     string
+		wantErr       error
+	}{
+		{
+			name:          "invalid namespace",
+			dynamicConfig: "http: {}",
+			namespace:     "My_Namespace",
+			wantErr:       errors.New(`invalid namespace "My_Namespace"`),
+		},
+		{
+			name:          "nothing to export",
+			dynamicConfig: "tcp: {}",
+			namespace:     kubernetes.DefaultNamespace,
+			wantErr:       errors.New("no HTTP router, middleware or service to export"),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := kubernetes.Generate(test.dynamicConfig, test.namespace)
+			require.EqualError(t, err, test.wantErr.Error())
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package matcher
+
+import (
+	"fmt"
+	"maps"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/traefik/traefik/v3/pkg/config/dynamic"
+	httpmuxer "github.com/traefik/traefik/v3/pkg/muxer/http"
+	"gopkg.in/yaml.v3"
+)
+
+// providerName is the provider qualifying the names of the routers, middlewares and services, as in the playground.
+const providerName = "file"
+
+// httpEntryPoint is the entrypoint of the routers without entrypoints, looked up first.
+const httpEntryPoint = "web"
+
+// clientPort is the port of the remote address of the requests having a client IP, as in the playground.
+const clientPort = "12345"
+
+// Request is the HTTP request whose routing is evaluated. Its fields are those of an experiment request.
+type Request struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	// Host overrides the host of the URL, such as to send the request to an IP with a virtual host.
+	Host string `json:"host,omitempty"`
+	// Scheme is the scheme Traefik sees the request with, forwarded as X-Forwarded-Proto.
+	Scheme string `json:"scheme,omitempty"`
+	// ClientIP is the IP address the request originates from.
+	ClientIP string      `json:"clientIP,omitempty"`
+	Headers  http.Header `json:"headers,omitempty"`
+}
+
+// Result tells how a Request is routed.
+type Result struct {
+	// Matched tells whether a router matched the request.
+	Matched bool `json:"matched"`
+	// EntryPoint is the entrypoint receiving the request: the first one, "web" first, with a router matching it.
+	EntryPoint string `json:"entryPoint,omitempty"`
+	// Router is the qualified name of the router which matched the request.
+	Router string `json:"router,omitempty"`
+	// Rule and Priority are the rule and the effective priority of the matched router.
+	Rule     string `json:"rule,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+	// Middlewares are the qualified names of the middlewares of the matched router, in execution order. The
+	// middlewares of a chain directly follow the chain itself.
+	Middlewares []string `json:"middlewares,omitempty"`
+	// Service is the qualified name of the service of the matched router.
+	Service string `json:"service,omitempty"`
+	// Errors explain why routers were left out, such as when their rule is invalid.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Evaluate returns which router of the given dynamic configuration, in YAML, matches the given request. Only the
+// rules are evaluated: unlike a run of the experiment, the middlewares aren't run, and a request a middleware would
+// reject is still reported as matched. TLS routers are left out, as the requests of the playground are plain HTTP.
+// Unlike the fake Traefik instances, it works on the dynamic configuration as is, without a router manager, so that
+// the routing of simple experiments can be evaluated in the browser.
+func Evaluate(dynamicConfig string, req Request) (Result, error) {
+	var config dynamic.Configuration
+	if err := yaml.Unmarshal([]byte(dynamicConfig), &config); err != nil {
+		return Result{}, fmt.Errorf("decoding dynamic configuration: %w", err)
+	}
+
+	httpReq, err := newHTTPRequest(req)
+	if err != nil {
+		return Result{}, err
+	}
+
+	if config.HTTP == nil {
+		return Result{}, nil
+	}
+
+	parser, err := httpmuxer.NewSyntaxParser()
+	if err != nil {
+		return Result{}, fmt.Errorf("creating syntax parser: %w", err)
+	}
+
+	var (
+		result   Result
+		matchers = make(map[string]*Matcher)
+	)
+
+	for _, name := range slices.Sorted(maps.Keys(config.HTTP.Routers)) {
+		router := config.HTTP.Routers[name]
+		if router == nil || router.TLS != nil {
+			continue
+		}
+
+		if router.Service == "" {
+			result.Errors = append(result.Errors, fmt.Sprintf("router %q: the service is missing", name))
+
+			continue
+		}
+
+		// Routers without entrypoints are on every entrypoint, and "web" is looked up first.
+		entryPoints := router.EntryPoints
+		if len(entryPoints) == 0 {
+			entryPoints = []string{httpEntryPoint}
+		}
+
+		for _, entryPoint := range entryPoints {
+			matcher, ok := matchers[entryPoint]
+			if !ok {
+				matcher = New(parser)
+				matchers[entryPoint] = matcher
+			}
+
+			if err = matcher.AddRoute(name, router.Rule, router.RuleSyntax, router.Priority); err != nil {
+				result.Errors = append(result.Errors, fmt.Sprintf("router %q: %s", name, err))
+
+				break
+			}
+		}
+	}
+
+	for _, entryPoint := range sortedEntryPoints(matchers) {
+		matched := matchers[entryPoint].Match(httpReq)
+		if matched == "" {
+			continue
+		}
+
+		router := config.HTTP.Routers[matched]
+
+		result.Matched = true
+		result.EntryPoint = entryPoint
+		result.Router = qualify(matched)
+		result.Rule, result.Priority = matchers[entryPoint].Rule(matched)
+		result.Middlewares = middlewareChain(config.HTTP.Middlewares, router.Middlewares, nil)
+		result.Service = qualify(router.Service)
+
+		break
+	}
+
+	return result, nil
+}
+
+// newHTTPRequest makes the HTTP request Traefik receives for the given Request.
+func newHTTPRequest(req Request) (*http.Request, error) {
+	httpReq, err := http.NewRequest(req.Method, req.URL, http.NoBody)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+
+	httpReq.Header = req.Headers.Clone()
+	if httpReq.Header == nil {
+		httpReq.Header = make(http.Header)
+	}
+
+	if req.Host != "" {
+		httpReq.Host = req.Host
+	}
+
+	if req.Scheme != "" {
+		httpReq.URL.Scheme = req.Scheme
+		httpReq.Header.Set("X-Forwarded-Proto", req.Scheme)
+	}
+
+	if req.ClientIP != "" {
+		httpReq.RemoteAddr = net.JoinHostPort(req.ClientIP, clientPort)
+	}
+
+	return httpReq, nil
+}
+
+// sortedEntryPoints returns the names of the given entrypoints in the order they are looked up for a router: "web"
+// first, then by name.
+func sortedEntryPoints(matchers map[string]*Matcher) []string {
+	names := slices.Sorted(maps.Keys(matchers))
+	if i := slices.Index(names, httpEntryPoint); i > 0 {
+		names = slices.Concat([]string{httpEntryPoint}, names[:i], names[i+1:])
+	}
+
+	return names
+}
+
+// middlewareChain returns the qualified names of the given middlewares in execution order, expanding the chains
+// defined in the given middlewares. Recursive chains are only expanded once.
+func middlewareChain(middlewares map[string]*dynamic.Middleware, names, parents []string) []string {
+	var chain []string
+	for _, name := range names {
+		qualifiedName := qualify(name)
+		if slices.Contains(parents, qualifiedName) {
+			continue
+		}
+
+		chain = append(chain, qualifiedName)
+
+		middleware, ok := middlewares[strings.TrimSuffix(qualifiedName, "@"+providerName)]
+		if !ok || middleware == nil || middleware.Chain == nil {
+			continue
+		}
+
+		chain = append(chain, middlewareChain(middlewares, middleware.Chain.Middlewares, append(parents, qualifiedName))...)
+	}
+
+	return chain
+}
+
+// qualify qualifies the given name with the provider of the dynamic configuration, unless already qualified.
+func qualify(name string) string {
+	if strings.Contains(name, "@") {
+		return name
+	}
+
+	return name + "@" + providerName
+}
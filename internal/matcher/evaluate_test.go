@@ -0,0 +1,172 @@
+package matcher_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/jspdown/traefik-playground/internal/matcher"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvaluate(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		dynamicConfig string
+		req           matcher.Request
+		want          matcher.Result
+	}{
+		{
+			name: "highest priority router",
+			dynamicConfig: `
+http:
+  routers:
+    api:
+      rule: PathPrefix(` + "`/api`" + `)
+      service: whoami@playground
+      middlewares: [secured]
+    catchAll:
+      rule: PathPrefix(` + "`/`" + `)
+      service: whoami@playground
+  middlewares:
+    secured:
+      chain:
+        middlewares: [auth, secured]
+    auth:
+      basicAuth:
+        users: ["user:$apr1$H6uskkkW$IgXLP6ewTrSuBkTrqE8wj/"]
+`,
+			req: matcher.Request{Method: http.MethodGet, URL: "http://example.com/api/users"},
+			want: matcher.Result{
+				Matched:     true,
+				EntryPoint:  "web",
+				Router:      "api@file",
+				Rule:        "PathPrefix(`/api`)",
+				Priority:    18,
+				Middlewares: []string{"secured@file", "auth@file"},
+				Service:     "whoami@playground",
+			},
+		},
+		{
+			name: "host override and headers",
+			dynamicConfig: `
+http:
+  routers:
+    admin:
+      rule: Host(` + "`admin.example.com`" + `) && Header(` + "`X-Admin`, `true`" + `)
+      priority: 100
+      service: whoami@playground
+`,
+			req: matcher.Request{
+				Method:  http.MethodGet,
+				URL:     "http://10.0.0.1/",
+				Host:    "admin.example.com",
+				Headers: http.Header{"X-Admin": {"true"}},
+			},
+			want: matcher.Result{
+				Matched:    true,
+				EntryPoint: "web",
+				Router:     "admin@file",
+				Rule:       "Host(`admin.example.com`) && Header(`X-Admin`, `true`)",
+				Priority:   100,
+				Service:    "whoami@playground",
+			},
+		},
+		{
+			name: "client IP",
+			dynamicConfig: `
+http:
+  routers:
+    internal:
+      rule: ClientIP(` + "`10.0.0.0/8`" + `)
+      service: whoami@playground
+`,
+			req: matcher.Request{Method: http.MethodGet, URL: "http://example.com/", ClientIP: "10.1.2.3"},
+			want: matcher.Result{
+				Matched:    true,
+				EntryPoint: "web",
+				Router:     "internal@file",
+				Rule:       "ClientIP(`10.0.0.0/8`)",
+				Priority:   22,
+				Service:    "whoami@playground",
+			},
+		},
+		{
+			name: "other entrypoint",
+			dynamicConfig: `
+http:
+  routers:
+    web:
+      rule: Path(` + "`/web`" + `)
+      service: whoami@playground
+    websecure:
+      entryPoints: [websecure]
+      rule: Path(` + "`/secure`" + `)
+      service: api
+`,
+			req: matcher.Request{Method: http.MethodGet, URL: "http://example.com/secure"},
+			want: matcher.Result{
+				Matched:    true,
+				EntryPoint: "websecure",
+				Router:     "websecure@file",
+				Rule:       "Path(`/secure`)",
+				Priority:   15,
+				Service:    "api@file",
+			},
+		},
+		{
+			name: "no match and invalid routers",
+			dynamicConfig: `
+http:
+  routers:
+    api:
+      rule: Path(` + "`/api`" + `)
+      service: whoami@playground
+    invalid:
+      rule: Foo(` + "`bar`" + `)
+      service: whoami@playground
+    noService:
+      rule: PathPrefix(` + "`/`" + `)
+    tls:
+      rule: PathPrefix(` + "`/`" + `)
+      service: whoami@playground
+      tls: {}
+`,
+			req: matcher.Request{Method: http.MethodGet, URL: "http://example.com/"},
+			want: matcher.Result{
+				Errors: []string{
+					`router "invalid": error while parsing rule Foo(` + "`bar`" + `): parsing rule Foo(` + "`bar`" + `): unsupported function: Foo`,
+					`router "noService": the service is missing`,
+				},
+			},
+		},
+		{
+			name:          "no HTTP configuration",
+			dynamicConfig: "tcp: {}",
+			req:           matcher.Request{Method: http.MethodGet, URL: "http://example.com/"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := matcher.Evaluate(test.dynamicConfig, test.req)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestEvaluate_invalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := matcher.Evaluate("http: [", matcher.Request{Method: http.MethodGet, URL: "http://example.com/"})
+	require.ErrorContains(t, err, "decoding dynamic configuration")
+
+	_, err = matcher.Evaluate("http: {}", matcher.Request{Method: "BAD METHOD", URL: "http://example.com/"})
+	require.ErrorContains(t, err, "creating request")
+}
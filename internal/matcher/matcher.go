@@ -0,0 +1,184 @@
+// Package matcher tells which router matches an HTTP request, the way Traefik's muxer routes requests, without
+// handling them. It only depends on the parts of Traefik which compile to WebAssembly, so that the fake Traefik
+// instances of the tester and the routing evaluation running in the browser share the same router matching.
+package matcher
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+
+	"github.com/traefik/traefik/v3/pkg/middlewares/requestdecorator"
+	httpmuxer "github.com/traefik/traefik/v3/pkg/muxer/http"
+)
+
+// Evaluation explains how a router was considered to route a request.
+type Evaluation struct {
+	// Router is the qualified name of the router.
+	Router string `json:"router"`
+	// Rule and Priority are the rule of the router and its effective priority.
+	Rule     string `json:"rule,omitempty"`
+	Priority int    `json:"priority,omitempty"`
+	// Evaluated tells whether the rule was evaluated against the request. Disabled and TLS routers, as well as the
+	// routers of other entrypoints, aren't.
+	Evaluated bool `json:"evaluated"`
+	// Matched tells whether the rule matched the request.
+	Matched bool `json:"matched"`
+	// Selected tells whether the router handled the request: the matching router with the highest priority.
+	Selected bool `json:"selected,omitempty"`
+	// Reason explains why the router was or wasn't selected.
+	Reason string `json:"reason"`
+}
+
+type matchedRouterKey struct{}
+
+// Matcher finds which router of an entrypoint matches a request.
+type Matcher struct {
+	parser       httpmuxer.SyntaxParser
+	reqDecorator *requestdecorator.RequestDecorator
+	muxer        *httpmuxer.Muxer
+	handler      http.Handler
+	rules        map[string]routerRule
+
+	// routers are the routers of the entrypoint, by decreasing priority, each matching requests on its own.
+	routers []routerEvaluator
+	// skipped explains why the other routers aren't evaluated, in the order they were skipped.
+	skipped []Evaluation
+}
+
+// routerEvaluator tells whether the rule of a single router matches a request.
+type routerEvaluator struct {
+	name     string
+	rule     string
+	priority int
+	handler  http.Handler
+}
+
+// routerRule is the rule of a router along with its effective priority.
+type routerRule struct {
+	rule     string
+	priority int
+}
+
+// New creates a new Matcher without routers, parsing the rules with the given parser.
+func New(parser httpmuxer.SyntaxParser) *Matcher {
+	reqDecorator := requestdecorator.New(nil)
+	muxer := httpmuxer.NewMuxer(parser)
+
+	return &Matcher{
+		parser:       parser,
+		reqDecorator: reqDecorator,
+		muxer:        muxer,
+		handler:      decorated(reqDecorator, muxer),
+		rules:        make(map[string]routerRule),
+	}
+}
+
+// AddRoute adds the given router, matching the requests with the given rule and syntax. Without priority, the
+// priority is computed from the length of the rule, as Traefik does. Routers of the same priority are matched in
+// the order they are added. An error is returned, and the router left out, if the rule is invalid.
+func (m *Matcher) AddRoute(name, rule, syntax string, priority int) error {
+	if priority == 0 {
+		priority = httpmuxer.GetRulePriority(rule)
+	}
+
+	handler := http.HandlerFunc(func(_ http.ResponseWriter, req *http.Request) {
+		if matched, ok := req.Context().Value(matchedRouterKey{}).(*string); ok {
+			*matched = name
+		}
+	})
+
+	if err := m.muxer.AddRoute(rule, syntax, priority, handler); err != nil {
+		return err
+	}
+
+	routerMuxer := httpmuxer.NewMuxer(m.parser)
+	if err := routerMuxer.AddRoute(rule, syntax, priority, handler); err != nil {
+		return err
+	}
+
+	m.routers = append(m.routers, routerEvaluator{
+		name:     name,
+		rule:     rule,
+		priority: priority,
+		handler:  decorated(m.reqDecorator, routerMuxer),
+	})
+	slices.SortStableFunc(m.routers, func(a, b routerEvaluator) int {
+		return cmp.Compare(b.priority, a.priority)
+	})
+
+	m.rules[name] = routerRule{rule: rule, priority: priority}
+
+	return nil
+}
+
+// Skip adds the given router without evaluating it, reporting the given reason.
+func (m *Matcher) Skip(name, rule, reason string) {
+	m.skipped = append(m.skipped, Evaluation{Router: name, Rule: rule, Reason: reason})
+}
+
+// Rule returns the rule of the given router and its priority, as used to route the requests.
+func (m *Matcher) Rule(routerName string) (string, int) {
+	rule := m.rules[routerName]
+
+	return rule.rule, rule.priority
+}
+
+// Match returns the name of the router matching the given request, or an empty string if none does.
+func (m *Matcher) Match(req *http.Request) string {
+	return match(m.handler, req)
+}
+
+// Explain evaluates the rule of each router against the given request, and explains why the given selected router,
+// as returned by Match, won over the others. The evaluated routers come first, in the order the muxer tries them,
+// followed by the skipped routers.
+func (m *Matcher) Explain(req *http.Request, selected string) []Evaluation {
+	evaluations := make([]Evaluation, 0, len(m.routers)+len(m.skipped))
+
+	for _, router := range m.routers {
+		evaluation := Evaluation{
+			Router:    router.name,
+			Rule:      router.rule,
+			Priority:  router.priority,
+			Evaluated: true,
+			Matched:   match(router.handler, req) == router.name,
+		}
+
+		switch {
+		case !evaluation.Matched:
+			evaluation.Reason = "the rule didn't match"
+		case router.name == selected:
+			evaluation.Selected = true
+			evaluation.Reason = "the rule matched, with the highest priority among the matching routers"
+		case m.rules[selected].priority > router.priority:
+			evaluation.Reason = fmt.Sprintf("the rule matched, but the router %q has a higher priority", selected)
+		default:
+			evaluation.Reason = fmt.Sprintf("the rule matched, but the router %q has the same priority and was tried first", selected)
+		}
+
+		evaluations = append(evaluations, evaluation)
+	}
+
+	return append(evaluations, m.skipped...)
+}
+
+// decorated returns an http.Handler passing the requests to the given muxer once decorated, as Traefik does before
+// routing them.
+func decorated(reqDecorator *requestdecorator.RequestDecorator, muxer *httpmuxer.Muxer) http.Handler {
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		reqDecorator.ServeHTTP(rw, req, muxer.ServeHTTP)
+	})
+}
+
+// match returns the name of the router the given handler routes the given request to, or an empty string if none.
+func match(handler http.Handler, req *http.Request) string {
+	var matched string
+
+	ctx := context.WithValue(req.Context(), matchedRouterKey{}, &matched)
+	handler.ServeHTTP(httptest.NewRecorder(), req.WithContext(ctx))
+
+	return matched
+}
@@ -0,0 +1,138 @@
+package traefik
+
+import (
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// LineRange is a range of lines of a YAML document. Lines start at 1 and End is included.
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// annotatedLogFields maps the log fields naming a router or a service to the section of the dynamic configuration
+// defining them.
+//
+//nolint:gochecknoglobals // Read-only.
+var annotatedLogFields = map[string]string{
+	"routerName":  "routers",
+	"serviceName": "services",
+}
+
+// AnnotateLogs locates, in the given dynamic configuration, the definition of the routers and services named by
+// the routerName and serviceName fields of the given logs. Definitions are returned keyed by log index, then by
+// field. Names which aren't defined by the dynamic configuration, such as those of the services provided by the
+// playground, are left out, as well as every name when the dynamic configuration can't be parsed.
+func AnnotateLogs(dynamicConfig string, logs []Log) map[int]map[string]LineRange {
+	var definitions map[string]map[string]LineRange
+
+	annotations := make(map[int]map[string]LineRange)
+	for i, l := range logs {
+		for field, section := range annotatedLogFields {
+			name, ok := l.Fields[field].(string)
+			if !ok {
+				continue
+			}
+
+			name, ok = localName(name)
+			if !ok {
+				continue
+			}
+
+			// Only parse the dynamic configuration once a log needs it.
+			if definitions == nil {
+				definitions = locateDefinitions(dynamicConfig)
+			}
+
+			lines, ok := definitions[section][name]
+			if !ok {
+				continue
+			}
+
+			if annotations[i] == nil {
+				annotations[i] = make(map[string]LineRange)
+			}
+			annotations[i][field] = lines
+		}
+	}
+
+	return annotations
+}
+
+// localName returns the name, as written in the dynamic configuration, of the given qualified router or service name.
+// It returns false when the name belongs to another provider.
+func localName(qualifiedName string) (string, bool) {
+	name, provider, qualified := strings.Cut(qualifiedName, "@")
+	if qualified && provider != providerName {
+		return "", false
+	}
+
+	return name, name != ""
+}
+
+// locateDefinitions returns the lines of the routers and services defined by the given dynamic configuration,
+// keyed by section then name. HTTP definitions take precedence over TCP ones, which take precedence over UDP ones.
+func locateDefinitions(dynamicConfig string) map[string]map[string]LineRange {
+	definitions := make(map[string]map[string]LineRange)
+	for _, section := range annotatedLogFields {
+		definitions[section] = make(map[string]LineRange)
+	}
+
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(dynamicConfig), &document); err != nil {
+		return definitions
+	}
+
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		return definitions
+	}
+
+	for _, protocol := range []string{"http", "tcp", "udp"} {
+		_, protocolNode := mappingValue(document.Content[0], protocol)
+
+		for section := range definitions {
+			_, sectionNode := mappingValue(protocolNode, section)
+			if sectionNode == nil || sectionNode.Kind != yaml.MappingNode {
+				continue
+			}
+
+			for i := 0; i+1 < len(sectionNode.Content); i += 2 {
+				key, value := sectionNode.Content[i], sectionNode.Content[i+1]
+				if _, ok := definitions[section][key.Value]; ok {
+					continue
+				}
+
+				definitions[section][key.Value] = LineRange{Start: key.Line, End: max(key.Line, lastLine(value))}
+			}
+		}
+	}
+
+	return definitions
+}
+
+// mappingValue returns the key and value nodes of the given key in the given mapping node, or nil if it's missing.
+func mappingValue(node *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i], node.Content[i+1]
+		}
+	}
+
+	return nil, nil
+}
+
+// lastLine returns the last line the given node, or one of its descendants, starts on.
+func lastLine(node *yaml.Node) int {
+	line := node.Line
+	for _, child := range node.Content {
+		line = max(line, lastLine(child))
+	}
+
+	return line
+}
@@ -0,0 +1,70 @@
+package traefik
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnotateLogs(t *testing.T) {
+	t.Parallel()
+
+	dynamicConfig := `http:
+  routers:
+    api:
+      rule: PathPrefix(` + "`/api`" + `)
+      service: api
+    web: {rule: "PathPrefix(` + "`/`" + `)", service: whoami@playground}
+  services:
+    api:
+      loadBalancer:
+        servers:
+          - url: http://whoami
+
+tcp:
+  routers:
+    api:
+      rule: HostSNI(` + "`*`" + `)
+      service: db
+  services:
+    db:
+      loadBalancer:
+        servers:
+          - address: whoami:8080
+`
+
+	logs := []Log{
+		{Message: "Starting", Fields: map[string]interface{}{"providerName": "file"}},
+		{Message: "Routing", Fields: map[string]interface{}{"routerName": "api@file", "serviceName": "api@file"}},
+		{Message: "Routing", Fields: map[string]interface{}{"routerName": "web@file", "serviceName": "whoami@playground"}},
+		{Message: "Dialing", Fields: map[string]interface{}{"serviceName": "db@file"}},
+		{Message: "Unknown", Fields: map[string]interface{}{"routerName": "missing@file", "serviceName": 42}},
+		{Message: "Internal", Fields: map[string]interface{}{"routerName": "api@internal"}},
+	}
+
+	got := AnnotateLogs(dynamicConfig, logs)
+
+	assert.Equal(t, map[int]map[string]LineRange{
+		// HTTP definitions take precedence over the TCP router with the same name.
+		1: {
+			"routerName":  {Start: 3, End: 5},
+			"serviceName": {Start: 8, End: 11},
+		},
+		// Flow mappings are defined on a single line, the playground services are not part of the configuration.
+		2: {
+			"routerName": {Start: 6, End: 6},
+		},
+		3: {
+			"serviceName": {Start: 19, End: 22},
+		},
+	}, got)
+}
+
+func TestAnnotateLogs_invalidConfig(t *testing.T) {
+	t.Parallel()
+
+	logs := []Log{{Fields: map[string]interface{}{"routerName": "api@file"}}}
+
+	assert.Empty(t, AnnotateLogs("http: [", logs))
+	assert.Empty(t, AnnotateLogs("", logs))
+}
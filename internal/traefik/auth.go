@@ -0,0 +1,57 @@
+package traefik
+
+import (
+	"net/http"
+	"net/http/httptest"
+)
+
+// defaultAuthHeader is the header checked by the Auth server when no other header is configured.
+const defaultAuthHeader = "Authorization"
+
+// Auth is a fake authentication server meant to be used as a forwardAuth address.
+// The decision is configured through the path of the address:
+//   - "/allow" always accepts the request.
+//   - "/deny" always rejects the request.
+//   - Any other path accepts the request only if it carries the header given by the "header"
+//     query parameter (Authorization by default).
+type Auth struct{}
+
+// NewAuth creates a new Auth server.
+func NewAuth() *httptest.Server {
+	return httptest.NewServer(newAuthHandler())
+}
+
+func newAuthHandler() http.Handler {
+	s := &Auth{}
+
+	handler := http.NewServeMux()
+	handler.HandleFunc("/allow", s.allow)
+	handler.HandleFunc("/deny", s.deny)
+	handler.HandleFunc("/", s.handle)
+
+	return handler
+}
+
+func (s *Auth) handle(rw http.ResponseWriter, req *http.Request) {
+	header := req.URL.Query().Get("header")
+	if header == "" {
+		header = defaultAuthHeader
+	}
+
+	if req.Header.Get(header) == "" {
+		s.deny(rw, req)
+
+		return
+	}
+
+	s.allow(rw, req)
+}
+
+func (s *Auth) allow(rw http.ResponseWriter, _ *http.Request) {
+	rw.Header().Set("X-Auth-User", "playground")
+	rw.WriteHeader(http.StatusOK)
+}
+
+func (s *Auth) deny(rw http.ResponseWriter, _ *http.Request) {
+	rw.WriteHeader(http.StatusUnauthorized)
+}
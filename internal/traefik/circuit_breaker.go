@@ -0,0 +1,67 @@
+package traefik
+
+import "regexp"
+
+// CircuitBreakerState is the state of a circuitBreaker middleware.
+type CircuitBreakerState string
+
+// List of supported CircuitBreakerState values, named after the Traefik documentation.
+const (
+	// CircuitBreakerClosed is the state in which requests are forwarded while the expression is watched.
+	CircuitBreakerClosed CircuitBreakerState = "closed"
+	// CircuitBreakerOpen is the state in which requests are answered by the fallback.
+	CircuitBreakerOpen CircuitBreakerState = "open"
+	// CircuitBreakerRecovering is the state in which requests are progressively forwarded again.
+	CircuitBreakerRecovering CircuitBreakerState = "recovering"
+)
+
+// CircuitBreakerTransition is a change of state of a circuitBreaker middleware.
+type CircuitBreakerTransition struct {
+	// Middleware is the qualified name of the circuitBreaker middleware.
+	Middleware string              `json:"middleware"`
+	From       CircuitBreakerState `json:"from"`
+	To         CircuitBreakerState `json:"to"`
+}
+
+// circuitBreakerTransitionRe matches the debug log emitted by a circuitBreaker middleware when its state changes.
+//
+//nolint:gochecknoglobals // Read-only.
+var circuitBreakerTransitionRe = regexp.MustCompile(`^CircuitBreaker\(state=(\w+)[^)]*\) setting state to (\w+)`)
+
+// circuitBreakerStates maps the states logged by the circuit breaker implementation to CircuitBreakerState values.
+//
+//nolint:gochecknoglobals // Read-only.
+var circuitBreakerStates = map[string]CircuitBreakerState{
+	"standby":    CircuitBreakerClosed,
+	"tripped":    CircuitBreakerOpen,
+	"recovering": CircuitBreakerRecovering,
+}
+
+// CircuitBreakerTransitions returns the state transitions of the circuitBreaker middlewares found in the given logs,
+// in order. Transitions logged in truncated logs are missing.
+func CircuitBreakerTransitions(logs []Log) []CircuitBreakerTransition {
+	var transitions []CircuitBreakerTransition
+
+	for _, l := range logs {
+		matches := circuitBreakerTransitionRe.FindStringSubmatch(l.Message)
+		if matches == nil {
+			continue
+		}
+
+		from, fromOK := circuitBreakerStates[matches[1]]
+		to, toOK := circuitBreakerStates[matches[2]]
+		if !fromOK || !toOK {
+			continue
+		}
+
+		middleware, _ := l.Fields["middlewareName"].(string)
+
+		transitions = append(transitions, CircuitBreakerTransition{
+			Middleware: middleware,
+			From:       from,
+			To:         to,
+		})
+	}
+
+	return transitions
+}
@@ -4,10 +4,14 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os/exec"
+	"slices"
+	"time"
 
 	"github.com/jspdown/traefik-playground/internal/command"
 	"github.com/rs/zerolog/log"
@@ -15,39 +19,186 @@ import (
 
 var _ command.Command = (*Command)(nil)
 
+// TesterTimeoutExitCode is the exit status of a tester process giving up as its timeout elapsed, like timeout(1).
+const TesterTimeoutExitCode = 124
+
+// TesterError indicates that the tester running a Command failed. The reason follows the logs of the Command.
+type TesterError struct {
+	// Reason is why the tester failed, such as its exit status.
+	Reason string
+	// TimedOut tells whether the tester gave up as its timeout elapsed.
+	TimedOut bool
+}
+
+func (e *TesterError) Error() string {
+	if e.TimedOut {
+		return "tester timed out: " + e.Reason
+	}
+
+	return "tester failed: " + e.Reason
+}
+
+// Unwrap returns context.DeadlineExceeded when the tester timed out, so that it's told apart from other failures.
+func (e *TesterError) Unwrap() error {
+	if e.TimedOut {
+		return context.DeadlineExceeded
+	}
+
+	return nil
+}
+
 // Command spawns a fake Traefik instance using a given dynamic configuration and sends an HTTP request.
 type Command struct {
 	dynamicConfig string
 	request       *http.Request
+	options       CommandOptions
 
 	stdout bytes.Buffer
-	stderr bytes.Buffer
+	// stderr keeps the last MaxLogSize bytes of logs, so that verbose runs don't hold all their logs in memory.
+	stderr logTail
+
+	// streamReader and streamWriter are only set on Commands streaming their response.
+	streamReader *io.PipeReader
+	streamWriter *io.PipeWriter
+
+	// warmPool is only set on Commands running on a WarmPool, see UseWarmPool.
+	warmPool *WarmPool
 }
 
-// NewCommand creates a new Command.
-func NewCommand(dynamicConfig string, req *http.Request) (*Command, error) {
-	return &Command{
+// CommandOptions configures how a Command sends its HTTP request, and the tester process it runs on.
+type CommandOptions struct {
+	// Burst is the number of times the HTTP request is sent back to back, see Traefik.SendBurst. The request is sent
+	// once when lower than 2. The Result holds the response to the last request, and the Report lists the outcome
+	// of every request.
+	Burst int `json:"burst,omitempty"`
+	// KeepCookies makes the requests of a burst send the cookies set by the previous responses, see
+	// Traefik.SendBurst.
+	KeepCookies bool `json:"keepCookies,omitempty"`
+	// Concurrent makes the requests of a burst be sent at once rather than back to back, see
+	// Traefik.SendConcurrentBurst.
+	Concurrent bool `json:"concurrent,omitempty"`
+	// Delay is the delay before the body of the HTTP request is sent, see DelayRequest.
+	Delay time.Duration `json:"delay,omitempty"`
+	// TrustedIPs are the IPs and CIDRs the entrypoints trust the forwarded headers of, see Options.
+	TrustedIPs []string `json:"trustedIPs,omitempty"`
+	// StaticConfig is the static configuration of the fake Traefik instance, in YAML, see Options.
+	StaticConfig string `json:"staticConfig,omitempty"`
+	// Stream makes the HTTP response be streamed while the fake Traefik instance produces it. The response must be
+	// read with Command.Stream while the Command executes. Streamed responses can't be sent in burst.
+	Stream bool `json:"stream,omitempty"`
+
+	// MaxLogSize limits the number of bytes of logs returned by Result, zero means unlimited.
+	MaxLogSize int `json:"-"`
+	// Limits caps the resources the fake Traefik instance can use.
+	Limits command.ResourceLimits `json:"-"`
+	// PassEnv lists the environment variables forwarded to the fake Traefik instance.
+	PassEnv []string `json:"-"`
+	// Timeout is the duration after which the fake Traefik instance gives up.
+	Timeout time.Duration `json:"-"`
+}
+
+// TraefikOptions returns the Options of the fake Traefik instance running a Command with the CommandOptions.
+func (o CommandOptions) TraefikOptions() Options {
+	return Options{
+		TrustedIPs:   o.TrustedIPs,
+		StaticConfig: o.StaticConfig,
+	}
+}
+
+// NewCommand creates a new Command sending the given HTTP request to a fake Traefik instance using the given dynamic
+// configuration, as described by the given CommandOptions.
+func NewCommand(dynamicConfig string, req *http.Request, opts CommandOptions) (*Command, error) {
+	if opts.Burst < 0 {
+		return nil, errors.New("burst count must be positive")
+	}
+	if opts.Stream && opts.Burst > 1 {
+		return nil, errors.New("requests sent in burst can't be streamed")
+	}
+
+	c := &Command{
 		dynamicConfig: dynamicConfig,
 		request:       req,
-	}, nil
+		options:       opts,
+		stderr:        logTail{maxSize: opts.MaxLogSize},
+	}
+
+	if opts.Stream {
+		c.streamReader, c.streamWriter = io.Pipe()
+	}
+
+	return c, nil
 }
 
-// Exec executes the command.
+// writeRequest writes the given request in wire format. Unlike http.Request.Write, which always writes
+// HTTP/1.1, the request line holds the protocol version of the request.
+func writeRequest(w io.Writer, req *http.Request) error {
+	var buf bytes.Buffer
+	if err := req.Write(&buf); err != nil {
+		return err
+	}
+
+	raw := buf.Bytes()
+	if req.ProtoMajor == 1 && req.ProtoMinor == 0 {
+		requestLine, rest, _ := bytes.Cut(raw, []byte("\r\n"))
+		requestLine = bytes.TrimSuffix(requestLine, []byte(" HTTP/1.1"))
+
+		raw = slices.Concat(requestLine, []byte(" HTTP/1.0\r\n"), rest)
+	}
+
+	_, err := w.Write(raw)
+
+	return err
+}
+
+// UseWarmPool makes the Command run on a tester process of the given WarmPool instead of starting its own, unless
+// it streams its response. A nil WarmPool makes it start its own process again.
+func (c *Command) UseWarmPool(pool *WarmPool) {
+	c.warmPool = pool
+}
+
+// Exec executes the command. A *TesterError is returned if the tester fails.
 func (c *Command) Exec(ctx context.Context) error {
-	logger := log.Ctx(ctx).With().Logger()
+	err := c.exec(ctx)
 
-	reqBuffer := bytes.NewBuffer(nil)
-	if err := c.request.Write(reqBuffer); err != nil {
-		return fmt.Errorf("marshaling request: %w", err)
+	// Let the reader of the stream know that the response is complete, or why it's not.
+	c.CloseStream(err)
+
+	return err
+}
+
+// CloseStream ends the stream of a Command streaming its response with the given error, or io.EOF if nil.
+// It must be called if the Command never gets executed, to release the reader of the stream.
+func (c *Command) CloseStream(err error) {
+	if c.streamWriter != nil {
+		_ = c.streamWriter.CloseWithError(err)
 	}
+}
 
-	cmd := command.NewIsolatedCommand(ctx, []command.MountPoint{
+func (c *Command) exec(ctx context.Context) error {
+	if c.warmPool != nil && c.streamWriter == nil {
+		return c.execWarm(ctx)
+	}
+
+	job, err := c.job()
+	if err != nil {
+		return err
+	}
+
+	// The dynamic configuration is written on the standard input rather than given as an argument.
+	args := append([]string{"/app/traefik-playground", "tester", "--log-level=debug"}, job.args()...)
+	args = append(args, "--timeout", c.options.Timeout.String())
+
+	cmd, err := command.NewIsolatedCommand(ctx, []command.MountPoint{
 		{Host: "/app", Target: "/app"},
-	}, "/app/traefik-playground", "tester",
-		"--request", reqBuffer.String(),
-		"--log-level=debug",
-	)
+	}, c.options.Limits, c.options.PassEnv, args...)
+	if err != nil {
+		return fmt.Errorf("creating isolated command: %w", err)
+	}
+
 	cmd.Stdout = &c.stdout
+	if c.streamWriter != nil {
+		cmd.Stdout = c.streamWriter
+	}
 	cmd.Stderr = &c.stderr
 
 	commandIn, err := cmd.StdinPipe()
@@ -74,14 +225,10 @@ func (c *Command) Exec(ctx context.Context) error {
 	if err = cmd.Wait(); err != nil {
 		var exitErr *exec.ExitError
 		if errors.As(err, &exitErr) {
-			logger.Error().Err(err).
-				Str("stderr", c.stderr.String()).
-				Str("stdout", c.stdout.String()).
-				Msg("Command has failed")
-
-			c.stderr.Write([]byte(fmt.Sprintf("\n\ncommand failed with status %d", exitErr.ExitCode())))
-
-			return nil
+			return c.fail(ctx, &TesterError{
+				Reason:   fmt.Sprintf("exited with status %d", exitErr.ExitCode()),
+				TimedOut: exitErr.ExitCode() == TesterTimeoutExitCode,
+			})
 		}
 
 		return fmt.Errorf("running command: %w", err)
@@ -90,14 +237,128 @@ func (c *Command) Exec(ctx context.Context) error {
 	return nil
 }
 
-// Result returns the HTTP response and logs of the previously run command.
-func (c *Command) Result() (*http.Response, []Log, error) {
-	res, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(c.stdout.Bytes())), c.request)
+// execWarm executes the command on a tester process of its WarmPool.
+func (c *Command) execWarm(ctx context.Context) error {
+	job, err := c.job()
 	if err != nil {
-		return nil, nil, fmt.Errorf("reading response: %w", err)
+		return err
+	}
+
+	result, err := c.warmPool.run(ctx, job)
+	if err != nil {
+		return fmt.Errorf("running on tester process: %w", err)
+	}
+
+	c.stdout.Write(result.Output)
+	c.stderr.WriteString(result.Logs)
+
+	if result.Error != "" {
+		return c.fail(ctx, &TesterError{Reason: result.Error, TimedOut: result.TimedOut})
+	}
+
+	return nil
+}
+
+// job returns the Job run by the tester for the Command, whether on its own process or on a WarmPool.
+func (c *Command) job() (Job, error) {
+	reqBuffer := bytes.NewBuffer(nil)
+	if err := writeRequest(reqBuffer, c.request); err != nil {
+		return Job{}, fmt.Errorf("marshaling request: %w", err)
 	}
 
-	logs := ParseRawLogs(c.stderr.String())
+	return Job{
+		DynamicConfig:  c.dynamicConfig,
+		Request:        reqBuffer.String(),
+		RemoteAddr:     c.request.RemoteAddr,
+		CommandOptions: c.options,
+	}, nil
+}
+
+// fail records that the tester failed with the given error, and returns it. The reason follows the logs returned by
+// Logs.
+func (c *Command) fail(ctx context.Context, err *TesterError) error {
+	log.Ctx(ctx).Error().
+		Str("reason", err.Reason).
+		Bool("timedOut", err.TimedOut).
+		Str("stderr", c.stderr.String()).
+		Str("stdout", c.stdout.String()).
+		Msg("Command has failed")
+
+	_, _ = c.stderr.WriteString("\n\ncommand failed: " + err.Reason)
+
+	return err
+}
+
+// Stream returns the HTTP response and the report of a Command streaming its response, as soon as the
+// response headers are received. The body is streamed as it's produced, and closing it stops reading the stream.
+// The report lacks the metrics, which are only known once the response is complete.
+func (c *Command) Stream() (*http.Response, Report, error) {
+	if c.streamReader == nil {
+		return nil, Report{}, errors.New("not a stream command")
+	}
+
+	stdout := bufio.NewReader(c.streamReader)
+
+	rawReport, err := stdout.ReadBytes('\n')
+	if err != nil {
+		_ = c.streamReader.CloseWithError(err)
+
+		return nil, Report{}, fmt.Errorf("reading report: %w", err)
+	}
+
+	var report Report
+	if err = json.Unmarshal(rawReport, &report); err != nil {
+		_ = c.streamReader.CloseWithError(err)
+
+		return nil, Report{}, fmt.Errorf("decoding report: %w", err)
+	}
+
+	res, err := http.ReadResponse(stdout, c.request)
+	if err != nil {
+		_ = c.streamReader.CloseWithError(err)
+
+		return nil, Report{}, fmt.Errorf("reading response: %w", err)
+	}
+
+	res.Body = streamBody{Reader: res.Body, stream: c.streamReader}
+
+	return res, report, nil
+}
+
+// streamBody is the body of a streamed response. Closing it closes the stream it's read from.
+type streamBody struct {
+	io.Reader
+
+	stream *io.PipeReader
+}
+
+func (b streamBody) Close() error {
+	return b.stream.Close()
+}
+
+// Logs returns the logs of the previously run command.
+func (c *Command) Logs() []Log {
+	return ParseRawLogs(c.stderr.String())
+}
+
+// Result returns the HTTP response, report and logs of the previously run command.
+func (c *Command) Result() (*http.Response, Report, []Log, error) {
+	stdout := bufio.NewReader(bytes.NewReader(c.stdout.Bytes()))
+
+	rawReport, err := stdout.ReadBytes('\n')
+	if err != nil {
+		return nil, Report{}, nil, fmt.Errorf("reading report: %w", err)
+	}
+
+	var report Report
+	if err = json.Unmarshal(rawReport, &report); err != nil {
+		return nil, Report{}, nil, fmt.Errorf("decoding report: %w", err)
+	}
+
+	res, err := http.ReadResponse(stdout, c.request)
+	if err != nil {
+		return nil, Report{}, nil, fmt.Errorf("reading response: %w", err)
+	}
 
-	return res, logs, nil
+	return res, report, c.Logs(), nil
 }
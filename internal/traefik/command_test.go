@@ -0,0 +1,95 @@
+package traefik
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteRequest(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		proto           string
+		wantRequestLine string
+	}{
+		{proto: "HTTP/1.1", wantRequestLine: "POST /foo HTTP/1.1"},
+		{proto: "HTTP/1.0", wantRequestLine: "POST /foo HTTP/1.0"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.proto, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodPost, "http://example.com/foo", strings.NewReader("body"))
+			req.Proto = test.proto
+			req.ProtoMajor, req.ProtoMinor, _ = http.ParseHTTPVersion(test.proto)
+
+			var buf bytes.Buffer
+			require.NoError(t, writeRequest(&buf, req))
+
+			requestLine, _, _ := strings.Cut(buf.String(), "\r\n")
+			assert.Equal(t, test.wantRequestLine, requestLine)
+
+			// The tester reads the request back with the same protocol and body.
+			got, err := http.ReadRequest(bufio.NewReader(&buf))
+			require.NoError(t, err)
+
+			assert.Equal(t, test.proto, got.Proto)
+			assert.Equal(t, int64(len("body")), got.ContentLength)
+		})
+	}
+}
+
+func TestCommand_job(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	req.RemoteAddr = "1.2.3.4:1234"
+
+	opts := CommandOptions{
+		Burst:        3,
+		KeepCookies:  true,
+		Concurrent:   true,
+		Delay:        time.Second,
+		TrustedIPs:   []string{"10.0.0.0/8", "192.168.0.1"},
+		StaticConfig: "entryPoints: {}",
+		Timeout:      time.Second,
+	}
+
+	cmd, err := NewCommand("http: {}", req, opts)
+	require.NoError(t, err)
+
+	job, err := cmd.job()
+	require.NoError(t, err)
+
+	var rawRequest bytes.Buffer
+	require.NoError(t, writeRequest(&rawRequest, req))
+
+	assert.Equal(t, Job{
+		DynamicConfig:  "http: {}",
+		Request:        rawRequest.String(),
+		RemoteAddr:     "1.2.3.4:1234",
+		CommandOptions: opts,
+	}, job)
+
+	// The tester running the Job on its own process gets the same settings as flags.
+	assert.Equal(t, []string{
+		"--request", rawRequest.String(),
+		"--remote-addr", "1.2.3.4:1234",
+		"--burst", "3",
+		"--keep-cookies",
+		"--concurrent",
+		"--delay", "1s",
+		"--trusted-ip", "10.0.0.0/8",
+		"--trusted-ip", "192.168.0.1",
+		"--static-config", "entryPoints: {}",
+	}, job.args())
+}
@@ -0,0 +1,28 @@
+package traefik
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+)
+
+// newCookieJar creates a jar keeping cookies as a browser would, without a public suffix list.
+func newCookieJar() http.CookieJar {
+	// cookiejar.New never fails without options.
+	jar, _ := cookiejar.New(nil)
+
+	return jar
+}
+
+// cookieURL returns the URL the cookies of the given request are kept for. The request line of an incoming request
+// only holds the path: the host comes from the Host header, and the scheme from X-Forwarded-Proto, so that the secure
+// cookies are only sent along with https requests.
+func cookieURL(req *http.Request) *url.URL {
+	scheme := "http"
+	if req.TLS != nil || strings.EqualFold(req.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+
+	return &url.URL{Scheme: scheme, Host: req.Host, Path: req.URL.Path}
+}
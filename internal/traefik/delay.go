@@ -0,0 +1,58 @@
+package traefik
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DelayRequest simulates a client sending the given request slowly. The body of the request can only be read
+// once the given delay has elapsed, as if the client was sending it late. Requests without a body can't be held,
+// DelayRequest waits for the delay instead. Both stop waiting when the context of the request is done.
+func DelayRequest(req *http.Request, delay time.Duration) error {
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = &delayedBody{ReadCloser: req.Body, ctx: req.Context(), delay: delay}
+
+		return nil
+	}
+
+	return wait(req.Context(), delay)
+}
+
+// delayedBody is a request body whose first read waits for a delay.
+type delayedBody struct {
+	io.ReadCloser
+
+	ctx   context.Context
+	delay time.Duration
+
+	once sync.Once
+	err  error
+}
+
+func (b *delayedBody) Read(p []byte) (int, error) {
+	b.once.Do(func() {
+		b.err = wait(b.ctx, b.delay)
+	})
+
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	return b.ReadCloser.Read(p)
+}
+
+// wait waits for the given delay, or until the given context is done.
+func wait(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
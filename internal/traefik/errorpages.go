@@ -0,0 +1,57 @@
+package traefik
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ErrorPages is a fake error page server meant to be used as the service of an errors middleware.
+// It responds 200 OK with an HTML page naming the status code found at the start of the last
+// segment of the path, such as "/503" or "/503.html", which is what the errors middleware query
+// produces with the {status} placeholder.
+type ErrorPages struct{}
+
+// NewErrorPages creates a new ErrorPages server.
+func NewErrorPages() *httptest.Server {
+	return httptest.NewServer(newErrorPagesHandler())
+}
+
+func newErrorPagesHandler() http.Handler {
+	s := &ErrorPages{}
+
+	handler := http.NewServeMux()
+	handler.HandleFunc("/", s.handle)
+
+	return handler
+}
+
+func (s *ErrorPages) handle(rw http.ResponseWriter, req *http.Request) {
+	title := "Playground error page"
+	if status, ok := parseStatus(path.Base(req.URL.Path)); ok {
+		title = fmt.Sprintf("Playground error page for %d %s", status, http.StatusText(status))
+	}
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	rw.WriteHeader(http.StatusOK)
+
+	_, _ = fmt.Fprintf(rw, "<!DOCTYPE html>\n<html><head><title>%[1]s</title></head><body><h1>%[1]s</h1></body></html>\n", title)
+}
+
+// parseStatus parses the HTTP status code the given path segment starts with.
+func parseStatus(segment string) (int, bool) {
+	digits := strings.IndexFunc(segment, func(r rune) bool { return r < '0' || r > '9' })
+	if digits == -1 {
+		digits = len(segment)
+	}
+
+	status, err := strconv.Atoi(segment[:digits])
+	if err != nil || status < 100 || status > 599 {
+		return 0, false
+	}
+
+	return status, true
+}
@@ -0,0 +1,85 @@
+package traefik
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultEventCount    = 5
+	maxEventCount        = 100
+	defaultEventInterval = 200 * time.Millisecond
+	maxEventInterval     = time.Second
+)
+
+// Events is a fake server streaming Server-Sent Events. It sends the number of events given by the "count" query
+// parameter, 5 by default and 100 at most, one every "interval" (a duration such as "500ms"), 200ms by default
+// and 1s at most. Each event is flushed as soon as it's written.
+type Events struct{}
+
+// NewEvents creates a new Events server.
+func NewEvents() *httptest.Server {
+	return httptest.NewServer(newEventsHandler())
+}
+
+func newEventsHandler() http.Handler {
+	s := &Events{}
+
+	handler := http.NewServeMux()
+	handler.HandleFunc("/", s.handle)
+
+	return handler
+}
+
+func (s *Events) handle(rw http.ResponseWriter, req *http.Request) {
+	count := defaultEventCount
+	if value := req.URL.Query().Get("count"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 || parsed > maxEventCount {
+			http.Error(rw, fmt.Sprintf("count must be between 0 and %d", maxEventCount), http.StatusBadRequest)
+
+			return
+		}
+
+		count = parsed
+	}
+
+	interval := defaultEventInterval
+	if value := req.URL.Query().Get("interval"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 || parsed > maxEventInterval {
+			http.Error(rw, fmt.Sprintf("interval must be between 0s and %s", maxEventInterval), http.StatusBadRequest)
+
+			return
+		}
+
+		interval = parsed
+	}
+
+	rw.Header().Set("Content-Type", "text/event-stream")
+	rw.Header().Set("Cache-Control", "no-cache")
+	rw.WriteHeader(http.StatusOK)
+
+	controller := http.NewResponseController(rw)
+
+	for i := range count {
+		if i > 0 {
+			select {
+			case <-req.Context().Done():
+				return
+			case <-time.After(interval):
+			}
+		}
+
+		if _, err := fmt.Fprintf(rw, "id: %d\ndata: event %d of %d\n\n", i+1, i+1, count); err != nil {
+			return
+		}
+
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
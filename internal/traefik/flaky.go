@@ -0,0 +1,100 @@
+package traefik
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+)
+
+const (
+	defaultFlakyFailures = 2
+	maxFlakyFailures     = 10
+)
+
+// Flaky is a fake server failing the first requests, meant to be used to test the retry middleware. Each request
+// URI fails the number of times given by the "failures" query parameter, 2 by default and 10 at most, then
+// responds 200 OK with the number of the attempt.
+//
+// Traefik only retries requests it couldn't send, so failures aren't answered by the server: they are connection
+// failures raised by the RoundTripper returned by Flaky.RoundTripper.
+type Flaky struct {
+	mu       sync.Mutex
+	attempts map[string]int
+}
+
+func newFlaky() *Flaky {
+	return &Flaky{attempts: make(map[string]int)}
+}
+
+// ServeHTTP responds to the request which made it through the failures.
+func (s *Flaky) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	failures, ok := flakyFailures(req)
+	if !ok {
+		http.Error(rw, fmt.Sprintf("failures must be between 0 and %d", maxFlakyFailures), http.StatusBadRequest)
+
+		return
+	}
+
+	s.mu.Lock()
+	attempt := s.attempts[req.URL.RequestURI()]
+	s.mu.Unlock()
+
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.WriteHeader(http.StatusOK)
+
+	_, _ = fmt.Fprintf(rw, "Succeeded on attempt %d, after %d failures\n", attempt, failures)
+}
+
+// RoundTripper returns an http.RoundTripper failing the requests sent to the given host, the address of the Flaky
+// server, as many times as they ask to. The failure is reported as a refused connection to the given public address.
+// Other requests are sent with the given http.RoundTripper.
+func (s *Flaky) RoundTripper(next http.RoundTripper, host string, publicAddr *net.TCPAddr) http.RoundTripper {
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if req.URL.Host != host {
+			return next.RoundTrip(req)
+		}
+
+		// Invalid requests are left for the server to reject.
+		failures, ok := flakyFailures(req)
+		if !ok {
+			return next.RoundTrip(req)
+		}
+
+		s.mu.Lock()
+		s.attempts[req.URL.RequestURI()]++
+		attempt := s.attempts[req.URL.RequestURI()]
+		s.mu.Unlock()
+
+		if attempt <= failures {
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Addr: publicAddr, Err: syscall.ECONNREFUSED}
+		}
+
+		return next.RoundTrip(req)
+	})
+}
+
+// flakyFailures returns the number of times the given request asks to fail.
+func flakyFailures(req *http.Request) (int, bool) {
+	value := req.URL.Query().Get("failures")
+	if value == "" {
+		return defaultFlakyFailures, true
+	}
+
+	failures, err := strconv.Atoi(value)
+	if err != nil || failures < 0 || failures > maxFlakyFailures {
+		return 0, false
+	}
+
+	return failures, true
+}
+
+// roundTripperFunc is an adapter allowing to use a function as an http.RoundTripper.
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper.
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
@@ -0,0 +1,40 @@
+package traefik
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/traefik/traefik/v3/pkg/config/static"
+	"github.com/traefik/traefik/v3/pkg/ip"
+	"github.com/traefik/traefik/v3/pkg/middlewares/forwardedheaders"
+)
+
+// newForwardedHeaders returns the forwardedHeaders settings of the entrypoints trusting the given IPs and CIDRs.
+// Without trusted IPs, no settings are returned: the entrypoints keep the playground default, see
+// withForwardedHeaders.
+func newForwardedHeaders(trustedIPs []string) (*static.ForwardedHeaders, error) {
+	if len(trustedIPs) == 0 {
+		return nil, nil //nolint:nilnil // No settings is the playground default.
+	}
+
+	if _, err := ip.NewChecker(trustedIPs); err != nil {
+		return nil, fmt.Errorf("parsing trusted IPs: %w", err)
+	}
+
+	return &static.ForwardedHeaders{TrustedIPs: trustedIPs}, nil
+}
+
+// withForwardedHeaders wraps the given entrypoint handler with the forwarded headers middleware of Traefik's
+// entrypoints, configured with the given settings. Like with Traefik, the forwarded headers of the requests sent
+// from untrusted IPs are then replaced, even without any trusted IP.
+//
+// Without settings, the handler is left as is. This is a playground default Traefik doesn't have: the playground
+// forwards the scheme and the client IP of the experiments through these headers, which must then reach the
+// routers untouched.
+func withForwardedHeaders(settings *static.ForwardedHeaders, handler http.Handler) (http.Handler, error) {
+	if settings == nil {
+		return handler, nil
+	}
+
+	return forwardedheaders.NewXForwarded(settings.Insecure, settings.TrustedIPs, settings.Connection, handler)
+}
@@ -0,0 +1,253 @@
+package traefik
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	// The tester runs in a sandbox without the time zone database of the host, the embedded one makes the TZ
+	// forwarded to it apply to the timestamps of the logs.
+	_ "time/tzdata"
+
+	"github.com/traefik/traefik/v3/pkg/config/dynamic"
+	"gopkg.in/yaml.v3"
+)
+
+// Job is an HTTP request to send to a fake Traefik instance using a dynamic configuration, as run by the tester.
+type Job struct {
+	// DynamicConfig is the dynamic configuration of the fake Traefik instance, in YAML.
+	DynamicConfig string `json:"dynamicConfig"`
+	// Request is the HTTP request to send, in wire format.
+	Request string `json:"request"`
+	// RemoteAddr is the address the HTTP request originates from.
+	RemoteAddr string `json:"remoteAddr,omitempty"`
+	// CommandOptions describes how the HTTP request is sent.
+	CommandOptions
+}
+
+// args returns the command line flags of the tester running the Job on its own process, the dynamic configuration
+// aside as it's read from the standard input.
+func (j Job) args() []string {
+	args := []string{"--request", j.Request}
+	if j.RemoteAddr != "" {
+		args = append(args, "--remote-addr", j.RemoteAddr)
+	}
+	if j.Burst > 1 {
+		args = append(args, "--burst", strconv.Itoa(j.Burst))
+	}
+	if j.KeepCookies {
+		args = append(args, "--keep-cookies")
+	}
+	if j.Concurrent {
+		args = append(args, "--concurrent")
+	}
+	if j.Delay > 0 {
+		args = append(args, "--delay", j.Delay.String())
+	}
+	for _, trustedIP := range j.TrustedIPs {
+		args = append(args, "--trusted-ip", trustedIP)
+	}
+	if j.StaticConfig != "" {
+		args = append(args, "--static-config", j.StaticConfig)
+	}
+	if j.Stream {
+		args = append(args, "--stream")
+	}
+
+	return args
+}
+
+// RunJob starts a fake Traefik instance, sends the HTTP request of the given Job and writes the Report on the first
+// line of w, followed by the HTTP response. The instance is fully stopped by the time RunJob returns, so that
+// nothing it still runs leaks into the next Job.
+func RunJob(ctx context.Context, job Job, w io.Writer) error {
+	var dynamicConfig dynamic.Configuration
+	if err := yaml.NewDecoder(strings.NewReader(job.DynamicConfig)).Decode(&dynamicConfig); err != nil {
+		return fmt.Errorf("decoding dynamic configuration: %w", err)
+	}
+
+	instance, err := NewTraefik(&dynamicConfig, job.TraefikOptions())
+	if err != nil {
+		return fmt.Errorf("initializing Traefik instance: %w", err)
+	}
+
+	req, err := http.ReadRequest(bufio.NewReader(strings.NewReader(job.Request)))
+	if err != nil {
+		return fmt.Errorf("reading request: %w", err)
+	}
+
+	req = req.WithContext(ctx)
+	req.RemoteAddr = job.RemoteAddr
+
+	if job.Delay > 0 {
+		if err = DelayRequest(req, job.Delay); err != nil {
+			return fmt.Errorf("delaying request: %w", err)
+		}
+	}
+
+	type output struct {
+		buf bytes.Buffer
+		err error
+	}
+
+	// The channel is buffered so that the instance doesn't hang if the context ends before the response is produced.
+	outputCh := make(chan *output, 1)
+	instance.OnReady(func() {
+		out := &output{}
+		out.err = writeJobOutput(&out.buf, instance, req, job)
+
+		outputCh <- out
+	})
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if err = instance.Start(ctx); err != nil {
+		return fmt.Errorf("starting Traefik instance: %w", err)
+	}
+
+	defer func() {
+		cancel()
+		<-instance.Stopped()
+	}()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case out := <-outputCh:
+		if out.err != nil {
+			return out.err
+		}
+
+		_, err = w.Write(out.buf.Bytes())
+
+		return err
+	}
+}
+
+// writeJobOutput sends the given request to the given Traefik instance as described by the given Job, in burst when
+// its Burst is greater than 1, and writes the Report on the first line of w, followed by the HTTP response.
+func writeJobOutput(w io.Writer, instance *Traefik, req *http.Request, job Job) error {
+	send := instance.Send
+	switch {
+	case job.Burst > 1 && job.Concurrent:
+		send = func(req *http.Request) (*http.Response, Report, error) {
+			return instance.SendConcurrentBurst(req, job.Burst)
+		}
+	case job.Burst > 1:
+		send = func(req *http.Request) (*http.Response, Report, error) {
+			return instance.SendBurst(req, job.Burst, job.KeepCookies)
+		}
+	}
+
+	res, report, err := send(req)
+	if err != nil {
+		return err
+	}
+
+	defer func() { _ = res.Body.Close() }()
+
+	if report.ResolvedConfig, err = instance.ResolvedConfig(); err != nil {
+		return fmt.Errorf("resolving configuration: %w", err)
+	}
+
+	if err = json.NewEncoder(w).Encode(report); err != nil {
+		return fmt.Errorf("writing report: %w", err)
+	}
+
+	// Trailers are only written after a chunked body.
+	if len(res.Trailer) > 0 {
+		res.TransferEncoding = []string{"chunked"}
+		res.ContentLength = -1
+	}
+
+	return res.Write(w)
+}
+
+// JobResult is the outcome of a Job run by Serve.
+type JobResult struct {
+	// Output is what RunJob wrote: the Report followed by the HTTP response.
+	Output []byte `json:"output,omitempty"`
+	// Logs are the logs written while the Job was running.
+	Logs string `json:"logs,omitempty"`
+	// Error is the reason why the Job failed, empty when it succeeded.
+	Error string `json:"error,omitempty"`
+	// TimedOut tells whether the Job failed as its timeout elapsed.
+	TimedOut bool `json:"timedOut,omitempty"`
+}
+
+// JobLogs collects the logs written while Serve runs a Job. It must be the output of the logger.
+type JobLogs struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (l *JobLogs) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.buf.Write(p)
+}
+
+// take returns the logs collected so far, and starts collecting anew.
+func (l *JobLogs) take() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	logs := l.buf.String()
+	l.buf.Reset()
+
+	return logs
+}
+
+// Serve runs the Jobs read from r one after the other, each within the given timeout, until r is closed. Jobs are
+// read and their JobResult written on w as JSON lines. The logs of each Job are taken from the given JobLogs.
+func Serve(ctx context.Context, r io.Reader, w io.Writer, logs *JobLogs, timeout time.Duration) error {
+	decoder := json.NewDecoder(r)
+	encoder := json.NewEncoder(w)
+
+	for {
+		var job Job
+		if err := decoder.Decode(&job); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+
+			return fmt.Errorf("decoding job: %w", err)
+		}
+
+		if err := encoder.Encode(serveJob(ctx, job, logs, timeout)); err != nil {
+			return fmt.Errorf("writing job result: %w", err)
+		}
+	}
+}
+
+func serveJob(ctx context.Context, job Job, logs *JobLogs, timeout time.Duration) JobResult {
+	// Drop what the instances of the previous Jobs logged while shutting down.
+	logs.take()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var output bytes.Buffer
+	err := RunJob(ctx, job, &output)
+
+	result := JobResult{
+		Output: output.Bytes(),
+		Logs:   logs.take(),
+	}
+	if err != nil {
+		result.Error = err.Error()
+		result.TimedOut = errors.Is(err, context.DeadlineExceeded)
+	}
+
+	return result
+}
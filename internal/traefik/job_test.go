@@ -0,0 +1,68 @@
+package traefik
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunJob_trailers(t *testing.T) {
+	t.Parallel()
+
+	backend := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, _ *http.Request) {
+		rw.Header().Set("Trailer", "X-Checksum")
+		rw.WriteHeader(http.StatusOK)
+
+		_, _ = io.WriteString(rw, "body")
+
+		rw.Header().Set("X-Checksum", "abc")
+	}))
+	t.Cleanup(backend.Close)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+
+	var rawRequest bytes.Buffer
+	require.NoError(t, writeRequest(&rawRequest, req))
+
+	var output bytes.Buffer
+	require.NoError(t, RunJob(t.Context(), Job{
+		DynamicConfig: `
+http:
+  routers:
+    api:
+      rule: PathPrefix(` + "`/`" + `)
+      service: api
+  services:
+    api:
+      loadBalancer:
+        servers:
+          - url: ` + backend.URL,
+		Request: rawRequest.String(),
+	}, &output))
+
+	stdout := bufio.NewReader(&output)
+
+	rawReport, err := stdout.ReadBytes('\n')
+	require.NoError(t, err)
+
+	var report Report
+	require.NoError(t, json.Unmarshal(rawReport, &report))
+	assert.Equal(t, "api@file", report.Router)
+
+	res, err := http.ReadResponse(stdout, nil)
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "body", string(body))
+	assert.Equal(t, http.Header{"X-Checksum": {"abc"}}, res.Trailer)
+}
@@ -1,6 +1,7 @@
 package traefik
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"strings"
@@ -29,8 +30,127 @@ type Log struct {
 	Level     LogLevel               `json:"level"`
 	Error     string                 `json:"error"`
 	Fields    map[string]interface{} `json:"fields"`
+
+	// Noise is set when the log is unrelated to the handling of the request.
+	Noise bool `json:"noise,omitempty"`
+}
+
+// DefaultNoiseLogPrefixes returns the message prefixes of the logs Traefik emits while starting up.
+func DefaultNoiseLogPrefixes() []string {
+	return []string{
+		"Configuration loaded from flags",
+		"Starting provider",
+		"*traefik.provider provider configuration",
+		"Configuration received",
+	}
+}
+
+// LogFilter flags as noise the logs whose message starts with one of the denied prefixes.
+type LogFilter struct {
+	deniedPrefixes []string
+}
+
+// NewLogFilter creates a new LogFilter.
+func NewLogFilter(deniedPrefixes []string) LogFilter {
+	return LogFilter{
+		deniedPrefixes: deniedPrefixes,
+	}
+}
+
+// Apply flags the noisy logs. Logs are kept so that they can still be displayed on demand.
+func (f LogFilter) Apply(logs []Log) []Log {
+	if len(logs) == 0 {
+		return logs
+	}
+
+	filtered := make([]Log, 0, len(logs))
+	for _, l := range logs {
+		for _, prefix := range f.deniedPrefixes {
+			if strings.HasPrefix(l.Message, prefix) {
+				l.Noise = true
+
+				break
+			}
+		}
+
+		filtered = append(filtered, l)
+	}
+
+	return filtered
+}
+
+// truncatedLogsMessage is the message of the log replacing the truncated logs.
+const truncatedLogsMessage = "[%d bytes of older logs truncated]"
+
+// TruncateRawLogs drops the oldest lines of the given raw logs so they don't exceed maxSize bytes.
+// Dropped lines are replaced by a marker line. A maxSize lower or equal to zero disables the limit.
+func TruncateRawLogs(rawLogs string, maxSize int) string {
+	tail := logTail{maxSize: maxSize}
+	_, _ = tail.WriteString(rawLogs)
+
+	return tail.String()
+}
+
+// logTail is an io.Writer keeping the last maxSize bytes written to it, counting the bytes it drops, so that the
+// logs of a run don't take more memory than they are returned with. A maxSize lower or equal to zero keeps
+// everything.
+type logTail struct {
+	maxSize int
+
+	buf     []byte
+	dropped int
+}
+
+// Write implements io.Writer.
+func (t *logTail) Write(p []byte) (int, error) {
+	if t.maxSize > 0 && len(p) >= t.maxSize {
+		t.dropped += len(t.buf) + len(p) - t.maxSize
+		t.buf = append(t.buf[:0], p[len(p)-t.maxSize:]...)
+
+		return len(p), nil
+	}
+
+	t.buf = append(t.buf, p...)
+
+	// Drop the oldest bytes once twice the limit is reached, rather than on every write.
+	if t.maxSize > 0 && len(t.buf) > 2*t.maxSize {
+		drop := len(t.buf) - t.maxSize
+		t.dropped += drop
+		t.buf = t.buf[:copy(t.buf, t.buf[drop:])]
+	}
+
+	return len(p), nil
+}
+
+// WriteString implements io.StringWriter.
+func (t *logTail) WriteString(s string) (int, error) {
+	return t.Write([]byte(s))
+}
+
+// String returns the last maxSize bytes written, without a partial first line. Dropped lines are replaced by a
+// marker line.
+func (t *logTail) String() string {
+	logs := t.buf
+	dropped := t.dropped
+	if t.maxSize > 0 && len(logs) > t.maxSize {
+		dropped += len(logs) - t.maxSize
+		logs = logs[len(logs)-t.maxSize:]
+	}
+
+	if dropped == 0 {
+		return string(logs)
+	}
+
+	// Avoid keeping a partial line.
+	if idx := bytes.IndexByte(logs, '\n'); idx >= 0 {
+		dropped += idx + 1
+		logs = logs[idx+1:]
+	}
+
+	return fmt.Sprintf(truncatedLogsMessage, dropped) + "\n" + string(logs)
 }
 
+// ParseRawLogs parses the raw logs emitted by the fake Traefik instance.
 func ParseRawLogs(rawLogs string) []Log {
 	rawLines := strings.Split(rawLogs, "\n")
 	logs := make([]Log, 0, len(rawLines))
@@ -1,9 +1,11 @@
 package traefik
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseRawLogs(t *testing.T) {
@@ -187,3 +189,100 @@ invalid json
 		})
 	}
 }
+
+func TestLogFilter_Apply(t *testing.T) {
+	t.Parallel()
+
+	logs := ParseRawLogs(`
+{"level":"info","time":"2023-01-01T00:00:00Z","message":"Configuration loaded from flags"}
+{"level":"info","time":"2023-01-01T00:00:00Z","message":"Starting provider aggregator *aggregator.ProviderAggregator"}
+{"level":"info","time":"2023-01-01T00:00:00Z","message":"Starting provider *traefik.provider"}
+{"level":"debug","time":"2023-01-01T00:00:00Z","message":"*traefik.provider provider configuration"}
+{"level":"debug","time":"2023-01-01T00:00:00Z","message":"Configuration received","providerName":"file"}
+{"level":"debug","time":"2023-01-01T00:00:00Z","message":"Creating middleware","middlewareName":"add-header@file"}
+{"level":"debug","time":"2023-01-01T00:00:00Z","message":"Service selected by WRR: http://127.0.0.1:33515"}
+plain text log`)
+
+	got := NewLogFilter(DefaultNoiseLogPrefixes()).Apply(logs)
+
+	var visible []string
+	for _, l := range got {
+		if !l.Noise {
+			visible = append(visible, l.Message)
+		}
+	}
+
+	require.Len(t, got, len(logs))
+	assert.Equal(t, []string{
+		"Creating middleware",
+		"Service selected by WRR: http://127.0.0.1:33515",
+		"plain text log",
+	}, visible)
+}
+
+func TestLogFilter_Apply_noPrefixes(t *testing.T) {
+	t.Parallel()
+
+	logs := []Log{{Message: "Configuration received"}}
+
+	assert.Equal(t, logs, NewLogFilter(nil).Apply(logs))
+}
+
+func TestTruncateRawLogs(t *testing.T) {
+	t.Parallel()
+
+	line := `{"level":"debug","time":"2023-01-01T00:00:00Z","message":"Service selected by WRR: http://127.0.0.1:33515"}`
+	rawLogs := strings.Repeat(line+"\n", 1000) + `{"level":"error","time":"2023-01-01T00:00:01Z","message":"last message"}`
+
+	truncated := TruncateRawLogs(rawLogs, 1024)
+	assert.LessOrEqual(t, len(truncated), 1024+len(truncatedLogsMessage)+10)
+
+	logs := ParseRawLogs(truncated)
+	require.NotEmpty(t, logs)
+
+	assert.Regexp(t, `^\[\d+ bytes of older logs truncated\]$`, logs[0].Message)
+	assert.Empty(t, logs[0].Level)
+	assert.Equal(t, "last message", logs[len(logs)-1].Message)
+
+	for _, l := range logs[1 : len(logs)-1] {
+		assert.Equal(t, LogLevelDebug, l.Level)
+	}
+}
+
+func TestLogTail(t *testing.T) {
+	t.Parallel()
+
+	line := `{"level":"debug","time":"2023-01-01T00:00:00Z","message":"Service selected by WRR: http://127.0.0.1:33515"}`
+
+	var rawLogs strings.Builder
+	tail := logTail{maxSize: 1024}
+
+	for range 1000 {
+		_, err := tail.WriteString(line + "\n")
+		require.NoError(t, err)
+		rawLogs.WriteString(line + "\n")
+
+		// The logs written so far aren't all kept in memory.
+		assert.LessOrEqual(t, len(tail.buf), 2*1024)
+	}
+
+	_, err := tail.Write([]byte("last message"))
+	require.NoError(t, err)
+	rawLogs.WriteString("last message")
+
+	assert.Equal(t, TruncateRawLogs(rawLogs.String(), 1024), tail.String())
+	assert.Equal(t, rawLogs.Len()-len(tail.buf), tail.dropped)
+
+	_, err = tail.Write([]byte(strings.Repeat("a", 2048)))
+	require.NoError(t, err)
+	assert.Len(t, tail.buf, 1024)
+}
+
+func TestTruncateRawLogs_underLimit(t *testing.T) {
+	t.Parallel()
+
+	rawLogs := `{"level":"info","time":"2023-01-01T00:00:00Z","message":"test message"}`
+
+	assert.Equal(t, rawLogs, TruncateRawLogs(rawLogs, len(rawLogs)))
+	assert.Equal(t, rawLogs, TruncateRawLogs(rawLogs, 0))
+}
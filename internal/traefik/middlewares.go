@@ -0,0 +1,155 @@
+package traefik
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+
+	ptypes "github.com/traefik/paerser/types"
+	"github.com/traefik/traefik/v3/pkg/config/dynamic"
+)
+
+// MiddlewareSchema describes a middleware type supported by the dynamic configuration.
+type MiddlewareSchema struct {
+	Name   string        `json:"name"`
+	Fields []FieldSchema `json:"fields"`
+}
+
+// FieldSchema describes an option of a middleware.
+type FieldSchema struct {
+	Name string `json:"name"`
+	// Type is one of "string", "boolean", "integer", "number", "duration", "object", "any",
+	// "array<T>" or "map<string,T>", where T is itself a type.
+	Type   string        `json:"type"`
+	Fields []FieldSchema `json:"fields,omitempty"`
+}
+
+// Middlewares lists the HTTP middlewares that can be declared in the dynamic configuration, sorted by name.
+// The list is built by reflection on dynamic.Middleware using the YAML field names.
+func Middlewares() []MiddlewareSchema {
+	middlewareType := reflect.TypeOf(dynamic.Middleware{})
+
+	var middlewares []MiddlewareSchema
+	for i := range middlewareType.NumField() {
+		field := middlewareType.Field(i)
+
+		name, ok := yamlFieldName(field)
+		if !ok || field.Type.Kind() != reflect.Ptr || field.Type.Elem().Kind() != reflect.Struct {
+			continue
+		}
+
+		middlewares = append(middlewares, MiddlewareSchema{
+			Name:   name,
+			Fields: structFields(field.Type.Elem(), nil),
+		})
+	}
+
+	slices.SortFunc(middlewares, func(a, b MiddlewareSchema) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+
+	return middlewares
+}
+
+// structFields describes the fields of the given struct type. Visited holds the struct types
+// being described, to stop on recursive types.
+func structFields(t reflect.Type, visited []reflect.Type) []FieldSchema {
+	if slices.Contains(visited, t) {
+		return nil
+	}
+
+	visited = append(visited, t)
+
+	var fields []FieldSchema
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if field.Anonymous && field.Tag.Get("yaml") == ",inline" {
+			fields = append(fields, structFields(indirect(field.Type), visited)...)
+
+			continue
+		}
+
+		name, ok := yamlFieldName(field)
+		if !ok {
+			continue
+		}
+
+		fieldSchema := FieldSchema{
+			Name: name,
+			Type: typeName(field.Type),
+		}
+
+		if elem := indirect(field.Type); elem.Kind() == reflect.Struct {
+			fieldSchema.Fields = structFields(elem, visited)
+		}
+
+		fields = append(fields, fieldSchema)
+	}
+
+	return fields
+}
+
+// typeName returns the name of the given type as documented on FieldSchema.Type.
+func typeName(t reflect.Type) string {
+	t = indirect(t)
+	if isDuration(t) {
+		return "duration"
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Struct:
+		return "object"
+	case reflect.Slice, reflect.Array:
+		return "array<" + typeName(t.Elem()) + ">"
+	case reflect.Map:
+		return "map<string," + typeName(t.Elem()) + ">"
+	default:
+		return "any"
+	}
+}
+
+// yamlFieldName returns the YAML name of the given field. It returns false if the field is not
+// part of the YAML representation.
+func yamlFieldName(field reflect.StructField) (string, bool) {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+
+	switch name {
+	case "-":
+		return "", false
+	case "":
+		return field.Name, true
+	default:
+		return name, true
+	}
+}
+
+// durationType is the type of the durations of the dynamic configuration.
+//
+//nolint:gochecknoglobals // Read-only.
+var durationType = reflect.TypeOf(ptypes.Duration(0))
+
+// isDuration reports whether the given type is the duration type used in the dynamic configuration.
+func isDuration(t reflect.Type) bool {
+	return t == durationType
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t
+}
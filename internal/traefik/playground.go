@@ -0,0 +1,80 @@
+package traefik
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// Public addresses of the playground backends, as written in the dynamic configurations. The ServerInjector
+// replaces them with the private addresses of the backends.
+const (
+	whoamiURL        = "http://10.10.10.10"
+	authURL          = "http://10.10.10.11"
+	largeWhoamiURL   = "http://10.10.10.12"
+	errorPagesURL    = "http://10.10.10.13"
+	eventsURL        = "http://10.10.10.14"
+	flakyURL         = "http://10.10.10.15"
+	slowURL          = "http://10.10.10.16"
+	etagWhoamiURL    = "http://10.10.10.17"
+	whoami1URL       = "http://10.10.10.21"
+	whoami2URL       = "http://10.10.10.22"
+	whoami3URL       = "http://10.10.10.23"
+	whoamiUDPAddress = "10.10.10.10:53"
+)
+
+// playgroundURLs returns the public URLs of the playground HTTP backends.
+func playgroundURLs() []string {
+	return []string{whoamiURL, authURL, largeWhoamiURL, errorPagesURL, eventsURL, flakyURL, slowURL, etagWhoamiURL, whoami1URL, whoami2URL, whoami3URL}
+}
+
+// IsPlaygroundServerURL tells whether the given HTTP service server URL points at a playground backend.
+func IsPlaygroundServerURL(serverURL string) bool {
+	return slices.ContainsFunc(playgroundURLs(), func(publicURL string) bool {
+		return isServerURLOf(serverURL, publicURL)
+	})
+}
+
+// isServerURLOf tells whether the given server URL points at the playground backend of the given public URL. They are
+// compared once parsed, so that the default port, a root path or a different case are still matched.
+func isServerURLOf(serverURL, publicURL string) bool {
+	u, err := url.Parse(serverURL)
+	if err != nil || u.User != nil || u.RawQuery != "" || u.Fragment != "" || (u.Path != "" && u.Path != "/") {
+		return false
+	}
+
+	if !strings.EqualFold(u.Scheme, "http") || (u.Port() != "" && u.Port() != "80") {
+		return false
+	}
+
+	return strings.EqualFold(u.Hostname(), strings.TrimPrefix(publicURL, "http://"))
+}
+
+// IsPlaygroundHost tells whether the given host, without port, is the host of a playground backend.
+func IsPlaygroundHost(host string) bool {
+	for _, publicURL := range playgroundURLs() {
+		if strings.TrimPrefix(publicURL, "http://") == host {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsPlaygroundForwardAuthAddress tells whether the given forwardAuth middleware address points at a playground
+// backend. Unlike server URLs, the address can have a path and a query.
+func IsPlaygroundForwardAuthAddress(address string) bool {
+	for _, publicURL := range playgroundURLs() {
+		rest, ok := strings.CutPrefix(address, publicURL)
+		if ok && (rest == "" || strings.HasPrefix(rest, "/") || strings.HasPrefix(rest, "?")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsPlaygroundUDPAddress tells whether the given UDP service server address points at a playground backend.
+func IsPlaygroundUDPAddress(address string) bool {
+	return address == whoamiUDPAddress
+}
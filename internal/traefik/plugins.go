@@ -0,0 +1,46 @@
+package traefik
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"slices"
+
+	"github.com/traefik/traefik/v3/pkg/config/dynamic"
+	"github.com/traefik/traefik/v3/pkg/plugins"
+)
+
+// pluginStub is a middleware.PluginsBuilder stubbing the plugins as no-op middlewares. The playground can't load
+// plugins, but the routers using them still bind instead of failing to build.
+type pluginStub struct{}
+
+// Build returns a constructor of a middleware forwarding the requests unchanged.
+func (*pluginStub) Build(_ string, _ map[string]interface{}, _ string) (plugins.Constructor, error) {
+	return func(_ context.Context, next http.Handler) (http.Handler, error) {
+		return next, nil
+	}, nil
+}
+
+// stubbedPlugins returns the sorted types of the plugins used by the middlewares of the given dynamic configuration.
+func stubbedPlugins(dynamicConfig dynamic.Configuration) []string {
+	if dynamicConfig.HTTP == nil {
+		return nil
+	}
+
+	types := make(map[string]struct{})
+	for _, m := range dynamicConfig.HTTP.Middlewares {
+		if m == nil {
+			continue
+		}
+
+		for pluginType := range m.Plugin {
+			types[pluginType] = struct{}{}
+		}
+	}
+
+	if len(types) == 0 {
+		return nil
+	}
+
+	return slices.Sorted(maps.Keys(types))
+}
@@ -5,6 +5,10 @@ import (
 	"github.com/traefik/traefik/v3/pkg/safe"
 )
 
+// providerName is the name of the provider of the user dynamic configuration, which qualifies its routers,
+// services and middlewares.
+const providerName = "file"
+
 // provider acts like if it was Traefik's file provider.
 // We are reusing the name "file" provider because it use the same syntax as the file provider.
 // It provides a single dynamic configuration.
@@ -25,7 +29,7 @@ func (f *provider) Init() error { return nil }
 // Provide provides the dynamic configuration.
 func (f *provider) Provide(configurationChan chan<- dynamic.Message, _ *safe.Pool) error {
 	configurationChan <- dynamic.Message{
-		ProviderName:  "file",
+		ProviderName:  providerName,
 		Configuration: f.config,
 	}
 
@@ -0,0 +1,226 @@
+package traefik
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/jspdown/traefik-playground/internal/matcher"
+	"github.com/traefik/traefik/v3/pkg/config/runtime"
+	httpmuxer "github.com/traefik/traefik/v3/pkg/muxer/http"
+	serverprovider "github.com/traefik/traefik/v3/pkg/server/provider"
+)
+
+// Report holds what the fake Traefik instance observed while handling a request.
+type Report struct {
+	// Router is the name of the router which matched the request. It's empty when no router matched.
+	Router string `json:"router,omitempty"`
+	// Rule is the rule of the matched router.
+	Rule string `json:"rule,omitempty"`
+	// Priority is the priority of the matched router, computed from the length of its rule when not set.
+	Priority int `json:"priority,omitempty"`
+	// Middlewares are the qualified names of the middlewares the matched router ran, in execution order.
+	// The middlewares of a chain directly follow the chain itself.
+	Middlewares []string `json:"middlewares,omitempty"`
+	// RouterEvaluations explains, for every HTTP router, whether it matched the request and why it was or wasn't
+	// selected. See matcher.Matcher.Explain.
+	RouterEvaluations []RouterEvaluation `json:"routerEvaluations,omitempty"`
+	// Metrics are the counters measured while handling the request.
+	Metrics Metrics `json:"metrics"`
+	// ResolvedConfig is the runtime configuration resolved by Traefik, see Traefik.ResolvedConfig.
+	ResolvedConfig json.RawMessage `json:"resolvedConfig,omitempty"`
+	// Backend is the public URL of the playground backend Traefik last attempted to send the request to, empty if it
+	// didn't reach any, such as when no router matched.
+	Backend string `json:"backend,omitempty"`
+	// HeaderChanges lists the headers Traefik added, changed or removed before forwarding the request to the
+	// Backend, such as X-Forwarded-For, sorted by name. It's empty when the request didn't reach a backend.
+	HeaderChanges []HeaderChange `json:"headerChanges,omitempty"`
+	// Burst lists the outcome of each request sent with Traefik.SendBurst, in order.
+	Burst []BurstResponse `json:"burst,omitempty"`
+	// StubbedPlugins are the types of the plugins used by the middlewares. The playground can't load plugins:
+	// they are stubbed as middlewares forwarding the requests unchanged.
+	StubbedPlugins []string `json:"stubbedPlugins,omitempty"`
+}
+
+// RouterEvaluation explains how a router was considered to route a request.
+type RouterEvaluation = matcher.Evaluation
+
+// HeaderChange is a request header Traefik added, changed or removed before forwarding the request to a backend.
+type HeaderChange struct {
+	Name string `json:"name"`
+	// Values are the values of the header forwarded to the backend, empty when Traefik removed the header.
+	Values []string `json:"values,omitempty"`
+	// Original are the values of the header Traefik received, empty when Traefik added the header.
+	Original []string `json:"original,omitempty"`
+}
+
+// diffHeaders returns the changes between the headers of a request received by Traefik and the headers of the
+// request it forwarded, sorted by name.
+func diffHeaders(received, forwarded http.Header) []HeaderChange {
+	// The proxy sets an empty User-Agent to keep the transport from sending its own, no header is actually sent.
+	if slices.Equal(forwarded.Values("User-Agent"), []string{""}) {
+		forwarded = forwarded.Clone()
+		forwarded.Del("User-Agent")
+	}
+
+	names := slices.Collect(maps.Keys(received))
+	for name := range forwarded {
+		if _, ok := received[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	var changes []HeaderChange
+	for _, name := range names {
+		if slices.Equal(received[name], forwarded[name]) {
+			continue
+		}
+
+		changes = append(changes, HeaderChange{
+			Name:     name,
+			Values:   forwarded[name],
+			Original: received[name],
+		})
+	}
+
+	return changes
+}
+
+// BurstResponse is the outcome of a request sent as part of a burst.
+type BurstResponse struct {
+	StatusCode int `json:"statusCode"`
+	// Limited tells whether Traefik rejected the request with a 429 status before it reached a backend,
+	// such as the rateLimit middleware does.
+	Limited bool `json:"limited,omitempty"`
+	// Backend is the public URL of the playground backend the request was last sent to, see Report.Backend.
+	Backend string `json:"backend,omitempty"`
+}
+
+// Metrics holds the counters measured while handling a request.
+type Metrics struct {
+	// BytesSent is the number of request body bytes read by Traefik.
+	BytesSent int64 `json:"bytesSent"`
+	// BytesReceived is the number of response body bytes written by Traefik, before any decoding.
+	BytesReceived int64 `json:"bytesReceived"`
+	// BackendConnections is the number of connections the playground backends accepted.
+	// Connections kept alive from a previous request aren't counted again.
+	BackendConnections int64 `json:"backendConnections"`
+	// BackendRequests is the number of requests the playground backends received, retries included.
+	BackendRequests int64 `json:"backendRequests"`
+	// Attempts is the number of times Traefik tried to send the request to the playground service of the matched
+	// router, more than once when retried, such as by the retry middleware. Requests made by middlewares, such as
+	// forwardAuth, aren't counted.
+	Attempts int64 `json:"attempts"`
+}
+
+// countingReader counts the bytes read from the wrapped io.ReadCloser.
+// The count is safe to read while the body is still being consumed by the proxy.
+type countingReader struct {
+	io.ReadCloser
+
+	n atomic.Int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n.Add(int64(n))
+
+	return n, err
+}
+
+// routerMatcher finds which router of an entrypoint matches a request, along with the middlewares it runs.
+type routerMatcher struct {
+	*matcher.Matcher
+
+	middlewares map[string][]string
+}
+
+// newRouterMatcher creates a new routerMatcher for the enabled non-TLS routers of the given entrypoint.
+// The runtime configuration is expected to be already processed by the router manager, which
+// disables invalid routers and computes the default priorities.
+func newRouterMatcher(parser httpmuxer.SyntaxParser, runtimeConfig *runtime.Configuration, entryPointName string) *routerMatcher {
+	m := &routerMatcher{
+		Matcher:     matcher.New(parser),
+		middlewares: make(map[string][]string),
+	}
+
+	// Routers are added in a stable order, for routers of the same priority to be matched in the same order.
+	for _, routerName := range slices.Sorted(maps.Keys(runtimeConfig.Routers)) {
+		routerInfo := runtimeConfig.Routers[routerName]
+
+		if reason := skipReason(routerInfo, entryPointName); reason != "" {
+			m.Skip(routerName, routerInfo.Rule, reason)
+
+			continue
+		}
+
+		// Invalid rules have already been reported by the router manager.
+		if err := m.AddRoute(routerName, routerInfo.Rule, routerInfo.RuleSyntax, routerInfo.Priority); err != nil {
+			continue
+		}
+
+		ctx := serverprovider.AddInContext(context.Background(), routerName)
+		m.middlewares[routerName] = middlewareChain(ctx, runtimeConfig, routerInfo.Middlewares, nil)
+	}
+
+	return m
+}
+
+// skipReason returns why the given router isn't evaluated for the requests received by the given entrypoint, or an
+// empty string if it is.
+func skipReason(routerInfo *runtime.RouterInfo, entryPointName string) string {
+	switch {
+	case routerInfo.TLS != nil:
+		return "TLS routers don't handle the plain HTTP requests of the playground"
+	case routerInfo.Status == runtime.StatusDisabled:
+		if len(routerInfo.Err) == 0 {
+			return "the router is disabled"
+		}
+
+		return "the router is disabled: " + strings.Join(routerInfo.Err, "; ")
+	case !slices.Contains(routerInfo.EntryPoints, entryPointName):
+		return fmt.Sprintf("the router is on the entrypoints %s, not on %q receiving the request",
+			strings.Join(routerInfo.EntryPoints, ", "), entryPointName)
+	default:
+		return ""
+	}
+}
+
+// middlewareChain returns the qualified names of the given middlewares in execution order, expanding the chains.
+// Like Traefik's middleware builder, names are qualified with the provider of the router or chain referencing them.
+func middlewareChain(ctx context.Context, runtimeConfig *runtime.Configuration, names, parents []string) []string {
+	var chain []string
+	for _, name := range names {
+		qualifiedName := serverprovider.GetQualifiedName(ctx, name)
+
+		// Recursive chains are reported as errors by the middleware builder.
+		if slices.Contains(parents, qualifiedName) {
+			continue
+		}
+
+		chain = append(chain, qualifiedName)
+
+		middleware, ok := runtimeConfig.Middlewares[qualifiedName]
+		if !ok || middleware.Chain == nil {
+			continue
+		}
+
+		chainCtx := serverprovider.AddInContext(ctx, qualifiedName)
+		chain = append(chain, middlewareChain(chainCtx, runtimeConfig, middleware.Chain.Middlewares, append(parents, qualifiedName))...)
+	}
+
+	return chain
+}
+
+// Middlewares returns the qualified names of the middlewares run by the given router, in execution order.
+func (m *routerMatcher) Middlewares(routerName string) []string {
+	return m.middlewares[routerName]
+}
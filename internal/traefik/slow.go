@@ -0,0 +1,60 @@
+package traefik
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+)
+
+const (
+	defaultSlowDelay = 200 * time.Millisecond
+	maxSlowDelay     = time.Second
+)
+
+// Slow is a fake server taking its time to respond, meant to be used to keep requests in flight, such as to exceed
+// the limit of the inFlightReq middleware. It responds 200 OK once the "delay" query parameter (a duration such as
+// "500ms") has elapsed, 200ms by default and 1s at most.
+type Slow struct{}
+
+// NewSlow creates a new Slow server.
+func NewSlow() *httptest.Server {
+	return httptest.NewServer(newSlowHandler())
+}
+
+func newSlowHandler() http.Handler {
+	s := &Slow{}
+
+	handler := http.NewServeMux()
+	handler.HandleFunc("/", s.handle)
+
+	return handler
+}
+
+func (s *Slow) handle(rw http.ResponseWriter, req *http.Request) {
+	delay := defaultSlowDelay
+	if value := req.URL.Query().Get("delay"); value != "" {
+		parsed, err := time.ParseDuration(value)
+		if err != nil || parsed < 0 || parsed > maxSlowDelay {
+			http.Error(rw, fmt.Sprintf("delay must be between 0s and %s", maxSlowDelay), http.StatusBadRequest)
+
+			return
+		}
+
+		delay = parsed
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-req.Context().Done():
+		return
+	}
+
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.WriteHeader(http.StatusOK)
+
+	_, _ = fmt.Fprintf(rw, "Responded after %s\n", delay)
+}
@@ -0,0 +1,328 @@
+package traefik
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	ptypes "github.com/traefik/paerser/types"
+	"github.com/traefik/traefik/v3/cmd"
+	"github.com/traefik/traefik/v3/pkg/config/static"
+	"gopkg.in/yaml.v3"
+)
+
+// maxStaticEntryPoints is the maximum number of entrypoints a static configuration can set options on.
+const maxStaticEntryPoints = 5
+
+// allowedStaticOptions is the tree of the static configuration options a user can set, a "*" key matching any
+// name. Only the options taking effect in the playground are allowed: the others, such as the address of the
+// entrypoints, the providers or HTTP/3, either can't be emulated or would reach outside the sandbox.
+//
+//nolint:gochecknoglobals // Read-only.
+var allowedStaticOptions = staticOptions{
+	"entryPoints": staticOptions{
+		"*": staticOptions{
+			"transport": staticOptions{
+				"respondingTimeouts": staticOptions{
+					"readTimeout": nil,
+				},
+			},
+			"forwardedHeaders": staticOptions{
+				"insecure":   nil,
+				"trustedIPs": nil,
+				"connection": nil,
+			},
+		},
+	},
+	"serversTransport": staticOptions{
+		"forwardingTimeouts": staticOptions{
+			"dialTimeout":           nil,
+			"responseHeaderTimeout": nil,
+			"idleConnTimeout":       nil,
+		},
+	},
+}
+
+// staticOptions is a tree of static configuration options, the leaves being nil.
+type staticOptions map[string]staticOptions
+
+// staticConfigFile is the static configuration set by a user, restricted to the allowedStaticOptions.
+type staticConfigFile struct {
+	EntryPoints      map[string]*staticEntryPoint `yaml:"entryPoints"`
+	ServersTransport *staticServersTransport      `yaml:"serversTransport"`
+}
+
+type staticEntryPoint struct {
+	Transport        *staticEntryPointTransport `yaml:"transport"`
+	ForwardedHeaders *static.ForwardedHeaders   `yaml:"forwardedHeaders"`
+}
+
+type staticEntryPointTransport struct {
+	RespondingTimeouts *staticRespondingTimeouts `yaml:"respondingTimeouts"`
+}
+
+type staticRespondingTimeouts struct {
+	ReadTimeout *ptypes.Duration `yaml:"readTimeout"`
+}
+
+type staticServersTransport struct {
+	ForwardingTimeouts *staticForwardingTimeouts `yaml:"forwardingTimeouts"`
+}
+
+type staticForwardingTimeouts struct {
+	DialTimeout           *ptypes.Duration `yaml:"dialTimeout"`
+	ResponseHeaderTimeout *ptypes.Duration `yaml:"responseHeaderTimeout"`
+	IdleConnTimeout       *ptypes.Duration `yaml:"idleConnTimeout"`
+}
+
+// ValidateStaticConfig checks that the given static configuration, in YAML, only sets the options allowed in the
+// playground and is valid once merged into the defaults of the fake Traefik instances.
+func ValidateStaticConfig(staticConfig string) error {
+	_, err := newStaticConfiguration(Options{StaticConfig: staticConfig})
+
+	return err
+}
+
+// parseStaticConfig parses the given static configuration, in YAML, rejecting the options which aren't allowed.
+func parseStaticConfig(staticConfig string) (staticConfigFile, error) {
+	var config staticConfigFile
+	if strings.TrimSpace(staticConfig) == "" {
+		return config, nil
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(staticConfig), &root); err != nil {
+		return staticConfigFile{}, fmt.Errorf("parsing static configuration: %w", err)
+	}
+
+	if len(root.Content) > 0 {
+		if err := checkStaticOptions(root.Content[0], allowedStaticOptions, ""); err != nil {
+			return staticConfigFile{}, err
+		}
+	}
+
+	decoder := yaml.NewDecoder(strings.NewReader(staticConfig))
+	decoder.KnownFields(true)
+
+	if err := decoder.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+		return staticConfigFile{}, fmt.Errorf("parsing static configuration: %w", err)
+	}
+
+	if len(config.EntryPoints) > maxStaticEntryPoints {
+		return staticConfigFile{}, fmt.Errorf("too many entrypoints in the static configuration (max: %d)", maxStaticEntryPoints)
+	}
+
+	return config, nil
+}
+
+// checkStaticOptions checks that the options set by the given node, found at the given path, are in the given tree
+// of allowed options.
+func checkStaticOptions(node *yaml.Node, allowed staticOptions, path string) error {
+	if allowed == nil {
+		return nil
+	}
+
+	if node.Kind != yaml.MappingNode {
+		if node.Tag == "!!null" {
+			return nil
+		}
+
+		return fmt.Errorf("static configuration option %q must be a mapping", strings.TrimPrefix(path, "."))
+	}
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		name := node.Content[i].Value
+		optionPath := path + "." + name
+
+		children, ok := allowed[name]
+		if !ok {
+			children, ok = allowed["*"]
+		}
+		if !ok {
+			return fmt.Errorf("static configuration option %q isn't supported, only %s can be set",
+				strings.TrimPrefix(optionPath, "."), strings.Join(allowedStaticOptionPaths(allowedStaticOptions, ""), ", "))
+		}
+
+		if err := checkStaticOptions(node.Content[i+1], children, optionPath); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// allowedStaticOptionPaths returns the paths of the leaves of the given tree of options, sorted.
+func allowedStaticOptionPaths(options staticOptions, path string) []string {
+	var paths []string
+	for name, children := range options {
+		if name == "*" {
+			name = "<name>"
+		}
+
+		if children == nil {
+			paths = append(paths, path+name)
+
+			continue
+		}
+
+		paths = append(paths, allowedStaticOptionPaths(children, path+name+".")...)
+	}
+
+	slices.Sort(paths)
+
+	return paths
+}
+
+// newStaticConfiguration returns the static configuration of a fake Traefik instance with the given Options: the
+// "web" and "udp" entrypoints, along with those named by the static configuration of the Options, with its options
+// merged into the defaults.
+func newStaticConfiguration(options Options) (static.Configuration, error) {
+	config, err := parseStaticConfig(options.StaticConfig)
+	if err != nil {
+		return static.Configuration{}, err
+	}
+
+	forwardedHeaders, err := newForwardedHeaders(options.TrustedIPs)
+	if err != nil {
+		return static.Configuration{}, err
+	}
+
+	newHTTPEntryPoint := func() *static.EntryPoint {
+		entryPoint := &static.EntryPoint{Address: ":80"}
+		entryPoint.SetDefaults()
+		entryPoint.ForwardedHeaders = forwardedHeaders
+
+		return entryPoint
+	}
+
+	udpEntryPoint := &static.EntryPoint{Address: ":53/udp"}
+	udpEntryPoint.SetDefaults()
+
+	staticConfig := cmd.NewTraefikConfiguration().Configuration
+	staticConfig.EntryPoints = map[string]*static.EntryPoint{
+		httpEntrypoint: newHTTPEntryPoint(),
+		udpEntrypoint:  udpEntryPoint,
+	}
+
+	for name, settings := range config.EntryPoints {
+		entryPoint, ok := staticConfig.EntryPoints[name]
+		if !ok {
+			entryPoint = newHTTPEntryPoint()
+			staticConfig.EntryPoints[name] = entryPoint
+		}
+
+		if settings == nil {
+			continue
+		}
+
+		// The forwarded headers settings of the entrypoint take precedence over the trusted IPs of the Options.
+		if settings.ForwardedHeaders != nil {
+			entryPoint.ForwardedHeaders = settings.ForwardedHeaders
+		}
+
+		if settings.Transport != nil && settings.Transport.RespondingTimeouts != nil {
+			setDuration(&entryPoint.Transport.RespondingTimeouts.ReadTimeout, settings.Transport.RespondingTimeouts.ReadTimeout)
+		}
+	}
+
+	if config.ServersTransport != nil && config.ServersTransport.ForwardingTimeouts != nil {
+		timeouts := config.ServersTransport.ForwardingTimeouts
+
+		if staticConfig.ServersTransport.ForwardingTimeouts == nil {
+			staticConfig.ServersTransport.ForwardingTimeouts = &static.ForwardingTimeouts{}
+			staticConfig.ServersTransport.ForwardingTimeouts.SetDefaults()
+		}
+
+		setDuration(&staticConfig.ServersTransport.ForwardingTimeouts.DialTimeout, timeouts.DialTimeout)
+		setDuration(&staticConfig.ServersTransport.ForwardingTimeouts.ResponseHeaderTimeout, timeouts.ResponseHeaderTimeout)
+		setDuration(&staticConfig.ServersTransport.ForwardingTimeouts.IdleConnTimeout, timeouts.IdleConnTimeout)
+	}
+
+	if err = staticConfig.ValidateConfiguration(); err != nil {
+		return static.Configuration{}, fmt.Errorf("validating static configuration: %w", err)
+	}
+
+	for name, entryPoint := range staticConfig.EntryPoints {
+		if entryPoint.ForwardedHeaders == nil {
+			continue
+		}
+
+		if _, err = newForwardedHeaders(entryPoint.ForwardedHeaders.TrustedIPs); err != nil {
+			return static.Configuration{}, fmt.Errorf("entrypoint %q: %w", name, err)
+		}
+	}
+
+	return staticConfig, nil
+}
+
+// setDuration sets the given duration to the given value, if any.
+func setDuration(duration *ptypes.Duration, value *ptypes.Duration) {
+	if value != nil {
+		*duration = *value
+	}
+}
+
+// withReadTimeout wraps the given entrypoint handler so that the body of the requests can't be read once the given
+// timeout has elapsed since they were received, as with the respondingTimeouts.readTimeout option of Traefik's
+// entrypoints. The connection of a client too slow to send its request fails the same way.
+func withReadTimeout(timeout time.Duration, handler http.Handler) http.Handler {
+	if timeout <= 0 {
+		return handler
+	}
+
+	return http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if req.Body != nil && req.Body != http.NoBody {
+			body := &readTimeoutBody{ReadCloser: req.Body, timer: time.NewTimer(timeout)}
+			defer body.timer.Stop()
+
+			req.Body = body
+		}
+
+		handler.ServeHTTP(rw, req)
+	})
+}
+
+// readTimeoutBody is a request body whose reads fail with os.ErrDeadlineExceeded once its timer has fired, as the
+// reads of a connection past its deadline do. A body read entirely keeps reporting the end of the body.
+type readTimeoutBody struct {
+	io.ReadCloser
+
+	timer *time.Timer
+	// err is the error the body stopped being read with, such as io.EOF.
+	err error
+}
+
+type readResult struct {
+	n   int
+	err error
+}
+
+func (b *readTimeoutBody) Read(p []byte) (int, error) {
+	if b.err != nil {
+		return 0, b.err
+	}
+
+	// The read is made on a buffer of its own, as it may still be in progress once the deadline is exceeded.
+	buf := make([]byte, len(p))
+	resultCh := make(chan readResult, 1)
+	go func() {
+		n, err := b.ReadCloser.Read(buf)
+		resultCh <- readResult{n: n, err: err}
+	}()
+
+	select {
+	case result := <-resultCh:
+		b.err = result.err
+
+		return copy(p, buf[:result.n]), result.err
+	case <-b.timer.C:
+		b.err = os.ErrDeadlineExceeded
+
+		return 0, b.err
+	}
+}
@@ -0,0 +1,103 @@
+package traefik
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"strings"
+)
+
+// StreamWriter is an http.ResponseWriter writing the response in HTTP/1.1 wire format as soon as it's produced.
+// The body is chunked so that it can be read back with http.ReadResponse while it's still being written.
+type StreamWriter struct {
+	w       io.Writer
+	header  http.Header
+	chunked io.WriteCloser
+}
+
+// NewStreamWriter creates a new StreamWriter writing to the given writer.
+func NewStreamWriter(w io.Writer) *StreamWriter {
+	return &StreamWriter{
+		w:      w,
+		header: make(http.Header),
+	}
+}
+
+// Header returns the response headers.
+func (s *StreamWriter) Header() http.Header {
+	return s.header
+}
+
+// WriteHeader writes the status line and the headers. Informational responses are skipped.
+func (s *StreamWriter) WriteHeader(statusCode int) {
+	if s.chunked != nil || statusCode < http.StatusOK {
+		return
+	}
+
+	s.header.Del("Content-Length")
+	s.header.Set("Transfer-Encoding", "chunked")
+
+	s.chunked = httputil.NewChunkedWriter(s.w)
+
+	_, _ = fmt.Fprintf(s.w, "HTTP/1.1 %03d %s\r\n", statusCode, http.StatusText(statusCode))
+	_ = s.header.Write(s.w)
+	_, _ = io.WriteString(s.w, "\r\n")
+}
+
+// Write writes a chunk of the body, writing the headers first if needed.
+func (s *StreamWriter) Write(p []byte) (int, error) {
+	if s.chunked == nil {
+		s.WriteHeader(http.StatusOK)
+	}
+
+	return s.chunked.Write(p)
+}
+
+// Flush does nothing as chunks are written as soon as they are received. It lets handlers know that the response
+// can be streamed.
+func (s *StreamWriter) Flush() {}
+
+// Close terminates the body. It must be called once the response has been written.
+func (s *StreamWriter) Close() error {
+	if s.chunked == nil {
+		s.WriteHeader(http.StatusOK)
+	}
+
+	if err := s.chunked.Close(); err != nil {
+		return fmt.Errorf("closing body: %w", err)
+	}
+
+	// The body ends with the trailer section.
+	if err := s.trailers().Write(s.w); err != nil {
+		return fmt.Errorf("writing trailers: %w", err)
+	}
+
+	if _, err := io.WriteString(s.w, "\r\n"); err != nil {
+		return fmt.Errorf("closing body: %w", err)
+	}
+
+	return nil
+}
+
+// trailers returns the trailers set by the handler: the headers announced by the Trailer header and those prefixed
+// with http.TrailerPrefix.
+func (s *StreamWriter) trailers() http.Header {
+	trailers := make(http.Header)
+	for _, names := range s.header.Values("Trailer") {
+		for name := range strings.SplitSeq(names, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if values, ok := s.header[name]; ok {
+				trailers[name] = values
+			}
+		}
+	}
+
+	for name, values := range s.header {
+		if trailerName, ok := strings.CutPrefix(name, http.TrailerPrefix); ok {
+			trailers[http.CanonicalHeaderKey(trailerName)] = values
+		}
+	}
+
+	return trailers
+}
@@ -0,0 +1,39 @@
+package traefik
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamWriter_trailers(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+
+	w := NewStreamWriter(&buf)
+	w.Header().Set("Trailer", "X-Checksum")
+	w.WriteHeader(http.StatusOK)
+
+	_, err := io.WriteString(w, "body")
+	require.NoError(t, err)
+
+	w.Header().Set("X-Checksum", "abc")
+	w.Header().Set(http.TrailerPrefix+"X-Unannounced", "def")
+
+	require.NoError(t, w.Close())
+
+	res, err := http.ReadResponse(bufio.NewReader(&buf), nil)
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, "body", string(body))
+	assert.Equal(t, http.Header{"X-Checksum": {"abc"}, "X-Unannounced": {"def"}}, res.Trailer)
+}
@@ -0,0 +1,202 @@
+package traefik
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/traefik/traefik/v3/pkg/config/dynamic"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigNode is a node of a dynamic configuration parsed as a tree, along with its position in the YAML document.
+type ConfigNode struct {
+	// Key is the key of the node when it's the value of a mapping.
+	Key         string    `json:"key,omitempty"`
+	KeyPosition *Position `json:"keyPosition,omitempty"`
+
+	// Kind is one of "mapping", "sequence", "scalar" or "alias".
+	Kind string `json:"kind"`
+	// Tag is the YAML type of the node, such as "str", "int", "bool", "null", "map" or "seq".
+	Tag string `json:"tag,omitempty"`
+	// Type is the type of the field expected at this position, as documented on FieldSchema.Type. It is empty
+	// when the node is not part of the dynamic configuration, in which case Unknown is true.
+	Type    string `json:"type,omitempty"`
+	Unknown bool   `json:"unknown,omitempty"`
+
+	// Value is the value of a scalar, or the name of the anchor referenced by an alias.
+	Value    string   `json:"value,omitempty"`
+	Position Position `json:"position"`
+
+	HeadComment string `json:"headComment,omitempty"`
+	LineComment string `json:"lineComment,omitempty"`
+	FootComment string `json:"footComment,omitempty"`
+
+	Children []ConfigNode `json:"children,omitempty"`
+}
+
+// Position is a position in a YAML document. Lines and columns start at 1.
+type Position struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// TokenizeDynamicConfig parses the given dynamic configuration as a tree of ConfigNode. Unlike unmarshaling, it
+// keeps comments and positions, and reports the nodes which are not part of the dynamic configuration instead of
+// ignoring them. Types are resolved by reflection on dynamic.Configuration using the YAML field names.
+func TokenizeDynamicConfig(dynamicConfig string) (ConfigNode, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(dynamicConfig), &document); err != nil {
+		return ConfigNode{}, fmt.Errorf("parsing YAML: %w", err)
+	}
+
+	configType := reflect.TypeOf(dynamic.Configuration{})
+
+	// An empty document has no content.
+	if document.Kind != yaml.DocumentNode || len(document.Content) == 0 {
+		return ConfigNode{
+			Kind:     "mapping",
+			Type:     typeName(configType),
+			Position: Position{Line: 1, Column: 1},
+		}, nil
+	}
+
+	root := tokenize(document.Content[0], configType)
+	root.HeadComment = joinComments(document.HeadComment, root.HeadComment)
+	root.FootComment = joinComments(root.FootComment, document.FootComment)
+
+	return root, nil
+}
+
+// tokenize builds the ConfigNode of the given YAML node, expected to hold a value of the given type.
+// A nil type means the node is not part of the dynamic configuration.
+func tokenize(node *yaml.Node, t reflect.Type) ConfigNode {
+	configNode := ConfigNode{
+		Tag:         strings.TrimPrefix(node.ShortTag(), "!!"),
+		Position:    Position{Line: node.Line, Column: node.Column},
+		HeadComment: node.HeadComment,
+		LineComment: node.LineComment,
+		FootComment: node.FootComment,
+	}
+
+	if t != nil {
+		t = indirect(t)
+		configNode.Type = typeName(t)
+	} else {
+		configNode.Unknown = true
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		configNode.Kind = "mapping"
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+
+			child := tokenize(value, valueType(t, key.Value))
+			child.Key = key.Value
+			child.KeyPosition = &Position{Line: key.Line, Column: key.Column}
+			child.HeadComment = joinComments(key.HeadComment, child.HeadComment)
+			child.LineComment = joinComments(key.LineComment, child.LineComment)
+			child.FootComment = joinComments(child.FootComment, key.FootComment)
+
+			configNode.Children = append(configNode.Children, child)
+		}
+	case yaml.SequenceNode:
+		configNode.Kind = "sequence"
+
+		for _, item := range node.Content {
+			configNode.Children = append(configNode.Children, tokenize(item, itemType(t)))
+		}
+	case yaml.AliasNode:
+		configNode.Kind = "alias"
+		configNode.Value = node.Value
+	default:
+		configNode.Kind = "scalar"
+		configNode.Value = node.Value
+	}
+
+	return configNode
+}
+
+// valueType returns the type of the value held under the given key by a value of the given type.
+// It returns nil if the key is not part of the type.
+func valueType(t reflect.Type, key string) reflect.Type {
+	if t == nil {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem()
+	case reflect.Interface:
+		// Values of free-form options, such as plugin configurations, can be anything.
+		return t
+	case reflect.Struct:
+		if isDuration(t) {
+			return nil
+		}
+
+		field, ok := fieldByYAMLName(t, key)
+		if !ok {
+			return nil
+		}
+
+		return field.Type
+	default:
+		return nil
+	}
+}
+
+// itemType returns the type of the items of a sequence held by a value of the given type.
+// It returns nil if the type can't hold a sequence.
+func itemType(t reflect.Type) reflect.Type {
+	if t == nil {
+		return nil
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		return t.Elem()
+	case reflect.Interface:
+		return t
+	default:
+		return nil
+	}
+}
+
+// fieldByYAMLName returns the field of the given struct type whose YAML name is the given name, looking into
+// inlined structs.
+func fieldByYAMLName(t reflect.Type, name string) (reflect.StructField, bool) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		if field.Anonymous && field.Tag.Get("yaml") == ",inline" {
+			if inlined, ok := fieldByYAMLName(indirect(field.Type), name); ok {
+				return inlined, true
+			}
+
+			continue
+		}
+
+		if fieldName, ok := yamlFieldName(field); ok && fieldName == name {
+			return field, true
+		}
+	}
+
+	return reflect.StructField{}, false
+}
+
+func joinComments(comments ...string) string {
+	var nonEmpty []string
+	for _, comment := range comments {
+		if comment != "" {
+			nonEmpty = append(nonEmpty, comment)
+		}
+	}
+
+	return strings.Join(nonEmpty, "\n")
+}
@@ -0,0 +1,221 @@
+package traefik
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenizeDynamicConfig(t *testing.T) {
+	t.Parallel()
+
+	dynamicConfig := `# Routes the API.
+http:
+  routers:
+    api:
+      rule: PathPrefix(` + "`/api`" + `) # API only
+      entryPoints: [web]
+      priority: 10
+  services:
+    api:
+      loadBalancer:
+        servers:
+          - url: http://10.10.10.10
+        healthCheck:
+          interval: 10s
+`
+
+	got, err := TokenizeDynamicConfig(dynamicConfig)
+	require.NoError(t, err)
+
+	want := ConfigNode{
+		Kind:     "mapping",
+		Tag:      "map",
+		Type:     "object",
+		Position: Position{Line: 2, Column: 1},
+		Children: []ConfigNode{{
+			Key:         "http",
+			KeyPosition: &Position{Line: 2, Column: 1},
+			Kind:        "mapping",
+			Tag:         "map",
+			Type:        "object",
+			Position:    Position{Line: 3, Column: 3},
+			HeadComment: "# Routes the API.",
+			Children: []ConfigNode{
+				{
+					Key:         "routers",
+					KeyPosition: &Position{Line: 3, Column: 3},
+					Kind:        "mapping",
+					Tag:         "map",
+					Type:        "map<string,object>",
+					Position:    Position{Line: 4, Column: 5},
+					Children: []ConfigNode{{
+						Key:         "api",
+						KeyPosition: &Position{Line: 4, Column: 5},
+						Kind:        "mapping",
+						Tag:         "map",
+						Type:        "object",
+						Position:    Position{Line: 5, Column: 7},
+						Children: []ConfigNode{
+							{
+								Key:         "rule",
+								KeyPosition: &Position{Line: 5, Column: 7},
+								Kind:        "scalar",
+								Tag:         "str",
+								Type:        "string",
+								Value:       "PathPrefix(`/api`)",
+								Position:    Position{Line: 5, Column: 13},
+								LineComment: "# API only",
+							},
+							{
+								Key:         "entryPoints",
+								KeyPosition: &Position{Line: 6, Column: 7},
+								Kind:        "sequence",
+								Tag:         "seq",
+								Type:        "array<string>",
+								Position:    Position{Line: 6, Column: 20},
+								Children: []ConfigNode{{
+									Kind:     "scalar",
+									Tag:      "str",
+									Type:     "string",
+									Value:    "web",
+									Position: Position{Line: 6, Column: 21},
+								}},
+							},
+							{
+								Key:         "priority",
+								KeyPosition: &Position{Line: 7, Column: 7},
+								Kind:        "scalar",
+								Tag:         "int",
+								Type:        "integer",
+								Value:       "10",
+								Position:    Position{Line: 7, Column: 17},
+							},
+						},
+					}},
+				},
+				{
+					Key:         "services",
+					KeyPosition: &Position{Line: 8, Column: 3},
+					Kind:        "mapping",
+					Tag:         "map",
+					Type:        "map<string,object>",
+					Position:    Position{Line: 9, Column: 5},
+					Children: []ConfigNode{{
+						Key:         "api",
+						KeyPosition: &Position{Line: 9, Column: 5},
+						Kind:        "mapping",
+						Tag:         "map",
+						Type:        "object",
+						Position:    Position{Line: 10, Column: 7},
+						Children: []ConfigNode{{
+							Key:         "loadBalancer",
+							KeyPosition: &Position{Line: 10, Column: 7},
+							Kind:        "mapping",
+							Tag:         "map",
+							Type:        "object",
+							Position:    Position{Line: 11, Column: 9},
+							Children: []ConfigNode{
+								{
+									Key:         "servers",
+									KeyPosition: &Position{Line: 11, Column: 9},
+									Kind:        "sequence",
+									Tag:         "seq",
+									Type:        "array<object>",
+									Position:    Position{Line: 12, Column: 11},
+									Children: []ConfigNode{{
+										Kind:     "mapping",
+										Tag:      "map",
+										Type:     "object",
+										Position: Position{Line: 12, Column: 13},
+										Children: []ConfigNode{{
+											Key:         "url",
+											KeyPosition: &Position{Line: 12, Column: 13},
+											Kind:        "scalar",
+											Tag:         "str",
+											Type:        "string",
+											Value:       "http://10.10.10.10",
+											Position:    Position{Line: 12, Column: 18},
+										}},
+									}},
+								},
+								{
+									Key:         "healthCheck",
+									KeyPosition: &Position{Line: 13, Column: 9},
+									Kind:        "mapping",
+									Tag:         "map",
+									Type:        "object",
+									Position:    Position{Line: 14, Column: 11},
+									Children: []ConfigNode{{
+										Key:         "interval",
+										KeyPosition: &Position{Line: 14, Column: 11},
+										Kind:        "scalar",
+										Tag:         "str",
+										Type:        "duration",
+										Value:       "10s",
+										Position:    Position{Line: 14, Column: 21},
+									}},
+								},
+							},
+						}},
+					}},
+				},
+			},
+		}},
+	}
+
+	assert.Equal(t, want, got)
+}
+
+func TestTokenizeDynamicConfig_unknown(t *testing.T) {
+	t.Parallel()
+
+	dynamicConfig := `
+http:
+  routers:
+    api:
+      rul: PathPrefix(` + "`/api`" + `)
+  middlewares:
+    plugin:
+      plugin:
+        example:
+          options: [1, {a: b}]
+`
+
+	got, err := TokenizeDynamicConfig(dynamicConfig)
+	require.NoError(t, err)
+
+	routers := got.Children[0].Children[0]
+	rule := routers.Children[0].Children[0]
+	assert.Equal(t, "rul", rule.Key)
+	assert.True(t, rule.Unknown)
+	assert.Empty(t, rule.Type)
+
+	// Plugin options are free-form.
+	options := got.Children[0].Children[1].Children[0].Children[0].Children[0].Children[0]
+	assert.Equal(t, "options", options.Key)
+	assert.False(t, options.Unknown)
+	assert.Equal(t, "any", options.Type)
+
+	for _, item := range options.Children {
+		assert.False(t, item.Unknown)
+		assert.Equal(t, "any", item.Type)
+	}
+}
+
+func TestTokenizeDynamicConfig_empty(t *testing.T) {
+	t.Parallel()
+
+	got, err := TokenizeDynamicConfig("")
+	require.NoError(t, err)
+
+	assert.Equal(t, ConfigNode{Kind: "mapping", Type: "object", Position: Position{Line: 1, Column: 1}}, got)
+}
+
+func TestTokenizeDynamicConfig_invalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := TokenizeDynamicConfig("http: [")
+	require.Error(t, err)
+}
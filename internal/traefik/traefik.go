@@ -1,18 +1,28 @@
 package traefik
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"maps"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"regexp"
 	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/traefik/traefik/v3/cmd"
+	"github.com/rs/zerolog/log"
 	"github.com/traefik/traefik/v3/pkg/config/dynamic"
 	"github.com/traefik/traefik/v3/pkg/config/runtime"
 	"github.com/traefik/traefik/v3/pkg/config/static"
+	"github.com/traefik/traefik/v3/pkg/middlewares/requestdecorator"
 	httpmuxer "github.com/traefik/traefik/v3/pkg/muxer/http"
 	"github.com/traefik/traefik/v3/pkg/provider/aggregator"
 	"github.com/traefik/traefik/v3/pkg/proxy/httputil"
@@ -20,59 +30,235 @@ import (
 	"github.com/traefik/traefik/v3/pkg/server"
 	"github.com/traefik/traefik/v3/pkg/server/middleware"
 	"github.com/traefik/traefik/v3/pkg/server/router"
+	udprouter "github.com/traefik/traefik/v3/pkg/server/router/udp"
 	"github.com/traefik/traefik/v3/pkg/server/service"
+	udpservice "github.com/traefik/traefik/v3/pkg/server/service/udp"
 	"github.com/traefik/traefik/v3/pkg/tls"
+	"github.com/traefik/traefik/v3/pkg/udp"
 )
 
-const httpEntrypoint = "web"
+const (
+	httpEntrypoint = "web"
+	udpEntrypoint  = "udp"
+)
+
+// maxDatagramSize is the maximum size of a UDP datagram.
+const maxDatagramSize = 65535
+
+// flakyPublicAddr is the address of the flaky@playground service, as written in the dynamic configuration.
+//
+//nolint:gochecknoglobals // Read-only.
+var flakyPublicAddr = &net.TCPAddr{IP: net.IPv4(10, 10, 10, 15), Port: 80}
 
 // Traefik is a fake Traefik instance.
 type Traefik struct {
 	staticConfig  static.Configuration
 	dynamicConfig *dynamic.Configuration
 
-	handlerMu sync.RWMutex
-	handlers  map[string]http.Handler
+	serverInjector *ServerInjector
+
+	handlerMu   sync.RWMutex
+	handlers    map[string]http.Handler
+	udpHandlers map[string]udp.Handler
+	// httpEntryPoints and udpEntryPoints are the names of the entrypoints, in the order they are looked up for a
+	// router handling the traffic.
+	httpEntryPoints []string
+	udpEntryPoints  []string
+	routerMatchers  map[string]*routerMatcher
+	runtimeConfig   *runtime.Configuration
+	stubbedPlugins  []string
+
+	udpListener *udp.Listener
+
+	// backendConns counts the connections accepted by the playground backends.
+	backendConns atomic.Int64
+	// backendRequests counts the requests received by the playground backends.
+	backendRequests atomic.Int64
+	// upstreamHosts holds the addresses of the playground backends meant to be the service of a router.
+	upstreamHosts map[string]struct{}
+	// upstreamRequests counts the requests Traefik attempted to send to the upstreamHosts.
+	upstreamRequests atomic.Int64
+	// lastBackend is the public URL of the upstreamHost Traefik last attempted to send a request to.
+	lastBackend atomic.Pointer[string]
+	// lastForwardedHeader holds the headers of the request Traefik last attempted to send to an upstreamHost.
+	lastForwardedHeader atomic.Pointer[http.Header]
+
+	// flaky fails the requests sent to flakyHost, see Flaky.
+	flaky     *Flaky
+	flakyHost string
 
 	readyFuncs []func()
+	// stopped is closed once the instance has stopped, see Stopped.
+	stopped chan struct{}
 }
 
-// NewTraefik creates a new fake Traefik instance.
-func NewTraefik(dynamicConfig *dynamic.Configuration) (*Traefik, error) {
-	entryPoint := static.EntryPoint{Address: ":80"}
-	entryPoint.SetDefaults()
-
-	staticConfig := cmd.NewTraefikConfiguration().Configuration
-	staticConfig.EntryPoints = map[string]*static.EntryPoint{
-		httpEntrypoint: &entryPoint,
-	}
+// Options are the options of a fake Traefik instance, set on top of the defaults of the playground.
+type Options struct {
+	// TrustedIPs are the IPs and CIDRs the HTTP entrypoints trust the forwarded headers, such as X-Forwarded-For, of
+	// the requests sent from, as with their forwardedHeaders.trustedIPs option. The forwarded headers of the other
+	// requests are replaced. Without trusted IPs, the forwarded headers are left untouched.
+	TrustedIPs []string
+	// StaticConfig is the static configuration, in YAML, merged into the defaults. Only a few options can be set,
+	// see ValidateStaticConfig. The forwardedHeaders option of an entrypoint takes precedence over TrustedIPs.
+	StaticConfig string
+}
 
-	if err := staticConfig.ValidateConfiguration(); err != nil {
-		return nil, fmt.Errorf("validating static configuration: %w", err)
+// NewTraefik creates a new fake Traefik instance with the given Options.
+// Alongside the "web" and "udp" entrypoints, an entrypoint is created for each entrypoint referenced by the
+// routers, so that configurations copied from instances naming their entrypoints differently still bind.
+func NewTraefik(dynamicConfig *dynamic.Configuration, options Options) (*Traefik, error) {
+	staticConfig, err := newStaticConfiguration(options)
+	if err != nil {
+		return nil, err
 	}
 
 	return &Traefik{
 		staticConfig:  staticConfig,
 		dynamicConfig: dynamicConfig,
+		stopped:       make(chan struct{}),
 	}, nil
 }
 
-// OnReady registers a function to be called when the instance is ready to receive HTTP traffic.
+// OnReady registers a function to be called when the instance is ready to receive HTTP and UDP traffic.
 func (t *Traefik) OnReady(readyFn func()) {
 	t.readyFuncs = append(t.readyFuncs, readyFn)
 }
 
-// Start starts the Traefik instance.
+// Start starts the Traefik instance. It stops once the given context ends, see Stopped.
 func (t *Traefik) Start(ctx context.Context) error {
-	whoami := NewWhoami()
+	whoami := t.startUpstream(newWhoamiHandler())
 
 	testServerInjector := NewServerInjector()
+	t.serverInjector = testServerInjector
+
 	testServerInjector.AddServer(Server{
 		Name:       "whoami@playground",
-		PublicURL:  "http://10.10.10.10",
+		PublicURL:  whoamiURL,
 		PrivateURL: whoami.URL,
 	})
 
+	largeWhoami := t.startUpstream(newLargeWhoamiHandler())
+
+	testServerInjector.AddServer(Server{
+		Name:       "whoami-large@playground",
+		PublicURL:  largeWhoamiURL,
+		PrivateURL: largeWhoami.URL,
+	})
+
+	auth := t.startBackend(newAuthHandler())
+
+	testServerInjector.AddServer(Server{
+		Name:       "auth@playground",
+		PublicURL:  authURL,
+		PrivateURL: auth.URL,
+	})
+
+	errorPages := t.startBackend(newErrorPagesHandler())
+
+	testServerInjector.AddServer(Server{
+		Name:       "errors@playground",
+		PublicURL:  errorPagesURL,
+		PrivateURL: errorPages.URL,
+	})
+
+	events := t.startUpstream(newEventsHandler())
+
+	testServerInjector.AddServer(Server{
+		Name:       "events@playground",
+		PublicURL:  eventsURL,
+		PrivateURL: events.URL,
+	})
+
+	t.flaky = newFlaky()
+	flaky := t.startUpstream(t.flaky)
+	t.flakyHost = flaky.Listener.Addr().String()
+
+	testServerInjector.AddServer(Server{
+		Name:       "flaky@playground",
+		PublicURL:  flakyURL,
+		PrivateURL: flaky.URL,
+	})
+
+	slow := t.startUpstream(newSlowHandler())
+
+	testServerInjector.AddServer(Server{
+		Name:       "slow@playground",
+		PublicURL:  slowURL,
+		PrivateURL: slow.URL,
+	})
+
+	etagWhoami := t.startUpstream(newETagWhoamiHandler())
+
+	testServerInjector.AddServer(Server{
+		Name:       "whoami-etag@playground",
+		PublicURL:  etagWhoamiURL,
+		PrivateURL: etagWhoami.URL,
+	})
+
+	// Replicas of whoami, to experiment with load balancing, such as sticky sessions.
+	var replicas []*httptest.Server
+	for i, publicURL := range []string{whoami1URL, whoami2URL, whoami3URL} {
+		replica := t.startUpstream(newWhoamiHandler())
+		replicas = append(replicas, replica)
+
+		testServerInjector.AddServer(Server{
+			Name:       fmt.Sprintf("whoami-%d@playground", i+1),
+			PublicURL:  publicURL,
+			PrivateURL: replica.URL,
+		})
+	}
+
+	whoamiUDP, err := NewWhoamiUDP()
+	if err != nil {
+		return fmt.Errorf("creating UDP whoami: %w", err)
+	}
+
+	testServerInjector.AddUDPServer(UDPServer{
+		Name:           "whoami-udp@playground",
+		PublicAddress:  whoamiUDPAddress,
+		PrivateAddress: whoamiUDP.Addr(),
+	})
+
+	udpTimeout := time.Duration(t.staticConfig.EntryPoints[udpEntrypoint].UDP.Timeout)
+
+	t.udpListener, err = udp.Listen(net.ListenConfig{}, "udp", "127.0.0.1:0", udpTimeout)
+	if err != nil {
+		return fmt.Errorf("listening on UDP entrypoint: %w", err)
+	}
+
+	pool := safe.NewPool(ctx)
+
+	udpServed := make(chan struct{})
+	go func() {
+		defer close(udpServed)
+
+		t.serveUDP()
+	}()
+
+	go func() {
+		<-ctx.Done()
+
+		_ = t.udpListener.Close()
+		<-udpServed
+
+		_ = whoamiUDP.Close()
+		whoami.Close()
+		auth.Close()
+		largeWhoami.Close()
+		errorPages.Close()
+		events.Close()
+		flaky.Close()
+		slow.Close()
+		etagWhoami.Close()
+
+		for _, replica := range replicas {
+			replica.Close()
+		}
+
+		pool.Stop()
+		close(t.stopped)
+	}()
+
 	parser, err := httpmuxer.NewSyntaxParser()
 	if err != nil {
 		return fmt.Errorf("creating syntax parser: %w", err)
@@ -83,18 +269,29 @@ func (t *Traefik) Start(ctx context.Context) error {
 		return fmt.Errorf("adding file provider: %w", err)
 	}
 
-	pool := safe.NewPool(ctx)
-	defaultEntryPoints := []string{httpEntrypoint}
-	configWatcher := server.NewConfigurationWatcher(pool, providerAggregator, defaultEntryPoints, "file")
+	// Like Traefik does when no entrypoint is marked as default, routers without entrypoints use all of them.
+	defaultEntryPoints, _ := splitEntryPoints(withReferencedEntryPoints(t.staticConfig.EntryPoints, t.dynamicConfig))
+	configWatcher := server.NewConfigurationWatcher(pool, providerAggregator, defaultEntryPoints, providerName)
 
 	// When the dynamic configuration changes, rebuild the handlers and notify the listeners.
 	var firstConfigurationReceived bool
 	configWatcher.AddListener(func(config dynamic.Configuration) {
 		injectedDynamicConfig := testServerInjector.Inject(&config)
-		handlers := buildHandlers(ctx, pool, parser, t.staticConfig, *injectedDynamicConfig)
+		applyDefaults(injectedDynamicConfig)
+
+		handlers := buildHandlers(ctx, pool, parser, t.staticConfig, *injectedDynamicConfig, t.wrapRoundTripper)
 
 		t.handlerMu.Lock()
-		t.handlers = handlers
+		t.handlers = handlers.http
+		t.udpHandlers = handlers.udp
+		t.httpEntryPoints = handlers.httpEntryPoints
+		t.udpEntryPoints = handlers.udpEntryPoints
+		t.routerMatchers = handlers.routerMatchers
+		t.runtimeConfig = handlers.runtimeConfig
+		t.stubbedPlugins = handlers.stubbedPlugins
+		t.handlerMu.Unlock()
+
+		// Ready functions are called outside the lock as they may send traffic to the instance.
 		if !firstConfigurationReceived {
 			for _, readyFunc := range t.readyFuncs {
 				readyFunc()
@@ -102,7 +299,6 @@ func (t *Traefik) Start(ctx context.Context) error {
 
 			firstConfigurationReceived = true
 		}
-		t.handlerMu.Unlock()
 	})
 
 	configWatcher.Start()
@@ -110,47 +306,648 @@ func (t *Traefik) Start(ctx context.Context) error {
 	return nil
 }
 
+// Stopped returns a channel closed once the instance started with Start has stopped: the context given to Start
+// ended, the playground backends completed their requests and the configuration watcher has returned.
+func (t *Traefik) Stopped() <-chan struct{} {
+	return t.stopped
+}
+
 // Send sends an HTTP request to the fake Traefik instance.
-func (t *Traefik) Send(req *http.Request) (*http.Response, error) {
+// Alongside the response, it returns a Report of how the request was handled.
+func (t *Traefik) Send(req *http.Request) (*http.Response, Report, error) {
 	rw := httptest.NewRecorder()
 
-	handler, ok := t.handlers[httpEntrypoint]
+	report := t.Route(req)
+
+	var body *countingReader
+	if req.Body != nil && req.Body != http.NoBody {
+		body = &countingReader{ReadCloser: req.Body}
+		req.Body = body
+	}
+
+	// Middlewares, such as headers, modify the headers of the received request in place.
+	receivedHeader := req.Header.Clone()
+
+	backendConns := t.backendConns.Load()
+	backendRequests := t.backendRequests.Load()
+	upstreamRequests := t.upstreamRequests.Load()
+	t.lastBackend.Store(nil)
+	t.lastForwardedHeader.Store(nil)
+
+	if err := t.Stream(rw, req); err != nil {
+		return nil, Report{}, err
+	}
+
+	if backend := t.lastBackend.Load(); backend != nil {
+		report.Backend = *backend
+	}
+	if forwardedHeader := t.lastForwardedHeader.Load(); forwardedHeader != nil {
+		report.HeaderChanges = diffHeaders(receivedHeader, *forwardedHeader)
+	}
+
+	report.Metrics = Metrics{
+		BytesReceived:      int64(rw.Body.Len()),
+		BackendConnections: t.backendConns.Load() - backendConns,
+		BackendRequests:    t.backendRequests.Load() - backendRequests,
+		Attempts:           t.upstreamRequests.Load() - upstreamRequests,
+	}
+	if body != nil {
+		report.Metrics.BytesSent = body.n.Load()
+	}
+
+	return rw.Result(), report, nil
+}
+
+// SendBurst sends the given request count times back to back to the fake Traefik instance, and returns the
+// response to the last one. The Burst of the Report lists the outcome of every request, in order, while the rest
+// of the Report describes the last request. With keepCookies, the cookies set by the responses are sent along with
+// the next requests, as a browser would, such as to pin a sticky session to a server.
+func (t *Traefik) SendBurst(req *http.Request, count int, keepCookies bool) (*http.Response, Report, error) {
+	if count < 1 {
+		return nil, Report{}, errors.New("burst count must be positive")
+	}
+
+	var jar http.CookieJar
+	if keepCookies {
+		jar = newCookieJar()
+	}
+
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, Report{}, fmt.Errorf("reading request body: %w", err)
+		}
+	}
+
+	var (
+		res    *http.Response
+		report Report
+		burst  = make([]BurstResponse, 0, count)
+	)
+
+	for i := range count {
+		// Only the response to the last request is returned.
+		if res != nil {
+			_ = res.Body.Close()
+		}
+
+		burstReq := req.Clone(req.Context())
+		if body != nil {
+			burstReq.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		if jar != nil {
+			for _, cookie := range jar.Cookies(cookieURL(req)) {
+				burstReq.AddCookie(cookie)
+			}
+		}
+
+		var err error
+		if res, report, err = t.Send(burstReq); err != nil {
+			return nil, Report{}, fmt.Errorf("sending request %d: %w", i+1, err)
+		}
+
+		if jar != nil {
+			jar.SetCookies(cookieURL(req), res.Cookies())
+		}
+
+		burst = append(burst, BurstResponse{
+			StatusCode: res.StatusCode,
+			Limited:    res.StatusCode == http.StatusTooManyRequests && report.Metrics.BackendRequests == 0,
+			Backend:    report.Backend,
+		})
+	}
+
+	report.Burst = burst
+
+	return res, report, nil
+}
+
+type requestStatsKey struct{}
+
+// requestStats records the attempts of Traefik to send a single request to the playground services. Unlike the
+// counters of the instance, they aren't mixed up with the ones of the requests handled at the same time.
+type requestStats struct {
+	attempts        atomic.Int64
+	backend         atomic.Pointer[string]
+	forwardedHeader atomic.Pointer[http.Header]
+}
+
+// SendConcurrentBurst sends the given request count times at once to the fake Traefik instance, such as to exceed
+// the limit of an inFlightReq middleware, and returns the response to the last one. The Burst of the Report lists
+// the outcome of every request, in the order they were created, while its Metrics cover the whole burst and the
+// rest of the Report describes the last request.
+func (t *Traefik) SendConcurrentBurst(req *http.Request, count int) (*http.Response, Report, error) {
+	if count < 1 {
+		return nil, Report{}, errors.New("burst count must be positive")
+	}
+
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return nil, Report{}, fmt.Errorf("reading request body: %w", err)
+		}
+	}
+
+	report := t.Route(req)
+	receivedHeader := req.Header.Clone()
+
+	backendConns := t.backendConns.Load()
+	backendRequests := t.backendRequests.Load()
+	upstreamRequests := t.upstreamRequests.Load()
+
+	var (
+		wg        sync.WaitGroup
+		recorders = make([]*httptest.ResponseRecorder, count)
+		stats     = make([]*requestStats, count)
+		bodies    = make([]*countingReader, 0, count)
+		errs      = make([]error, count)
+	)
+
+	for i := range count {
+		recorders[i] = httptest.NewRecorder()
+		stats[i] = &requestStats{}
+
+		burstReq := req.Clone(context.WithValue(req.Context(), requestStatsKey{}, stats[i]))
+		if body != nil {
+			burstBody := &countingReader{ReadCloser: io.NopCloser(bytes.NewReader(body))}
+			bodies = append(bodies, burstBody)
+			burstReq.Body = burstBody
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if err := t.Stream(recorders[i], burstReq); err != nil {
+				errs[i] = fmt.Errorf("sending request %d: %w", i+1, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, Report{}, err
+	}
+
+	burst := make([]BurstResponse, 0, count)
+	for i, recorder := range recorders {
+		var backend string
+		if b := stats[i].backend.Load(); b != nil {
+			backend = *b
+		}
+
+		burst = append(burst, BurstResponse{
+			StatusCode: recorder.Code,
+			Limited:    recorder.Code == http.StatusTooManyRequests && stats[i].attempts.Load() == 0,
+			Backend:    backend,
+		})
+	}
+
+	last := recorders[count-1]
+
+	report.Backend = burst[count-1].Backend
+	if forwardedHeader := stats[count-1].forwardedHeader.Load(); forwardedHeader != nil {
+		report.HeaderChanges = diffHeaders(receivedHeader, *forwardedHeader)
+	}
+	report.Burst = burst
+	report.Metrics = Metrics{
+		BytesReceived:      int64(last.Body.Len()),
+		BackendConnections: t.backendConns.Load() - backendConns,
+		BackendRequests:    t.backendRequests.Load() - backendRequests,
+		Attempts:           t.upstreamRequests.Load() - upstreamRequests,
+	}
+	for _, burstBody := range bodies {
+		report.Metrics.BytesSent += burstBody.n.Load()
+	}
+
+	return last.Result(), report, nil
+}
+
+// Route returns a Report of how the given request is routed by the fake Traefik instance, without sending it.
+// The Metrics of the Report are left empty.
+func (t *Traefik) Route(req *http.Request) Report {
+	t.handlerMu.RLock()
+	matcher := t.routerMatchers[t.entryPoint(req)]
+	stubbedPlugins := t.stubbedPlugins
+	t.handlerMu.RUnlock()
+
+	report := Report{StubbedPlugins: stubbedPlugins}
+	if matcher != nil {
+		report.Router = matcher.Match(req)
+		report.Middlewares = matcher.Middlewares(report.Router)
+		report.Rule, report.Priority = matcher.Rule(report.Router)
+		report.RouterEvaluations = matcher.Explain(req, report.Router)
+	}
+
+	return report
+}
+
+// Stream sends an HTTP request to the fake Traefik instance, and writes the response to the given
+// http.ResponseWriter as Traefik produces it.
+func (t *Traefik) Stream(rw http.ResponseWriter, req *http.Request) error {
+	t.handlerMu.RLock()
+	entryPoint := t.entryPoint(req)
+	handler, ok := t.handlers[entryPoint]
+	t.handlerMu.RUnlock()
+
 	if !ok {
-		return nil, fmt.Errorf("no handler for entrypoint %q", httpEntrypoint)
+		return fmt.Errorf("no handler for entrypoint %q", entryPoint)
 	}
 
 	handler.ServeHTTP(rw, req)
 
-	return rw.Result(), nil
+	return nil
+}
+
+// entryPoint returns the name of the HTTP entrypoint receiving the given request: the first one with a router
+// matching it, or the "web" entrypoint when none does. The handlerMu lock must be held.
+func (t *Traefik) entryPoint(req *http.Request) string {
+	for _, name := range t.httpEntryPoints {
+		if matcher := t.routerMatchers[name]; matcher != nil && matcher.Match(req) != "" {
+			return name
+		}
+	}
+
+	return httpEntrypoint
 }
 
-func buildHandlers(ctx context.Context, pool *safe.Pool, parser httpmuxer.SyntaxParser, staticConfig static.Configuration, dynamicConfig dynamic.Configuration) map[string]http.Handler {
-	allEntryPointNames := slices.Collect(maps.Keys(staticConfig.EntryPoints))
+// startUpstream starts a playground backend meant to be the service of a router, serving the given handler.
+// Unlike the requests to the backends called by middlewares, such as forwardAuth, the requests Traefik attempts
+// to send to it are counted as Attempts in the Metrics.
+func (t *Traefik) startUpstream(handler http.Handler) *httptest.Server {
+	server := t.startBackend(handler)
+
+	if t.upstreamHosts == nil {
+		t.upstreamHosts = make(map[string]struct{})
+	}
+	t.upstreamHosts[server.Listener.Addr().String()] = struct{}{}
+
+	return server
+}
+
+// wrapRoundTripper wraps the http.RoundTripper Traefik sends the requests to the services with, so that the
+// attempts to reach the playground backends are counted and recorded, and the flaky backend fails.
+func (t *Traefik) wrapRoundTripper(next http.RoundTripper) http.RoundTripper {
+	next = t.flaky.RoundTripper(next, t.flakyHost, flakyPublicAddr)
+
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if _, ok := t.upstreamHosts[req.URL.Host]; ok {
+			t.upstreamRequests.Add(1)
+
+			backend := t.serverInjector.publicURL(req.URL.Host)
+			t.lastBackend.Store(&backend)
+
+			forwardedHeader := req.Header.Clone()
+			t.lastForwardedHeader.Store(&forwardedHeader)
+
+			if stats, ok := req.Context().Value(requestStatsKey{}).(*requestStats); ok {
+				stats.attempts.Add(1)
+				stats.backend.Store(&backend)
+				stats.forwardedHeader.Store(&forwardedHeader)
+			}
+		}
+
+		return next.RoundTrip(req)
+	})
+}
+
+// startBackend starts a playground backend serving the given handler.
+// The connections it accepts are counted in the Metrics of the requests sent to the instance.
+func (t *Traefik) startBackend(handler http.Handler) *httptest.Server {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		t.backendRequests.Add(1)
+		handler.ServeHTTP(rw, req)
+	}))
+	server.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			t.backendConns.Add(1)
+		}
+	}
+
+	server.Start()
+
+	return server
+}
+
+// ResolvedConfig returns the JSON encoded runtime configuration resolved by Traefik from the dynamic configuration.
+// Unlike the dynamic configuration, it has the default values applied, the names qualified with their provider
+// and the routers linked to their services, along with the errors found while building them.
+// The services provided by the playground are left out, and their private addresses replaced by the public ones.
+func (t *Traefik) ResolvedConfig() (json.RawMessage, error) {
+	t.handlerMu.RLock()
+	defer t.handlerMu.RUnlock()
+
+	if t.runtimeConfig == nil {
+		return nil, errors.New("instance not started")
+	}
+
+	resolved := *t.runtimeConfig
+
+	isPlayground := func(name string) bool { return strings.HasSuffix(name, "@playground") }
+
+	resolved.Services = maps.Clone(resolved.Services)
+	maps.DeleteFunc(resolved.Services, func(name string, _ *runtime.ServiceInfo) bool { return isPlayground(name) })
+
+	resolved.UDPServices = maps.Clone(resolved.UDPServices)
+	maps.DeleteFunc(resolved.UDPServices, func(name string, _ *runtime.UDPServiceInfo) bool { return isPlayground(name) })
+
+	raw, err := json.Marshal(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling runtime configuration: %w", err)
+	}
+
+	return t.serverInjector.restore(raw), nil
+}
+
+// SendUDP sends a UDP datagram to the fake Traefik instance and returns the first datagram received in reply.
+func (t *Traefik) SendUDP(ctx context.Context, datagram []byte) ([]byte, error) {
+	if t.udpListener == nil {
+		return nil, errors.New("instance not started")
+	}
+
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "udp", t.udpListener.Addr().String())
+	if err != nil {
+		return nil, fmt.Errorf("dialing UDP entrypoint: %w", err)
+	}
+
+	defer func() { _ = conn.Close() }()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = conn.SetDeadline(deadline); err != nil {
+			return nil, fmt.Errorf("setting deadline: %w", err)
+		}
+	}
+
+	if _, err = conn.Write(datagram); err != nil {
+		return nil, fmt.Errorf("writing datagram: %w", err)
+	}
+
+	buffer := make([]byte, maxDatagramSize)
+
+	n, err := conn.Read(buffer)
+	if err != nil {
+		return nil, fmt.Errorf("reading datagram: %w", err)
+	}
+
+	return buffer[:n], nil
+}
+
+// serveUDP dispatches the UDP sessions to the handler of the UDP entrypoint until the listener is closed.
+func (t *Traefik) serveUDP() {
+	for {
+		conn, err := t.udpListener.Accept()
+		if err != nil {
+			return
+		}
+
+		t.handlerMu.RLock()
+		handler, ok := t.udpHandler()
+		t.handlerMu.RUnlock()
+
+		if !ok {
+			_ = conn.Close()
+
+			continue
+		}
+
+		go handler.ServeUDP(conn)
+	}
+}
+
+// udpHandler returns the handler of the first UDP entrypoint with a router. As UDP routers have no rule, the
+// datagrams are received by this entrypoint. The handlerMu lock must be held.
+func (t *Traefik) udpHandler() (udp.Handler, bool) {
+	for _, name := range t.udpEntryPoints {
+		if handler, ok := t.udpHandlers[name]; ok {
+			return handler, true
+		}
+	}
+
+	return nil, false
+}
+
+// entryPointHandlers holds the handlers of the entrypoints built from a dynamic configuration.
+type entryPointHandlers struct {
+	http            map[string]http.Handler
+	udp             map[string]udp.Handler
+	httpEntryPoints []string
+	udpEntryPoints  []string
+	routerMatchers  map[string]*routerMatcher
+	runtimeConfig   *runtime.Configuration
+	stubbedPlugins  []string
+}
+
+func buildHandlers(ctx context.Context, pool *safe.Pool, parser httpmuxer.SyntaxParser, staticConfig static.Configuration, dynamicConfig dynamic.Configuration, wrapRoundTripper func(http.RoundTripper) http.RoundTripper) entryPointHandlers {
+	entryPoints := withReferencedEntryPoints(staticConfig.EntryPoints, &dynamicConfig)
+	httpEntryPointNames, udpEntryPointNames := splitEntryPoints(entryPoints)
+
 	runtimeConfig := runtime.NewConfig(dynamicConfig)
 
 	tlsManager := tls.NewManager()
 
 	transportManager := service.NewTransportManager(nil)
-	proxyBuilder := httputil.NewProxyBuilder(transportManager, nil)
+	proxyBuilder := httputil.NewProxyBuilder(wrappedTransportManager{TransportManager: transportManager, wrap: wrapRoundTripper}, nil)
+	defaultTransport := &dynamic.ServersTransport{
+		InsecureSkipVerify:  staticConfig.ServersTransport.InsecureSkipVerify,
+		RootCAs:             staticConfig.ServersTransport.RootCAs,
+		MaxIdleConnsPerHost: staticConfig.ServersTransport.MaxIdleConnsPerHost,
+	}
+	// Like Traefik's internal provider, the default transport takes the forwarding timeouts of the static configuration.
+	if timeouts := staticConfig.ServersTransport.ForwardingTimeouts; timeouts != nil {
+		defaultTransport.ForwardingTimeouts = &dynamic.ForwardingTimeouts{
+			DialTimeout:           timeouts.DialTimeout,
+			ResponseHeaderTimeout: timeouts.ResponseHeaderTimeout,
+			IdleConnTimeout:       timeouts.IdleConnTimeout,
+		}
+	}
+
 	transportManager.Update(map[string]*dynamic.ServersTransport{
-		"default@internal": {
-			InsecureSkipVerify:  staticConfig.ServersTransport.InsecureSkipVerify,
-			RootCAs:             staticConfig.ServersTransport.RootCAs,
-			MaxIdleConnsPerHost: staticConfig.ServersTransport.MaxIdleConnsPerHost,
-		},
+		"default@internal": defaultTransport,
 	})
 
 	serviceManager := service.NewManager(runtimeConfig.Services, nil, pool, transportManager, proxyBuilder)
 
-	middlewaresBuilder := middleware.NewBuilder(runtimeConfig.Middlewares, serviceManager, nil)
+	middlewaresBuilder := middleware.NewBuilder(runtimeConfig.Middlewares, serviceManager, &pluginStub{})
 	routerManager := router.NewManager(runtimeConfig, serviceManager, middlewaresBuilder, nil, tlsManager, parser)
 
-	return routerManager.BuildHandlers(ctx, allEntryPointNames, false)
+	udpServiceManager := udpservice.NewManager(runtimeConfig)
+	udpRouterManager := udprouter.NewManager(runtimeConfig, udpServiceManager)
+
+	handlers := routerManager.BuildHandlers(ctx, httpEntryPointNames, false)
+
+	// Like Traefik's entrypoints, decorate the requests with their canonical host.
+	// The Host matcher relies on it to match requests.
+	reqDecorator := requestdecorator.New(nil)
+	for name, handler := range handlers {
+		handlers[name] = http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+			reqDecorator.ServeHTTP(rw, req, handler.ServeHTTP)
+		})
+
+		// Like Traefik's entrypoints, handle the forwarded headers before anything else.
+		forwarded, err := withForwardedHeaders(entryPoints[name].ForwardedHeaders, handlers[name])
+		if err != nil {
+			log.Ctx(ctx).Error().Err(err).Str("entryPoint", name).Msg("Unable to handle forwarded headers")
+		} else {
+			handlers[name] = forwarded
+		}
+
+		if transport := entryPoints[name].Transport; transport != nil && transport.RespondingTimeouts != nil {
+			handlers[name] = withReadTimeout(time.Duration(transport.RespondingTimeouts.ReadTimeout), handlers[name])
+		}
+	}
+
+	routerMatchers := make(map[string]*routerMatcher, len(httpEntryPointNames))
+	for _, name := range httpEntryPointNames {
+		routerMatchers[name] = newRouterMatcher(parser, runtimeConfig, name)
+	}
+
+	udpHandlers := udpRouterManager.BuildHandlers(ctx, udpEntryPointNames)
+
+	// Like Traefik does once the handlers are built, cross-reference the routers, services and middlewares.
+	runtimeConfig.PopulateUsedBy()
+
+	return entryPointHandlers{
+		http:            handlers,
+		udp:             udpHandlers,
+		httpEntryPoints: httpEntryPointNames,
+		udpEntryPoints:  udpEntryPointNames,
+		routerMatchers:  routerMatchers,
+		runtimeConfig:   runtimeConfig,
+		stubbedPlugins:  stubbedPlugins(dynamicConfig),
+	}
+}
+
+// withReferencedEntryPoints returns the given static entrypoints along with an entrypoint for each entrypoint
+// referenced by the routers of the given dynamic configuration. HTTP and TCP routers share TCP entrypoints, which
+// take precedence over UDP ones when a name is referenced by both. The added entrypoints share the forwarded headers
+// settings of the "web" entrypoint.
+func withReferencedEntryPoints(entryPoints map[string]*static.EntryPoint, dynamicConfig *dynamic.Configuration) map[string]*static.EntryPoint {
+	synthesized := maps.Clone(entryPoints)
+
+	add := func(names []string, address string) {
+		for _, name := range names {
+			if _, ok := synthesized[name]; ok {
+				continue
+			}
+
+			entryPoint := &static.EntryPoint{Address: address}
+			entryPoint.SetDefaults()
+
+			if web, ok := entryPoints[httpEntrypoint]; ok {
+				entryPoint.ForwardedHeaders = web.ForwardedHeaders
+			}
+
+			synthesized[name] = entryPoint
+		}
+	}
+
+	if dynamicConfig == nil {
+		return synthesized
+	}
+
+	if dynamicConfig.HTTP != nil {
+		for _, r := range dynamicConfig.HTTP.Routers {
+			if r != nil {
+				add(r.EntryPoints, ":80")
+			}
+		}
+	}
+
+	if dynamicConfig.TCP != nil {
+		for _, r := range dynamicConfig.TCP.Routers {
+			if r != nil {
+				add(r.EntryPoints, ":80")
+			}
+		}
+	}
+
+	if dynamicConfig.UDP != nil {
+		for _, r := range dynamicConfig.UDP.Routers {
+			if r != nil {
+				add(r.EntryPoints, ":53/udp")
+			}
+		}
+	}
+
+	return synthesized
+}
+
+// splitEntryPoints returns the names of the given TCP and UDP entrypoints. Names are sorted, the "web" and "udp"
+// entrypoints first.
+func splitEntryPoints(entryPoints map[string]*static.EntryPoint) (tcpNames, udpNames []string) {
+	for _, name := range slices.Sorted(maps.Keys(entryPoints)) {
+		protocol, _ := entryPoints[name].GetProtocol()
+
+		switch {
+		case protocol == "udp" && name == udpEntrypoint:
+			udpNames = append([]string{name}, udpNames...)
+		case protocol == "udp":
+			udpNames = append(udpNames, name)
+		case name == httpEntrypoint:
+			tcpNames = append([]string{name}, tcpNames...)
+		default:
+			tcpNames = append(tcpNames, name)
+		}
+	}
+
+	return tcpNames, udpNames
+}
+
+// wrappedTransportManager is a TransportManager wrapping the http.RoundTripper it provides.
+type wrappedTransportManager struct {
+	*service.TransportManager
+
+	wrap func(http.RoundTripper) http.RoundTripper
+}
+
+// GetRoundTripper returns the wrapped http.RoundTripper of the given servers transport.
+func (m wrappedTransportManager) GetRoundTripper(name string) (http.RoundTripper, error) {
+	roundTripper, err := m.TransportManager.GetRoundTripper(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return m.wrap(roundTripper), nil
+}
+
+// applyDefaults sets the default value of the load-balancer options left unset, as Traefik's file provider does.
+// Traefik handles unset options as their default value, but this makes them visible in the runtime configuration.
+func applyDefaults(dynamicConfig *dynamic.Configuration) {
+	if dynamicConfig.HTTP == nil {
+		return
+	}
+
+	for _, s := range dynamicConfig.HTTP.Services {
+		if s.LoadBalancer == nil {
+			continue
+		}
+
+		if s.LoadBalancer.PassHostHeader == nil {
+			passHostHeader := dynamic.DefaultPassHostHeader
+			s.LoadBalancer.PassHostHeader = &passHostHeader
+		}
+
+		if s.LoadBalancer.Strategy == "" {
+			s.LoadBalancer.Strategy = dynamic.BalancerStrategyWRR
+		}
+
+		if s.LoadBalancer.ResponseForwarding == nil {
+			s.LoadBalancer.ResponseForwarding = &dynamic.ResponseForwarding{}
+			s.LoadBalancer.ResponseForwarding.SetDefaults()
+		}
+	}
 }
 
 // ServerInjector injects Servers in the dynamic configuration.
 type ServerInjector struct {
-	testServers []Server
+	testServers    []Server
+	testUDPServers []UDPServer
 }
 
 // NewServerInjector creates a new ServerInjector.
@@ -165,11 +962,23 @@ type Server struct {
 	PrivateURL string
 }
 
+// UDPServer holds the configuration of a UDP server.
+type UDPServer struct {
+	Name           string
+	PublicAddress  string
+	PrivateAddress string
+}
+
 // AddServer adds a new Server to inject.
 func (i *ServerInjector) AddServer(server Server) {
 	i.testServers = append(i.testServers, server)
 }
 
+// AddUDPServer adds a new UDPServer to inject.
+func (i *ServerInjector) AddUDPServer(server UDPServer) {
+	i.testUDPServers = append(i.testUDPServers, server)
+}
+
 // Inject injects the Servers in the given dynamic configuration.
 func (i *ServerInjector) Inject(dynamicConfig *dynamic.Configuration) *dynamic.Configuration {
 	dynamicConfig = dynamicConfig.DeepCopy()
@@ -197,12 +1006,94 @@ func (i *ServerInjector) Inject(dynamicConfig *dynamic.Configuration) *dynamic.C
 
 		for serverIdx, server := range s.LoadBalancer.Servers {
 			for _, testServer := range i.testServers {
-				if server.URL == testServer.PublicURL {
+				if isServerURLOf(server.URL, testServer.PublicURL) {
 					s.LoadBalancer.Servers[serverIdx].URL = testServer.PrivateURL
 				}
 			}
 		}
 	}
 
+	// Replace the PublicURL with the PrivateURL in the forwardAuth middlewares defined by the user.
+	for _, m := range dynamicConfig.HTTP.Middlewares {
+		if m.ForwardAuth == nil {
+			continue
+		}
+
+		for _, testServer := range i.testServers {
+			rest, ok := strings.CutPrefix(m.ForwardAuth.Address, testServer.PublicURL)
+			if ok && (rest == "" || strings.HasPrefix(rest, "/") || strings.HasPrefix(rest, "?")) {
+				m.ForwardAuth.Address = testServer.PrivateURL + rest
+
+				break
+			}
+		}
+	}
+
+	i.injectUDP(dynamicConfig)
+
 	return dynamicConfig
 }
+
+// publicURL returns the public URL of the injected server listening on the given private host, empty if none.
+func (i *ServerInjector) publicURL(privateHost string) string {
+	for _, server := range i.testServers {
+		if server.PrivateURL == "http://"+privateHost {
+			return server.PublicURL
+		}
+	}
+
+	return ""
+}
+
+// restore replaces the private URLs and addresses of the injected servers with their public ones in the given data.
+func (i *ServerInjector) restore(data []byte) []byte {
+	replace := func(private, public string) {
+		// Make sure a port isn't followed by more digits, as in another address.
+		data = regexp.MustCompile(regexp.QuoteMeta(private)+`\b`).ReplaceAllLiteral(data, []byte(public))
+	}
+
+	for _, server := range i.testServers {
+		replace(server.PrivateURL, server.PublicURL)
+	}
+
+	for _, server := range i.testUDPServers {
+		replace(server.PrivateAddress, server.PublicAddress)
+	}
+
+	return data
+}
+
+func (i *ServerInjector) injectUDP(dynamicConfig *dynamic.Configuration) {
+	if dynamicConfig.UDP == nil {
+		dynamicConfig.UDP = &dynamic.UDPConfiguration{}
+	}
+	if dynamicConfig.UDP.Services == nil {
+		dynamicConfig.UDP.Services = make(map[string]*dynamic.UDPService)
+	}
+
+	// Create the new UDP services.
+	for _, testServer := range i.testUDPServers {
+		dynamicConfig.UDP.Services[testServer.Name] = &dynamic.UDPService{
+			LoadBalancer: &dynamic.UDPServersLoadBalancer{
+				Servers: []dynamic.UDPServer{
+					{Address: testServer.PrivateAddress},
+				},
+			},
+		}
+	}
+
+	// Replace the PublicAddress with the PrivateAddress in the UDP services defined by the user.
+	for _, s := range dynamicConfig.UDP.Services {
+		if s.LoadBalancer == nil {
+			continue
+		}
+
+		for serverIdx, server := range s.LoadBalancer.Servers {
+			for _, testServer := range i.testUDPServers {
+				if server.Address == testServer.PublicAddress {
+					s.LoadBalancer.Servers[serverIdx].Address = testServer.PrivateAddress
+				}
+			}
+		}
+	}
+}
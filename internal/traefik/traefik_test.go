@@ -1,18 +1,30 @@
 package traefik
 
 import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/jspdown/traefik-playground/internal/matcher"
+	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	ptypes "github.com/traefik/paerser/types"
 	"github.com/traefik/traefik/v3/pkg/config/dynamic"
+	"gopkg.in/yaml.v3"
 )
 
 func TestTraefik(t *testing.T) {
@@ -28,7 +40,7 @@ func TestTraefik(t *testing.T) {
 	request := httptest.NewRequest(http.MethodPost, "https://example.com/foo", strings.NewReader(`{"foo": "bar"}`))
 	request.Header.Set("X-Header", "Value")
 
-	traefik, err := NewTraefik(&dynamicConfig)
+	traefik, err := NewTraefik(&dynamicConfig, Options{})
 	require.NoError(t, err)
 
 	readyCh := make(chan struct{})
@@ -44,7 +56,7 @@ func TestTraefik(t *testing.T) {
 		t.Fatal("timed out waiting for Traefik to be ready")
 	}
 
-	res, err := traefik.Send(request)
+	res, _, err := traefik.Send(request)
 	require.NoError(t, err)
 
 	defer func() { _ = res.Body.Close() }()
@@ -67,3 +79,2209 @@ func TestTraefik(t *testing.T) {
 			"\r\n"+
 			`{"foo": "bar"}`, string(body))
 }
+
+func TestTraefik_HostOverride(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {Rule: "Host(`api.example.com`)", Service: "whoami@playground"},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/foo", nil)
+
+	res, _, err := traefik.Send(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/foo", nil)
+	req.Host = "api.example.com"
+
+	res, _, err = traefik.Send(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	assert.Equal(t, http.StatusTeapot, res.StatusCode)
+}
+
+func TestTraefik_EntryPoints(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {
+					EntryPoints: []string{"http"},
+					Rule:        "PathPrefix(`/api`)",
+					Service:     "whoami@playground",
+				},
+				"web": {
+					EntryPoints: []string{"web"},
+					Rule:        "PathPrefix(`/web`)",
+					Service:     "whoami@playground",
+				},
+				"any": {
+					Rule:    "PathPrefix(`/any`)",
+					Service: "whoami@playground",
+				},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	tests := []struct {
+		path       string
+		wantRouter string
+		wantStatus int
+	}{
+		{path: "/api", wantRouter: "api@file", wantStatus: http.StatusTeapot},
+		{path: "/web", wantRouter: "web@file", wantStatus: http.StatusTeapot},
+		{path: "/any", wantRouter: "any@file", wantStatus: http.StatusTeapot},
+		{path: "/unknown", wantStatus: http.StatusNotFound},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com"+test.path, nil)
+
+		res, report, err := traefik.Send(req)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		assert.Equal(t, test.wantStatus, res.StatusCode, test.path)
+		assert.Equal(t, test.wantRouter, report.Router, test.path)
+	}
+}
+
+func TestTraefik_EntryPoints_udp(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {
+					EntryPoints: []string{"http"},
+					Rule:        "PathPrefix(`/api`)",
+					Service:     "whoami@playground",
+				},
+			},
+		},
+		UDP: &dynamic.UDPConfiguration{
+			Routers: map[string]*dynamic.UDPRouter{
+				"dns": {
+					EntryPoints: []string{"dns"},
+					Service:     "whoami-udp@playground",
+				},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api", nil)
+
+	res, report, err := traefik.Send(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	assert.Equal(t, http.StatusTeapot, res.StatusCode)
+	assert.Equal(t, "api@file", report.Router)
+
+	ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+	defer cancel()
+
+	reply, err := traefik.SendUDP(ctx, []byte("ping"))
+	require.NoError(t, err)
+
+	assert.Equal(t, "ping", string(reply))
+}
+
+func TestTraefik_BasicAuth(t *testing.T) {
+	t.Parallel()
+
+	hash := sha1.Sum([]byte("pass"))
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {
+					Rule:        "PathPrefix(`/`)",
+					Service:     "whoami@playground",
+					Middlewares: []string{"auth"},
+				},
+			},
+			Middlewares: map[string]*dynamic.Middleware{
+				"auth": {
+					BasicAuth: &dynamic.BasicAuth{
+						Users: dynamic.Users{"user:{SHA}" + base64.StdEncoding.EncodeToString(hash[:])},
+					},
+				},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/foo", nil)
+
+	res, _, err := traefik.Send(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/foo", nil)
+	req.SetBasicAuth("user", "pass")
+
+	res, _, err = traefik.Send(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	assert.Equal(t, http.StatusTeapot, res.StatusCode)
+}
+
+func TestTraefik_ForwardAuth(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc          string
+		address       string
+		authorization string
+		wantStatus    int
+	}{
+		{
+			desc:       "missing header",
+			address:    "http://10.10.10.11",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:          "header present",
+			address:       "http://10.10.10.11",
+			authorization: "Bearer token",
+			wantStatus:    http.StatusTeapot,
+		},
+		{
+			desc:       "custom header missing",
+			address:    "http://10.10.10.11/?header=X-Api-Key",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			desc:       "always allow",
+			address:    "http://10.10.10.11/allow",
+			wantStatus: http.StatusTeapot,
+		},
+		{
+			desc:          "always deny",
+			address:       "http://10.10.10.11/deny",
+			authorization: "Bearer token",
+			wantStatus:    http.StatusUnauthorized,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			traefik, err := NewTraefik(&dynamic.Configuration{
+				HTTP: &dynamic.HTTPConfiguration{
+					Routers: map[string]*dynamic.Router{
+						"api": {
+							Rule:        "PathPrefix(`/`)",
+							Service:     "whoami@playground",
+							Middlewares: []string{"auth"},
+						},
+					},
+					Middlewares: map[string]*dynamic.Middleware{
+						"auth": {
+							ForwardAuth: &dynamic.ForwardAuth{Address: test.address},
+						},
+					},
+				},
+			}, Options{})
+			require.NoError(t, err)
+
+			readyCh := make(chan struct{})
+			traefik.OnReady(func() {
+				close(readyCh)
+			})
+
+			require.NoError(t, traefik.Start(t.Context()))
+
+			select {
+			case <-readyCh:
+			case <-time.After(time.Second):
+				t.Fatal("timed out waiting for Traefik to be ready")
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "http://localhost/foo", nil)
+			if test.authorization != "" {
+				req.Header.Set("Authorization", test.authorization)
+			}
+
+			res, _, err := traefik.Send(req)
+			require.NoError(t, err)
+			require.NoError(t, res.Body.Close())
+
+			assert.Equal(t, test.wantStatus, res.StatusCode)
+		})
+	}
+}
+
+func TestTraefik_Plugin(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {
+					Rule:        "PathPrefix(`/api`)",
+					Service:     "whoami@playground",
+					Middlewares: []string{"block"},
+				},
+			},
+			Middlewares: map[string]*dynamic.Middleware{
+				"block": {Plugin: map[string]dynamic.PluginConf{
+					"blockpath": {"regex": []interface{}{"^/api"}},
+				}},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api", nil)
+
+	res, report, err := traefik.Send(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	// The plugin is stubbed: the request goes through unchanged.
+	assert.Equal(t, http.StatusTeapot, res.StatusCode)
+	assert.Equal(t, "api@file", report.Router)
+	assert.Equal(t, []string{"block@file"}, report.Middlewares)
+	assert.Equal(t, []string{"blockpath"}, report.StubbedPlugins)
+}
+
+func TestTraefik_IPAllowList(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {
+					Rule:        "PathPrefix(`/`)",
+					Service:     "whoami@playground",
+					Middlewares: []string{"allow-list"},
+				},
+			},
+			Middlewares: map[string]*dynamic.Middleware{
+				"allow-list": {
+					IPAllowList: &dynamic.IPAllowList{SourceRange: []string{"10.0.0.0/8"}},
+				},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/foo", nil)
+	req.RemoteAddr = "10.1.2.3:1234"
+
+	res, _, err := traefik.Send(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	assert.Equal(t, http.StatusTeapot, res.StatusCode)
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/foo", nil)
+	req.RemoteAddr = "192.168.1.1:1234"
+
+	res, _, err = traefik.Send(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	assert.Equal(t, http.StatusForbidden, res.StatusCode)
+}
+
+func TestTraefik_ForwardedHeaders(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"whoami": {Rule: "PathPrefix(`/`)", Service: "whoami@playground"},
+			},
+		},
+	}, Options{TrustedIPs: []string{"10.0.0.0/8"}})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	tests := []struct {
+		desc       string
+		remoteAddr string
+		wantXFF    []string
+	}{
+		{
+			desc:       "trusted",
+			remoteAddr: "10.0.0.1:1234",
+			wantXFF:    []string{"203.0.113.1, 10.0.0.1"},
+		},
+		{
+			desc:       "untrusted",
+			remoteAddr: "192.0.2.1:1234",
+			wantXFF:    []string{"192.0.2.1"},
+		},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		req.RemoteAddr = test.remoteAddr
+		req.Header.Set("X-Forwarded-For", "203.0.113.1")
+
+		_, report, err := traefik.Send(req)
+		require.NoError(t, err, test.desc)
+
+		i := slices.IndexFunc(report.HeaderChanges, func(change HeaderChange) bool {
+			return change.Name == "X-Forwarded-For"
+		})
+		require.NotEqual(t, -1, i, test.desc)
+
+		// Traefik appends the address of the client to the X-Forwarded-For header it forwards, once the
+		// entrypoint dropped the untrusted one.
+		assert.Equal(t, test.wantXFF, report.HeaderChanges[i].Values, test.desc)
+		assert.Equal(t, []string{"203.0.113.1"}, report.HeaderChanges[i].Original, test.desc)
+	}
+}
+
+func TestTraefik_ForwardedHeaders_staticConfig(t *testing.T) {
+	t.Parallel()
+
+	// The forwardedHeaders option of the "web" entrypoint takes precedence over the trusted IPs of the Options,
+	// which still apply to the entrypoints without it.
+	traefik := startTraefik(t, &dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"web":   {EntryPoints: []string{"web"}, Rule: "Host(`web.example.com`)", Service: "whoami@playground"},
+				"other": {EntryPoints: []string{"other"}, Rule: "Host(`other.example.com`)", Service: "whoami@playground"},
+			},
+		},
+	}, Options{
+		TrustedIPs: []string{"10.0.0.0/8"},
+		StaticConfig: `
+entryPoints:
+  web:
+    forwardedHeaders:
+      trustedIPs: ["192.0.2.0/24"]
+  other: {}
+`,
+	})
+
+	tests := []struct {
+		desc       string
+		host       string
+		remoteAddr string
+		wantXFF    []string
+	}{
+		{
+			desc:       "trusted by the entrypoint",
+			host:       "web.example.com",
+			remoteAddr: "192.0.2.1:1234",
+			wantXFF:    []string{"203.0.113.1, 192.0.2.1"},
+		},
+		{
+			desc:       "only trusted by the options",
+			host:       "web.example.com",
+			remoteAddr: "10.0.0.1:1234",
+			wantXFF:    []string{"10.0.0.1"},
+		},
+		{
+			desc:       "trusted by the options on an entrypoint without forwardedHeaders",
+			host:       "other.example.com",
+			remoteAddr: "10.0.0.1:1234",
+			wantXFF:    []string{"203.0.113.1, 10.0.0.1"},
+		},
+		{
+			desc:       "only trusted by another entrypoint",
+			host:       "other.example.com",
+			remoteAddr: "192.0.2.1:1234",
+			wantXFF:    []string{"192.0.2.1"},
+		},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://"+test.host+"/", nil)
+		req.RemoteAddr = test.remoteAddr
+		req.Header.Set("X-Forwarded-For", "203.0.113.1")
+
+		_, report, err := traefik.Send(req)
+		require.NoError(t, err, test.desc)
+
+		i := slices.IndexFunc(report.HeaderChanges, func(change HeaderChange) bool {
+			return change.Name == "X-Forwarded-For"
+		})
+		require.NotEqual(t, -1, i, test.desc)
+
+		assert.Equal(t, test.wantXFF, report.HeaderChanges[i].Values, test.desc)
+	}
+}
+
+func TestTraefik_ForwardedHeaders_noTrustedIPs(t *testing.T) {
+	t.Parallel()
+
+	// Like with Traefik, an entrypoint with forwardedHeaders settings replaces the forwarded headers of untrusted
+	// requests, even without trusted IPs. The other entrypoints keep the playground default, leaving them untouched.
+	traefik := startTraefik(t, &dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"web":   {EntryPoints: []string{"web"}, Rule: "Host(`web.example.com`)", Service: "whoami@playground"},
+				"other": {EntryPoints: []string{"other"}, Rule: "Host(`other.example.com`)", Service: "whoami@playground"},
+			},
+		},
+	}, Options{
+		StaticConfig: `
+entryPoints:
+  web:
+    forwardedHeaders: {}
+  other: {}
+`,
+	})
+
+	tests := []struct {
+		desc      string
+		host      string
+		wantXFF   []string
+		wantProto []string
+	}{
+		{
+			desc:      "forwardedHeaders settings",
+			host:      "web.example.com",
+			wantXFF:   []string{"192.0.2.1"},
+			wantProto: []string{"http"},
+		},
+		{
+			desc:    "playground default",
+			host:    "other.example.com",
+			wantXFF: []string{"203.0.113.1, 192.0.2.1"},
+		},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "http://"+test.host+"/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Forwarded-For", "203.0.113.1")
+		req.Header.Set("X-Forwarded-Proto", "https")
+
+		_, report, err := traefik.Send(req)
+		require.NoError(t, err, test.desc)
+
+		changedValues := func(name string) []string {
+			i := slices.IndexFunc(report.HeaderChanges, func(change HeaderChange) bool {
+				return change.Name == name
+			})
+			if i == -1 {
+				return nil
+			}
+
+			return report.HeaderChanges[i].Values
+		}
+
+		assert.Equal(t, test.wantXFF, changedValues("X-Forwarded-For"), test.desc)
+		assert.Equal(t, test.wantProto, changedValues("X-Forwarded-Proto"), test.desc)
+	}
+}
+
+func TestTraefik_ForwardedHeaders_invalidTrustedIPs(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewTraefik(&dynamic.Configuration{}, Options{TrustedIPs: []string{"not-an-ip"}})
+	require.Error(t, err)
+}
+
+func TestTraefik_StaticConfig_readTimeout(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"whoami": {Rule: "PathPrefix(`/`)", Service: "whoami@playground"},
+			},
+		},
+	}, Options{StaticConfig: `
+entryPoints:
+  web:
+    transport:
+      respondingTimeouts:
+        readTimeout: 50ms
+`})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	tests := []struct {
+		desc       string
+		delay      time.Duration
+		wantStatus int
+	}{
+		{
+			desc:       "body sent within the read timeout",
+			wantStatus: http.StatusTeapot,
+		},
+		{
+			desc:       "body sent after the read timeout",
+			delay:      500 * time.Millisecond,
+			wantStatus: http.StatusGatewayTimeout,
+		},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/", strings.NewReader("hello"))
+		if test.delay > 0 {
+			require.NoError(t, DelayRequest(req, test.delay), test.desc)
+		}
+
+		start := time.Now()
+
+		res, _, err := traefik.Send(req)
+		require.NoError(t, err, test.desc)
+
+		assert.Equal(t, test.wantStatus, res.StatusCode, test.desc)
+		// The request fails as soon as the read timeout elapses, without waiting for the body.
+		assert.Less(t, time.Since(start), 400*time.Millisecond, test.desc)
+	}
+}
+
+func TestValidateStaticConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc         string
+		staticConfig string
+		wantErr      string
+	}{
+		{
+			desc: "empty",
+		},
+		{
+			desc: "allowed options",
+			staticConfig: `
+entryPoints:
+  web:
+    transport:
+      respondingTimeouts:
+        readTimeout: 5s
+  websecure:
+    forwardedHeaders:
+      trustedIPs: [10.0.0.0/8]
+serversTransport:
+  forwardingTimeouts:
+    responseHeaderTimeout: 1s
+`,
+		},
+		{
+			desc: "entrypoint address",
+			staticConfig: `
+entryPoints:
+  web:
+    address: ":8080"
+`,
+			wantErr: `static configuration option "entryPoints.web.address" isn't supported, only ` +
+				"entryPoints.<name>.forwardedHeaders.connection, entryPoints.<name>.forwardedHeaders.insecure, " +
+				"entryPoints.<name>.forwardedHeaders.trustedIPs, entryPoints.<name>.transport.respondingTimeouts.readTimeout, " +
+				"serversTransport.forwardingTimeouts.dialTimeout, serversTransport.forwardingTimeouts.idleConnTimeout, " +
+				"serversTransport.forwardingTimeouts.responseHeaderTimeout can be set",
+		},
+		{
+			desc:         "providers",
+			staticConfig: "providers:\n  docker: {}\n",
+			wantErr:      `static configuration option "providers" isn't supported`,
+		},
+		{
+			desc:         "not a mapping",
+			staticConfig: "entryPoints: web\n",
+			wantErr:      `static configuration option "entryPoints" must be a mapping`,
+		},
+		{
+			desc:         "invalid duration",
+			staticConfig: "serversTransport:\n  forwardingTimeouts:\n    dialTimeout: soon\n",
+			wantErr:      "parsing static configuration",
+		},
+		{
+			desc:         "invalid trusted IP",
+			staticConfig: "entryPoints:\n  web:\n    forwardedHeaders:\n      trustedIPs: [not-an-ip]\n",
+			wantErr:      `entrypoint "web": parsing trusted IPs`,
+		},
+		{
+			desc:         "too many entrypoints",
+			staticConfig: "entryPoints: {a: {}, b: {}, c: {}, d: {}, e: {}, f: {}}\n",
+			wantErr:      "too many entrypoints in the static configuration (max: 5)",
+		},
+	}
+
+	for _, test := range tests {
+		err := ValidateStaticConfig(test.staticConfig)
+		if test.wantErr == "" {
+			assert.NoError(t, err, test.desc)
+
+			continue
+		}
+
+		require.Error(t, err, test.desc)
+		assert.Contains(t, err.Error(), test.wantErr, test.desc)
+	}
+}
+
+func TestTraefik_Errors(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"unavailable": {Rule: "PathPrefix(`/`)", Service: "unavailable", Middlewares: []string{"errors"}},
+			},
+			Services: map[string]*dynamic.Service{
+				// A load-balancer without servers answers 503 Service Unavailable.
+				"unavailable": {LoadBalancer: &dynamic.ServersLoadBalancer{}},
+			},
+			Middlewares: map[string]*dynamic.Middleware{
+				"errors": {Errors: &dynamic.ErrorPage{
+					Status:  []string{"500-599"},
+					Service: "errors@playground",
+					Query:   "/{status}.html",
+				}},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	res, _, err := traefik.Send(httptest.NewRequest(http.MethodGet, "http://example.com/foo", nil))
+	require.NoError(t, err)
+
+	defer func() { _ = res.Body.Close() }()
+
+	assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(body), "Playground error page for 503 Service Unavailable")
+}
+
+func TestTraefik_Compress(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {
+					Rule:        "PathPrefix(`/`)",
+					Service:     "whoami-large@playground",
+					Middlewares: []string{"compress"},
+				},
+			},
+			Middlewares: map[string]*dynamic.Middleware{
+				"compress": {Compress: &dynamic.Compress{Encodings: []string{"gzip"}}},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/foo", nil)
+
+	res, _, err := traefik.Send(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	assert.Equal(t, http.StatusTeapot, res.StatusCode)
+	assert.Empty(t, res.Header.Get("Content-Encoding"))
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/foo", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	res, _, err = traefik.Send(req)
+	require.NoError(t, err)
+
+	defer func() { _ = res.Body.Close() }()
+
+	assert.Equal(t, http.StatusTeapot, res.StatusCode)
+	assert.Equal(t, "gzip", res.Header.Get("Content-Encoding"))
+
+	reader, err := gzip.NewReader(res.Body)
+	require.NoError(t, err)
+
+	body, err := io.ReadAll(reader)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(body), largeWhoamiPadding)
+}
+
+func TestTraefik_ConditionalRequest(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {
+					Rule:    "PathPrefix(`/`)",
+					Service: "whoami-etag@playground",
+				},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://localhost/foo", nil)
+
+	res, _, err := traefik.Send(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	require.Equal(t, http.StatusOK, res.StatusCode)
+
+	etag := res.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/foo", nil)
+	req.Header.Set("If-None-Match", etag)
+
+	res, _, err = traefik.Send(req)
+	require.NoError(t, err)
+
+	defer func() { _ = res.Body.Close() }()
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusNotModified, res.StatusCode)
+	assert.Equal(t, etag, res.Header.Get("ETag"))
+	assert.Empty(t, body)
+
+	// The ETag identifies the resource at the requested path only.
+	req = httptest.NewRequest(http.MethodGet, "http://localhost/bar", nil)
+	req.Header.Set("If-None-Match", etag)
+
+	res, _, err = traefik.Send(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestTraefik_Retry(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"retried": {
+					Rule:        "PathPrefix(`/retried`)",
+					Service:     "flaky",
+					Middlewares: []string{"retry"},
+				},
+				"not-retried": {
+					Rule:    "PathPrefix(`/not-retried`)",
+					Service: "flaky",
+				},
+			},
+			Services: map[string]*dynamic.Service{
+				"flaky": {LoadBalancer: &dynamic.ServersLoadBalancer{
+					Servers: []dynamic.Server{{URL: "http://10.10.10.15"}},
+				}},
+			},
+			Middlewares: map[string]*dynamic.Middleware{
+				"retry": {Retry: &dynamic.Retry{Attempts: 3}},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	tests := []struct {
+		desc         string
+		path         string
+		wantStatus   int
+		wantBody     string
+		wantAttempts int64
+	}{
+		{
+			desc:         "third attempt succeeds",
+			path:         "/retried?failures=2",
+			wantStatus:   http.StatusOK,
+			wantBody:     "Succeeded on attempt 3, after 2 failures\n",
+			wantAttempts: 3,
+		},
+		{
+			desc:         "attempts exhausted",
+			path:         "/retried?failures=3",
+			wantStatus:   http.StatusBadGateway,
+			wantAttempts: 3,
+		},
+		{
+			desc:         "without retry",
+			path:         "/not-retried?failures=1",
+			wantStatus:   http.StatusBadGateway,
+			wantAttempts: 1,
+		},
+	}
+
+	for _, test := range tests {
+		res, report, err := traefik.Send(httptest.NewRequest(http.MethodGet, "http://example.com"+test.path, nil))
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		assert.Equal(t, test.wantStatus, res.StatusCode, test.desc)
+		if test.wantBody != "" {
+			assert.Equal(t, test.wantBody, string(body), test.desc)
+		}
+		assert.Equal(t, test.wantAttempts, report.Metrics.Attempts, test.desc)
+	}
+}
+
+func TestTraefik_Send_report(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api":          {Rule: "PathPrefix(`/api`)", Service: "whoami@playground"},
+				"api-v2":       {Rule: "PathPrefix(`/api/v2`)", Service: "whoami@playground"},
+				"low-priority": {Rule: "PathPrefix(`/`)", Priority: 1, Service: "whoami@playground"},
+				"invalid":      {Rule: "PathPrefix(`/invalid`)", Service: "unknown"},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	tests := []struct {
+		path       string
+		wantRouter string
+	}{
+		{path: "/api/v1", wantRouter: "api@file"},
+		{path: "/api/v2/foo", wantRouter: "api-v2@file"},
+		{path: "/foo", wantRouter: "low-priority@file"},
+		{path: "/invalid", wantRouter: "low-priority@file"},
+	}
+
+	for _, test := range tests {
+		_, report, err := traefik.Send(httptest.NewRequest(http.MethodGet, "http://example.com"+test.path, nil))
+		require.NoError(t, err)
+
+		assert.Equal(t, test.wantRouter, report.Router, test.path)
+	}
+}
+
+func TestTraefik_Send_report_headerChanges(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {Rule: "PathPrefix(`/api`)", Service: "whoami@playground", Middlewares: []string{"headers"}},
+			},
+			Middlewares: map[string]*dynamic.Middleware{
+				"headers": {
+					Headers: &dynamic.Headers{
+						CustomRequestHeaders: map[string]string{"X-Added": "added", "X-Changed": "changed", "X-Removed": ""},
+					},
+				},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api", nil)
+	req.Header.Set("X-Changed", "original")
+	req.Header.Set("X-Removed", "original")
+	req.Header.Set("X-Unchanged", "original")
+
+	_, report, err := traefik.Send(req)
+	require.NoError(t, err)
+
+	assert.Equal(t, []HeaderChange{
+		{Name: "X-Added", Values: []string{"added"}},
+		{Name: "X-Changed", Values: []string{"changed"}, Original: []string{"original"}},
+		{Name: "X-Forwarded-For", Values: []string{"192.0.2.1"}},
+		{Name: "X-Removed", Original: []string{"original"}},
+	}, report.HeaderChanges)
+
+	// The request didn't reach a backend.
+	_, report, err = traefik.Send(httptest.NewRequest(http.MethodGet, "http://example.com/unknown", nil))
+	require.NoError(t, err)
+
+	assert.Empty(t, report.HeaderChanges)
+}
+
+func TestTraefik_Send_report_priority(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api":   {Rule: "PathPrefix(`/api`)", Service: "whoami@playground"},
+				"admin": {Rule: "PathPrefix(`/api/admin`)", Priority: 1, Service: "whoami@playground"},
+				"root":  {Rule: "PathPrefix(`/`)", Priority: 10, Service: "whoami@playground"},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	tests := []struct {
+		path         string
+		wantRouter   string
+		wantRule     string
+		wantPriority int
+	}{
+		// The priority computed from the length of the rule beats the lower priority of the more specific router.
+		{path: "/api/admin", wantRouter: "api@file", wantRule: "PathPrefix(`/api`)", wantPriority: 18},
+		{path: "/foo", wantRouter: "root@file", wantRule: "PathPrefix(`/`)", wantPriority: 10},
+	}
+
+	for _, test := range tests {
+		_, report, err := traefik.Send(httptest.NewRequest(http.MethodGet, "http://example.com"+test.path, nil))
+		require.NoError(t, err)
+
+		assert.Equal(t, test.wantRouter, report.Router, test.path)
+		assert.Equal(t, test.wantRule, report.Rule, test.path)
+		assert.Equal(t, test.wantPriority, report.Priority, test.path)
+	}
+}
+
+func TestTraefik_Send_report_middlewares(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {
+					Rule:        "PathPrefix(`/api`)",
+					Service:     "whoami@playground",
+					Middlewares: []string{"strip", "headers"},
+				},
+				"chained": {
+					Rule:        "PathPrefix(`/chained`)",
+					Service:     "whoami@playground",
+					Middlewares: []string{"chain", "strip"},
+				},
+			},
+			Middlewares: map[string]*dynamic.Middleware{
+				"strip": {StripPrefix: &dynamic.StripPrefix{Prefixes: []string{"/api"}}},
+				"headers": {Headers: &dynamic.Headers{
+					CustomRequestHeaders: map[string]string{"X-Foo": "bar"},
+				}},
+				"chain": {Chain: &dynamic.Chain{Middlewares: []string{"headers", "strip"}}},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	tests := []struct {
+		path            string
+		wantMiddlewares []string
+	}{
+		{path: "/api", wantMiddlewares: []string{"strip@file", "headers@file"}},
+		{path: "/chained", wantMiddlewares: []string{"chain@file", "headers@file", "strip@file", "strip@file"}},
+		{path: "/foo"},
+	}
+
+	for _, test := range tests {
+		res, report, err := traefik.Send(httptest.NewRequest(http.MethodGet, "http://example.com"+test.path, nil))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		assert.Equal(t, test.wantMiddlewares, report.Middlewares, test.path)
+	}
+}
+
+func TestTraefik_Send_metrics(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {Rule: "PathPrefix(`/api`)", Service: "whoami@playground"},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	tests := []struct {
+		desc                   string
+		wantBackendConnections int64
+	}{
+		{desc: "first request", wantBackendConnections: 1},
+		{desc: "kept alive connection", wantBackendConnections: 0},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/api", strings.NewReader(`{"foo": "bar"}`))
+
+		res, report, err := traefik.Send(req)
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+
+		assert.Equal(t, int64(len(`{"foo": "bar"}`)), report.Metrics.BytesSent, test.desc)
+		assert.Equal(t, int64(len(body)), report.Metrics.BytesReceived, test.desc)
+		assert.Equal(t, test.wantBackendConnections, report.Metrics.BackendConnections, test.desc)
+		assert.Equal(t, int64(1), report.Metrics.BackendRequests, test.desc)
+		assert.Equal(t, int64(1), report.Metrics.Attempts, test.desc)
+	}
+}
+
+func TestTraefik_SendBurst_rateLimit(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {
+					Rule:        "PathPrefix(`/api`)",
+					Service:     "whoami@playground",
+					Middlewares: []string{"limit"},
+				},
+			},
+			Middlewares: map[string]*dynamic.Middleware{
+				"limit": {RateLimit: &dynamic.RateLimit{
+					Average: 2,
+					Period:  ptypes.Duration(time.Second),
+					Burst:   2,
+				}},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "http://example.com/api", strings.NewReader("body"))
+
+	res, report, err := traefik.SendBurst(req, 5, false)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+	assert.Equal(t, []BurstResponse{
+		{StatusCode: http.StatusTeapot, Backend: "http://10.10.10.10"},
+		{StatusCode: http.StatusTeapot, Backend: "http://10.10.10.10"},
+		{StatusCode: http.StatusTooManyRequests, Limited: true},
+		{StatusCode: http.StatusTooManyRequests, Limited: true},
+		{StatusCode: http.StatusTooManyRequests, Limited: true},
+	}, report.Burst)
+	assert.Equal(t, "api@file", report.Router)
+	assert.Equal(t, int64(0), report.Metrics.BackendRequests)
+}
+
+func TestTraefik_SendConcurrentBurst_inFlightReq(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {
+					Rule:        "PathPrefix(`/api`)",
+					Service:     "slow@playground",
+					Middlewares: []string{"limit"},
+				},
+			},
+			Middlewares: map[string]*dynamic.Middleware{
+				"limit": {InFlightReq: &dynamic.InFlightReq{Amount: 1}},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api?delay=500ms", http.NoBody)
+
+	res, report, err := traefik.SendConcurrentBurst(req, 4)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	// The first request to reach the middleware holds the only slot until the backend responds.
+	var handled, limited int
+	for _, burstRes := range report.Burst {
+		switch {
+		case burstRes.Limited:
+			assert.Equal(t, http.StatusTooManyRequests, burstRes.StatusCode)
+			assert.Empty(t, burstRes.Backend)
+			limited++
+		default:
+			assert.Equal(t, BurstResponse{StatusCode: http.StatusOK, Backend: "http://10.10.10.16"}, burstRes)
+			handled++
+		}
+	}
+
+	assert.Equal(t, 1, handled)
+	assert.Equal(t, 3, limited)
+	assert.Equal(t, "api@file", report.Router)
+	assert.Equal(t, int64(1), report.Metrics.Attempts)
+}
+
+func TestTraefik_SendBurst_circuitBreaker(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {
+					Rule:        "PathPrefix(`/api`)",
+					Service:     "whoami@playground",
+					Middlewares: []string{"breaker"},
+				},
+			},
+			Middlewares: map[string]*dynamic.Middleware{
+				"breaker": {CircuitBreaker: &dynamic.CircuitBreaker{
+					// The whoami service always answers with a 418 status.
+					Expression:       "ResponseCodeRatio(400, 500, 0, 600) > 0.5",
+					CheckPeriod:      ptypes.Duration(100 * time.Millisecond),
+					FallbackDuration: ptypes.Duration(10 * time.Second),
+					RecoveryDuration: ptypes.Duration(10 * time.Second),
+					ResponseCode:     http.StatusServiceUnavailable,
+				}},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	var logs syncBuffer
+	ctx := zerolog.New(&logs).Level(zerolog.DebugLevel).WithContext(t.Context())
+
+	require.NoError(t, traefik.Start(ctx))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api", http.NoBody)
+
+	res, report, err := traefik.SendBurst(req, 3, false)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	assert.Equal(t, []BurstResponse{
+		{StatusCode: http.StatusTeapot, Backend: "http://10.10.10.10"},
+		{StatusCode: http.StatusServiceUnavailable},
+		{StatusCode: http.StatusServiceUnavailable},
+	}, report.Burst)
+
+	assert.Equal(t, []CircuitBreakerTransition{
+		{Middleware: "breaker@file", From: CircuitBreakerClosed, To: CircuitBreakerOpen},
+	}, CircuitBreakerTransitions(ParseRawLogs(logs.String())))
+}
+
+func TestTraefik_SendBurst_stickySessions(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {
+					Rule:    "PathPrefix(`/api`)",
+					Service: "replicas",
+				},
+			},
+			Services: map[string]*dynamic.Service{
+				"replicas": {LoadBalancer: &dynamic.ServersLoadBalancer{
+					Sticky: &dynamic.Sticky{Cookie: &dynamic.Cookie{Name: "sticky"}},
+					Servers: []dynamic.Server{
+						{URL: "http://10.10.10.21"},
+						{URL: "http://10.10.10.22"},
+						{URL: "http://10.10.10.23"},
+					},
+				}},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	backends := func(burst []BurstResponse) map[string]int {
+		counts := make(map[string]int)
+		for _, res := range burst {
+			assert.Equal(t, http.StatusTeapot, res.StatusCode)
+			counts[res.Backend]++
+		}
+
+		return counts
+	}
+
+	// Without cookies, each request starts a new session and the requests are spread across the replicas.
+	res, report, err := traefik.SendBurst(httptest.NewRequest(http.MethodGet, "http://example.com/api", http.NoBody), 6, false)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	assert.Equal(t, map[string]int{"http://10.10.10.21": 2, "http://10.10.10.22": 2, "http://10.10.10.23": 2}, backends(report.Burst))
+
+	// With cookies, the session is pinned to the replica which handled the first request.
+	res, report, err = traefik.SendBurst(httptest.NewRequest(http.MethodGet, "http://example.com/api", http.NoBody), 6, true)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	counts := backends(report.Burst)
+	require.Len(t, counts, 1)
+	assert.Equal(t, 6, counts[report.Burst[0].Backend])
+	assert.Equal(t, report.Burst[0].Backend, report.Backend)
+
+	// The sticky cookie is only set on the first response, the next requests already hold it.
+	assert.Empty(t, res.Cookies())
+}
+
+// syncBuffer is a bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func TestTraefik_Send_report_routerEvaluations(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api":   {Rule: "PathPrefix(`/api`)", Service: "whoami@playground"},
+				"admin": {Rule: "Host(`admin.example.com`)", Priority: 100, Service: "whoami@playground"},
+				"root":  {Rule: "PathPrefix(`/`)", Priority: 1, Service: "whoami@playground"},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	res, report, err := traefik.Send(httptest.NewRequest(http.MethodGet, "http://example.com/api/users", nil))
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	assert.Equal(t, "api@file", report.Router)
+	assert.Equal(t, []RouterEvaluation{
+		{
+			Router:    "admin@file",
+			Rule:      "Host(`admin.example.com`)",
+			Priority:  100,
+			Evaluated: true,
+			Reason:    "the rule didn't match",
+		},
+		{
+			Router:    "api@file",
+			Rule:      "PathPrefix(`/api`)",
+			Priority:  18,
+			Evaluated: true,
+			Matched:   true,
+			Selected:  true,
+			Reason:    "the rule matched, with the highest priority among the matching routers",
+		},
+		{
+			Router:    "root@file",
+			Rule:      "PathPrefix(`/`)",
+			Priority:  1,
+			Evaluated: true,
+			Matched:   true,
+			Reason:    `the rule matched, but the router "api@file" has a higher priority`,
+		},
+	}, report.RouterEvaluations)
+}
+
+func TestTraefik_Route_routerEvaluations_skipped(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api":     {Rule: "PathPrefix(`/api`)", Service: "whoami@playground"},
+				"invalid": {Rule: "PathPrefix(`/invalid`)", Service: "unknown"},
+				"secure":  {Rule: "PathPrefix(`/`)", Service: "whoami@playground", TLS: &dynamic.RouterTLSConfig{}},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	report := traefik.Route(httptest.NewRequest(http.MethodGet, "http://example.com/invalid", nil))
+
+	assert.Empty(t, report.Router)
+	require.Len(t, report.RouterEvaluations, 3)
+
+	assert.Equal(t, RouterEvaluation{
+		Router:    "api@file",
+		Rule:      "PathPrefix(`/api`)",
+		Priority:  18,
+		Evaluated: true,
+		Reason:    "the rule didn't match",
+	}, report.RouterEvaluations[0])
+
+	assert.Equal(t, "invalid@file", report.RouterEvaluations[1].Router)
+	assert.False(t, report.RouterEvaluations[1].Evaluated)
+	assert.Contains(t, report.RouterEvaluations[1].Reason, "the router is disabled: ")
+
+	assert.Equal(t, RouterEvaluation{
+		Router: "secure@file",
+		Rule:   "PathPrefix(`/`)",
+		Reason: "TLS routers don't handle the plain HTTP requests of the playground",
+	}, report.RouterEvaluations[2])
+}
+
+func TestTraefik_Send_report_noMatch(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {Rule: "PathPrefix(`/api`)", Service: "whoami@playground"},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	res, report, err := traefik.Send(httptest.NewRequest(http.MethodGet, "http://example.com/foo", nil))
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	assert.Empty(t, report.Router)
+}
+
+func TestTraefik_Send_playgroundServerURLWrittenDifferently(t *testing.T) {
+	t.Parallel()
+
+	for _, serverURL := range []string{"http://10.10.10.10/", "HTTP://10.10.10.10:80"} {
+		t.Run(serverURL, func(t *testing.T) {
+			t.Parallel()
+
+			traefik := startTraefik(t, &dynamic.Configuration{
+				HTTP: &dynamic.HTTPConfiguration{
+					Routers: map[string]*dynamic.Router{
+						"api": {Rule: "PathPrefix(`/`)", Service: "api"},
+					},
+					Services: map[string]*dynamic.Service{
+						"api": {
+							LoadBalancer: &dynamic.ServersLoadBalancer{
+								Servers: []dynamic.Server{{URL: serverURL}},
+							},
+						},
+					},
+				},
+			}, Options{})
+
+			res, report, err := traefik.Send(httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+			require.NoError(t, err)
+
+			// The playground whoami answers with a teapot.
+			assert.Equal(t, http.StatusTeapot, res.StatusCode)
+			assert.Equal(t, "http://10.10.10.10", report.Backend)
+		})
+	}
+}
+
+func TestTraefik_ResolvedConfig(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {Rule: "PathPrefix(`/api`)", Service: "api"},
+			},
+			Services: map[string]*dynamic.Service{
+				"api": {
+					LoadBalancer: &dynamic.ServersLoadBalancer{
+						Servers: []dynamic.Server{{URL: "http://10.10.10.10"}},
+					},
+				},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	_, err = traefik.ResolvedConfig()
+	require.Error(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	raw, err := traefik.ResolvedConfig()
+	require.NoError(t, err)
+
+	var resolved struct {
+		Routers map[string]struct {
+			Service string `json:"service"`
+			Status  string `json:"status"`
+		} `json:"routers"`
+		Services map[string]struct {
+			LoadBalancer struct {
+				PassHostHeader *bool `json:"passHostHeader"`
+				Servers        []struct {
+					URL string `json:"url"`
+				} `json:"servers"`
+			} `json:"loadBalancer"`
+			UsedBy []string `json:"usedBy"`
+		} `json:"services"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &resolved))
+
+	require.Contains(t, resolved.Routers, "api@file")
+	assert.Equal(t, "api", resolved.Routers["api@file"].Service)
+	assert.Equal(t, "enabled", resolved.Routers["api@file"].Status)
+
+	// Only the services defined by the user are listed.
+	require.Len(t, resolved.Services, 1)
+	require.Contains(t, resolved.Services, "api@file")
+
+	service := resolved.Services["api@file"]
+	assert.Equal(t, []string{"api@file"}, service.UsedBy)
+
+	require.NotNil(t, service.LoadBalancer.PassHostHeader)
+	assert.True(t, *service.LoadBalancer.PassHostHeader)
+
+	require.Len(t, service.LoadBalancer.Servers, 1)
+	assert.Equal(t, "http://10.10.10.10", service.LoadBalancer.Servers[0].URL)
+}
+
+func TestTraefik_Stream(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"events": {Rule: "PathPrefix(`/`)", Service: "events@playground"},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	pr, pw := io.Pipe()
+
+	doneCh := make(chan error, 1)
+	go func() {
+		streamWriter := NewStreamWriter(pw)
+
+		streamErr := traefik.Stream(streamWriter, httptest.NewRequest(http.MethodGet, "http://example.com/?count=2&interval=500ms", nil))
+		if streamErr == nil {
+			streamErr = streamWriter.Close()
+		}
+
+		_ = pw.CloseWithError(streamErr)
+		doneCh <- streamErr
+	}()
+
+	res, err := http.ReadResponse(bufio.NewReader(pr), nil)
+	require.NoError(t, err)
+
+	defer func() { _ = res.Body.Close() }()
+
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, "text/event-stream", res.Header.Get("Content-Type"))
+
+	body := bufio.NewReader(res.Body)
+
+	var firstEvent strings.Builder
+	for {
+		line, readErr := body.ReadString('\n')
+		require.NoError(t, readErr)
+
+		if line == "\n" {
+			break
+		}
+
+		firstEvent.WriteString(line)
+	}
+
+	assert.Equal(t, "id: 1\ndata: event 1 of 2\n", firstEvent.String())
+
+	select {
+	case <-doneCh:
+		t.Fatal("the first event was only received once the backend closed the stream")
+	default:
+	}
+
+	rest, err := io.ReadAll(body)
+	require.NoError(t, err)
+
+	assert.Equal(t, "id: 2\ndata: event 2 of 2\n\n", string(rest))
+	require.NoError(t, <-doneCh)
+}
+
+func TestTraefik_UDP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc   string
+		config *dynamic.UDPConfiguration
+	}{
+		{
+			desc: "playground service",
+			config: &dynamic.UDPConfiguration{
+				Routers: map[string]*dynamic.UDPRouter{
+					"dns": {
+						EntryPoints: []string{"udp"},
+						Service:     "whoami-udp@playground",
+					},
+				},
+			},
+		},
+		{
+			desc: "custom service pointing to 10.10.10.10:53",
+			config: &dynamic.UDPConfiguration{
+				Routers: map[string]*dynamic.UDPRouter{
+					"dns": {
+						EntryPoints: []string{"udp"},
+						Service:     "dns",
+					},
+				},
+				Services: map[string]*dynamic.UDPService{
+					"dns": {
+						LoadBalancer: &dynamic.UDPServersLoadBalancer{
+							Servers: []dynamic.UDPServer{{Address: "10.10.10.10:53"}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			traefik, err := NewTraefik(&dynamic.Configuration{UDP: test.config}, Options{})
+			require.NoError(t, err)
+
+			readyCh := make(chan struct{})
+			traefik.OnReady(func() {
+				close(readyCh)
+			})
+
+			require.NoError(t, traefik.Start(t.Context()))
+
+			select {
+			case <-readyCh:
+			case <-time.After(time.Second):
+				t.Fatal("timed out waiting for Traefik to be ready")
+			}
+
+			ctx, cancel := context.WithTimeout(t.Context(), time.Second)
+			defer cancel()
+
+			reply, err := traefik.SendUDP(ctx, []byte("ping"))
+			require.NoError(t, err)
+
+			assert.Equal(t, "ping", string(reply))
+		})
+	}
+}
+
+func TestTraefik_UDP_noRouter(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {Rule: "PathPrefix(`/`)", Service: "whoami@playground"},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = traefik.SendUDP(ctx, []byte("ping"))
+	require.Error(t, err)
+}
+
+func TestTraefik_Send_delayed(t *testing.T) {
+	t.Parallel()
+
+	traefik, err := NewTraefik(&dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"whoami": {
+					Rule:    "PathPrefix(`/`)",
+					Service: "whoami",
+				},
+			},
+			Services: map[string]*dynamic.Service{
+				"whoami": {LoadBalancer: &dynamic.ServersLoadBalancer{
+					Servers: []dynamic.Server{{URL: whoamiURL}},
+				}},
+			},
+		},
+	}, Options{})
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(t.Context()))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	tests := []struct {
+		desc       string
+		body       string
+		delay      time.Duration
+		timeout    time.Duration
+		wantStatus int
+	}{
+		{
+			desc:       "body sent before the timeout",
+			body:       "hello",
+			delay:      10 * time.Millisecond,
+			timeout:    time.Second,
+			wantStatus: http.StatusTeapot,
+		},
+		{
+			desc:       "body sent after the timeout",
+			body:       "hello",
+			delay:      time.Second,
+			timeout:    50 * time.Millisecond,
+			wantStatus: http.StatusGatewayTimeout,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(t.Context(), test.timeout)
+			defer cancel()
+
+			req := httptest.NewRequestWithContext(ctx, http.MethodPost, "http://example.com/", strings.NewReader(test.body))
+			require.NoError(t, DelayRequest(req, test.delay))
+
+			start := time.Now()
+
+			res, _, err := traefik.Send(req)
+			require.NoError(t, err)
+
+			assert.Equal(t, test.wantStatus, res.StatusCode)
+			assert.GreaterOrEqual(t, time.Since(start), min(test.delay, test.timeout))
+		})
+	}
+}
+
+func TestDelayRequest_noBody(t *testing.T) {
+	t.Parallel()
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+
+	start := time.Now()
+	require.NoError(t, DelayRequest(req, 20*time.Millisecond))
+	assert.GreaterOrEqual(t, time.Since(start), 20*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+	defer cancel()
+
+	err := DelayRequest(req.WithContext(ctx), time.Second)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestTraefik_Stopped(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	traefik := startTraefikWithContext(t, ctx, &dynamic.Configuration{
+		HTTP: &dynamic.HTTPConfiguration{
+			Routers: map[string]*dynamic.Router{
+				"api": {Rule: "PathPrefix(`/`)", Service: "whoami@playground"},
+			},
+		},
+	}, Options{})
+
+	res, _, err := traefik.Send(httptest.NewRequest(http.MethodGet, "http://localhost/", nil))
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	assert.Equal(t, http.StatusTeapot, res.StatusCode)
+
+	select {
+	case <-traefik.Stopped():
+		t.Fatal("instance stopped before its context ended")
+	default:
+	}
+
+	cancel()
+
+	select {
+	case <-traefik.Stopped():
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the instance to stop")
+	}
+}
+
+// TestTraefik_Route_evaluate checks that matcher.Evaluate, evaluating the routing of experiments in the browser,
+// picks the same router as the fake Traefik instance.
+func TestTraefik_Route_evaluate(t *testing.T) {
+	t.Parallel()
+
+	configs := []string{
+		`
+http:
+  routers:
+    api:
+      rule: PathPrefix(` + "`/api`" + `)
+      service: whoami@playground
+    catchAll:
+      rule: PathPrefix(` + "`/`" + `)
+      service: whoami@playground
+`,
+		`
+http:
+  routers:
+    low:
+      rule: Host(` + "`example.com`" + `) && PathPrefix(` + "`/api/v1`" + `)
+      priority: 1
+      service: whoami@playground
+    high:
+      rule: Host(` + "`example.com`" + `)
+      priority: 10
+      service: whoami@playground
+    headers:
+      rule: Header(` + "`X-Version`, `2`" + `) || ClientIP(` + "`10.0.0.0/8`" + `)
+      priority: 100
+      service: whoami@playground
+`,
+		`
+http:
+  routers:
+    internal:
+      entryPoints: [internal]
+      rule: PathPrefix(` + "`/`" + `)
+      service: whoami@playground
+    web:
+      entryPoints: [web]
+      rule: Path(` + "`/web`" + `)
+      service: whoami@playground
+    invalid:
+      rule: Unknown(` + "`/`" + `)
+      service: whoami@playground
+    tls:
+      rule: PathPrefix(` + "`/`" + `)
+      priority: 1000
+      service: whoami@playground
+      tls: {}
+`,
+	}
+
+	requests := []matcher.Request{
+		{Method: http.MethodGet, URL: "http://example.com/"},
+		{Method: http.MethodGet, URL: "http://example.com/api/v1/users"},
+		{Method: http.MethodGet, URL: "http://other.com/api"},
+		{Method: http.MethodGet, URL: "http://other.com/web"},
+		{Method: http.MethodGet, URL: "http://10.0.0.1/", Host: "example.com"},
+		{Method: http.MethodGet, URL: "http://other.com/", Headers: http.Header{"X-Version": {"2"}}},
+		{Method: http.MethodGet, URL: "http://other.com/", ClientIP: "10.1.2.3"},
+	}
+
+	for i, config := range configs {
+		var dynamicConfig dynamic.Configuration
+		require.NoError(t, yaml.Unmarshal([]byte(config), &dynamicConfig))
+
+		traefik := startTraefik(t, &dynamicConfig, Options{})
+
+		for _, req := range requests {
+			want, err := matcher.Evaluate(config, req)
+			require.NoError(t, err)
+
+			httpReq := httptest.NewRequest(req.Method, req.URL, nil)
+			httpReq.Header = req.Headers.Clone()
+			if req.Host != "" {
+				httpReq.Host = req.Host
+			}
+			if req.ClientIP != "" {
+				httpReq.RemoteAddr = req.ClientIP + ":12345"
+			}
+
+			report := traefik.Route(httpReq)
+
+			assert.Equal(t, want.Router, report.Router, "config %d, request %+v", i, req)
+			assert.Equal(t, want.Rule, report.Rule, "config %d, request %+v", i, req)
+			assert.Equal(t, want.Priority, report.Priority, "config %d, request %+v", i, req)
+		}
+	}
+}
+
+// startTraefik starts a fake Traefik instance with the given dynamic configuration and Options, and waits for it to
+// be ready. The instance stops with the test.
+func startTraefik(t *testing.T, dynamicConfig *dynamic.Configuration, options Options) *Traefik {
+	t.Helper()
+
+	return startTraefikWithContext(t, t.Context(), dynamicConfig, options)
+}
+
+// startTraefikWithContext is like startTraefik, the instance running with the given context.
+func startTraefikWithContext(t *testing.T, ctx context.Context, dynamicConfig *dynamic.Configuration, options Options) *Traefik {
+	t.Helper()
+
+	traefik, err := NewTraefik(dynamicConfig, options)
+	require.NoError(t, err)
+
+	readyCh := make(chan struct{})
+	traefik.OnReady(func() {
+		close(readyCh)
+	})
+
+	require.NoError(t, traefik.Start(ctx))
+
+	select {
+	case <-readyCh:
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for Traefik to be ready")
+	}
+
+	return traefik
+}
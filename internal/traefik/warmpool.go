@@ -0,0 +1,253 @@
+package traefik
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/jspdown/traefik-playground/internal/command"
+	"github.com/rs/zerolog/log"
+)
+
+// workerWaitDelay is how long a stopped tester process is given to release its output before it's abandoned.
+const workerWaitDelay = time.Second
+
+// maxWorkerStderrSize is the number of bytes kept from the standard error of a tester process, which only holds
+// what's written outside of its Jobs.
+const maxWorkerStderrSize = 64 * 1024
+
+// WarmPool holds tester processes started ahead of time, each running the Jobs of several Commands one after the
+// other. It saves the cost of starting a sandboxed process for every Command. A process is replaced once it ran
+// maxRuns Jobs, or as soon as one of its Jobs fails, as its state can't be trusted anymore.
+type WarmPool struct {
+	newCmd  func() (*exec.Cmd, error)
+	maxRuns int
+
+	mu     sync.Mutex
+	idle   chan *worker
+	closed bool
+}
+
+// NewWarmPool creates a WarmPool keeping up to size idle tester processes, each running at most maxRuns Jobs.
+// Limits, passEnv and timeout apply to the tester processes as they apply to the processes of the Commands: as a
+// process runs several Jobs, they share its CPU time limit, while each Job gets the whole timeout.
+func NewWarmPool(size, maxRuns int, limits command.ResourceLimits, passEnv []string, timeout time.Duration) *WarmPool {
+	return newWarmPool(size, maxRuns, func() (*exec.Cmd, error) {
+		return command.NewIsolatedCommand(context.Background(), []command.MountPoint{
+			{Host: "/app", Target: "/app"},
+		}, limits, passEnv, "/app/traefik-playground", "tester", "--serve", "--log-level=debug", "--timeout", timeout.String())
+	})
+}
+
+func newWarmPool(size, maxRuns int, newCmd func() (*exec.Cmd, error)) *WarmPool {
+	return &WarmPool{
+		newCmd:  newCmd,
+		maxRuns: max(maxRuns, 1),
+		idle:    make(chan *worker, size),
+	}
+}
+
+// Prewarm starts tester processes in the background until the pool holds size idle ones.
+func (p *WarmPool) Prewarm() {
+	for range cap(p.idle) - len(p.idle) {
+		go p.refill()
+	}
+}
+
+// Close stops the idle tester processes. The processes running a Job are stopped once it completes.
+func (p *WarmPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.closed = true
+
+	for {
+		select {
+		case w := <-p.idle:
+			w.stop()
+		default:
+			return
+		}
+	}
+}
+
+// run runs the given Job on an idle tester process, or on a new one if none is idle.
+func (p *WarmPool) run(ctx context.Context, job Job) (JobResult, error) {
+	w, err := p.get()
+	if err != nil {
+		return JobResult{}, err
+	}
+
+	result, err := w.run(ctx, job)
+
+	// An idle process may have exited in the meantime, such as when it ran out of CPU time. Give the Job a second
+	// chance on a new process.
+	if errors.Is(err, errWorkerExited) && w.runs > 1 && ctx.Err() == nil {
+		w.stop()
+		go p.refill()
+
+		if w, err = p.startWorker(); err != nil {
+			return JobResult{}, err
+		}
+
+		result, err = w.run(ctx, job)
+	}
+
+	if err != nil || result.Error != "" || w.runs >= p.maxRuns {
+		w.stop()
+		go p.refill()
+
+		if err != nil && ctx.Err() == nil {
+			log.Ctx(ctx).Error().Err(err).Str("stderr", w.stderr.String()).Msg("Tester process has failed")
+		}
+
+		return result, err
+	}
+
+	p.put(w)
+
+	return result, nil
+}
+
+func (p *WarmPool) get() (*worker, error) {
+	select {
+	case w := <-p.idle:
+		return w, nil
+	default:
+		return p.startWorker()
+	}
+}
+
+// put hands the given tester process back to the pool, or stops it if the pool is full or closed.
+func (p *WarmPool) put(w *worker) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		w.stop()
+
+		return
+	}
+
+	select {
+	case p.idle <- w:
+	default:
+		w.stop()
+	}
+}
+
+// refill starts a tester process for the pool, unless it's already full or closed.
+func (p *WarmPool) refill() {
+	p.mu.Lock()
+	full := p.closed || len(p.idle) == cap(p.idle)
+	p.mu.Unlock()
+
+	if full {
+		return
+	}
+
+	w, err := p.startWorker()
+	if err != nil {
+		log.Error().Err(err).Msg("Unable to start tester process")
+
+		return
+	}
+
+	p.put(w)
+}
+
+func (p *WarmPool) startWorker() (*worker, error) {
+	cmd, err := p.newCmd()
+	if err != nil {
+		return nil, fmt.Errorf("creating tester process: %w", err)
+	}
+
+	w := &worker{cmd: cmd, stderr: logTail{maxSize: maxWorkerStderrSize}}
+	cmd.Stderr = &w.stderr
+	cmd.WaitDelay = workerWaitDelay
+
+	if w.stdin, err = cmd.StdinPipe(); err != nil {
+		return nil, fmt.Errorf("setting up tester process stdin pipe: %w", err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("setting up tester process stdout pipe: %w", err)
+	}
+
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("starting tester process: %w", err)
+	}
+
+	w.results = json.NewDecoder(stdout)
+
+	return w, nil
+}
+
+// errWorkerExited indicates that a tester process exited before returning the result of a Job.
+var errWorkerExited = errors.New("tester process exited")
+
+// worker is a tester process started in serve mode.
+type worker struct {
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	results *json.Decoder
+	// stderr must only be read once the process is stopped.
+	stderr logTail
+
+	// runs is the number of Jobs sent to the process.
+	runs int
+
+	stopOnce sync.Once
+}
+
+// run runs the given Job on the tester process. The process is stopped if the context ends before the Job
+// completes.
+func (w *worker) run(ctx context.Context, job Job) (JobResult, error) {
+	w.runs++
+
+	if err := json.NewEncoder(w.stdin).Encode(job); err != nil {
+		return JobResult{}, fmt.Errorf("%w: sending job: %w", errWorkerExited, err)
+	}
+
+	type resultOrErr struct {
+		result JobResult
+		err    error
+	}
+
+	// The channel is buffered so that the decoding doesn't hang once the process is stopped.
+	resultCh := make(chan resultOrErr, 1)
+	go func() {
+		var result JobResult
+		err := w.results.Decode(&result)
+
+		resultCh <- resultOrErr{result: result, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		w.stop()
+
+		return JobResult{}, ctx.Err()
+	case res := <-resultCh:
+		if res.err != nil {
+			return JobResult{}, fmt.Errorf("%w: reading job result: %w", errWorkerExited, res.err)
+		}
+
+		return res.result, nil
+	}
+}
+
+// stop kills the tester process and waits for it to exit.
+func (w *worker) stop() {
+	w.stopOnce.Do(func() {
+		_ = w.stdin.Close()
+		_ = w.cmd.Process.Kill()
+		_ = w.cmd.Wait()
+	})
+}
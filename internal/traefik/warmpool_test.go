@@ -0,0 +1,294 @@
+package traefik
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jspdown/traefik-playground/internal/command"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testerProcessEnv is the environment variable making the test binary act as a tester process.
+const testerProcessEnv = "TRAEFIK_PLAYGROUND_TESTER_PROCESS"
+
+const warmPoolDynamicConfig = `
+http:
+  routers:
+    whoami:
+      rule: PathPrefix(` + "`/`" + `)
+      service: whoami@playground
+      middlewares:
+        - headers
+  middlewares:
+    headers:
+      headers:
+        customResponseHeaders:
+          X-Response-Header: response
+`
+
+// TestTesterProcess isn't a test: it makes the test binary serve Jobs as a tester process started in serve mode,
+// for the tests of the WarmPool.
+func TestTesterProcess(t *testing.T) {
+	if os.Getenv(testerProcessEnv) != "1" {
+		t.Skip("only run as a tester process")
+	}
+
+	logs := &JobLogs{}
+	log.Logger = zerolog.New(logs).With().Timestamp().Logger().Level(zerolog.DebugLevel)
+	zerolog.DefaultContextLogger = &log.Logger
+
+	if err := Serve(context.Background(), os.Stdin, os.Stdout, logs, 2*time.Second); err != nil {
+		os.Exit(1)
+	}
+
+	os.Exit(0)
+}
+
+// newTestWarmPool creates a WarmPool whose tester processes are the test binary, started with the given additional
+// environment variables. The given counter, if any, counts the started processes.
+func newTestWarmPool(t testing.TB, size, maxRuns int, started *atomic.Int64, env ...string) *WarmPool {
+	t.Helper()
+
+	pool := newWarmPool(size, maxRuns, func() (*exec.Cmd, error) {
+		if started != nil {
+			started.Add(1)
+		}
+
+		cmd := exec.Command(os.Args[0], "-test.run=^TestTesterProcess$")
+		cmd.Env = append(append(os.Environ(), testerProcessEnv+"=1"), env...)
+
+		return cmd, nil
+	})
+	t.Cleanup(pool.Close)
+
+	return pool
+}
+
+func runWarmCommand(t testing.TB, pool *WarmPool, dynamicConfig string, req *http.Request) (*http.Response, Report, []Log) {
+	t.Helper()
+
+	cmd, err := NewCommand(dynamicConfig, req, CommandOptions{Timeout: 2 * time.Second})
+	require.NoError(t, err)
+
+	cmd.UseWarmPool(pool)
+	require.NoError(t, cmd.Exec(t.Context()))
+
+	res, report, logs, err := cmd.Result()
+	require.NoError(t, err)
+
+	return res, report, logs
+}
+
+func TestWarmPool_reusedProcess(t *testing.T) {
+	t.Parallel()
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com/foo", strings.NewReader(`{"foo": "bar"}`))
+		req.RemoteAddr = ""
+
+		return req
+	}
+
+	var started atomic.Int64
+	reusedPool := newTestWarmPool(t, 1, 10, &started)
+
+	// Warm the process up with a different experiment, then run the experiment on the same process.
+	warmUpConfig := "http:\n  routers:\n    other:\n      rule: Path(`/bar`)\n      service: whoami@playground\n"
+	_, _, _ = runWarmCommand(t, reusedPool, warmUpConfig, httptest.NewRequest(http.MethodGet, "http://example.com/bar", nil))
+	reusedRes, reusedReport, reusedLogs := runWarmCommand(t, reusedPool, warmPoolDynamicConfig, newRequest())
+
+	assert.Equal(t, int64(1), started.Load())
+
+	freshPool := newTestWarmPool(t, 1, 10, nil)
+	freshRes, freshReport, freshLogs := runWarmCommand(t, freshPool, warmPoolDynamicConfig, newRequest())
+
+	assert.Equal(t, freshRes.StatusCode, reusedRes.StatusCode)
+	assert.Equal(t, "response", reusedRes.Header.Get("X-Response-Header"))
+
+	// The playground backends answer with the current date.
+	freshRes.Header.Del("Date")
+	reusedRes.Header.Del("Date")
+	assert.Equal(t, freshRes.Header, reusedRes.Header)
+
+	assert.Equal(t, readBody(t, freshRes), readBody(t, reusedRes))
+	assert.Equal(t, freshReport, reusedReport)
+	assert.Len(t, reusedLogs, len(freshLogs))
+}
+
+func TestWarmPool_maxRuns(t *testing.T) {
+	t.Parallel()
+
+	var started atomic.Int64
+	pool := newTestWarmPool(t, 1, 2, &started)
+
+	for range 2 {
+		res, _, _ := runWarmCommand(t, pool, warmPoolDynamicConfig, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+		assert.Equal(t, http.StatusTeapot, res.StatusCode)
+	}
+
+	// The process is replaced after 2 runs, the replacement is started in the background.
+	assert.Eventually(t, func() bool { return len(pool.idle) == 1 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(2), started.Load())
+
+	res, _, _ := runWarmCommand(t, pool, warmPoolDynamicConfig, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+	assert.Equal(t, http.StatusTeapot, res.StatusCode)
+	assert.Equal(t, int64(2), started.Load())
+}
+
+func TestWarmPool_timeout(t *testing.T) {
+	t.Parallel()
+
+	var started atomic.Int64
+	pool := newTestWarmPool(t, 1, 10, &started)
+
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+
+	cmd, err := NewCommand(warmPoolDynamicConfig, req, CommandOptions{Delay: time.Second, Timeout: 2 * time.Second})
+	require.NoError(t, err)
+
+	cmd.UseWarmPool(pool)
+
+	ctx, cancel := context.WithTimeout(t.Context(), 100*time.Millisecond)
+	defer cancel()
+
+	require.ErrorIs(t, cmd.Exec(ctx), context.DeadlineExceeded)
+
+	// The process which timed out is replaced in the background, and the next experiment runs on the replacement.
+	assert.Eventually(t, func() bool { return len(pool.idle) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	res, _, _ := runWarmCommand(t, pool, warmPoolDynamicConfig, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+	assert.Equal(t, http.StatusTeapot, res.StatusCode)
+	assert.Equal(t, int64(2), started.Load())
+}
+
+func TestWarmPool_http10(t *testing.T) {
+	t.Parallel()
+
+	newRequest := func(proto string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "http://example.com/foo", nil)
+		req.RemoteAddr = ""
+		req.Proto = proto
+		req.ProtoMajor, req.ProtoMinor, _ = http.ParseHTTPVersion(proto)
+
+		return req
+	}
+
+	pool := newTestWarmPool(t, 1, 10, nil)
+
+	res, report, _ := runWarmCommand(t, pool, warmPoolDynamicConfig, newRequest("HTTP/1.0"))
+	wantRes, wantReport, _ := runWarmCommand(t, pool, warmPoolDynamicConfig, newRequest("HTTP/1.1"))
+
+	assert.Equal(t, wantRes.StatusCode, res.StatusCode)
+	assert.Equal(t, "response", res.Header.Get("X-Response-Header"))
+	assert.Equal(t, wantReport, report)
+
+	// Traefik's proxy talks HTTP/1.1 to the backends whatever the version of the request it received.
+	assert.Equal(t, readBody(t, wantRes), readBody(t, res))
+}
+
+func TestWarmPool_timeZone(t *testing.T) {
+	t.Parallel()
+
+	// Asia/Kolkata has no daylight saving time.
+	pool := newTestWarmPool(t, 1, 10, nil, "TZ=Asia/Kolkata")
+
+	_, _, logs := runWarmCommand(t, pool, warmPoolDynamicConfig, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+	require.NotEmpty(t, logs)
+
+	for _, l := range logs {
+		assert.True(t, strings.HasSuffix(l.Timestamp, "+05:30"), l.Timestamp)
+	}
+}
+
+func TestWarmPool_failedJob(t *testing.T) {
+	t.Parallel()
+
+	pool := newTestWarmPool(t, 1, 10, nil)
+
+	cmd, err := NewCommand("http: [", httptest.NewRequest(http.MethodGet, "http://example.com/", nil), CommandOptions{Timeout: 2 * time.Second})
+	require.NoError(t, err)
+
+	cmd.UseWarmPool(pool)
+
+	var testerErr *TesterError
+	require.ErrorAs(t, cmd.Exec(t.Context()), &testerErr)
+	assert.False(t, testerErr.TimedOut)
+
+	_, _, _, err = cmd.Result()
+	require.Error(t, err)
+
+	assert.Contains(t, cmd.stderr.String(), "command failed: decoding dynamic configuration")
+}
+
+func TestWarmPool_outcomes(t *testing.T) {
+	t.Parallel()
+
+	pool := newTestWarmPool(t, 1, 10, nil)
+	workerPool := command.NewWorkerPool(1, 1)
+
+	spawn := func(dynamicConfig string, opts CommandOptions) error {
+		cmd, err := NewCommand(dynamicConfig, httptest.NewRequest(http.MethodGet, "http://example.com/", nil), opts)
+		require.NoError(t, err)
+
+		cmd.UseWarmPool(pool)
+
+		return workerPool.Spawn(t.Context(), cmd, command.PriorityHigh)
+	}
+
+	require.NoError(t, spawn(warmPoolDynamicConfig, CommandOptions{}))
+
+	// The tester fails to decode the dynamic configuration.
+	err := spawn("http: [", CommandOptions{})
+	var testerErr *TesterError
+	require.ErrorAs(t, err, &testerErr)
+	assert.False(t, testerErr.TimedOut)
+	require.NotErrorIs(t, err, context.DeadlineExceeded)
+
+	// The tester gives up after its 2 seconds timeout, while delaying the request.
+	err = spawn(warmPoolDynamicConfig, CommandOptions{Delay: 5 * time.Second})
+	require.ErrorAs(t, err, &testerErr)
+	assert.True(t, testerErr.TimedOut)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	assert.Equal(t, command.Outcomes{Succeeded: 1, Failed: 1, TimedOut: 1}, workerPool.Outcomes())
+}
+
+func readBody(t testing.TB, res *http.Response) string {
+	t.Helper()
+
+	defer func() { _ = res.Body.Close() }()
+
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+
+	return string(body)
+}
+
+func BenchmarkWarmPool_reusedProcess(b *testing.B) {
+	pool := newTestWarmPool(b, 1, math.MaxInt, nil)
+
+	for b.Loop() {
+		runWarmCommand(b, pool, warmPoolDynamicConfig, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+	}
+}
+
+func BenchmarkWarmPool_freshProcess(b *testing.B) {
+	// Without idle processes, each experiment starts its own process, as when the WarmPool isn't used.
+	pool := newTestWarmPool(b, 0, 1, nil)
+
+	for b.Loop() {
+		runWarmCommand(b, pool, warmPoolDynamicConfig, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+	}
+}
@@ -1,8 +1,15 @@
 package traefik
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"time"
 )
 
 // Whoami is a fake server responding 418 Teapot with the raw request.
@@ -10,12 +17,16 @@ type Whoami struct{}
 
 // NewWhoami creates a new Whoami.
 func NewWhoami() *httptest.Server {
+	return httptest.NewServer(newWhoamiHandler())
+}
+
+func newWhoamiHandler() http.Handler {
 	s := &Whoami{}
 
 	handler := http.NewServeMux()
 	handler.HandleFunc("/", s.handle)
 
-	return httptest.NewServer(handler)
+	return handler
 }
 
 func (s *Whoami) handle(rw http.ResponseWriter, req *http.Request) {
@@ -27,3 +38,121 @@ func (s *Whoami) handle(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 }
+
+// largeWhoamiPadding is the line repeated by LargeWhoami to produce a large and compressible body.
+const largeWhoamiPadding = "The quick brown fox jumps over the lazy dog.\n"
+
+// largeWhoamiPaddingCount is the number of times largeWhoamiPadding is repeated.
+const largeWhoamiPaddingCount = 400
+
+// LargeWhoami is a fake server responding 418 Teapot with the raw request followed by a large
+// and compressible text. It is meant to exercise middlewares such as compress.
+type LargeWhoami struct{}
+
+// NewLargeWhoami creates a new LargeWhoami.
+func NewLargeWhoami() *httptest.Server {
+	return httptest.NewServer(newLargeWhoamiHandler())
+}
+
+func newLargeWhoamiHandler() http.Handler {
+	s := &LargeWhoami{}
+
+	handler := http.NewServeMux()
+	handler.HandleFunc("/", s.handle)
+
+	return handler
+}
+
+func (s *LargeWhoami) handle(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.WriteHeader(http.StatusTeapot)
+
+	if err := req.Write(rw); err != nil {
+		return
+	}
+
+	_, _ = io.WriteString(rw, "\n"+strings.Repeat(largeWhoamiPadding, largeWhoamiPaddingCount))
+}
+
+// etagWhoamiLastModified is the modification time of the resources served by ETagWhoami, which never change.
+var etagWhoamiLastModified = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC) //nolint:gochecknoglobals // Constant.
+
+// ETagWhoami is a fake server responding 200 OK with a resource identified by the request URI, along with its ETag
+// and Last-Modified headers. It honors conditional requests, answering 304 Not Modified to an If-None-Match header
+// holding the ETag or to an If-Modified-Since header not older than the modification time. It is meant to exercise
+// caching middlewares and clients.
+type ETagWhoami struct{}
+
+// NewETagWhoami creates a new ETagWhoami.
+func NewETagWhoami() *httptest.Server {
+	return httptest.NewServer(newETagWhoamiHandler())
+}
+
+func newETagWhoamiHandler() http.Handler {
+	s := &ETagWhoami{}
+
+	handler := http.NewServeMux()
+	handler.HandleFunc("/", s.handle)
+
+	return handler
+}
+
+func (s *ETagWhoami) handle(rw http.ResponseWriter, req *http.Request) {
+	// Unlike whoami, the request isn't echoed back: a resource must stay the same for its ETag to be meaningful.
+	resource := "Resource " + req.URL.RequestURI() + "\n"
+
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	rw.Header().Set("ETag", etag(resource))
+
+	http.ServeContent(rw, req, "", etagWhoamiLastModified, strings.NewReader(resource))
+}
+
+// etag returns a strong ETag identifying the given content.
+func etag(content string) string {
+	sum := sha256.Sum256([]byte(content))
+
+	return `"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// WhoamiUDP is a fake UDP server echoing back the datagrams it receives.
+type WhoamiUDP struct {
+	conn net.PacketConn
+}
+
+// NewWhoamiUDP creates and starts a new WhoamiUDP listening on the loopback interface.
+func NewWhoamiUDP() (*WhoamiUDP, error) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("listening: %w", err)
+	}
+
+	s := &WhoamiUDP{conn: conn}
+	go s.serve()
+
+	return s, nil
+}
+
+// Addr returns the address the server listens on.
+func (s *WhoamiUDP) Addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+// Close stops the server.
+func (s *WhoamiUDP) Close() error {
+	return s.conn.Close()
+}
+
+func (s *WhoamiUDP) serve() {
+	buffer := make([]byte, maxDatagramSize)
+
+	for {
+		n, addr, err := s.conn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+
+		if _, err = s.conn.WriteTo(buffer[:n], addr); err != nil {
+			return
+		}
+	}
+}
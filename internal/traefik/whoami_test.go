@@ -3,8 +3,11 @@ package traefik
 import (
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -34,3 +37,127 @@ func TestWhoami(t *testing.T) {
 		"Accept-Encoding: gzip\r\n"+
 		"\r\n", string(bodyBytes))
 }
+
+func TestLargeWhoami(t *testing.T) {
+	t.Parallel()
+
+	server := NewLargeWhoami()
+	t.Cleanup(server.Close)
+
+	req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+"/", nil)
+	require.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusTeapot, resp.StatusCode)
+	assert.Equal(t, "text/plain; charset=utf-8", resp.Header.Get("Content-Type"))
+
+	bodyBytes, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.True(t, strings.HasPrefix(string(bodyBytes), "GET / HTTP/1.1\r\n"))
+	assert.Greater(t, len(bodyBytes), 16*1024)
+}
+
+func TestETagWhoami(t *testing.T) {
+	t.Parallel()
+
+	server := NewETagWhoami()
+	t.Cleanup(server.Close)
+
+	send := func(t *testing.T, header http.Header) (*http.Response, string) {
+		t.Helper()
+
+		req, err := http.NewRequestWithContext(t.Context(), http.MethodGet, server.URL+"/foo?bar=baz", nil)
+		require.NoError(t, err)
+
+		req.Header = header
+
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+
+		defer func() { _ = resp.Body.Close() }()
+
+		bodyBytes, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+
+		return resp, string(bodyBytes)
+	}
+
+	resp, body := send(t, http.Header{})
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "Resource /foo?bar=baz\n", body)
+	assert.Equal(t, "Mon, 01 Jan 2024 00:00:00 GMT", resp.Header.Get("Last-Modified"))
+
+	etag := resp.Header.Get("ETag")
+	assert.Regexp(t, `^"[0-9a-f]{16}"$`, etag)
+
+	tests := []struct {
+		desc       string
+		header     http.Header
+		wantStatus int
+	}{
+		{
+			desc:       "matching ETag",
+			header:     http.Header{"If-None-Match": {etag}},
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			desc:       "another ETag",
+			header:     http.Header{"If-None-Match": {`"other"`}},
+			wantStatus: http.StatusOK,
+		},
+		{
+			desc:       "not modified since",
+			header:     http.Header{"If-Modified-Since": {"Tue, 02 Jan 2024 00:00:00 GMT"}},
+			wantStatus: http.StatusNotModified,
+		},
+		{
+			desc:       "modified since",
+			header:     http.Header{"If-Modified-Since": {"Sun, 31 Dec 2023 00:00:00 GMT"}},
+			wantStatus: http.StatusOK,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			t.Parallel()
+
+			resp, body := send(t, test.header)
+			assert.Equal(t, test.wantStatus, resp.StatusCode)
+			assert.Equal(t, etag, resp.Header.Get("ETag"))
+
+			if test.wantStatus == http.StatusNotModified {
+				assert.Empty(t, body)
+			}
+		})
+	}
+}
+
+func TestWhoamiUDP(t *testing.T) {
+	t.Parallel()
+
+	server, err := NewWhoamiUDP()
+	require.NoError(t, err)
+
+	t.Cleanup(func() { _ = server.Close() })
+
+	conn, err := net.Dial("udp", server.Addr())
+	require.NoError(t, err)
+
+	defer func() { _ = conn.Close() }()
+
+	require.NoError(t, conn.SetDeadline(time.Now().Add(time.Second)))
+
+	_, err = conn.Write([]byte("hello"))
+	require.NoError(t, err)
+
+	buffer := make([]byte, 1024)
+	n, err := conn.Read(buffer)
+	require.NoError(t, err)
+
+	assert.Equal(t, "hello", string(buffer[:n]))
+}
@@ -0,0 +1,59 @@
+// Package version reports the versions the playground is built with.
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// traefikModule is the path of the Traefik module the playground is built against.
+const traefikModule = "github.com/traefik/traefik/v3"
+
+// Version is the playground version, set at build time from the release tag.
+var Version = "dev" //nolint:gochecknoglobals // Set at build time.
+
+// TraefikVersion is the version of the Traefik module the playground is built against, read from the build info,
+// or "latest" when unknown.
+var TraefikVersion = traefikModuleVersion() //nolint:gochecknoglobals // Read-only.
+
+// Info holds the versions the playground is built with.
+type Info struct {
+	// Version is the playground version.
+	Version string `json:"version"`
+	// TraefikVersion is the version of the Traefik module running the experiments.
+	TraefikVersion string `json:"traefikVersion"`
+	// GoVersion is the version of Go the playground is built with.
+	GoVersion string `json:"goVersion"`
+}
+
+// Get returns the versions the playground is built with.
+func Get() Info {
+	return Info{
+		Version:        Version,
+		TraefikVersion: TraefikVersion,
+		GoVersion:      runtime.Version(),
+	}
+}
+
+// traefikModuleVersion returns the version of the Traefik module found in the build info, or "latest" if the
+// binary isn't built with it.
+func traefikModuleVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "latest"
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path != traefikModule {
+			continue
+		}
+
+		if dep.Replace != nil {
+			return dep.Replace.Version
+		}
+
+		return dep.Version
+	}
+
+	return "latest"
+}
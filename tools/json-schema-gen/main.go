@@ -1,13 +1,20 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"os"
 	"path"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"runtime/debug"
+	"strings"
 
 	"github.com/danielgtaylor/huma/v2"
 	"github.com/ettle/strcase"
 	"github.com/rs/zerolog/log"
+	ptypes "github.com/traefik/paerser/types"
 	"github.com/traefik/traefik/v3/pkg/config/dynamic"
 	"gopkg.in/yaml.v3"
 	kyaml "sigs.k8s.io/yaml"
@@ -22,7 +29,41 @@ type Schema struct {
 	Definitions huma.Registry `yaml:"definitions"`
 }
 
+// traefikModule is the Traefik module the schema is generated from.
+const traefikModule = "github.com/traefik/traefik/v3"
+
+// versionRegexp matches the Traefik versions a schema can be generated for: a major or a minor version.
+var versionRegexp = regexp.MustCompile(`^3(\.\d+)?$`) //nolint:gochecknoglobals // Compiled once.
+
 func main() {
+	version := flag.String("version", "3", "Traefik version the schema is generated for, either a major (3) or a minor (3.4) version")
+	output := flag.String("output", "", "Directory the schema is written into, as traefik-v<version>.schema.json. Written on stdout if empty")
+	flag.Parse()
+
+	if err := checkVersion(*version, vendoredTraefikVersion()); err != nil {
+		log.Fatal().Err(err).Msg("Invalid version")
+	}
+
+	jsonSchema, err := generateSchema(*version)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Unable to generate JSON schema")
+	}
+
+	if *output == "" {
+		if _, err = os.Stdout.Write(jsonSchema); err != nil {
+			log.Fatal().Err(err).Msg("Unable to write JSON schema on stdout")
+		}
+
+		return
+	}
+
+	if err = os.WriteFile(filepath.Join(*output, schemaFilename(*version)), jsonSchema, 0o644); err != nil { //nolint:gosec // The schema is public.
+		log.Fatal().Err(err).Msg("Unable to write JSON schema")
+	}
+}
+
+// generateSchema generates the JSON schema of the dynamic configuration for the given Traefik version.
+func generateSchema(version string) ([]byte, error) {
 	registry := huma.NewMapRegistry("#/definitions/", func(t reflect.Type, hint string) string {
 		name := huma.DefaultSchemaNamer(t, hint)
 		if t.Kind() == reflect.Ptr {
@@ -38,8 +79,10 @@ func main() {
 		return prefix + name
 	})
 
+	registry.RegisterTypeAlias(reflect.TypeOf(ptypes.Duration(0)), reflect.TypeOf(duration{}))
+
 	schema := Schema{
-		ID:          "https://traefik-playground.ozouf.fr/traefik-v3.schema.json",
+		ID:          "https://traefik-playground.ozouf.fr/" + schemaFilename(version),
 		SchemaURL:   "http://json-schema.org/draft-07/schema#",
 		Definitions: registry,
 		Schema:      huma.SchemaFromType(registry, reflect.TypeOf(dynamic.Configuration{})),
@@ -50,20 +93,70 @@ func main() {
 		cleanSchema(definition)
 	}
 
-	schema.Title = "Traefik v3 Dynamic Configuration"
+	schema.Title = "Traefik v" + version + " Dynamic Configuration"
 
 	yamlSchema, err := yaml.Marshal(schema)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Unable to marshal JSON schema to YAML")
+		return nil, fmt.Errorf("marshaling JSON schema to YAML: %w", err)
 	}
 
 	jsonSchema, err := kyaml.YAMLToJSONStrict(yamlSchema)
 	if err != nil {
-		log.Fatal().Err(err).Msg("Unable to convert YAML to JSON")
+		return nil, fmt.Errorf("converting YAML to JSON: %w", err)
+	}
+
+	return jsonSchema, nil
+}
+
+// schemaFilename returns the filename of the schema of the given Traefik version.
+func schemaFilename(version string) string {
+	return "traefik-v" + version + ".schema.json"
+}
+
+// checkVersion makes sure the given version is the vendored Traefik version, or one of its prefixes, as the schema
+// can only reflect the vendored version. The vendored version is not checked when unknown.
+func checkVersion(version, vendored string) error {
+	if !versionRegexp.MatchString(version) {
+		return fmt.Errorf("unsupported version %q", version)
+	}
+
+	if vendored == "" {
+		return nil
 	}
 
-	if _, err = os.Stdout.Write(jsonSchema); err != nil {
-		log.Fatal().Err(err).Msg("Unable to write JSON schema on stdout")
+	if vendored != "v"+version && !strings.HasPrefix(vendored, "v"+version+".") {
+		return fmt.Errorf("version %q doesn't match the vendored Traefik version %s", version, vendored)
+	}
+
+	return nil
+}
+
+// vendoredTraefikVersion returns the version of the Traefik module the tool is built with, if known.
+func vendoredTraefikVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return ""
+	}
+
+	for _, dep := range info.Deps {
+		if dep.Path == traefikModule {
+			return dep.Version
+		}
+	}
+
+	return ""
+}
+
+// duration describes ptypes.Duration, which is either a Go duration string or a number of seconds.
+type duration struct{}
+
+// Schema implements huma.SchemaProvider.
+func (duration) Schema(huma.Registry) *huma.Schema {
+	return &huma.Schema{
+		OneOf: []*huma.Schema{
+			{Type: huma.TypeString},
+			{Type: huma.TypeInteger},
+		},
 	}
 }
 
@@ -73,6 +166,9 @@ func cleanSchema(schema *huma.Schema) {
 		schema.Format = ""
 	}
 
+	// Traefik applies defaults to missing fields, none of them are required.
+	schema.Required = nil
+
 	for _, subSchema := range schema.AllOf {
 		cleanSchema(subSchema)
 	}
@@ -89,6 +185,10 @@ func cleanSchema(schema *huma.Schema) {
 	if schema.Not != nil {
 		cleanSchema(schema.Not)
 	}
+	// Maps, such as the TCP and UDP routers and services, describe their values with additional properties.
+	if additionalProperties, ok := schema.AdditionalProperties.(*huma.Schema); ok {
+		cleanSchema(additionalProperties)
+	}
 
 	for _, property := range schema.Properties {
 		cleanSchema(property)
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateSchema(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		version string
+		wantID  string
+	}{
+		{
+			version: "3",
+			wantID:  "https://traefik-playground.ozouf.fr/traefik-v3.schema.json",
+		},
+		{
+			version: "3.4",
+			wantID:  "https://traefik-playground.ozouf.fr/traefik-v3.4.schema.json",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.version, func(t *testing.T) {
+			t.Parallel()
+
+			jsonSchema, err := generateSchema(test.version)
+			require.NoError(t, err)
+
+			var schema struct {
+				ID    string `json:"$id"`
+				Title string `json:"title"`
+			}
+			require.NoError(t, json.Unmarshal(jsonSchema, &schema))
+
+			assert.Equal(t, test.wantID, schema.ID)
+			assert.Equal(t, "Traefik v"+test.version+" Dynamic Configuration", schema.Title)
+		})
+	}
+}
+
+func TestCheckVersion(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		version  string
+		vendored string
+		wantErr  string
+	}{
+		{version: "3", vendored: "v3.4.4"},
+		{version: "3.4", vendored: "v3.4.4"},
+		{version: "3.4", vendored: ""},
+		{version: "3.5", vendored: "v3.4.4", wantErr: `version "3.5" doesn't match the vendored Traefik version v3.4.4`},
+		{version: "3.4.4", vendored: "v3.4.4", wantErr: `unsupported version "3.4.4"`},
+		{version: "2", vendored: "v3.4.4", wantErr: `unsupported version "2"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.version+"/"+test.vendored, func(t *testing.T) {
+			t.Parallel()
+
+			err := checkVersion(test.version, test.vendored)
+			if test.wantErr != "" {
+				require.EqualError(t, err, test.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestGenerateSchema_tcpAndUDP(t *testing.T) {
+	t.Parallel()
+
+	jsonSchema, err := generateSchema("3")
+	require.NoError(t, err)
+
+	// Integer formats are removed everywhere, including from map values.
+	assert.NotContains(t, string(jsonSchema), `"format"`)
+
+	var schema struct {
+		Properties  map[string]schemaRef        `json:"properties"`
+		Definitions map[string]schemaDefinition `json:"definitions"`
+	}
+	require.NoError(t, json.Unmarshal(jsonSchema, &schema))
+
+	tests := []struct {
+		section        string
+		wantProperties []string
+	}{
+		{
+			section:        "tcp",
+			wantProperties: []string{"entryPoints", "middlewares", "priority", "rule", "ruleSyntax", "service", "tls"},
+		},
+		{
+			section:        "udp",
+			wantProperties: []string{"entryPoints", "service"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.section, func(t *testing.T) {
+			t.Parallel()
+
+			configuration := schema.Definitions[definitionName(t, schema.Properties[test.section].Ref)]
+			require.Contains(t, configuration.Properties, "routers")
+			require.Contains(t, configuration.Properties, "services")
+
+			routers := configuration.Properties["routers"]
+			assert.Equal(t, "object", routers.Type)
+			require.NotNil(t, routers.AdditionalProperties)
+
+			router, ok := schema.Definitions[definitionName(t, routers.AdditionalProperties.Ref)]
+			require.True(t, ok)
+			assert.Equal(t, "object", router.Type)
+			assert.False(t, router.AdditionalProperties)
+
+			var properties []string
+			for name := range router.Properties {
+				properties = append(properties, name)
+			}
+			assert.ElementsMatch(t, test.wantProperties, properties)
+		})
+	}
+}
+
+type schemaRef struct {
+	Ref string `json:"$ref"`
+}
+
+type schemaDefinition struct {
+	Type                 string `json:"type"`
+	AdditionalProperties bool   `json:"additionalProperties"`
+	Properties           map[string]struct {
+		Type                 string     `json:"type"`
+		AdditionalProperties *schemaRef `json:"additionalProperties"`
+	} `json:"properties"`
+}
+
+func definitionName(t *testing.T, ref string) string {
+	t.Helper()
+
+	name, ok := strings.CutPrefix(ref, "#/definitions/")
+	require.True(t, ok, "unexpected reference %q", ref)
+
+	return name
+}